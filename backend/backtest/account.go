@@ -0,0 +1,575 @@
+package backtest
+
+import (
+	"backend/models"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// simulatedUserId 模擬帳戶的使用者 ID（不會寫入資料庫）
+const simulatedUserId int64 = 0
+
+// TradeAction 模擬成交的類型
+type TradeAction string
+
+const (
+	TradeActionBuy         TradeAction = "BUY"         // 現貨買入
+	TradeActionSell        TradeAction = "SELL"        // 現貨賣出
+	TradeActionOpen        TradeAction = "OPEN"        // 槓桿開倉或加倉
+	TradeActionClose       TradeAction = "CLOSE"       // 槓桿減倉或平倉
+	TradeActionLiquidation TradeAction = "LIQUIDATION" // 槓桿爆倉
+)
+
+// Trade 模擬帳戶的一筆成交
+type Trade struct {
+	Time        time.Time   `json:"time"`
+	OrderId     int64       `json:"orderId,omitempty"`
+	Action      TradeAction `json:"action"`
+	Side        string      `json:"side"` // 現貨為 BUY/SELL，槓桿為倉位方向 LONG/SHORT
+	Price       float64     `json:"price"`
+	Quantity    float64     `json:"quantity"`
+	Amount      float64     `json:"amount"`      // 成交金額（USDT）
+	RealizedPnL float64     `json:"realizedPnl"` // 已實現盈虧（現貨賣出以平均成本計算）
+}
+
+// IsClosing 是否為實現盈虧的成交（計算勝率使用）
+func (t Trade) IsClosing() bool {
+	return t.Action == TradeActionSell || t.Action == TradeActionClose || t.Action == TradeActionLiquidation
+}
+
+// accountEvent 帳戶的成交（order）或倉位變動（position）
+type accountEvent struct {
+	order    *models.Order
+	position *models.LeveragePosition
+}
+
+// Account 隔離的模擬帳戶：單一交易對的現貨錢包、槓桿持倉與掛單
+// 錢包、倉位與訂單使用與實盤相同的 models 結構與計算（保證金、爆倉價格、結算），但只存在記憶體中
+type Account struct {
+	symbol       string
+	base         string
+	quote        string
+	marginMode   models.MarginMode
+	positionMode models.PositionMode
+
+	quoteWallet *models.Wallet
+	baseWallet  *models.Wallet
+	baseCost    float64 // 現貨持倉的總成本（USDT），計算賣出的已實現盈虧
+
+	positions []*models.LeveragePosition // 持倉中的倉位
+	orders    []*models.Order            // 掛單中的限價單
+	trades    []Trade
+	events    []accountEvent // 尚未通知策略的成交與倉位變動
+
+	price          float64
+	now            time.Time
+	nextOrderId    int64
+	nextPositionId int64
+	liquidations   int
+
+	recentPrices    []float64            // 最近的模擬成交價格（計算標記價格）
+	insuranceFund   models.InsuranceFund // 模擬的保險基金：爆倉與資金費用的對手帳戶
+	fundingRate     float64
+	fundingInterval time.Duration
+	nextFundingTime time.Time
+}
+
+// newAccount 建立模擬帳戶
+func newAccount(config Config) (*Account, error) {
+	base, quote, err := models.ParseSymbol(config.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Account{
+		symbol:       config.Symbol,
+		base:         base,
+		quote:        quote,
+		marginMode:   config.MarginMode,
+		positionMode: config.PositionMode,
+		quoteWallet:  &models.Wallet{Symbol: quote, Balance: config.InitialBalance},
+		baseWallet:   &models.Wallet{Symbol: base},
+
+		fundingRate:     config.FundingRate,
+		fundingInterval: config.FundingInterval,
+	}, nil
+}
+
+// Symbol 交易對
+func (a *Account) Symbol() string {
+	return a.symbol
+}
+
+// Price 目前的模擬價格
+func (a *Account) Price() float64 {
+	return a.price
+}
+
+// Time 目前的模擬時間
+func (a *Account) Time() time.Time {
+	return a.now
+}
+
+// Balance 錢包的可用餘額（quote 或 base 幣種）
+func (a *Account) Balance(asset string) float64 {
+	switch asset {
+	case a.quote:
+		return a.quoteWallet.GetAvailableBalance()
+	case a.base:
+		return a.baseWallet.GetAvailableBalance()
+	}
+	return 0
+}
+
+// Position 持倉中的倉位（沒有時為 nil）
+func (a *Account) Position(side models.PositionSide) *models.LeveragePosition {
+	for _, position := range a.positions {
+		if position.Side == side {
+			return position
+		}
+	}
+	return nil
+}
+
+// Positions 所有持倉中的倉位
+func (a *Account) Positions() []*models.LeveragePosition {
+	return append([]*models.LeveragePosition(nil), a.positions...)
+}
+
+// OpenOrders 所有掛單中的限價單
+func (a *Account) OpenOrders() []*models.Order {
+	return append([]*models.Order(nil), a.orders...)
+}
+
+// Trades 所有成交記錄
+func (a *Account) Trades() []Trade {
+	return a.trades
+}
+
+// Equity 以目前價格計算的總權益 = USDT 餘額 + 現貨市值 + 倉位保證金 + 未實現盈虧
+func (a *Account) Equity() float64 {
+	equity := a.quoteWallet.Balance + a.baseWallet.Balance*a.price
+	for _, position := range a.positions {
+		equity += position.Margin + position.CalculateUnrealizedPnL(a.price)
+	}
+	return equity
+}
+
+// MarketOrder 現貨市價單：買入時 quantity 為花費的 USDT，賣出時為 base 幣數量
+func (a *Account) MarketOrder(side models.OrderSide, quantity float64) (*models.Order, error) {
+	if quantity <= 0 {
+		return nil, errors.New("quantity must be positive")
+	}
+	if side != models.OrderSideBuy && side != models.OrderSideSell {
+		return nil, errors.New("side must be BUY or SELL")
+	}
+
+	order := a.newOrder(models.OrderTypeMarket, side, quantity, 0)
+	err := a.atomic(func() error {
+		if side == models.OrderSideBuy {
+			return a.executeBuy(order, quantity, a.price)
+		}
+		return a.executeSell(order, quantity, a.price)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// LimitOrder 現貨限價單：quantity 為 base 幣數量，價格觸及限價時以限價成交（與實盤相同，掛單時不凍結資金）
+func (a *Account) LimitOrder(side models.OrderSide, quantity float64, limitPrice float64) (*models.Order, error) {
+	if quantity <= 0 {
+		return nil, errors.New("quantity must be positive")
+	}
+	if limitPrice <= 0 {
+		return nil, errors.New("limit price must be positive")
+	}
+	if side != models.OrderSideBuy && side != models.OrderSideSell {
+		return nil, errors.New("side must be BUY or SELL")
+	}
+
+	order := a.newOrder(models.OrderTypeLimit, side, quantity, limitPrice)
+	a.orders = append(a.orders, order)
+	return order, nil
+}
+
+// OpenPosition 槓桿市價單：依持倉模式減倉、反手、開倉或加倉
+func (a *Account) OpenPosition(side models.PositionSide, leverage int, quantity float64, reduceOnly bool) error {
+	if quantity <= 0 {
+		return errors.New("quantity must be positive")
+	}
+	if leverage < 1 || leverage > 10 {
+		return errors.New("leverage must be between 1 and 10")
+	}
+	if reduceOnly {
+		if err := a.checkReduceOnly(side, quantity); err != nil {
+			return err
+		}
+	}
+
+	order := a.newLeverageOrder(models.OrderTypeMarket, side, leverage, quantity, 0, reduceOnly)
+	return a.atomic(func() error { return a.fillLeverage(order, a.price, 0) })
+}
+
+// LeverageLimitOrder 槓桿限價單：掛單時凍結保證金，價格觸及限價時以限價成交
+func (a *Account) LeverageLimitOrder(side models.PositionSide, leverage int, quantity float64, limitPrice float64, reduceOnly bool) (*models.Order, error) {
+	if quantity <= 0 {
+		return nil, errors.New("quantity must be positive")
+	}
+	if leverage < 1 || leverage > 100 {
+		return nil, errors.New("leverage must be between 1 and 100")
+	}
+	if limitPrice <= 0 {
+		return nil, errors.New("limit price must be positive")
+	}
+
+	order := a.newLeverageOrder(models.OrderTypeLimit, side, leverage, quantity, limitPrice, reduceOnly)
+	if reduceOnly {
+		if err := a.checkReduceOnly(side, quantity); err != nil {
+			return nil, err
+		}
+	} else if err := a.quoteWallet.LockMargin(order.RequiredMargin()); err != nil {
+		return nil, err
+	}
+
+	a.orders = append(a.orders, order)
+	return order, nil
+}
+
+// ClosePosition 平倉：quantity 為 0 或不小於持倉數量時全部平倉
+func (a *Account) ClosePosition(side models.PositionSide, quantity float64) error {
+	if quantity < 0 {
+		return errors.New("quantity must not be negative")
+	}
+	position := a.Position(side)
+	if position == nil {
+		return errors.New("position not found")
+	}
+	return a.atomic(func() error {
+		_, _, err := models.ReducePosition(&ledger{account: a}, position, simulatedUserId, quantity, a.price)
+		return err
+	})
+}
+
+// CancelOrder 取消掛單，槓桿限價單同時解除凍結的保證金
+func (a *Account) CancelOrder(orderId int64) error {
+	for _, order := range a.orders {
+		if order.Id != orderId {
+			continue
+		}
+		if err := a.quoteWallet.UnlockMargin(order.RequiredMargin()); err != nil {
+			return err
+		}
+		a.finish(order, models.OrderStatusCanceled)
+		a.removeOrder(orderId)
+		return nil
+	}
+	return errors.New("order not found")
+}
+
+// update 推進到新的價格：撮合掛單、結算資金費用並以標記價格檢查爆倉
+func (a *Account) update(price float64, now time.Time) {
+	a.price = price
+	a.now = now
+	if len(a.recentPrices) == models.MarkPriceSampleSize {
+		a.recentPrices = a.recentPrices[1:]
+	}
+	a.recentPrices = append(a.recentPrices, price)
+
+	a.matchOrders()
+	a.settleFunding()
+	a.checkLiquidations()
+}
+
+// markPrice 標記價格：與實盤相同，為最近成交價格的中位數
+func (a *Account) markPrice() float64 {
+	return models.CalculateMarkPrice(append([]float64(nil), a.recentPrices...))
+}
+
+// settleFunding 每個結算週期以標記價格向所有持倉收付資金費用（與實盤 fixed 模式相同，費率為 0 時不收付）
+func (a *Account) settleFunding() {
+	if a.fundingRate == 0 || a.fundingInterval <= 0 {
+		return
+	}
+	if a.nextFundingTime.IsZero() {
+		a.nextFundingTime = a.now.UTC().Truncate(a.fundingInterval).Add(a.fundingInterval)
+		return
+	}
+
+	for !a.now.Before(a.nextFundingTime) {
+		fundingTime := a.nextFundingTime
+		a.nextFundingTime = fundingTime.Add(a.fundingInterval)
+
+		markPrice := a.markPrice()
+		for _, position := range a.Positions() {
+			a.atomic(func() error {
+				_, err := models.ApplyFundingPayment(&ledger{account: a}, position, a.fundingRate, markPrice, fundingTime)
+				return err
+			})
+		}
+	}
+}
+
+// matchOrders 撮合掛單（與撮合器相同：買單在價格 <= 限價、賣單在價格 >= 限價時以限價成交）
+func (a *Account) matchOrders() {
+	for _, order := range a.OpenOrders() {
+		if order.Side == models.OrderSideBuy && a.price > order.LimitPrice {
+			continue
+		}
+		if order.Side == models.OrderSideSell && a.price < order.LimitPrice {
+			continue
+		}
+
+		a.removeOrder(order.Id)
+		err := a.atomic(func() error {
+			if order.IsLeverageOrder {
+				return a.fillLeverage(order, order.LimitPrice, order.RequiredMargin())
+			}
+			if order.Side == models.OrderSideBuy {
+				return a.executeBuy(order, order.Quantity*order.LimitPrice, order.LimitPrice)
+			}
+			return a.executeSell(order, order.Quantity, order.LimitPrice)
+		})
+		if err != nil {
+			// 與實盤相同：成交失敗時訂單標記為 FAILED，凍結的保證金解除
+			a.quoteWallet.UnlockMargin(order.RequiredMargin())
+			a.finish(order, models.OrderStatusFailed)
+			order.ErrorMsg = err.Error()
+		}
+	}
+}
+
+// checkLiquidations 以標記價格檢查爆倉：逐倉以爆倉價格檢查，全倉以帳戶總權益檢查
+// 與實盤相同，剩餘保證金轉入保險基金，穿倉虧損由保險基金承擔
+func (a *Account) checkLiquidations() {
+	markPrice := a.markPrice()
+	l := &ledger{account: a}
+
+	var cross []*models.LeveragePosition
+	for _, position := range a.Positions() {
+		if position.IsCross() {
+			cross = append(cross, position)
+			continue
+		}
+		if !position.IsLiquidated(markPrice) {
+			continue
+		}
+		err := a.atomic(func() error {
+			_, err := models.LiquidateIsolatedPosition(l, position, markPrice)
+			return err
+		})
+		if err == nil {
+			a.liquidations++
+		}
+	}
+	if len(cross) == 0 {
+		return
+	}
+
+	var result *models.LiquidationResult
+	err := a.atomic(func() error {
+		var err error
+		result, err = models.LiquidateCrossAccount(l, simulatedUserId, cross, map[string]float64{a.symbol: markPrice})
+		return err
+	})
+	if err == nil && result != nil {
+		a.liquidations++
+	}
+}
+
+// executeBuy 現貨買入：以 usdtAmount 在 price 買入 base 幣
+func (a *Account) executeBuy(order *models.Order, usdtAmount float64, price float64) error {
+	if a.quoteWallet.GetAvailableBalance() < usdtAmount {
+		return errors.New("insufficient USDT balance")
+	}
+
+	quantity := usdtAmount / price
+	a.quoteWallet.Balance -= usdtAmount
+	a.baseWallet.Balance += quantity
+	a.baseCost += usdtAmount
+
+	a.complete(order, price, usdtAmount)
+	a.record(Trade{OrderId: order.Id, Action: TradeActionBuy, Side: string(models.OrderSideBuy), Price: price,
+		Quantity: quantity, Amount: usdtAmount})
+	return nil
+}
+
+// executeSell 現貨賣出：在 price 賣出 quantity 個 base 幣，以平均成本計算已實現盈虧
+func (a *Account) executeSell(order *models.Order, quantity float64, price float64) error {
+	if a.baseWallet.GetAvailableBalance() < quantity {
+		return fmt.Errorf("insufficient %s balance", a.base)
+	}
+
+	amount := quantity * price
+	cost := a.baseCost * quantity / a.baseWallet.Balance
+	a.baseWallet.Balance -= quantity
+	a.quoteWallet.Balance += amount
+	a.baseCost -= cost
+
+	a.complete(order, price, amount)
+	a.record(Trade{OrderId: order.Id, Action: TradeActionSell, Side: string(models.OrderSideSell), Price: price,
+		Quantity: quantity, Amount: amount, RealizedPnL: amount - cost})
+	return nil
+}
+
+// fillLeverage 槓桿訂單成交（與實盤相同使用 models.FillLeverageOrder，需在 atomic 中呼叫）
+// 單向持倉或只減倉訂單先減少反方向持倉，剩餘數量才開新倉或加倉
+func (a *Account) fillLeverage(order *models.Order, price float64, lockedMargin float64) error {
+	fill, err := models.FillLeverageOrder(&ledger{account: a, orderId: order.Id}, simulatedUserId, order, a.symbol,
+		models.PositionSide(order.PositionSideStr), order.Leverage, price, order.Quantity, order.ReduceOnly, lockedMargin)
+	if err != nil {
+		return err
+	}
+
+	a.complete(order, price, (fill.ReduceQuantity+fill.OpenQuantity)*price)
+	return nil
+}
+
+// checkReduceOnly 只減倉訂單的數量不可超過反方向持倉
+func (a *Account) checkReduceOnly(side models.PositionSide, quantity float64) error {
+	position := a.Position(side.Opposite())
+	if position == nil {
+		return errors.New("reduce-only order has no position to reduce")
+	}
+	if quantity > position.Quantity {
+		return errors.New("reduce-only order quantity exceeds position size")
+	}
+	return nil
+}
+
+// newOrder 建立模擬訂單
+func (a *Account) newOrder(orderType models.OrderType, side models.OrderSide, quantity float64, limitPrice float64) *models.Order {
+	a.nextOrderId++
+	return &models.Order{
+		Id:         a.nextOrderId,
+		Symbol:     a.symbol,
+		Type:       orderType,
+		Side:       side,
+		Quantity:   quantity,
+		LimitPrice: limitPrice,
+		Status:     models.OrderStatusPending,
+		CreatedAt:  a.now,
+		UpdatedAt:  a.now,
+	}
+}
+
+// newLeverageOrder 建立模擬槓桿訂單（LONG 為買入，SHORT 為賣出）
+func (a *Account) newLeverageOrder(orderType models.OrderType, side models.PositionSide, leverage int, quantity float64, limitPrice float64, reduceOnly bool) *models.Order {
+	orderSide := models.OrderSideBuy
+	if side == models.PositionSideShort {
+		orderSide = models.OrderSideSell
+	}
+	order := a.newOrder(orderType, orderSide, quantity, limitPrice)
+	order.IsLeverageOrder = true
+	order.Leverage = leverage
+	order.PositionSideStr = string(side)
+	order.ReduceOnly = reduceOnly
+	return order
+}
+
+// complete 標記訂單成交並通知策略（與實盤相同，槓桿市價單只通知倉位變動）
+func (a *Account) complete(order *models.Order, price float64, totalAmount float64) {
+	a.finish(order, models.OrderStatusCompleted)
+	order.Price = price
+	order.TotalAmount = totalAmount
+	if !order.IsLeverageOrder || order.Type != models.OrderTypeMarket {
+		a.events = append(a.events, accountEvent{order: order})
+	}
+}
+
+// positionUpdated 通知策略倉位變動（通知當下的倉位快照）
+func (a *Account) positionUpdated(position *models.LeveragePosition) {
+	snapshot := *position
+	a.events = append(a.events, accountEvent{position: &snapshot})
+}
+
+// takeEvents 取出尚未通知策略的事件
+func (a *Account) takeEvents() []accountEvent {
+	events := a.events
+	a.events = nil
+	return events
+}
+
+// finish 結束訂單（與實盤相同，只有待處理的訂單可以轉換狀態）
+func (a *Account) finish(order *models.Order, status models.OrderStatus) {
+	if models.CanTransition(order.Status, status) {
+		order.Status = status
+		order.UpdatedAt = a.now
+	}
+}
+
+// record 記錄成交
+func (a *Account) record(trade Trade) {
+	trade.Time = a.now
+	a.trades = append(a.trades, trade)
+}
+
+// accountState 帳戶狀態的快照，操作失敗時還原（對應實盤的資料庫交易回滾）
+type accountState struct {
+	quoteWallet    models.Wallet
+	baseWallet     models.Wallet
+	baseCost       float64
+	insuranceFund  models.InsuranceFund
+	positions      []*models.LeveragePosition
+	positionValues []models.LeveragePosition
+	trades         int
+	events         int
+	nextPositionId int64
+}
+
+// atomic 執行操作，失敗時還原錢包、倉位、成交記錄與事件
+func (a *Account) atomic(operation func() error) error {
+	state := accountState{
+		quoteWallet:    *a.quoteWallet,
+		baseWallet:     *a.baseWallet,
+		baseCost:       a.baseCost,
+		insuranceFund:  a.insuranceFund,
+		positions:      a.Positions(),
+		trades:         len(a.trades),
+		events:         len(a.events),
+		nextPositionId: a.nextPositionId,
+	}
+	for _, position := range a.positions {
+		state.positionValues = append(state.positionValues, *position)
+	}
+
+	err := operation()
+	if err != nil {
+		*a.quoteWallet = state.quoteWallet
+		*a.baseWallet = state.baseWallet
+		a.baseCost = state.baseCost
+		a.insuranceFund = state.insuranceFund
+		for i, position := range state.positions {
+			*position = state.positionValues[i]
+		}
+		a.positions = state.positions
+		a.trades = a.trades[:state.trades]
+		a.events = a.events[:state.events]
+		a.nextPositionId = state.nextPositionId
+	}
+	return err
+}
+
+// closed 從持倉列表移除已結算的倉位
+func (a *Account) closed(position *models.LeveragePosition) {
+	closedAt := a.now
+	position.ClosedAt = &closedAt
+	for i, open := range a.positions {
+		if open == position {
+			a.positions = append(a.positions[:i], a.positions[i+1:]...)
+			return
+		}
+	}
+}
+
+// removeOrder 從掛單列表移除訂單
+func (a *Account) removeOrder(orderId int64) {
+	for i, order := range a.orders {
+		if order.Id == orderId {
+			a.orders = append(a.orders[:i], a.orders[i+1:]...)
+			return
+		}
+	}
+}
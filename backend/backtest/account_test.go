@@ -0,0 +1,196 @@
+package backtest
+
+import (
+	"backend/models"
+	"math"
+	"testing"
+	"time"
+)
+
+func newTestAccount(t *testing.T, positionMode models.PositionMode) *Account {
+	t.Helper()
+	config := Config{Symbol: "BTCUSDT", Interval: "1h", InitialBalance: 10000, PositionMode: positionMode}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	account, err := newAccount(config)
+	if err != nil {
+		t.Fatalf("newAccount() error = %v", err)
+	}
+	account.update(100, time.Unix(0, 0))
+	return account
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestAccountSpotRealizedPnL(t *testing.T) {
+	account := newTestAccount(t, models.PositionModeHedge)
+
+	if _, err := account.MarketOrder(models.OrderSideBuy, 1000); err != nil {
+		t.Fatalf("buy error = %v", err)
+	}
+	if !approxEqual(account.Balance("BTC"), 10) {
+		t.Fatalf("BTC balance = %v, want 10", account.Balance("BTC"))
+	}
+
+	account.update(120, time.Unix(60, 0))
+	if _, err := account.MarketOrder(models.OrderSideSell, 5); err != nil {
+		t.Fatalf("sell error = %v", err)
+	}
+
+	trades := account.Trades()
+	if last := trades[len(trades)-1]; !approxEqual(last.RealizedPnL, 100) {
+		t.Errorf("realized PnL = %v, want 100", last.RealizedPnL)
+	}
+	if !approxEqual(account.Equity(), 10200) {
+		t.Errorf("equity = %v, want 10200", account.Equity())
+	}
+
+	if _, err := account.MarketOrder(models.OrderSideSell, 6); err == nil {
+		t.Error("selling more than the balance should fail")
+	}
+}
+
+func TestAccountIsolatedLiquidation(t *testing.T) {
+	account := newTestAccount(t, models.PositionModeHedge)
+
+	if err := account.OpenPosition(models.PositionSideLong, 10, 10, false); err != nil {
+		t.Fatalf("open error = %v", err)
+	}
+	position := account.Position(models.PositionSideLong)
+	if position == nil || !approxEqual(position.Margin, 100) {
+		t.Fatalf("position = %+v, want margin 100", position)
+	}
+	if !approxEqual(account.Balance("USDT"), 9900) {
+		t.Fatalf("USDT balance = %v, want 9900", account.Balance("USDT"))
+	}
+
+	// 與實盤相同以標記價格（最近成交的中位數）判斷：單筆跌破爆倉價格的成交不會觸發爆倉
+	price := position.LiquidationPrice - 1
+	account.update(price, time.Unix(60, 0))
+	if account.Position(models.PositionSideLong) == nil {
+		t.Fatal("a single trade below the liquidation price should not move the mark price")
+	}
+
+	// 標記價格跌破爆倉價格：保證金全部虧損，穿倉虧損由保險基金承擔
+	surplus := position.LiquidationSurplus(price)
+	account.update(price, time.Unix(120, 0))
+	if account.Position(models.PositionSideLong) != nil {
+		t.Fatal("position should be liquidated")
+	}
+	if account.liquidations != 1 {
+		t.Errorf("liquidations = %d, want 1", account.liquidations)
+	}
+	if !approxEqual(account.Equity(), 9900) {
+		t.Errorf("equity = %v, want 9900", account.Equity())
+	}
+	if fund := account.insuranceFund; surplus >= 0 || !approxEqual(fund.Balance-fund.Deficit, surplus) {
+		t.Errorf("insurance fund = %+v, want bankruptcy loss %v recorded", fund, surplus)
+	}
+	trades := account.Trades()
+	if last := trades[len(trades)-1]; last.Action != TradeActionLiquidation || !approxEqual(last.RealizedPnL, -100) {
+		t.Errorf("last trade = %+v, want liquidation with -100 PnL", last)
+	}
+}
+
+func TestAccountOneWayReversal(t *testing.T) {
+	account := newTestAccount(t, models.PositionModeOneWay)
+
+	if err := account.OpenPosition(models.PositionSideLong, 5, 10, false); err != nil {
+		t.Fatalf("open long error = %v", err)
+	}
+	account.update(110, time.Unix(60, 0))
+
+	// 反向 15：先平掉 10 的多頭（獲利 100），剩餘 5 開空
+	if err := account.OpenPosition(models.PositionSideShort, 5, 15, false); err != nil {
+		t.Fatalf("open short error = %v", err)
+	}
+	if account.Position(models.PositionSideLong) != nil {
+		t.Error("long position should be closed")
+	}
+	short := account.Position(models.PositionSideShort)
+	if short == nil || !approxEqual(short.Quantity, 5) || !approxEqual(short.Margin, 110) {
+		t.Fatalf("short position = %+v, want quantity 5 and margin 110", short)
+	}
+	if !approxEqual(account.Equity(), 10100) {
+		t.Errorf("equity = %v, want 10100", account.Equity())
+	}
+}
+
+func TestAccountRollsBackFailedFill(t *testing.T) {
+	account := newTestAccount(t, models.PositionModeOneWay)
+
+	if err := account.OpenPosition(models.PositionSideLong, 1, 50, false); err != nil {
+		t.Fatalf("open long error = %v", err)
+	}
+
+	// 反手需要的保證金超過餘額：減倉也要一起還原
+	if err := account.OpenPosition(models.PositionSideShort, 1, 200, false); err == nil {
+		t.Fatal("reversal without enough balance should fail")
+	}
+	long := account.Position(models.PositionSideLong)
+	if long == nil || !approxEqual(long.Quantity, 50) {
+		t.Fatalf("long position = %+v, want quantity 50 restored", long)
+	}
+	if !approxEqual(account.Balance("USDT"), 5000) || len(account.Trades()) != 1 {
+		t.Errorf("USDT balance = %v, trades = %d, want 5000 and 1", account.Balance("USDT"), len(account.Trades()))
+	}
+}
+
+func TestAccountLeverageLimitOrder(t *testing.T) {
+	account := newTestAccount(t, models.PositionModeHedge)
+
+	order, err := account.LeverageLimitOrder(models.PositionSideLong, 10, 10, 90, false)
+	if err != nil {
+		t.Fatalf("limit order error = %v", err)
+	}
+	if !approxEqual(account.Balance("USDT"), 9910) {
+		t.Fatalf("available USDT = %v, want 9910 with 90 locked", account.Balance("USDT"))
+	}
+
+	account.update(95, time.Unix(60, 0))
+	if len(account.OpenOrders()) != 1 {
+		t.Fatal("order should still be pending above the limit price")
+	}
+
+	account.update(89, time.Unix(120, 0))
+	position := account.Position(models.PositionSideLong)
+	if order.Status != models.OrderStatusCompleted || position == nil || position.EntryPrice != 90 {
+		t.Fatalf("order status = %s, position = %+v, want filled at 90", order.Status, position)
+	}
+	if !approxEqual(account.Balance("USDT"), 9910) {
+		t.Errorf("available USDT = %v, want 9910", account.Balance("USDT"))
+	}
+}
+
+func TestAccountFunding(t *testing.T) {
+	config := Config{Symbol: "BTCUSDT", Interval: "1h", InitialBalance: 10000, FundingRate: 0.001, FundingInterval: time.Hour}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	account, err := newAccount(config)
+	if err != nil {
+		t.Fatalf("newAccount() error = %v", err)
+	}
+	account.update(100, time.Unix(0, 0))
+
+	if err = account.OpenPosition(models.PositionSideLong, 10, 10, false); err != nil {
+		t.Fatalf("open error = %v", err)
+	}
+	account.update(100, time.Unix(1800, 0))
+	if position := account.Position(models.PositionSideLong); !approxEqual(position.Margin, 100) {
+		t.Fatalf("margin = %v, want 100 before the funding time", position.Margin)
+	}
+
+	// 多頭支付 100 * 10 * 0.001 = 1，由逐倉保證金扣除並轉入保險基金
+	account.update(100, time.Unix(3600, 0))
+	position := account.Position(models.PositionSideLong)
+	if !approxEqual(position.Margin, 99) || !approxEqual(position.FundingFee, -1) {
+		t.Errorf("position = %+v, want margin 99 and funding fee -1", position)
+	}
+	if !approxEqual(account.insuranceFund.Balance, 1) {
+		t.Errorf("insurance fund balance = %v, want 1", account.insuranceFund.Balance)
+	}
+}
@@ -0,0 +1,79 @@
+package backtest
+
+import (
+	"backend/models"
+	"backend/strategy"
+	"sort"
+	"time"
+)
+
+// Candle 一根 K 線（與策略使用的 K 線相同）
+type Candle = strategy.Candle
+
+// RecordedTrade 一筆記錄的成交（逐筆重播時使用）
+type RecordedTrade struct {
+	Time     time.Time
+	Price    float64
+	Quantity float64
+}
+
+// pricePath K 線內的價格路徑：開盤 → 較近的極值 → 另一個極值 → 收盤
+// 收漲的 K 線假設先探底再衝高，收跌的 K 線假設先衝高再探底
+func pricePath(c Candle) []float64 {
+	if c.Close >= c.Open {
+		return []float64{c.Open, c.Low, c.High, c.Close}
+	}
+	return []float64{c.Open, c.High, c.Low, c.Close}
+}
+
+// TradePath 以記錄的成交重播時，每根 K 線內依時間排序的成交價格
+type TradePath map[int64][]float64
+
+// AggregateTrades 將記錄的成交彙整為 K 線，並保留每根 K 線內的逐筆價格
+func AggregateTrades(trades []RecordedTrade, interval time.Duration) ([]Candle, TradePath) {
+	sorted := make([]RecordedTrade, len(trades))
+	copy(sorted, trades)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	var candles []Candle
+	path := make(TradePath)
+	for _, trade := range sorted {
+		openTime := trade.Time.UTC().Truncate(interval)
+		if len(candles) == 0 || !candles[len(candles)-1].OpenTime.Equal(openTime) {
+			candles = append(candles, Candle{
+				OpenTime: openTime,
+				Open:     trade.Price,
+				High:     trade.Price,
+				Low:      trade.Price,
+			})
+		}
+
+		candle := &candles[len(candles)-1]
+		if trade.Price > candle.High {
+			candle.High = trade.Price
+		}
+		if trade.Price < candle.Low {
+			candle.Low = trade.Price
+		}
+		candle.Close = trade.Price
+		candle.Volume += trade.Quantity
+		path[openTime.Unix()] = append(path[openTime.Unix()], trade.Price)
+	}
+	return candles, path
+}
+
+// CandlesFromKlines 將儲存的 K 線轉換為回測使用的 K 線
+func CandlesFromKlines(klines []*models.Kline) []Candle {
+	candles := make([]Candle, 0, len(klines))
+	for _, kline := range klines {
+		candles = append(candles, Candle{
+			OpenTime: kline.OpenTime.UTC(),
+			Open:     kline.Open,
+			High:     kline.High,
+			Low:      kline.Low,
+			Close:    kline.Close,
+			Volume:   kline.Volume,
+		})
+	}
+	return candles
+}
@@ -0,0 +1,276 @@
+package backtest
+
+import (
+	"backend/models"
+	"backend/strategy"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxCandles 單次回測最多重播的 K 線數量
+	MaxCandles = 100000
+
+	binanceKlinesURL   = "https://api.binance.com/api/v3/klines"
+	binanceKlinesLimit = 1000
+)
+
+var binanceClient = &http.Client{Timeout: 30 * time.Second}
+
+// CountCandles [start, end) 之間應有的 K 線數量
+func CountCandles(start time.Time, end time.Time, interval time.Duration) int {
+	if !end.After(start) {
+		return 0
+	}
+	first := start.UTC().Truncate(interval)
+	if first.Before(start) {
+		first = first.Add(interval)
+	}
+	if !end.After(first) {
+		return 0
+	}
+	return int((end.Sub(first)-1)/interval) + 1
+}
+
+// LoadKlines 讀取儲存的 K 線，數量不足時從 Binance 補齊並儲存後再讀取
+func LoadKlines(symbol string, interval string, start time.Time, end time.Time) ([]Candle, error) {
+	duration, err := strategy.ParseInterval(interval)
+	if err != nil {
+		return nil, err
+	}
+
+	klines, err := models.GetKlines(symbol, interval, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stored klines: %v", err)
+	}
+	if len(klines) >= CountCandles(start, end, duration) {
+		return CandlesFromKlines(klines), nil
+	}
+
+	candles, err := FetchKlines(symbol, interval, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := make([]*models.Kline, 0, len(candles))
+	for _, candle := range candles {
+		stored = append(stored, &models.Kline{
+			Symbol:   symbol,
+			Interval: interval,
+			OpenTime: candle.OpenTime,
+			Open:     candle.Open,
+			High:     candle.High,
+			Low:      candle.Low,
+			Close:    candle.Close,
+			Volume:   candle.Volume,
+		})
+	}
+	saved, err := models.SaveKlines(stored)
+	if err != nil {
+		// 儲存失敗不影響這次回測
+		log.Printf("Failed to store %s %s klines: %v", symbol, interval, err)
+	} else {
+		log.Printf("Stored %d %s %s klines from Binance", saved, symbol, interval)
+	}
+	return candles, nil
+}
+
+// FetchKlines 從 Binance 分頁下載 [start, end) 之間的 K 線
+func FetchKlines(symbol string, interval string, start time.Time, end time.Time) ([]Candle, error) {
+	duration, err := strategy.ParseInterval(interval)
+	if err != nil {
+		return nil, err
+	}
+	if CountCandles(start, end, duration) > MaxCandles {
+		return nil, fmt.Errorf("too many candles: at most %d per backtest", MaxCandles)
+	}
+
+	var candles []Candle
+	from := start
+	for from.Before(end) {
+		url := fmt.Sprintf("%s?symbol=%s&interval=%s&startTime=%d&endTime=%d&limit=%d",
+			binanceKlinesURL, symbol, interval, from.UnixMilli(), end.UnixMilli()-1, binanceKlinesLimit)
+		page, err := fetchKlinePage(url)
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			break
+		}
+
+		candles = append(candles, page...)
+		from = page[len(page)-1].OpenTime.Add(duration)
+		if len(page) < binanceKlinesLimit {
+			break
+		}
+	}
+	return candles, nil
+}
+
+// fetchKlinePage 下載並解析一頁 K 線（Binance 格式：[[openTime, "open", "high", "low", "close", "volume", ...], ...]）
+func fetchKlinePage(url string) ([]Candle, error) {
+	resp, err := binanceClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Binance: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Binance response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("binance returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	var rawData [][]interface{}
+	if err = json.Unmarshal(body, &rawData); err != nil {
+		return nil, fmt.Errorf("failed to parse Binance klines: %v", err)
+	}
+
+	candles := make([]Candle, 0, len(rawData))
+	for _, k := range rawData {
+		if len(k) < 6 {
+			return nil, errors.New("failed to parse Binance klines: unexpected format")
+		}
+		openTime, _ := k[0].(float64)
+		candle := Candle{OpenTime: time.UnixMilli(int64(openTime)).UTC()}
+		fields := []*float64{&candle.Open, &candle.High, &candle.Low, &candle.Close, &candle.Volume}
+		for i, field := range fields {
+			value, _ := k[i+1].(string)
+			if *field, err = strconv.ParseFloat(value, 64); err != nil {
+				return nil, fmt.Errorf("failed to parse Binance klines: %v", err)
+			}
+		}
+		candles = append(candles, candle)
+	}
+	return candles, nil
+}
+
+// ReadCandlesCSV 讀取 K 線 CSV：openTime,open,high,low,close[,volume]（可有標題列）
+func ReadCandlesCSV(r io.Reader) ([]Candle, error) {
+	rows, err := readCSV(r, 5)
+	if err != nil {
+		return nil, err
+	}
+
+	candles := make([]Candle, 0, len(rows))
+	for i, row := range rows {
+		openTime, err := parseTime(row[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		values, err := parseFloats(row[1:])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+
+		candle := Candle{OpenTime: openTime, Open: values[0], High: values[1], Low: values[2], Close: values[3]}
+		if len(values) > 4 {
+			candle.Volume = values[4]
+		}
+		if candle.Low <= 0 {
+			return nil, fmt.Errorf("line %d: prices must be positive", i+1)
+		}
+		if candle.Low > candle.High || candle.Open < candle.Low || candle.Open > candle.High ||
+			candle.Close < candle.Low || candle.Close > candle.High {
+			return nil, fmt.Errorf("line %d: prices outside of high/low range", i+1)
+		}
+		candles = append(candles, candle)
+	}
+	return candles, nil
+}
+
+// ReadTradesCSV 讀取逐筆成交 CSV：time,price[,quantity]（可有標題列）
+func ReadTradesCSV(r io.Reader) ([]RecordedTrade, error) {
+	rows, err := readCSV(r, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	trades := make([]RecordedTrade, 0, len(rows))
+	for i, row := range rows {
+		tradeTime, err := parseTime(row[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		values, err := parseFloats(row[1:])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+
+		if values[0] <= 0 {
+			return nil, fmt.Errorf("line %d: price must be positive", i+1)
+		}
+		trade := RecordedTrade{Time: tradeTime, Price: values[0]}
+		if len(values) > 1 {
+			trade.Quantity = values[1]
+		}
+		trades = append(trades, trade)
+	}
+	return trades, nil
+}
+
+// readCSV 讀取 CSV 並略過標題列（第一欄不是時間時視為標題）
+func readCSV(r io.Reader, minColumns int) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) > 0 && len(rows[0]) > 0 {
+		if _, err = parseTime(rows[0][0]); err != nil {
+			rows = rows[1:]
+		}
+	}
+
+	for i, row := range rows {
+		if len(row) < minColumns {
+			return nil, fmt.Errorf("line %d: expected at least %d columns", i+1, minColumns)
+		}
+	}
+	return rows, nil
+}
+
+// parseTime 解析 Unix 時間（秒或毫秒）或 RFC3339 時間
+func parseTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if unix > 1e11 {
+			return time.UnixMilli(unix).UTC(), nil
+		}
+		return time.Unix(unix, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time: %s", value)
+	}
+	return t.UTC(), nil
+}
+
+// parseFloats 解析價格與數量欄位（不可為負數）
+func parseFloats(fields []string) ([]float64, error) {
+	values := make([]float64, 0, len(fields))
+	for _, field := range fields {
+		value, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number: %s", field)
+		}
+		if value < 0 {
+			return nil, fmt.Errorf("negative number: %s", field)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
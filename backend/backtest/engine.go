@@ -0,0 +1,168 @@
+package backtest
+
+import (
+	"backend/models"
+	"backend/strategy"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// defaultFundingInterval 資金費用的預設結算週期（與實盤資金費用服務的預設值相同）
+const defaultFundingInterval = 8 * time.Hour
+
+// 策略透過 Broker 操作模擬帳戶，與實盤使用相同的介面
+var _ strategy.Broker = (*Account)(nil)
+
+// Config 回測設定
+type Config struct {
+	Symbol         string              // 交易對：BTCUSDT, ETHUSDT, SOLUSDT
+	Interval       string              // K 線週期
+	InitialBalance float64             // 模擬帳戶的初始 USDT
+	MarginMode     models.MarginMode   // 槓桿保證金模式（預設逐倉）
+	PositionMode   models.PositionMode // 持倉模式（預設雙向持倉，與新使用者相同）
+	TradePath      TradePath           // 以記錄的成交重播時，每根 K 線內的逐筆價格（為空時以開高低收模擬）
+	Risk           strategy.RiskLimits // 策略的風險限制（與實盤相同）
+
+	FundingRate     float64       // 每個結算週期的資金費率（沒有指數價格，以固定費率模擬，為 0 時不收付）
+	FundingInterval time.Duration // 資金費用結算週期（預設 8 小時，與實盤相同）
+}
+
+// Validate 驗證回測設定並填入預設值
+func (c *Config) Validate() error {
+	if _, _, err := models.ParseSymbol(c.Symbol); err != nil {
+		return err
+	}
+	if _, err := strategy.ParseInterval(c.Interval); err != nil {
+		return err
+	}
+	if err := c.Risk.Validate(); err != nil {
+		return err
+	}
+	if c.InitialBalance <= 0 {
+		return errors.New("initial balance must be positive")
+	}
+
+	if c.MarginMode == "" {
+		c.MarginMode = models.MarginModeIsolated
+	}
+	if !c.MarginMode.IsValid() {
+		return errors.New("invalid margin mode, must be ISOLATED or CROSS")
+	}
+	if c.FundingInterval < 0 {
+		return errors.New("funding interval must not be negative")
+	}
+	if c.FundingInterval == 0 {
+		c.FundingInterval = defaultFundingInterval
+	}
+	if c.PositionMode == "" {
+		c.PositionMode = models.PositionModeHedge
+	}
+	if !c.PositionMode.IsValid() {
+		return errors.New("invalid position mode, must be ONE_WAY or HEDGE")
+	}
+	return nil
+}
+
+// EquityPoint 權益曲線上的一點（每根 K 線收盤時）
+type EquityPoint struct {
+	Time   time.Time `json:"time"`
+	Equity float64   `json:"equity"`
+}
+
+// Report 回測結果
+type Report struct {
+	Symbol         string        `json:"symbol"`
+	Interval       string        `json:"interval"`
+	Start          time.Time     `json:"start"`
+	End            time.Time     `json:"end"`
+	CandleCount    int           `json:"candleCount"`
+	InitialBalance float64       `json:"initialBalance"`
+	FinalEquity    float64       `json:"finalEquity"`
+	TotalReturn    float64       `json:"totalReturn"` // 總報酬率（0.1 表示 10%）
+	MaxDrawdown    float64       `json:"maxDrawdown"` // 最大回撤（0.2 表示 20%）
+	SharpeRatio    float64       `json:"sharpeRatio"` // 年化夏普比率（無風險利率為 0）
+	WinRate        float64       `json:"winRate"`     // 勝率（獲利的平倉交易 / 平倉交易）
+	TradeCount     int           `json:"tradeCount"`
+	ClosingTrades  int           `json:"closingTrades"`
+	Liquidations   int           `json:"liquidations"`
+	Trades         []Trade       `json:"trades"`
+	EquityCurve    []EquityPoint `json:"equityCurve"`
+}
+
+// Run 以 K 線重播策略：每根 K 線依價格路徑撮合掛單、檢查爆倉並呼叫 OnTick，收盤後呼叫 OnCandle 並記錄權益
+// 成交與倉位變動在觸發的事件處理完後依序以 OnFill、OnPositionUpdate 通知策略
+func Run(config Config, candles []Candle, s strategy.Strategy) (*Report, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if len(candles) == 0 {
+		return nil, errors.New("no candles to replay")
+	}
+	if len(candles) > MaxCandles {
+		return nil, fmt.Errorf("too many candles: at most %d per backtest", MaxCandles)
+	}
+	interval, _ := strategy.ParseInterval(config.Interval)
+
+	account, err := newAccount(config)
+	if err != nil {
+		return nil, err
+	}
+	broker := strategy.NewRiskGuard(account, config.Risk)
+
+	report := &Report{
+		Symbol:         config.Symbol,
+		Interval:       config.Interval,
+		Start:          candles[0].OpenTime,
+		End:            candles[len(candles)-1].OpenTime.Add(interval),
+		CandleCount:    len(candles),
+		InitialBalance: config.InitialBalance,
+		EquityCurve:    make([]EquityPoint, 0, len(candles)),
+	}
+
+	for _, candle := range candles {
+		// 1. 依 K 線內的價格路徑推進，撮合掛單、檢查爆倉並通知策略
+		path := config.TradePath[candle.OpenTime.Unix()]
+		if len(path) == 0 {
+			path = pricePath(candle)
+		}
+		for _, price := range path {
+			account.update(price, candle.OpenTime)
+			dispatch(s, broker, account)
+			s.OnTick(broker, price)
+			dispatch(s, broker, account)
+		}
+
+		// 2. 收盤後呼叫策略
+		closeTime := candle.OpenTime.Add(interval)
+		account.update(candle.Close, closeTime)
+		dispatch(s, broker, account)
+		s.OnCandle(broker, candle)
+		dispatch(s, broker, account)
+
+		report.EquityCurve = append(report.EquityCurve, EquityPoint{Time: closeTime, Equity: account.Equity()})
+	}
+
+	report.Trades = account.Trades()
+	report.TradeCount = len(report.Trades)
+	report.Liquidations = account.liquidations
+	report.FinalEquity = account.Equity()
+	report.TotalReturn = report.FinalEquity/config.InitialBalance - 1
+	report.MaxDrawdown = MaxDrawdown(report.EquityCurve)
+	report.SharpeRatio = SharpeRatio(report.EquityCurve, config.InitialBalance, interval)
+	report.WinRate, report.ClosingTrades = WinRate(report.Trades)
+	return report, nil
+}
+
+// dispatch 依序通知策略帳戶的成交與倉位變動（策略在回呼中下單產生的事件也會接著通知）
+func dispatch(s strategy.Strategy, broker strategy.Broker, account *Account) {
+	for events := account.takeEvents(); len(events) > 0; events = account.takeEvents() {
+		for _, event := range events {
+			if event.order != nil {
+				s.OnFill(broker, event.order)
+			} else {
+				s.OnPositionUpdate(broker, event.position)
+			}
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package backtest
+
+import (
+	"backend/models"
+	"backend/strategy"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// limitBuyer 第一根 K 線收盤時在指定價格掛買單，記錄收到的事件
+type limitBuyer struct {
+	strategy.Base
+	price  float64
+	placed bool
+	ticks  int
+	fills  []*models.Order
+}
+
+func (s *limitBuyer) OnTick(broker strategy.Broker, price float64) {
+	s.ticks++
+}
+
+func (s *limitBuyer) OnCandle(broker strategy.Broker, candle Candle) {
+	if !s.placed {
+		s.placed = true
+		broker.LimitOrder(models.OrderSideBuy, 1, s.price)
+	}
+}
+
+func (s *limitBuyer) OnFill(broker strategy.Broker, order *models.Order) {
+	s.fills = append(s.fills, order)
+}
+
+func testCandles(closes ...float64) []Candle {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	candles := make([]Candle, 0, len(closes))
+	open := closes[0]
+	for i, close := range closes {
+		candles = append(candles, Candle{
+			OpenTime: start.Add(time.Duration(i) * time.Hour),
+			Open:     open,
+			High:     math.Max(open, close) + 1,
+			Low:      math.Min(open, close) - 1,
+			Close:    close,
+		})
+		open = close
+	}
+	return candles
+}
+
+func TestRunFillsLimitOrderInsideCandle(t *testing.T) {
+	// 第二根 K 線的最低價 94 觸及 95 的買單
+	candles := testCandles(100, 100, 95, 110)
+	buyer := &limitBuyer{price: 95}
+	report, err := Run(Config{Symbol: "BTCUSDT", Interval: "1h", InitialBalance: 1000}, candles, buyer)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if buyer.ticks != 16 {
+		t.Errorf("OnTick called %d times, want 16", buyer.ticks)
+	}
+	if len(buyer.fills) != 1 || buyer.fills[0].Price != 95 {
+		t.Errorf("fills = %+v, want one fill at 95", buyer.fills)
+	}
+
+	if report.TradeCount != 1 || report.Trades[0].Price != 95 {
+		t.Fatalf("trades = %+v, want one buy at 95", report.Trades)
+	}
+	if !approxEqual(report.FinalEquity, 1015) {
+		t.Errorf("FinalEquity = %v, want 1015", report.FinalEquity)
+	}
+	if len(report.EquityCurve) != len(candles) {
+		t.Errorf("equity curve has %d points, want %d", len(report.EquityCurve), len(candles))
+	}
+	if !report.End.Equal(candles[3].OpenTime.Add(time.Hour)) {
+		t.Errorf("End = %v, want close of last candle", report.End)
+	}
+}
+
+func TestRunBuyAndHold(t *testing.T) {
+	buyAndHold, err := strategy.New("buy_and_hold", nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	report, err := Run(Config{Symbol: "BTCUSDT", Interval: "1h", InitialBalance: 1000}, testCandles(100, 120, 90, 110), buyAndHold)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !approxEqual(report.TotalReturn, 0.1) {
+		t.Errorf("TotalReturn = %v, want 0.1", report.TotalReturn)
+	}
+	if !approxEqual(report.MaxDrawdown, 0.25) {
+		t.Errorf("MaxDrawdown = %v, want 0.25", report.MaxDrawdown)
+	}
+}
+
+// leverageOpener 第一根 K 線收盤時開槓桿多單，記錄下單結果與倉位變動
+type leverageOpener struct {
+	strategy.Base
+	leverage  int
+	err       error
+	opened    bool
+	positions []*models.LeveragePosition
+}
+
+func (s *leverageOpener) OnCandle(broker strategy.Broker, candle Candle) {
+	if !s.opened {
+		s.opened = true
+		s.err = broker.OpenPosition(models.PositionSideLong, s.leverage, 1, false)
+	}
+}
+
+func (s *leverageOpener) OnPositionUpdate(broker strategy.Broker, position *models.LeveragePosition) {
+	s.positions = append(s.positions, position)
+}
+
+func TestRunAppliesRiskLimits(t *testing.T) {
+	config := Config{Symbol: "BTCUSDT", Interval: "1h", InitialBalance: 1000, Risk: strategy.RiskLimits{MaxLeverage: 3}}
+
+	rejected := &leverageOpener{leverage: 5}
+	if _, err := Run(config, testCandles(100, 101), rejected); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if rejected.err == nil || len(rejected.positions) != 0 {
+		t.Errorf("5x order: err = %v, positions = %d, want rejected by max leverage", rejected.err, len(rejected.positions))
+	}
+
+	accepted := &leverageOpener{leverage: 2}
+	if _, err := Run(config, testCandles(100, 101), accepted); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if accepted.err != nil || len(accepted.positions) != 1 || accepted.positions[0].Quantity != 1 {
+		t.Errorf("2x order: err = %v, positions = %+v, want one position update", accepted.err, accepted.positions)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	curve := []EquityPoint{{Equity: 100}, {Equity: 120}, {Equity: 90}, {Equity: 130}, {Equity: 117}}
+	if got := MaxDrawdown(curve); !approxEqual(got, 0.25) {
+		t.Errorf("MaxDrawdown() = %v, want 0.25", got)
+	}
+
+	flat := []EquityPoint{{Equity: 100}, {Equity: 100}, {Equity: 100}}
+	if got := SharpeRatio(flat, 100, time.Hour); got != 0 {
+		t.Errorf("SharpeRatio(flat) = %v, want 0", got)
+	}
+	if got := SharpeRatio(curve, 100, time.Hour); got <= 0 {
+		t.Errorf("SharpeRatio() = %v, want positive for a rising curve", got)
+	}
+
+	trades := []Trade{
+		{Action: TradeActionBuy},
+		{Action: TradeActionSell, RealizedPnL: 10},
+		{Action: TradeActionOpen},
+		{Action: TradeActionClose, RealizedPnL: -5},
+		{Action: TradeActionLiquidation, RealizedPnL: -100},
+		{Action: TradeActionClose, RealizedPnL: 1},
+	}
+	winRate, closing := WinRate(trades)
+	if closing != 4 || winRate != 0.5 {
+		t.Errorf("WinRate() = %v, %d, want 0.5, 4", winRate, closing)
+	}
+}
+
+func TestReadTradesAndAggregate(t *testing.T) {
+	input := "time,price,quantity\n" +
+		"2024-01-01T00:00:10Z,100,1\n" +
+		"2024-01-01T00:00:50Z,104,2\n" +
+		"2024-01-01T00:00:30Z,98,1\n" +
+		"2024-01-01T00:01:05Z,101,3\n"
+
+	trades, err := ReadTradesCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadTradesCSV() error = %v", err)
+	}
+
+	candles, path := AggregateTrades(trades, time.Minute)
+	if len(candles) != 2 {
+		t.Fatalf("got %d candles, want 2", len(candles))
+	}
+	first := candles[0]
+	if first.Open != 100 || first.High != 104 || first.Low != 98 || first.Close != 104 || first.Volume != 4 {
+		t.Errorf("first candle = %+v", first)
+	}
+	if got := path[first.OpenTime.Unix()]; len(got) != 3 || got[1] != 98 {
+		t.Errorf("trade path = %v, want [100 98 104]", got)
+	}
+}
+
+func TestCountCandles(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		start, end time.Time
+		want       int
+	}{
+		{start, start.Add(24 * time.Hour), 24},
+		{start.Add(30 * time.Minute), start.Add(3 * time.Hour), 2},
+		{start, start.Add(90 * time.Minute), 2},
+		{start, start, 0},
+	}
+
+	for _, tt := range tests {
+		if got := CountCandles(tt.start, tt.end, time.Hour); got != tt.want {
+			t.Errorf("CountCandles(%v, %v) = %d, want %d", tt.start, tt.end, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package backtest
+
+import (
+	"backend/models"
+	"errors"
+)
+
+// 槓桿成交、減倉、爆倉與資金費用使用與實盤相同的 models 結算步驟，只是儲存在記憶體中
+var _ models.LeverageLedger = (*ledger)(nil)
+
+// ledger 模擬帳戶的槓桿帳務
+// 倉位變更記錄轉為策略的倉位通知與成交記錄，錢包與倉位直接修改記憶體中的結構，交易記錄不需要保存
+type ledger struct {
+	account *Account
+	orderId int64 // 目前成交的訂單（記錄到成交，爆倉與資金費用為 0）
+}
+
+func (l *ledger) Wallet(userId int64) (*models.Wallet, error) {
+	return l.account.quoteWallet, nil
+}
+
+func (l *ledger) SaveWallet(wallet *models.Wallet) error {
+	return nil
+}
+
+func (l *ledger) PositionMode(userId int64) (models.PositionMode, error) {
+	return l.account.positionMode, nil
+}
+
+func (l *ledger) MarginMode(userId int64) (models.MarginMode, error) {
+	return l.account.marginMode, nil
+}
+
+func (l *ledger) OpenPosition(userId int64, symbol string, side models.PositionSide) (*models.LeveragePosition, error) {
+	return l.account.Position(side), nil
+}
+
+func (l *ledger) CreatePosition(position *models.LeveragePosition) error {
+	a := l.account
+	a.nextPositionId++
+	position.Id = a.nextPositionId
+	position.CreatedAt = a.now
+	a.positions = append(a.positions, position)
+	return nil
+}
+
+func (l *ledger) UpdatePosition(position *models.LeveragePosition) error {
+	return nil
+}
+
+func (l *ledger) SaveSettledPosition(position *models.LeveragePosition) error {
+	for _, open := range l.account.positions {
+		if open == position {
+			l.account.closed(position)
+			return nil
+		}
+	}
+	return errors.New("position is not open")
+}
+
+func (l *ledger) RecordPositionHistory(position *models.LeveragePosition, action models.PositionAction, price float64, quantityChange float64, marginChange float64, realizedPnL float64) error {
+	a := l.account
+	a.positionUpdated(position)
+
+	trade := Trade{OrderId: l.orderId, Side: string(position.Side), Price: price, RealizedPnL: realizedPnL}
+	switch action {
+	case models.PositionActionOpen, models.PositionActionIncrease:
+		trade.Action = TradeActionOpen
+	case models.PositionActionPartialClose, models.PositionActionClose:
+		trade.Action = TradeActionClose
+	case models.PositionActionLiquidate:
+		trade.Action = TradeActionLiquidation
+	default:
+		// 資金費用只通知倉位變動，不是成交
+		return nil
+	}
+	trade.Quantity = quantityChange
+	if trade.Quantity < 0 {
+		trade.Quantity = -trade.Quantity
+	}
+	trade.Amount = trade.Quantity * price
+	a.record(trade)
+	return nil
+}
+
+func (l *ledger) RecordTransaction(userId int64, orderId *int64, txType models.TransactionType, amount float64, balanceBefore float64, balanceAfter float64, description string) error {
+	return nil
+}
+
+func (l *ledger) ApplyInsuranceFund(amount float64, entryType models.InsuranceFundEntryType, position *models.LeveragePosition, userId int64, description string) (float64, error) {
+	return l.account.insuranceFund.Apply(amount), nil
+}
@@ -0,0 +1,81 @@
+package backtest
+
+import (
+	"math"
+	"time"
+)
+
+// periodsPerYear 加密貨幣全年無休，以 365 天計算年化
+const periodsPerYear = 365 * 24 * time.Hour
+
+// MaxDrawdown 最大回撤：權益從歷史高點下跌的最大比例
+func MaxDrawdown(curve []EquityPoint) float64 {
+	var peak, maxDrawdown float64
+	for _, point := range curve {
+		if point.Equity > peak {
+			peak = point.Equity
+		}
+		if peak > 0 {
+			if drawdown := (peak - point.Equity) / peak; drawdown > maxDrawdown {
+				maxDrawdown = drawdown
+			}
+		}
+	}
+	return maxDrawdown
+}
+
+// SharpeRatio 年化夏普比率：每根 K 線報酬率的平均 / 標準差 × √(每年的 K 線數量)，無風險利率為 0
+// 報酬率不足兩筆或沒有波動時返回 0
+func SharpeRatio(curve []EquityPoint, initialBalance float64, interval time.Duration) float64 {
+	if len(curve) < 2 || interval <= 0 {
+		return 0
+	}
+
+	returns := make([]float64, 0, len(curve))
+	previous := initialBalance
+	for _, point := range curve {
+		if previous <= 0 {
+			break
+		}
+		returns = append(returns, point.Equity/previous-1)
+		previous = point.Equity
+	}
+	if len(returns) < 2 {
+		return 0
+	}
+
+	var mean float64
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+
+	var variance float64
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	stddev := math.Sqrt(variance / float64(len(returns)-1))
+	if stddev == 0 {
+		return 0
+	}
+
+	return mean / stddev * math.Sqrt(float64(periodsPerYear)/float64(interval))
+}
+
+// WinRate 勝率：已實現盈虧為正的平倉交易 / 平倉交易（現貨賣出、減倉、平倉與爆倉）
+func WinRate(trades []Trade) (winRate float64, closingTrades int) {
+	var wins int
+	for _, trade := range trades {
+		if !trade.IsClosing() {
+			continue
+		}
+		closingTrades++
+		if trade.RealizedPnL > 0 {
+			wins++
+		}
+	}
+	if closingTrades == 0 {
+		return 0, 0
+	}
+	return float64(wins) / float64(closingTrades), closingTrades
+}
@@ -0,0 +1,193 @@
+// backtest 以命令列執行回測
+//
+// 資料來源（擇一）：
+//
+//	-candles file.csv   K 線 CSV：openTime,open,high,low,close[,volume]
+//	-trades file.csv    逐筆成交 CSV：time,price[,quantity]，依 -interval 彙整為 K 線並逐筆重播
+//	-dsn "user:pass@tcp(host:3306)/db"  讀取資料庫中儲存的 K 線（不足時從 Binance 補齊並儲存）
+//	（都未指定時直接從 Binance 下載 -start 到 -end 的 K 線）
+//
+// 範例：
+//
+//	go run ./cmd/backtest -symbol BTCUSDT -interval 1h -start 2024-01-01T00:00:00Z -end 2024-07-01T00:00:00Z \
+//		-strategy sma_cross -params fast=20,slow=50,leverage=3 -out report.json
+package main
+
+import (
+	"backend/backtest"
+	"backend/models"
+	"backend/strategy"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+func main() {
+	symbol := flag.String("symbol", "BTCUSDT", "交易對：BTCUSDT, ETHUSDT, SOLUSDT")
+	interval := flag.String("interval", "1h", "K 線週期：1m, 5m, 15m, 1h, 4h, 1d ...")
+	start := flag.String("start", "", "回測區間起點（RFC3339，讀取 CSV 時可省略）")
+	end := flag.String("end", "", "回測區間終點，不含（RFC3339，讀取 CSV 時可省略）")
+	candlesFile := flag.String("candles", "", "K 線 CSV 檔案")
+	tradesFile := flag.String("trades", "", "逐筆成交 CSV 檔案")
+	dsn := flag.String("dsn", "", "MySQL 連線字串（讀取儲存的 K 線）")
+	strategyName := flag.String("strategy", "sma_cross", "策略名稱："+strings.Join(strategy.Names(), ", "))
+	params := flag.String("params", "", "策略參數，例如 fast=10,slow=30,leverage=3")
+	balance := flag.Float64("balance", 100000, "模擬帳戶的初始 USDT")
+	marginMode := flag.String("margin-mode", string(models.MarginModeIsolated), "保證金模式：ISOLATED 或 CROSS")
+	positionMode := flag.String("position-mode", string(models.PositionModeHedge), "持倉模式：HEDGE 或 ONE_WAY")
+	marginTiers := flag.String("margin-tiers", "", "維持保證金率階梯設定檔（預設使用內建階梯）")
+	fundingRate := flag.Float64("funding-rate", 0.0001, "每個結算週期的資金費率（0 表示不收付）")
+	fundingInterval := flag.Duration("funding-interval", 8*time.Hour, "資金費用結算週期")
+	var risk strategy.RiskLimits
+	flag.Float64Var(&risk.MaxOrderNotional, "max-order-notional", 0, "風險限制：單筆訂單的最大名目價值（0 表示不限制）")
+	flag.Float64Var(&risk.MaxPositionNotional, "max-position-notional", 0, "風險限制：最大曝險（0 表示不限制）")
+	flag.IntVar(&risk.MaxLeverage, "max-leverage", 0, "風險限制：最大槓桿倍數（0 表示不限制）")
+	flag.Float64Var(&risk.MaxDailyLoss, "max-daily-loss", 0, "風險限制：單日最大虧損（0 表示不限制）")
+	out := flag.String("out", "", "完整報告的輸出檔案（JSON）")
+	flag.Parse()
+
+	// 1. 載入設定與策略
+	if *marginTiers != "" {
+		if err := models.LoadMarginTiers(*marginTiers); err != nil {
+			log.Fatalf("Failed to load margin tiers: %v", err)
+		}
+	}
+
+	strategyParams, err := parseParams(*params)
+	if err != nil {
+		log.Fatalf("Invalid params: %v", err)
+	}
+	s, err := strategy.New(*strategyName, strategyParams)
+	if err != nil {
+		log.Fatalf("Invalid strategy: %v", err)
+	}
+
+	config := backtest.Config{
+		Symbol:         *symbol,
+		Interval:       *interval,
+		InitialBalance: *balance,
+		MarginMode:     models.MarginMode(*marginMode),
+		PositionMode:   models.PositionMode(*positionMode),
+		Risk:           risk,
+
+		FundingRate:     *fundingRate,
+		FundingInterval: *fundingInterval,
+	}
+	if err = config.Validate(); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+
+	// 2. 載入 K 線
+	candles, err := loadCandles(&config, *candlesFile, *tradesFile, *dsn, *start, *end)
+	if err != nil {
+		log.Fatalf("Failed to load candles: %v", err)
+	}
+
+	// 3. 執行回測並輸出結果
+	report, err := backtest.Run(config, candles, s)
+	if err != nil {
+		log.Fatalf("Backtest failed: %v", err)
+	}
+	printReport(*strategyName, report)
+
+	if *out != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode report: %v", err)
+		}
+		if err = os.WriteFile(*out, data, 0644); err != nil {
+			log.Fatalf("Failed to write report: %v", err)
+		}
+		fmt.Printf("Report written to %s\n", *out)
+	}
+}
+
+// loadCandles 依指定的資料來源載入 K 線，逐筆成交同時設定 K 線內的價格路徑
+func loadCandles(config *backtest.Config, candlesFile string, tradesFile string, dsn string, start string, end string) ([]backtest.Candle, error) {
+	if candlesFile != "" {
+		file, err := os.Open(candlesFile)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return backtest.ReadCandlesCSV(file)
+	}
+
+	if tradesFile != "" {
+		file, err := os.Open(tradesFile)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		trades, err := backtest.ReadTradesCSV(file)
+		if err != nil {
+			return nil, err
+		}
+		interval, _ := strategy.ParseInterval(config.Interval)
+		candles, path := backtest.AggregateTrades(trades, interval)
+		config.TradePath = path
+		return candles, nil
+	}
+
+	startTime, err := time.Parse(time.RFC3339, start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -start: %v", err)
+	}
+	endTime, err := time.Parse(time.RFC3339, end)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -end: %v", err)
+	}
+
+	if dsn != "" {
+		// 只連線讀寫 K 線，不同步資料表結構
+		if err = orm.RegisterDataBase("default", "mysql", dsn); err != nil {
+			return nil, fmt.Errorf("failed to connect to database: %v", err)
+		}
+		return backtest.LoadKlines(config.Symbol, config.Interval, startTime.UTC(), endTime.UTC())
+	}
+	return backtest.FetchKlines(config.Symbol, config.Interval, startTime.UTC(), endTime.UTC())
+}
+
+// parseParams 解析 key=value,key=value 格式的策略參數
+func parseParams(value string) (map[string]float64, error) {
+	params := make(map[string]float64)
+	if value == "" {
+		return params, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		key, raw, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("expected key=value, got %q", pair)
+		}
+		number, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %q", key, raw)
+		}
+		params[key] = number
+	}
+	return params, nil
+}
+
+// printReport 輸出績效摘要
+func printReport(strategy string, report *backtest.Report) {
+	fmt.Printf("Strategy:       %s\n", strategy)
+	fmt.Printf("Symbol:         %s %s\n", report.Symbol, report.Interval)
+	fmt.Printf("Period:         %s - %s (%d candles)\n", report.Start.Format(time.RFC3339), report.End.Format(time.RFC3339), report.CandleCount)
+	fmt.Printf("Initial:        %.2f USDT\n", report.InitialBalance)
+	fmt.Printf("Final equity:   %.2f USDT\n", report.FinalEquity)
+	fmt.Printf("Total return:   %.2f%%\n", report.TotalReturn*100)
+	fmt.Printf("Max drawdown:   %.2f%%\n", report.MaxDrawdown*100)
+	fmt.Printf("Sharpe ratio:   %.2f\n", report.SharpeRatio)
+	fmt.Printf("Win rate:       %.2f%% (%d closing trades)\n", report.WinRate*100, report.ClosingTrades)
+	fmt.Printf("Trades:         %d\n", report.TradeCount)
+	fmt.Printf("Liquidations:   %d\n", report.Liquidations)
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/services"
+	"backend/utils"
+	"strconv"
+
+	"github.com/beego/beego/v2/server/web"
+)
+
+type AdminController struct {
+	web.Controller
+}
+
+// requireAdmin 驗證 JWT 並確認使用者為管理員，失敗時已回應錯誤
+func (c *AdminController) requireAdmin() (int64, bool) {
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return 0, false
+	}
+
+	isAdmin, err := services.IsAdmin(userId)
+	if err != nil || !isAdmin {
+		utils.RespondError(c.Ctx, 403, "Forbidden: admin only")
+		return 0, false
+	}
+	return userId, true
+}
+
+// GetInsuranceFund 查詢保險基金
+// @Title GetInsuranceFund
+// @Description 查詢保險基金目前餘額、未承擔的穿倉虧損赤字與餘額變動記錄（爆倉剩餘保證金轉入、穿倉虧損撥出）
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	symbol			query	string	false	"幣種（預設 USDT）"
+// @Param	limit			query	int		false	"每頁數量（預設50）"
+// @Param	offset			query	int		false	"偏移量（預設0）"
+// @Success 200 {array} models.InsuranceFundHistory
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Insurance fund not found
+// @router /insurance-fund [get]
+func (c *AdminController) GetInsuranceFund() {
+	// 1. 驗證管理員權限
+	if _, ok := c.requireAdmin(); !ok {
+		return
+	}
+
+	// 2. 解析查詢參數
+	symbol := c.GetString("symbol", "USDT")
+	limit, _ := strconv.Atoi(c.GetString("limit", "50"))
+	offset, _ := strconv.Atoi(c.GetString("offset", "0"))
+
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	// 3. 查詢基金餘額與變動記錄
+	fund, err := models.GetInsuranceFund(symbol)
+	if err != nil {
+		utils.RespondError(c.Ctx, 404, "Insurance fund not found")
+		return
+	}
+
+	history, err := models.GetInsuranceFundHistory(symbol, limit, offset)
+	if err != nil {
+		utils.RespondError(c.Ctx, 500, "Failed to get insurance fund history: "+err.Error())
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success": true,
+		"fund":    fund,
+		"history": history,
+		"count":   len(history),
+	})
+}
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/services"
+	"backend/strategy"
+	"backend/utils"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/beego/beego/v2/server/web"
+)
+
+// defaultBacktestBalance 未指定時模擬帳戶的初始 USDT（與新使用者的初始餘額相同）
+const defaultBacktestBalance = 100000.0
+
+type BacktestController struct {
+	web.Controller
+}
+
+// BacktestRequest 建立回測任務請求
+type BacktestRequest struct {
+	Symbol         string             `json:"symbol" valid:"Required"`    // 交易對：BTCUSDT, ETHUSDT, SOLUSDT
+	Interval       string             `json:"interval" valid:"Required"`  // K 線週期：1m, 5m, 15m, 1h, 4h, 1d ...
+	StartTime      time.Time          `json:"startTime" valid:"Required"` // 回測區間起點（RFC3339）
+	EndTime        time.Time          `json:"endTime" valid:"Required"`   // 回測區間終點（不含，RFC3339）
+	Strategy       string             `json:"strategy" valid:"Required"`  // 策略名稱：buy_and_hold, sma_cross
+	Params         map[string]float64 `json:"params,omitempty"`           // 策略參數，例如 {"fast": 10, "slow": 30, "leverage": 3}
+	InitialBalance float64            `json:"initialBalance,omitempty"`   // 模擬帳戶的初始 USDT（預設 100000）
+	MarginMode     string             `json:"marginMode,omitempty"`       // ISOLATED（預設）或 CROSS
+	PositionMode   string             `json:"positionMode,omitempty"`     // HEDGE（預設）或 ONE_WAY
+}
+
+// CreateBacktest 建立回測任務
+// @Title CreateBacktest
+// @Description 建立非同步回測任務：以儲存的歷史 K 線（不足時從 Binance 下載）在隔離的模擬帳戶重播策略，完成後以 GET /v1/backtests/:id 查詢成交記錄、權益曲線、最大回撤、夏普比率與勝率
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	body			body	BacktestRequest	true	"回測設定"
+// @Success 202 {object} models.Backtest
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @router / [post]
+func (c *BacktestController) CreateBacktest() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析請求
+	var req BacktestRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid request body")
+		return
+	}
+
+	if req.InitialBalance == 0 {
+		req.InitialBalance = defaultBacktestBalance
+	}
+
+	// 3. 建立任務（設定由 service 驗證）
+	job, err := services.GlobalBacktestRunner.SubmitBacktest(userId, &models.Backtest{
+		Symbol:         req.Symbol,
+		Interval:       req.Interval,
+		StartTime:      req.StartTime.UTC(),
+		EndTime:        req.EndTime.UTC(),
+		Strategy:       req.Strategy,
+		StrategyParams: req.Params,
+		InitialBalance: req.InitialBalance,
+		MarginMode:     models.MarginMode(req.MarginMode),
+		PositionMode:   models.PositionMode(req.PositionMode),
+	})
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Failed to create backtest: "+err.Error())
+		return
+	}
+
+	// 4. 返回任務（202：回測在背景執行）
+	utils.RespondJSON(c.Ctx, 202, map[string]interface{}{
+		"success":  true,
+		"message":  "Backtest queued",
+		"backtest": job,
+	})
+}
+
+// GetBacktests 查詢所有回測任務
+// @Title GetBacktests
+// @Description 查詢使用者的回測任務與績效摘要（由新到舊，不含成交記錄與權益曲線）
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	limit			query	int		false	"每頁數量（預設20）"
+// @Param	offset			query	int		false	"偏移量（預設0）"
+// @Success 200 {array} models.Backtest
+// @Failure 401 Unauthorized
+// @Failure 500 Internal server error
+// @router / [get]
+func (c *BacktestController) GetBacktests() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析查詢參數
+	limit, _ := strconv.Atoi(c.GetString("limit", "20"))
+	offset, _ := strconv.Atoi(c.GetString("offset", "0"))
+
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	// 3. 查詢任務
+	jobs, err := models.GetBacktestsByUser(userId, limit, offset)
+	if err != nil {
+		utils.RespondError(c.Ctx, 500, "Failed to get backtests: "+err.Error())
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":   true,
+		"backtests": jobs,
+		"count":     len(jobs),
+	})
+}
+
+// GetBacktestStrategies 查詢可回測的策略
+// @Title GetBacktestStrategies
+// @Description 查詢已註冊的策略名稱（回測與策略機器人共用）
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Success 200 {array} string
+// @Failure 401 Unauthorized
+// @router /strategies [get]
+func (c *BacktestController) GetBacktestStrategies() {
+	// 1. 驗證 JWT
+	if _, err := utils.ValidateJWT(c.Ctx.Request); err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":    true,
+		"strategies": strategy.Names(),
+	})
+}
+
+// GetBacktest 查詢單一回測任務
+// @Title GetBacktest
+// @Description 查詢回測任務的狀態，完成後包含完整報告（成交記錄、權益曲線、最大回撤、夏普比率與勝率）
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"回測任務 ID"
+// @Success 200 {object} models.Backtest
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Backtest not found
+// @router /:id [get]
+func (c *BacktestController) GetBacktest() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析任務 ID
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid backtest ID")
+		return
+	}
+
+	// 3. 查詢任務
+	job, err := services.GetBacktest(userId, id)
+	if err != nil {
+		switch err.Error() {
+		case "unauthorized: backtest does not belong to user":
+			utils.RespondError(c.Ctx, 403, err.Error())
+		case "backtest not found":
+			utils.RespondError(c.Ctx, 404, err.Error())
+		default:
+			utils.RespondError(c.Ctx, 500, "Failed to get backtest: "+err.Error())
+		}
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":  true,
+		"backtest": job,
+	})
+}
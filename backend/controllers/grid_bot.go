@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/services"
+	"backend/utils"
+	"encoding/json"
+	"strconv"
+
+	"github.com/beego/beego/v2/server/web"
+)
+
+type GridBotController struct {
+	web.Controller
+}
+
+// GridBotRequest 建立網格機器人請求
+type GridBotRequest struct {
+	Symbol     string  `json:"symbol" valid:"Required"`     // 交易對：BTCUSDT, ETHUSDT, SOLUSDT
+	LowerPrice float64 `json:"lowerPrice" valid:"Required"` // 網格下限
+	UpperPrice float64 `json:"upperPrice" valid:"Required"` // 網格上限（目前價格需在上下限之間）
+	GridCount  int     `json:"gridCount" valid:"Required"`  // 網格數量（2 ~ 100）
+	Investment float64 `json:"investment" valid:"Required"` // 投入的 USDT 金額
+}
+
+// CreateGridBot 建立網格機器人
+// @Title CreateGridBot
+// @Description 在上下限之間等距掛出限價單：低於目前價格掛買單，高於目前價格掛賣單（賣單庫存以市價買入），每成交一筆就在相鄰的網格掛出反向訂單
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	body			body	GridBotRequest	true	"網格設定"
+// @Success 200 {object} models.GridBot
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @router / [post]
+func (c *GridBotController) CreateGridBot() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析請求
+	var req GridBotRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid request body")
+		return
+	}
+
+	// 3. 建立機器人（參數由 service 驗證）
+	bot, err := services.GlobalGridBotService.CreateGridBot(userId, models.GridBotParams{
+		Symbol:     req.Symbol,
+		LowerPrice: req.LowerPrice,
+		UpperPrice: req.UpperPrice,
+		GridCount:  req.GridCount,
+		Investment: req.Investment,
+	})
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Failed to create grid bot: "+err.Error())
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success": true,
+		"message": "Grid bot created successfully",
+		"gridBot": bot,
+	})
+}
+
+// GetGridBots 查詢所有網格機器人
+// @Title GetGridBots
+// @Description 查詢使用者的網格機器人（由新到舊）
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	limit			query	int		false	"每頁數量（預設20）"
+// @Param	offset			query	int		false	"偏移量（預設0）"
+// @Success 200 {array} models.GridBot
+// @Failure 401 Unauthorized
+// @Failure 500 Internal server error
+// @router / [get]
+func (c *GridBotController) GetGridBots() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析查詢參數
+	limit, _ := strconv.Atoi(c.GetString("limit", "20"))
+	offset, _ := strconv.Atoi(c.GetString("offset", "0"))
+
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	// 3. 查詢機器人
+	bots, err := models.GetGridBotsByUser(userId, limit, offset)
+	if err != nil {
+		utils.RespondError(c.Ctx, 500, "Failed to get grid bots: "+err.Error())
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":  true,
+		"gridBots": bots,
+		"count":    len(bots),
+	})
+}
+
+// GetGridBot 查詢單一網格機器人
+// @Title GetGridBot
+// @Description 查詢網格機器人的狀態、已實現利潤與目前掛出的訂單
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"機器人 ID"
+// @Success 200 {object} models.GridBot
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Grid bot not found
+// @router /:id [get]
+func (c *GridBotController) GetGridBot() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析機器人 ID
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid grid bot ID")
+		return
+	}
+
+	// 3. 查詢機器人
+	bot, err := services.GetGridBot(userId, id)
+	if err != nil {
+		c.respondError(err, "Failed to get grid bot: ")
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success": true,
+		"gridBot": bot,
+	})
+}
+
+// StopGridBot 停止網格機器人
+// @Title StopGridBot
+// @Description 停止網格機器人並取消所有掛單，已買入的庫存保留在錢包
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"機器人 ID"
+// @Success 200 {object} models.GridBot
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Grid bot not found
+// @router /:id/stop [post]
+func (c *GridBotController) StopGridBot() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析機器人 ID
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid grid bot ID")
+		return
+	}
+
+	// 3. 停止機器人
+	bot, err := services.GlobalGridBotService.StopGridBot(userId, id)
+	if err != nil {
+		c.respondError(err, "Failed to stop grid bot: ")
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success": true,
+		"message": "Grid bot stopped successfully",
+		"gridBot": bot,
+	})
+}
+
+// respondError 將機器人相關錯誤轉換為 HTTP 狀態碼
+func (c *GridBotController) respondError(err error, prefix string) {
+	switch err.Error() {
+	case "unauthorized: grid bot does not belong to user":
+		utils.RespondError(c.Ctx, 403, err.Error())
+	case "grid bot not found":
+		utils.RespondError(c.Ctx, 404, err.Error())
+	default:
+		utils.RespondError(c.Ctx, 400, prefix+err.Error())
+	}
+}
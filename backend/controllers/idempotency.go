@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/utils"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/v2/server/web/context"
+)
+
+// IdempotencyKeyHeader 下單請求的冪等 Key header
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencyKeys 從 Idempotency-Key header 與 clientOrderId 取得要登記的 Key，格式錯誤時返回錯誤訊息
+func idempotencyKeys(ctx *context.Context, clientOrderId string) ([]string, string) {
+	var keys []string
+	if key := ctx.Input.Header(IdempotencyKeyHeader); key != "" {
+		if err := models.ValidateIdempotencyKey(key); err != nil {
+			return nil, "Invalid Idempotency-Key: " + err.Error()
+		}
+		keys = append(keys, "key:"+key)
+	}
+	if clientOrderId != "" {
+		if err := models.ValidateIdempotencyKey(clientOrderId); err != nil {
+			return nil, "Invalid clientOrderId: " + err.Error()
+		}
+		keys = append(keys, "cid:"+clientOrderId)
+	}
+	return keys, ""
+}
+
+// respondIdempotent 執行下單並返回結果
+// 帶有 Idempotency-Key 或 clientOrderId 的請求會記錄回應，重送時直接返回原本的結果而不再下單
+// 伺服器錯誤（5xx）不記錄，讓使用者可以用相同的 Key 重試
+// handle 收到登記的 Key，需要傳給建立訂單的服務，與訂單在同一交易中記錄訂單 ID
+// 處理中的記錄逾時（原始請求中斷）時，已建立訂單的以 recover 由訂單重建回應，尚未建立的由重送的請求接手，
+// 見 models.IdempotencyClaimTimeout
+func respondIdempotent(ctx *context.Context, userId int64, scope string, clientOrderId string,
+	handle func(keys []string) (int, map[string]interface{}), recover func(orderId int64) (int, map[string]interface{})) {
+	// 1. 取得 Key，沒有 Key 時直接執行
+	keys, errMsg := idempotencyKeys(ctx, clientOrderId)
+	if errMsg != "" {
+		utils.RespondError(ctx, 400, errMsg)
+		return
+	}
+	if len(keys) == 0 {
+		status, body := handle(nil)
+		utils.RespondJSON(ctx, status, body)
+		return
+	}
+
+	// 2. 登記 Key，已存在時重送原本的結果
+	hash := sha256.Sum256(append([]byte(scope+"\n"), ctx.Input.RequestBody...))
+	requestHash := hex.EncodeToString(hash[:])
+
+	existing, err := models.ClaimIdempotencyKeys(userId, keys, requestHash)
+	if err != nil {
+		utils.RespondError(ctx, 500, "Failed to record idempotency key: "+err.Error())
+		return
+	}
+	if existing != nil {
+		replayIdempotent(ctx, userId, existing, requestHash, recover)
+		return
+	}
+
+	// 3. 執行並保存回應
+	status, body := handle(keys)
+	if status >= 500 {
+		if err = models.ReleaseIdempotencyKeys(userId, keys); err != nil {
+			log.Printf("Failed to release idempotency keys %v for user %d: %v", keys, userId, err)
+		}
+	} else {
+		completeIdempotent(userId, keys, status, body)
+	}
+	utils.RespondJSON(ctx, status, body)
+}
+
+// completeIdempotent 保存請求的回應，失敗時只記錄日誌（回應照常返回）
+func completeIdempotent(userId int64, keys []string, status int, body map[string]interface{}) {
+	if response, err := json.Marshal(body); err != nil {
+		log.Printf("Failed to encode response for idempotency keys %v: %v", keys, err)
+	} else if err = models.CompleteIdempotencyKeys(userId, keys, status, string(response)); err != nil {
+		log.Printf("Failed to save response for idempotency keys %v for user %d: %v", keys, userId, err)
+	}
+}
+
+// replayIdempotent 以已存在的冪等記錄回應重送的請求
+// 原始請求已建立訂單但在保存回應前中斷時，以訂單重建回應並保存
+func replayIdempotent(ctx *context.Context, userId int64, record *models.IdempotencyRecord, requestHash string, recover func(orderId int64) (int, map[string]interface{})) {
+	if record.RequestHash != requestHash {
+		if strings.HasPrefix(record.Key, "cid:") {
+			utils.RespondError(ctx, 409, "Duplicate clientOrderId")
+		} else {
+			utils.RespondError(ctx, 422, "Idempotency-Key was already used with a different request")
+		}
+		return
+	}
+	if record.InProgress() && record.OrderId > 0 && record.Abandoned(time.Now()) {
+		status, body := recover(record.OrderId)
+		if status < 500 {
+			completeIdempotent(userId, []string{record.Key}, status, body)
+		}
+		ctx.Output.Header("Idempotent-Replayed", "true")
+		utils.RespondJSON(ctx, status, body)
+		return
+	}
+	if record.InProgress() {
+		utils.RespondError(ctx, 409, "A request with the same key is still being processed")
+		return
+	}
+
+	ctx.Output.Header("Idempotent-Replayed", "true")
+	ctx.Output.Header("Content-Type", "application/json; charset=utf-8")
+	ctx.Output.SetStatus(record.StatusCode)
+	ctx.Output.Body([]byte(record.Response))
+}
+
+// errorBody 與 utils.RespondError 相同格式的錯誤回應內容
+func errorBody(message string) map[string]interface{} {
+	return map[string]interface{}{
+		"success": false,
+		"error":   message,
+	}
+}
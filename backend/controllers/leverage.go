@@ -5,6 +5,7 @@ import (
 	"backend/services"
 	"backend/utils"
 	"encoding/json"
+	"errors"
 	"strconv"
 
 	"github.com/beego/beego/v2/client/orm"
@@ -17,22 +18,54 @@ type LeverageController struct {
 
 // OpenPositionRequest 開倉請求
 type OpenPositionRequest struct {
-	Symbol     string              `json:"symbol" valid:"Required"`    // 交易對：BTCUSDT, ETHUSDT, SOLUSDT
-	Side       models.PositionSide `json:"side" valid:"Required"`      // LONG 或 SHORT
-	Leverage   int                 `json:"leverage" valid:"Required"`  // 槓桿倍數 1-10
-	Quantity   float64             `json:"quantity" valid:"Required"`  // 數量
-	OrderType  models.OrderType    `json:"orderType" valid:"Required"` // MARKET 或 LIMIT
-	LimitPrice *float64            `json:"limitPrice,omitempty"`       // 限價（僅限價單需要）
+	Symbol        string              `json:"symbol" valid:"Required"`    // 交易對：BTCUSDT, ETHUSDT, SOLUSDT
+	Side          models.PositionSide `json:"side" valid:"Required"`      // LONG 或 SHORT
+	Leverage      int                 `json:"leverage" valid:"Required"`  // 槓桿倍數 1-10
+	Quantity      float64             `json:"quantity" valid:"Required"`  // 數量
+	OrderType     models.OrderType    `json:"orderType" valid:"Required"` // MARKET 或 LIMIT
+	LimitPrice    *float64            `json:"limitPrice,omitempty"`       // 限價（僅限價單需要）
+	ReduceOnly    bool                `json:"reduceOnly"`                 // 只減倉：LONG 只減少空頭持倉，SHORT 只減少多頭持倉
+	ClientOrderId string              `json:"clientOrderId,omitempty"`    // 使用者自訂的訂單 ID（同一使用者唯一，重送時返回原本的結果）
+}
+
+// ClosePositionRequest 平倉請求
+type ClosePositionRequest struct {
+	Quantity float64 `json:"quantity"` // 平倉數量，不填或不小於持倉數量時全部平倉
+}
+
+// TrailingStopRequest 追蹤停損平倉請求
+type TrailingStopRequest struct {
+	Quantity        float64 `json:"quantity"`        // 平倉數量，不填或不小於持倉數量時全部平倉
+	CallbackRate    float64 `json:"callbackRate"`    // 回撤比例（例如 0.01 表示 1%），與 callbackOffset 擇一
+	CallbackOffset  float64 `json:"callbackOffset"`  // 回撤金額（USDT），與 callbackRate 擇一
+	ActivationPrice float64 `json:"activationPrice"` // 啟動價格（不填則立即開始追蹤）
+}
+
+// SetMarginModeRequest 切換保證金模式請求
+type SetMarginModeRequest struct {
+	MarginMode models.MarginMode `json:"marginMode" valid:"Required"` // ISOLATED 或 CROSS
+}
+
+// SetPositionModeRequest 切換持倉模式請求
+type SetPositionModeRequest struct {
+	PositionMode models.PositionMode `json:"positionMode" valid:"Required"` // ONE_WAY 或 HEDGE
+}
+
+// AdjustMarginRequest 調整保證金請求
+type AdjustMarginRequest struct {
+	Amount float64 `json:"amount" valid:"Required"` // 正數追加保證金，負數減少保證金（USDT）
 }
 
 // OpenPosition 開槓桿倉位
 // @Title OpenPosition
-// @Description 開設槓桿倉位（做多/做空）
+// @Description 開設槓桿倉位（做多/做空），單向持倉模式下反方向開倉會先減倉或反手，reduceOnly 訂單只減少反方向持倉
 // @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	Idempotency-Key	header	string	false	"冪等 Key（重送時返回原本的結果）"
 // @Param	body			body	OpenPositionRequest	true	"開倉資訊"
 // @Success 200 {object} models.LeveragePosition
 // @Failure 400 Bad request
 // @Failure 401 Unauthorized
+// @Failure 409 Duplicate clientOrderId or request still being processed
 // @Failure 500 Internal server error
 // @router /position/open [post]
 func (c *LeverageController) OpenPosition() {
@@ -83,32 +116,48 @@ func (c *LeverageController) OpenPosition() {
 		return
 	}
 
-	// 4. 開倉
-	var position *models.LeveragePosition
+	// 4. 開倉（帶有 Idempotency-Key 或 clientOrderId 的重送請求返回原本的結果）
+	respondIdempotent(c.Ctx, userId, "leverage.position.open", req.ClientOrderId, func(keys []string) (int, map[string]interface{}) {
+		var position *models.LeveragePosition
+		if req.OrderType == models.OrderTypeMarket {
+			position, err = services.OpenLeveragePositionMarket(userId, req.Symbol, req.Side, req.Leverage, req.Quantity, req.ReduceOnly, req.ClientOrderId, keys)
+		} else {
+			position, err = services.OpenLeveragePositionLimit(userId, req.Symbol, req.Side, req.Leverage, req.Quantity, *req.LimitPrice, req.ReduceOnly, req.ClientOrderId, keys)
+		}
+		if errors.Is(err, models.ErrDuplicateClientOrderId) {
+			return 409, errorBody("Duplicate clientOrderId")
+		}
+		if err != nil {
+			return 400, errorBody("Failed to open position: " + err.Error())
+		}
 
-	if req.OrderType == models.OrderTypeMarket {
-		position, err = services.OpenLeveragePositionMarket(userId, req.Symbol, req.Side, req.Leverage, req.Quantity)
-	} else {
-		position, err = services.OpenLeveragePositionLimit(userId, req.Symbol, req.Side, req.Leverage, req.Quantity, *req.LimitPrice)
-	}
-	if err != nil {
-		utils.RespondError(c.Ctx, 400, "Failed to open position: "+err.Error())
-		return
-	}
+		// 5. 返回結果
+		return openPositionResult(position)
+	}, func(orderId int64) (int, map[string]interface{}) {
+		// 原始請求已建立訂單但未保存回應：以訂單重建開倉結果
+		position, err := services.GetLeverageOpenResult(userId, orderId)
+		if err != nil {
+			return 500, errorBody("Failed to get position: " + err.Error())
+		}
+		return openPositionResult(position)
+	})
+}
 
-	// 5. 返回結果
-	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+// openPositionResult 開倉成功的回應
+func openPositionResult(position *models.LeveragePosition) (int, map[string]interface{}) {
+	return 200, map[string]interface{}{
 		"success":  true,
 		"message":  "Position opened successfully",
 		"position": position,
-	})
+	}
 }
 
 // ClosePosition 平槓桿倉位
 // @Title ClosePosition
-// @Description 平倉（關閉槓桿倉位）
+// @Description 平倉（關閉槓桿倉位），可指定數量部分平倉
 // @Param	Authorization	header	string	true	"Bearer {token}"
 // @Param	id				path	int		true	"倉位 ID"
+// @Param	body			body	ClosePositionRequest	false	"平倉數量（不填則全部平倉）"
 // @Success 200 {object} models.LeveragePosition
 // @Failure 400 Bad request
 // @Failure 401 Unauthorized
@@ -130,8 +179,22 @@ func (c *LeverageController) ClosePosition() {
 		return
 	}
 
-	// 3. 平倉
-	position, err := services.CloseLeveragePosition(userId, positionId)
+	// 3. 解析請求（可選）
+	var req ClosePositionRequest
+	if len(c.Ctx.Input.RequestBody) > 0 {
+		if err = json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+			utils.RespondError(c.Ctx, 400, "Invalid request body")
+			return
+		}
+	}
+
+	if req.Quantity < 0 {
+		utils.RespondError(c.Ctx, 400, "Quantity must not be negative")
+		return
+	}
+
+	// 4. 平倉
+	position, err := services.CloseLeveragePosition(userId, positionId, req.Quantity)
 	if err != nil {
 		if err.Error() == "unauthorized: position does not belong to user" {
 			utils.RespondError(c.Ctx, 403, err.Error())
@@ -145,7 +208,7 @@ func (c *LeverageController) ClosePosition() {
 		return
 	}
 
-	// 4. 返回結果
+	// 5. 返回結果
 	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
 		"success":  true,
 		"message":  "Position closed successfully",
@@ -153,6 +216,139 @@ func (c *LeverageController) ClosePosition() {
 	})
 }
 
+// PlaceTrailingStop 下追蹤停損平倉單
+// @Title PlaceTrailingStop
+// @Description 為倉位設定追蹤停損：多頭追蹤最高價、空頭追蹤最低價，價格回撤超過設定值時以市價平倉
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"倉位 ID"
+// @Param	body			body	TrailingStopRequest	true	"追蹤停損參數"
+// @Success 200 {object} models.Order
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Position not found
+// @router /position/:id/trailing-stop [post]
+func (c *LeverageController) PlaceTrailingStop() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析倉位 ID
+	positionIdStr := c.Ctx.Input.Param(":id")
+	positionId, err := strconv.ParseInt(positionIdStr, 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid position ID")
+		return
+	}
+
+	// 3. 解析請求
+	var req TrailingStopRequest
+	if err = json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid request body")
+		return
+	}
+
+	if req.Quantity < 0 {
+		utils.RespondError(c.Ctx, 400, "Quantity must not be negative")
+		return
+	}
+
+	params := models.TrailingStopParams{
+		CallbackRate:    req.CallbackRate,
+		CallbackOffset:  req.CallbackOffset,
+		ActivationPrice: req.ActivationPrice,
+	}
+	if err = params.Validate(); err != nil {
+		utils.RespondError(c.Ctx, 400, err.Error())
+		return
+	}
+
+	// 4. 建立追蹤停損單
+	order, err := services.PlaceTrailingStopClose(userId, positionId, req.Quantity, params)
+	if err != nil {
+		if err.Error() == "unauthorized: position does not belong to user" {
+			utils.RespondError(c.Ctx, 403, err.Error())
+		} else if err.Error() == "position is not open" {
+			utils.RespondError(c.Ctx, 400, err.Error())
+		} else if err.Error() == "position not found" {
+			utils.RespondError(c.Ctx, 404, err.Error())
+		} else {
+			utils.RespondError(c.Ctx, 500, "Failed to place trailing stop: "+err.Error())
+		}
+		return
+	}
+
+	// 5. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success": true,
+		"message": "Trailing stop placed successfully",
+		"order":   order,
+	})
+}
+
+// AdjustMargin 調整倉位保證金
+// @Title AdjustMargin
+// @Description 追加或減少逐倉倉位的保證金，並重新計算爆倉價格
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"倉位 ID"
+// @Param	body			body	AdjustMarginRequest	true	"調整金額"
+// @Success 200 {object} models.LeveragePosition
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 404 Position not found
+// @router /position/:id/margin [post]
+func (c *LeverageController) AdjustMargin() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析倉位 ID
+	positionIdStr := c.Ctx.Input.Param(":id")
+	positionId, err := strconv.ParseInt(positionIdStr, 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid position ID")
+		return
+	}
+
+	// 3. 解析請求
+	var req AdjustMarginRequest
+	if err = json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid request body")
+		return
+	}
+
+	if req.Amount == 0 {
+		utils.RespondError(c.Ctx, 400, "Amount must not be zero")
+		return
+	}
+
+	// 4. 調整保證金
+	position, err := services.AdjustPositionMargin(userId, positionId, req.Amount)
+	if err != nil {
+		if err.Error() == "unauthorized: position does not belong to user" {
+			utils.RespondError(c.Ctx, 403, err.Error())
+		} else if err.Error() == "position not found" {
+			utils.RespondError(c.Ctx, 404, err.Error())
+		} else {
+			utils.RespondError(c.Ctx, 400, "Failed to adjust margin: "+err.Error())
+		}
+		return
+	}
+
+	// 5. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":  true,
+		"message":  "Margin adjusted successfully",
+		"position": position,
+	})
+}
+
 // GetOpenPositions 查詢持倉
 // @Title GetOpenPositions
 // @Description 查詢使用者的所有持倉
@@ -176,11 +372,11 @@ func (c *LeverageController) GetOpenPositions() {
 		return
 	}
 
-	// 3. 更新未實現盈虧
+	// 3. 以標記價格更新未實現盈虧
 	for _, position := range positions {
-		currentPrice, ok := services.GlobalPriceCache.GetPrice(position.Symbol)
+		markPrice, ok := services.GlobalPriceCache.GetMarkPrice(position.Symbol)
 		if ok {
-			position.UnrealizedPnL = position.CalculateUnrealizedPnL(currentPrice)
+			position.UnrealizedPnL = position.CalculateUnrealizedPnL(markPrice)
 		}
 	}
 
@@ -285,11 +481,11 @@ func (c *LeverageController) GetPositionDetail() {
 		return
 	}
 
-	// 5. 更新未實現盈虧（如果倉位還開著）
+	// 5. 以標記價格更新未實現盈虧（如果倉位還開著）
 	if position.Status == models.PositionStatusOpen {
-		currentPrice, ok := services.GlobalPriceCache.GetPrice(position.Symbol)
+		markPrice, ok := services.GlobalPriceCache.GetMarkPrice(position.Symbol)
 		if ok {
-			position.UnrealizedPnL = position.CalculateUnrealizedPnL(currentPrice)
+			position.UnrealizedPnL = position.CalculateUnrealizedPnL(markPrice)
 		}
 	}
 
@@ -299,3 +495,219 @@ func (c *LeverageController) GetPositionDetail() {
 		"position": position,
 	})
 }
+
+// GetPositionChanges 查詢倉位變更記錄
+// @Title GetPositionChanges
+// @Description 查詢單個倉位的開倉、加倉、部分平倉、調整保證金等變更記錄
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"倉位 ID"
+// @Success 200 {array} models.PositionHistory
+// @Failure 401 Unauthorized
+// @Failure 404 Position not found
+// @router /position/:id/changes [get]
+func (c *LeverageController) GetPositionChanges() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析倉位 ID
+	positionIdStr := c.Ctx.Input.Param(":id")
+	positionId, err := strconv.ParseInt(positionIdStr, 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid position ID")
+		return
+	}
+
+	// 3. 查詢倉位並驗證所有權
+	position, err := models.GetPositionById(positionId)
+	if err != nil {
+		utils.RespondError(c.Ctx, 404, "Position not found")
+		return
+	}
+
+	if position.User.Id != userId {
+		utils.RespondError(c.Ctx, 403, "Unauthorized: position does not belong to user")
+		return
+	}
+
+	// 4. 查詢變更記錄
+	changes, err := models.GetPositionHistoryByPosition(positionId)
+	if err != nil {
+		utils.RespondError(c.Ctx, 500, "Failed to get position changes: "+err.Error())
+		return
+	}
+
+	// 5. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success": true,
+		"changes": changes,
+		"count":   len(changes),
+	})
+}
+
+// GetMarginMode 查詢保證金模式
+// @Title GetMarginMode
+// @Description 查詢使用者的保證金模式，全倉模式時一併返回帳戶總權益與維持保證金
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Success 200 {object} models.CrossMarginStatus
+// @Failure 401 Unauthorized
+// @Failure 500 Internal server error
+// @router /margin-mode [get]
+func (c *LeverageController) GetMarginMode() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 查詢保證金模式
+	mode, status, err := services.GetMarginMode(userId)
+	if err != nil {
+		utils.RespondError(c.Ctx, 500, "Failed to get margin mode: "+err.Error())
+		return
+	}
+
+	// 3. 返回結果
+	response := map[string]interface{}{
+		"success":    true,
+		"marginMode": mode,
+	}
+	if status != nil {
+		response["account"] = status
+	}
+	utils.RespondJSON(c.Ctx, 200, response)
+}
+
+// SetMarginMode 切換保證金模式
+// @Title SetMarginMode
+// @Description 在逐倉（ISOLATED）與全倉（CROSS）之間切換，需沒有持倉與未成交的槓桿限價單
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	body			body	SetMarginModeRequest	true	"保證金模式"
+// @Success 200 {string} Margin mode updated
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 409 Open positions or pending orders exist
+// @router /margin-mode [post]
+func (c *LeverageController) SetMarginMode() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析請求
+	var req SetMarginModeRequest
+	if err = json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid request body")
+		return
+	}
+
+	if !req.MarginMode.IsValid() {
+		utils.RespondError(c.Ctx, 400, "Margin mode must be ISOLATED or CROSS")
+		return
+	}
+
+	// 3. 切換模式
+	err = services.SetMarginMode(userId, req.MarginMode)
+	if err != nil {
+		if err.Error() == "cannot change margin mode with open positions" ||
+			err.Error() == "cannot change margin mode with pending leverage orders" {
+			utils.RespondError(c.Ctx, 409, err.Error())
+		} else {
+			utils.RespondError(c.Ctx, 500, "Failed to set margin mode: "+err.Error())
+		}
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":    true,
+		"message":    "Margin mode updated",
+		"marginMode": req.MarginMode,
+	})
+}
+
+// GetPositionMode 查詢持倉模式
+// @Title GetPositionMode
+// @Description 查詢使用者的持倉模式（ONE_WAY 單向持倉或 HEDGE 雙向持倉）
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Success 200 {string} Position mode
+// @Failure 401 Unauthorized
+// @Failure 500 Internal server error
+// @router /position-mode [get]
+func (c *LeverageController) GetPositionMode() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 查詢持倉模式
+	mode, err := services.GetPositionMode(userId)
+	if err != nil {
+		utils.RespondError(c.Ctx, 500, "Failed to get position mode: "+err.Error())
+		return
+	}
+
+	// 3. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":      true,
+		"positionMode": mode,
+	})
+}
+
+// SetPositionMode 切換持倉模式
+// @Title SetPositionMode
+// @Description 在單向持倉（ONE_WAY）與雙向持倉（HEDGE）之間切換，需沒有持倉與未成交的槓桿限價單
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	body			body	SetPositionModeRequest	true	"持倉模式"
+// @Success 200 {string} Position mode updated
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 409 Open positions or pending orders exist
+// @router /position-mode [post]
+func (c *LeverageController) SetPositionMode() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析請求
+	var req SetPositionModeRequest
+	if err = json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid request body")
+		return
+	}
+
+	if !req.PositionMode.IsValid() {
+		utils.RespondError(c.Ctx, 400, "Position mode must be ONE_WAY or HEDGE")
+		return
+	}
+
+	// 3. 切換模式
+	err = services.SetPositionMode(userId, req.PositionMode)
+	if err != nil {
+		if err.Error() == "cannot change position mode with open positions" ||
+			err.Error() == "cannot change position mode with pending leverage orders" {
+			utils.RespondError(c.Ctx, 409, err.Error())
+		} else {
+			utils.RespondError(c.Ctx, 500, "Failed to set position mode: "+err.Error())
+		}
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":      true,
+		"message":      "Position mode updated",
+		"positionMode": req.PositionMode,
+	})
+}
@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"backend/models"
+	"backend/utils"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -74,6 +76,45 @@ func (c *MarketController) GetKLines() {
 	c.ServeJSON()
 }
 
+// GetFundingRates 查詢資金費率
+// @Title GetFundingRates
+// @Description 查詢交易對的歷史資金費率（每個結算週期一筆，由新到舊）
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	symbol			query	string	false	"交易對（預設 BTCUSDT）"
+// @Param	limit			query	int		false	"每頁數量（預設20）"
+// @Param	offset			query	int		false	"偏移量（預設0）"
+// @Success 200 {array} models.FundingRate
+// @Failure 401 Unauthorized
+// @Failure 500 Internal server error
+// @router /funding-rates [get]
+func (c *MarketController) GetFundingRates() {
+	// 1. 解析查詢參數
+	symbol := c.GetString("symbol", "BTCUSDT")
+	limit, _ := strconv.Atoi(c.GetString("limit", "20"))
+	offset, _ := strconv.Atoi(c.GetString("offset", "0"))
+
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	// 2. 查詢資金費率
+	rates, err := models.GetFundingRates(symbol, limit, offset)
+	if err != nil {
+		utils.RespondError(c.Ctx, 500, "Failed to get funding rates: "+err.Error())
+		return
+	}
+
+	// 3. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success": true,
+		"rates":   rates,
+		"count":   len(rates),
+	})
+}
+
 // 輔助函式：將 interface{} (實際是 string) 轉為 float64
 func strToFloat(v interface{}) float64 {
 	s, ok := v.(string)
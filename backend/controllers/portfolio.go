@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"backend/services"
+	"backend/utils"
+
+	"github.com/beego/beego/v2/server/web"
+)
+
+type PortfolioController struct {
+	web.Controller
+}
+
+// GetPortfolioSummary 查詢資產總值
+// @Title GetPortfolioSummary
+// @Description 以目前價格計算資產總值（USDT）：各幣種錢包以最新成交價計價，槓桿持倉計入保證金與以標記價格計算的未實現盈虧；沒有價格的交易對列在 missingPrices 並以 0 計價
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Success 200 {object} models.PortfolioSummary
+// @Failure 401 Unauthorized
+// @Failure 500 Internal server error
+// @router /summary [get]
+func (c *PortfolioController) GetPortfolioSummary() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 計算資產總值
+	summary, err := services.GetPortfolioSummary(userId)
+	if err != nil {
+		utils.RespondError(c.Ctx, 500, "Failed to get portfolio summary: "+err.Error())
+		return
+	}
+
+	// 3. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":   true,
+		"portfolio": summary,
+	})
+}
+
+// GetPortfolioHistory 查詢資產曲線
+// @Title GetPortfolioHistory
+// @Description 查詢資產總值的定期快照（依時間排序）：1d、7d 使用每小時快照，30d、90d、1y、all 使用每日快照（UTC 00:00）
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	range			query	string	false	"範圍：1d, 7d, 30d, 90d, 1y 或 all（預設 7d）"
+// @Success 200 {array} models.PortfolioSnapshot
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @router /history [get]
+func (c *PortfolioController) GetPortfolioHistory() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 查詢快照（範圍由 service 驗證）
+	snapshots, granularity, err := services.GetPortfolioHistory(userId, c.GetString("range"))
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Failed to get portfolio history: "+err.Error())
+		return
+	}
+
+	// 3. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":     true,
+		"granularity": granularity,
+		"snapshots":   snapshots,
+		"count":       len(snapshots),
+	})
+}
@@ -0,0 +1,273 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/services"
+	"backend/utils"
+	"encoding/json"
+	"strconv"
+
+	"github.com/beego/beego/v2/server/web"
+)
+
+type PriceAlertController struct {
+	web.Controller
+}
+
+// PriceAlertRequest 建立或修改價格提醒請求
+type PriceAlertRequest struct {
+	Symbol          string  `json:"symbol" valid:"Required"`    // 交易對：BTCUSDT, ETHUSDT, SOLUSDT
+	Condition       string  `json:"condition" valid:"Required"` // ABOVE, BELOW, CROSS 或 PERCENT_CHANGE
+	TargetPrice     float64 `json:"targetPrice,omitempty"`      // 目標價（ABOVE、BELOW、CROSS）
+	ChangePercent   float64 `json:"changePercent,omitempty"`    // 漲跌幅百分比，正數為上漲、負數為下跌（PERCENT_CHANGE）
+	WindowSeconds   int     `json:"windowSeconds,omitempty"`    // 漲跌幅的時間窗口秒數（PERCENT_CHANGE，60 ~ 86400）
+	Repeat          bool    `json:"repeat"`                     // 是否重複提醒（預設觸發一次後結束）
+	CooldownSeconds int     `json:"cooldownSeconds,omitempty"`  // 重複提醒的最短間隔秒數
+	Webhook         bool    `json:"webhook"`                    // 觸發時是否寫入 PRICE_ALERT webhook 事件（投遞到訂閱該事件的 webhook 端點）
+	Note            string  `json:"note,omitempty"`             // 備註（隨通知送出）
+}
+
+// params 轉換為 service 參數
+func (req *PriceAlertRequest) params() models.PriceAlertParams {
+	return models.PriceAlertParams{
+		Symbol:          req.Symbol,
+		Condition:       models.PriceAlertCondition(req.Condition),
+		TargetPrice:     req.TargetPrice,
+		ChangePercent:   req.ChangePercent,
+		WindowSeconds:   req.WindowSeconds,
+		Repeat:          req.Repeat,
+		CooldownSeconds: req.CooldownSeconds,
+		Webhook:         req.Webhook,
+		Note:            req.Note,
+	}
+}
+
+// CreatePriceAlert 建立價格提醒
+// @Title CreatePriceAlert
+// @Description 建立價格提醒，每筆成交價格更新時檢查，觸發時推送 PRICE_ALERT 消息，設定 webhook 時另寫入 PRICE_ALERT webhook 事件，以簽章後的 POST 投遞到訂閱該事件的 webhook 端點（失敗時重試）
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	body			body	PriceAlertRequest	true	"提醒設定"
+// @Success 200 {object} models.PriceAlert
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @router / [post]
+func (c *PriceAlertController) CreatePriceAlert() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析請求
+	var req PriceAlertRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid request body")
+		return
+	}
+
+	// 3. 建立提醒（參數由 service 驗證）
+	alert, err := services.GlobalPriceAlertService.CreatePriceAlert(userId, req.params())
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Failed to create price alert: "+err.Error())
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":    true,
+		"message":    "Price alert created successfully",
+		"priceAlert": alert,
+	})
+}
+
+// GetPriceAlerts 查詢所有價格提醒
+// @Title GetPriceAlerts
+// @Description 查詢使用者的價格提醒（由新到舊）
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	status			query	string	false	"狀態篩選：ACTIVE 或 TRIGGERED"
+// @Param	limit			query	int		false	"每頁數量（預設20）"
+// @Param	offset			query	int		false	"偏移量（預設0）"
+// @Success 200 {array} models.PriceAlert
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 500 Internal server error
+// @router / [get]
+func (c *PriceAlertController) GetPriceAlerts() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析查詢參數
+	status := models.PriceAlertStatus(c.GetString("status"))
+	if status != "" && status != models.PriceAlertStatusActive && status != models.PriceAlertStatusTriggered {
+		utils.RespondError(c.Ctx, 400, "Invalid status, must be ACTIVE or TRIGGERED")
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.GetString("limit", "20"))
+	offset, _ := strconv.Atoi(c.GetString("offset", "0"))
+
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	// 3. 查詢提醒
+	alerts, err := models.GetPriceAlertsByUser(userId, status, limit, offset)
+	if err != nil {
+		utils.RespondError(c.Ctx, 500, "Failed to get price alerts: "+err.Error())
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":     true,
+		"priceAlerts": alerts,
+		"count":       len(alerts),
+	})
+}
+
+// GetPriceAlert 查詢單一價格提醒
+// @Title GetPriceAlert
+// @Description 查詢價格提醒的設定、觸發次數與最近一次 webhook 通知的結果
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"提醒 ID"
+// @Success 200 {object} models.PriceAlert
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Price alert not found
+// @router /:id [get]
+func (c *PriceAlertController) GetPriceAlert() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析提醒 ID
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid price alert ID")
+		return
+	}
+
+	// 3. 查詢提醒
+	alert, err := services.GetPriceAlert(userId, id)
+	if err != nil {
+		c.respondError(err, "Failed to get price alert: ")
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":    true,
+		"priceAlert": alert,
+	})
+}
+
+// UpdatePriceAlert 修改價格提醒
+// @Title UpdatePriceAlert
+// @Description 修改提醒的條件與通知方式，並重新開始監控（已觸發的單次提醒會再次啟用）
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"提醒 ID"
+// @Param	body			body	PriceAlertRequest	true	"提醒設定"
+// @Success 200 {object} models.PriceAlert
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Price alert not found
+// @router /:id [put]
+func (c *PriceAlertController) UpdatePriceAlert() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析提醒 ID 與請求
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid price alert ID")
+		return
+	}
+
+	var req PriceAlertRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid request body")
+		return
+	}
+
+	// 3. 修改提醒
+	alert, err := services.GlobalPriceAlertService.UpdatePriceAlert(userId, id, req.params())
+	if err != nil {
+		c.respondError(err, "Failed to update price alert: ")
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":    true,
+		"message":    "Price alert updated successfully",
+		"priceAlert": alert,
+	})
+}
+
+// DeletePriceAlert 刪除價格提醒
+// @Title DeletePriceAlert
+// @Description 刪除價格提醒，重試中的 webhook 通知仍會送出
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"提醒 ID"
+// @Success 200 {string} string "Price alert deleted successfully"
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Price alert not found
+// @router /:id [delete]
+func (c *PriceAlertController) DeletePriceAlert() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析提醒 ID
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid price alert ID")
+		return
+	}
+
+	// 3. 刪除提醒
+	if err = services.GlobalPriceAlertService.DeletePriceAlert(userId, id); err != nil {
+		c.respondError(err, "Failed to delete price alert: ")
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success": true,
+		"message": "Price alert deleted successfully",
+	})
+}
+
+// respondError 將提醒相關錯誤轉換為 HTTP 狀態碼（其他錯誤多為參數驗證錯誤）
+func (c *PriceAlertController) respondError(err error, prefix string) {
+	switch err.Error() {
+	case "unauthorized: price alert does not belong to user":
+		utils.RespondError(c.Ctx, 403, err.Error())
+	case "price alert not found":
+		utils.RespondError(c.Ctx, 404, err.Error())
+	default:
+		utils.RespondError(c.Ctx, 400, prefix+err.Error())
+	}
+}
@@ -0,0 +1,372 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/services"
+	"backend/utils"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/beego/beego/v2/server/web"
+)
+
+type RecurringBuyController struct {
+	web.Controller
+}
+
+// RecurringBuyRequest 建立或修改定期定額請求
+type RecurringBuyRequest struct {
+	Symbol          string     `json:"symbol" valid:"Required"`      // 交易對：BTCUSDT, ETHUSDT, SOLUSDT
+	QuoteAmount     float64    `json:"quoteAmount" valid:"Required"` // 每次花費的 USDT 金額
+	Cadence         string     `json:"cadence" valid:"Required"`     // INTERVAL 或 CRON
+	IntervalSeconds int        `json:"intervalSeconds,omitempty"`    // 固定間隔秒數（INTERVAL，至少 60）
+	CronExpr        string     `json:"cronExpr,omitempty"`           // cron 表示式（CRON，UTC，例如 "0 9 * * 1" 為每週一 09:00）
+	StartAt         *time.Time `json:"startAt,omitempty"`            // 固定間隔的起點（不填為現在）
+	CatchUp         string     `json:"catchUp,omitempty"`            // 停機期間錯過的排程：SKIP 全部略過，RUN_ONCE 補買一次（預設）
+}
+
+// params 轉換為 service 參數
+func (req *RecurringBuyRequest) params() models.RecurringBuyParams {
+	catchUp := models.CatchUpPolicy(req.CatchUp)
+	if catchUp == "" {
+		catchUp = models.CatchUpPolicyRunOnce
+	}
+	return models.RecurringBuyParams{
+		Symbol:          req.Symbol,
+		QuoteAmount:     req.QuoteAmount,
+		Cadence:         models.RecurringBuyCadence(req.Cadence),
+		IntervalSeconds: req.IntervalSeconds,
+		CronExpr:        req.CronExpr,
+		StartAt:         req.StartAt,
+		CatchUp:         catchUp,
+	}
+}
+
+// CreateRecurringBuy 建立定期定額
+// @Title CreateRecurringBuy
+// @Description 建立定期定額買入排程，每次到期以市價單花費固定的 USDT 買入
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	body			body	RecurringBuyRequest	true	"排程設定"
+// @Success 200 {object} models.RecurringBuy
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @router / [post]
+func (c *RecurringBuyController) CreateRecurringBuy() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析請求
+	var req RecurringBuyRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid request body")
+		return
+	}
+
+	// 3. 建立排程（參數由 service 驗證）
+	schedule, err := services.CreateRecurringBuy(userId, req.params())
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Failed to create recurring buy: "+err.Error())
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":      true,
+		"message":      "Recurring buy created successfully",
+		"recurringBuy": schedule,
+	})
+}
+
+// GetRecurringBuys 查詢所有定期定額
+// @Title GetRecurringBuys
+// @Description 查詢使用者的所有定期定額排程
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Success 200 {array} models.RecurringBuy
+// @Failure 401 Unauthorized
+// @Failure 500 Internal server error
+// @router / [get]
+func (c *RecurringBuyController) GetRecurringBuys() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 查詢排程
+	schedules, err := models.GetRecurringBuysByUser(userId)
+	if err != nil {
+		utils.RespondError(c.Ctx, 500, "Failed to get recurring buys: "+err.Error())
+		return
+	}
+
+	// 3. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":       true,
+		"recurringBuys": schedules,
+		"count":         len(schedules),
+	})
+}
+
+// GetRecurringBuy 查詢單一定期定額
+// @Title GetRecurringBuy
+// @Description 查詢定期定額排程
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"排程 ID"
+// @Success 200 {object} models.RecurringBuy
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Recurring buy not found
+// @router /:id [get]
+func (c *RecurringBuyController) GetRecurringBuy() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析排程 ID
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid recurring buy ID")
+		return
+	}
+
+	// 3. 查詢排程
+	schedule, err := services.GetRecurringBuy(userId, id)
+	if err != nil {
+		c.respondError(err, "Failed to get recurring buy: ")
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":      true,
+		"recurringBuy": schedule,
+	})
+}
+
+// UpdateRecurringBuy 修改定期定額
+// @Title UpdateRecurringBuy
+// @Description 修改排程的交易對、金額、排程方式與補買規則，啟用中的排程從現在重新計算下一次時間
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"排程 ID"
+// @Param	body			body	RecurringBuyRequest	true	"排程設定"
+// @Success 200 {object} models.RecurringBuy
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Recurring buy not found
+// @router /:id [put]
+func (c *RecurringBuyController) UpdateRecurringBuy() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析排程 ID 與請求
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid recurring buy ID")
+		return
+	}
+
+	var req RecurringBuyRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid request body")
+		return
+	}
+
+	// 3. 修改排程
+	schedule, err := services.UpdateRecurringBuy(userId, id, req.params())
+	if err != nil {
+		c.respondError(err, "Failed to update recurring buy: ")
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":      true,
+		"message":      "Recurring buy updated successfully",
+		"recurringBuy": schedule,
+	})
+}
+
+// DeleteRecurringBuy 刪除定期定額
+// @Title DeleteRecurringBuy
+// @Description 刪除排程與執行記錄，已成交的訂單不受影響
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"排程 ID"
+// @Success 200 {string} string "Recurring buy deleted successfully"
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Recurring buy not found
+// @router /:id [delete]
+func (c *RecurringBuyController) DeleteRecurringBuy() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析排程 ID
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid recurring buy ID")
+		return
+	}
+
+	// 3. 刪除排程
+	if err = services.DeleteRecurringBuy(userId, id); err != nil {
+		c.respondError(err, "Failed to delete recurring buy: ")
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success": true,
+		"message": "Recurring buy deleted successfully",
+	})
+}
+
+// PauseRecurringBuy 暫停定期定額
+// @Title PauseRecurringBuy
+// @Description 暫停排程，暫停期間的排程不會補買
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"排程 ID"
+// @Success 200 {object} models.RecurringBuy
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Recurring buy not found
+// @router /:id/pause [post]
+func (c *RecurringBuyController) PauseRecurringBuy() {
+	c.updateStatus(services.PauseRecurringBuy, "Recurring buy paused successfully")
+}
+
+// ResumeRecurringBuy 恢復定期定額
+// @Title ResumeRecurringBuy
+// @Description 恢復已暫停的排程，從現在開始計算下一次時間
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"排程 ID"
+// @Success 200 {object} models.RecurringBuy
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Recurring buy not found
+// @router /:id/resume [post]
+func (c *RecurringBuyController) ResumeRecurringBuy() {
+	c.updateStatus(services.ResumeRecurringBuy, "Recurring buy resumed successfully")
+}
+
+// GetRecurringBuyRuns 查詢定期定額的執行記錄
+// @Title GetRecurringBuyRuns
+// @Description 查詢排程每次執行的結果（成交、失敗原因或停機期間略過）
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"排程 ID"
+// @Param	limit			query	int		false	"每頁數量（預設20）"
+// @Param	offset			query	int		false	"偏移量（預設0）"
+// @Success 200 {array} models.RecurringBuyRun
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Recurring buy not found
+// @router /:id/runs [get]
+func (c *RecurringBuyController) GetRecurringBuyRuns() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析排程 ID 與查詢參數
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid recurring buy ID")
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.GetString("limit", "20"))
+	offset, _ := strconv.Atoi(c.GetString("offset", "0"))
+
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	// 3. 檢查所有權並查詢執行記錄
+	if _, err = services.GetRecurringBuy(userId, id); err != nil {
+		c.respondError(err, "Failed to get recurring buy runs: ")
+		return
+	}
+
+	runs, err := models.GetRecurringBuyRuns(id, limit, offset)
+	if err != nil {
+		utils.RespondError(c.Ctx, 500, "Failed to get recurring buy runs: "+err.Error())
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success": true,
+		"runs":    runs,
+		"count":   len(runs),
+	})
+}
+
+// updateStatus 暫停、恢復排程的共用流程
+func (c *RecurringBuyController) updateStatus(update func(userId int64, id int64) (*models.RecurringBuy, error), message string) {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析排程 ID
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid recurring buy ID")
+		return
+	}
+
+	// 3. 更新排程
+	schedule, err := update(userId, id)
+	if err != nil {
+		c.respondError(err, "Failed to update recurring buy: ")
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":      true,
+		"message":      message,
+		"recurringBuy": schedule,
+	})
+}
+
+// respondError 將排程相關錯誤轉換為 HTTP 狀態碼（其他錯誤多為參數驗證錯誤）
+func (c *RecurringBuyController) respondError(err error, prefix string) {
+	switch err.Error() {
+	case "unauthorized: recurring buy does not belong to user":
+		utils.RespondError(c.Ctx, 403, err.Error())
+	case "recurring buy not found":
+		utils.RespondError(c.Ctx, 404, err.Error())
+	default:
+		utils.RespondError(c.Ctx, 400, prefix+err.Error())
+	}
+}
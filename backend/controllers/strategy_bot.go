@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/services"
+	"backend/strategy"
+	"backend/utils"
+	"encoding/json"
+	"strconv"
+
+	"github.com/beego/beego/v2/server/web"
+)
+
+type StrategyBotController struct {
+	web.Controller
+}
+
+// StrategyBotRequest 啟動策略機器人請求
+type StrategyBotRequest struct {
+	Strategy            string             `json:"strategy" valid:"Required"` // 策略名稱（見 GET /v1/strategy-bots/strategies）
+	Symbol              string             `json:"symbol" valid:"Required"`   // 交易對：BTCUSDT, ETHUSDT, SOLUSDT
+	Interval            string             `json:"interval"`                  // 呼叫 OnCandle 的 K 線週期（預設 1m）
+	Params              map[string]float64 `json:"params"`                    // 策略參數
+	MaxOrderNotional    float64            `json:"maxOrderNotional"`          // 單筆訂單的最大名目價值（0 表示不限制）
+	MaxPositionNotional float64            `json:"maxPositionNotional"`       // 交易對的最大曝險（0 表示不限制）
+	MaxLeverage         int                `json:"maxLeverage"`               // 最大槓桿倍數（0 表示不限制）
+	MaxDailyLoss        float64            `json:"maxDailyLoss"`              // 單日最大虧損，達到後停止機器人（0 表示不限制）
+}
+
+// StartStrategyBot 啟動策略機器人
+// @Title StartStrategyBot
+// @Description 以使用者的帳戶在伺服器內執行已註冊的策略（與回測相同），策略收到即時價格、K 線收盤、成交與持倉變更事件，下單前檢查風險限制
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	body			body	StrategyBotRequest	true	"策略設定"
+// @Success 200 {object} models.StrategyBot
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @router / [post]
+func (c *StrategyBotController) StartStrategyBot() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析請求
+	var req StrategyBotRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid request body")
+		return
+	}
+
+	// 3. 啟動機器人（參數由 service 驗證）
+	bot, err := services.GlobalStrategyBotService.StartStrategyBot(userId, &models.StrategyBot{
+		Strategy:            req.Strategy,
+		Symbol:              req.Symbol,
+		Interval:            req.Interval,
+		StrategyParams:      req.Params,
+		MaxOrderNotional:    req.MaxOrderNotional,
+		MaxPositionNotional: req.MaxPositionNotional,
+		MaxLeverage:         req.MaxLeverage,
+		MaxDailyLoss:        req.MaxDailyLoss,
+	})
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Failed to start strategy bot: "+err.Error())
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":     true,
+		"message":     "Strategy bot started successfully",
+		"strategyBot": bot,
+	})
+}
+
+// GetStrategyBots 查詢所有策略機器人
+// @Title GetStrategyBots
+// @Description 查詢使用者的策略機器人（由新到舊）
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	limit			query	int		false	"每頁數量（預設20）"
+// @Param	offset			query	int		false	"偏移量（預設0）"
+// @Success 200 {array} models.StrategyBot
+// @Failure 401 Unauthorized
+// @Failure 500 Internal server error
+// @router / [get]
+func (c *StrategyBotController) GetStrategyBots() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析查詢參數
+	limit, _ := strconv.Atoi(c.GetString("limit", "20"))
+	offset, _ := strconv.Atoi(c.GetString("offset", "0"))
+
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	// 3. 查詢機器人
+	bots, err := models.GetStrategyBotsByUser(userId, limit, offset)
+	if err != nil {
+		utils.RespondError(c.Ctx, 500, "Failed to get strategy bots: "+err.Error())
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":      true,
+		"strategyBots": bots,
+		"count":        len(bots),
+	})
+}
+
+// GetStrategies 查詢可執行的策略
+// @Title GetStrategies
+// @Description 查詢已註冊的策略名稱（回測與策略機器人共用）
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Success 200 {array} string
+// @Failure 401 Unauthorized
+// @router /strategies [get]
+func (c *StrategyBotController) GetStrategies() {
+	// 1. 驗證 JWT
+	if _, err := utils.ValidateJWT(c.Ctx.Request); err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":    true,
+		"strategies": strategy.Names(),
+	})
+}
+
+// GetStrategyBot 查詢單一策略機器人
+// @Title GetStrategyBot
+// @Description 查詢策略機器人的狀態、風險限制、下單記錄、目前的掛單與最近處理的價格
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"機器人 ID"
+// @Success 200 {object} models.StrategyBot
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Strategy bot not found
+// @router /:id [get]
+func (c *StrategyBotController) GetStrategyBot() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析機器人 ID
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid strategy bot ID")
+		return
+	}
+
+	// 3. 查詢機器人
+	bot, err := services.GlobalStrategyBotService.GetStrategyBot(userId, id)
+	if err != nil {
+		c.respondError(err, "Failed to get strategy bot: ")
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":     true,
+		"strategyBot": bot,
+	})
+}
+
+// StopStrategyBot 停止策略機器人
+// @Title StopStrategyBot
+// @Description 停止策略機器人並取消其掛單，已成交的現貨與槓桿持倉保留
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"機器人 ID"
+// @Success 200 {object} models.StrategyBot
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Strategy bot not found
+// @router /:id/stop [post]
+func (c *StrategyBotController) StopStrategyBot() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析機器人 ID
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid strategy bot ID")
+		return
+	}
+
+	// 3. 停止機器人
+	bot, err := services.GlobalStrategyBotService.StopStrategyBot(userId, id)
+	if err != nil {
+		c.respondError(err, "Failed to stop strategy bot: ")
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":     true,
+		"message":     "Strategy bot stopped successfully",
+		"strategyBot": bot,
+	})
+}
+
+// respondError 將機器人相關錯誤轉換為 HTTP 狀態碼
+func (c *StrategyBotController) respondError(err error, prefix string) {
+	switch err.Error() {
+	case "unauthorized: strategy bot does not belong to user":
+		utils.RespondError(c.Ctx, 403, err.Error())
+	case "strategy bot not found":
+		utils.RespondError(c.Ctx, 404, err.Error())
+	default:
+		utils.RespondError(c.Ctx, 400, prefix+err.Error())
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"backend/services"
 	"backend/utils"
 	"encoding/json"
+	"errors"
 	"strconv"
 
 	"github.com/beego/beego/v2/server/web"
@@ -16,21 +17,73 @@ type TradingController struct {
 
 // PlaceOrderRequest 下單請求
 type PlaceOrderRequest struct {
-	Symbol     string   `json:"symbol" valid:"Required"`   // 交易對：BTCUSDT, ETHUSDT, SOLUSDT
-	Type       string   `json:"type" valid:"Required"`     // MARKET 或 LIMIT
-	Side       string   `json:"side" valid:"Required"`     // BUY 或 SELL
-	Quantity   float64  `json:"quantity" valid:"Required"` // 數量
-	LimitPrice *float64 `json:"limitPrice,omitempty"`      // 限價（僅限價單需要）
+	Symbol          string   `json:"symbol" valid:"Required"`   // 交易對：BTCUSDT, ETHUSDT, SOLUSDT
+	Type            string   `json:"type" valid:"Required"`     // MARKET、LIMIT 或 TRAILING_STOP
+	Side            string   `json:"side" valid:"Required"`     // BUY 或 SELL（TRAILING_STOP 僅支援 SELL）
+	Quantity        float64  `json:"quantity" valid:"Required"` // 數量
+	LimitPrice      *float64 `json:"limitPrice,omitempty"`      // 限價（僅限價單需要）
+	CallbackRate    float64  `json:"callbackRate,omitempty"`    // 回撤比例（僅追蹤停損單，與 callbackOffset 擇一）
+	CallbackOffset  float64  `json:"callbackOffset,omitempty"`  // 回撤金額（僅追蹤停損單，與 callbackRate 擇一）
+	ActivationPrice float64  `json:"activationPrice,omitempty"` // 啟動價格（僅追蹤停損單，不填則立即開始追蹤）
+	ClientOrderId   string   `json:"clientOrderId,omitempty"`   // 使用者自訂的訂單 ID（同一使用者唯一，重送時返回原本的結果）
 }
 
-// PlaceOrder 下單（支援市價單和限價單）
+// AmendOrderRequest 修改訂單請求（至少填寫一項）
+type AmendOrderRequest struct {
+	LimitPrice *float64 `json:"limitPrice,omitempty"` // 新的限價
+	Quantity   *float64 `json:"quantity,omitempty"`   // 新的數量
+}
+
+// CancelAllOrdersRequest 全部取消請求（篩選條件皆為選填）
+type CancelAllOrdersRequest struct {
+	Symbol string `json:"symbol,omitempty"` // 交易對
+	Side   string `json:"side,omitempty"`   // BUY 或 SELL
+	Type   string `json:"type,omitempty"`   // LIMIT、STOP 或 TRAILING_STOP
+}
+
+// BatchCancelOrdersRequest 批次取消請求
+type BatchCancelOrdersRequest struct {
+	OrderIds []int64 `json:"orderIds" valid:"Required"` // 訂單 ID 列表（最多 100 筆）
+}
+
+// DeadMansSwitchRequest 自動撤單設定請求（不填的欄位不修改）
+type DeadMansSwitchRequest struct {
+	CancelOnDisconnect *bool `json:"cancelOnDisconnect,omitempty"` // 最後一個 WebSocket 斷線時取消所有限價單
+	CountdownSeconds   *int  `json:"countdownSeconds,omitempty"`   // 倒數秒數，結束前需再次設定，0 表示關閉倒數
+}
+
+// PlaceOrderListRequest OCO 訂單組請求
+type PlaceOrderListRequest struct {
+	Symbol     string  `json:"symbol" valid:"Required"`     // 交易對：BTCUSDT, ETHUSDT, SOLUSDT
+	Side       string  `json:"side" valid:"Required"`       // BUY 或 SELL（兩個子訂單相同方向）
+	Quantity   float64 `json:"quantity" valid:"Required"`   // 數量
+	LimitPrice float64 `json:"limitPrice" valid:"Required"` // 限價子訂單價格（賣出為止盈價，需高於市價）
+	StopPrice  float64 `json:"stopPrice" valid:"Required"`  // 停損子訂單觸發價格（賣出需低於市價，觸發後以市價成交）
+}
+
+// PlaceAlgoOrderRequest 演算法母單請求
+type PlaceAlgoOrderRequest struct {
+	Symbol          string  `json:"symbol" valid:"Required"`   // 交易對：BTCUSDT, ETHUSDT, SOLUSDT
+	Algo            string  `json:"algo" valid:"Required"`     // TWAP 或 ICEBERG
+	Side            string  `json:"side" valid:"Required"`     // BUY 或 SELL
+	Quantity        float64 `json:"quantity" valid:"Required"` // 總數量（TWAP 買入為花費的 USDT 金額，與市價單相同）
+	DurationSeconds int     `json:"durationSeconds,omitempty"` // TWAP 執行期間（秒）
+	IntervalSeconds int     `json:"intervalSeconds,omitempty"` // TWAP 子訂單間隔（秒，至少 5）
+	Randomize       float64 `json:"randomize,omitempty"`       // TWAP 數量與間隔的隨機幅度（0~0.5）
+	LimitPrice      float64 `json:"limitPrice,omitempty"`      // 冰山單限價
+	VisibleQuantity float64 `json:"visibleQuantity,omitempty"` // 冰山單每次掛出的數量
+}
+
+// PlaceOrder 下單（支援市價單、限價單和追蹤停損賣單）
 // @Title PlaceOrder
-// @Description 執行市價單或限價單買入/賣出
+// @Description 執行市價單或限價單買入/賣出，或掛出追蹤停損賣單（追蹤最高價，回撤超過設定值時以市價賣出）
 // @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	Idempotency-Key	header	string	false	"冪等 Key（重送時返回原本的結果）"
 // @Param	body			body	PlaceOrderRequest	true	"訂單資訊"
 // @Success 200 {object} models.Order
 // @Failure 400 Bad request
 // @Failure 401 Unauthorized
+// @Failure 409 Duplicate clientOrderId or request still being processed
 // @Failure 500 Internal server error
 // @router /order [post]
 func (c *TradingController) PlaceOrder() {
@@ -64,35 +117,66 @@ func (c *TradingController) PlaceOrder() {
 		return
 	}
 
-	var order *models.Order
+	if req.Type == "LIMIT" && (req.LimitPrice == nil || *req.LimitPrice <= 0) {
+		utils.RespondError(c.Ctx, 400, "Limit price is required and must be positive for limit orders")
+		return
+	}
 
-	// 4. 根據訂單類型執行
-	if req.Type == "MARKET" {
-		// 市價單
-		order, err = services.PlaceMarketOrder(userId, req.Symbol, side, req.Quantity)
-	} else if req.Type == "LIMIT" {
-		// 限價單
-		if req.LimitPrice == nil || *req.LimitPrice <= 0 {
-			utils.RespondError(c.Ctx, 400, "Limit price is required and must be positive for limit orders")
-			return
-		}
-		order, err = services.PlaceLimitOrder(userId, req.Symbol, side, req.Quantity, *req.LimitPrice)
-	} else {
-		utils.RespondError(c.Ctx, 400, "Invalid order type, must be MARKET or LIMIT")
+	if req.Type == "TRAILING_STOP" && side != models.OrderSideSell {
+		// 追蹤停損單（現貨只支援賣出）
+		utils.RespondError(c.Ctx, 400, "Trailing stop orders only support SELL")
 		return
 	}
 
-	if err != nil {
-		utils.RespondError(c.Ctx, 400, "Failed to place order: "+err.Error())
+	if req.Type != "MARKET" && req.Type != "LIMIT" && req.Type != "TRAILING_STOP" {
+		utils.RespondError(c.Ctx, 400, "Invalid order type, must be MARKET, LIMIT or TRAILING_STOP")
 		return
 	}
 
-	// 5. 返回結果
-	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+	// 4. 根據訂單類型執行（帶有 Idempotency-Key 或 clientOrderId 的重送請求返回原本的結果）
+	respondIdempotent(c.Ctx, userId, "trading.order", req.ClientOrderId, func(keys []string) (int, map[string]interface{}) {
+		var order *models.Order
+		if req.Type == "MARKET" {
+			// 市價單
+			order, err = services.PlaceMarketOrder(userId, req.Symbol, side, req.Quantity, req.ClientOrderId, keys)
+		} else if req.Type == "LIMIT" {
+			// 限價單
+			order, err = services.PlaceLimitOrder(userId, req.Symbol, side, req.Quantity, *req.LimitPrice, req.ClientOrderId, keys)
+		} else {
+			// 追蹤停損單
+			order, err = services.PlaceTrailingStopOrder(userId, req.Symbol, req.Quantity, models.TrailingStopParams{
+				CallbackRate:    req.CallbackRate,
+				CallbackOffset:  req.CallbackOffset,
+				ActivationPrice: req.ActivationPrice,
+			}, req.ClientOrderId, keys)
+		}
+
+		if errors.Is(err, models.ErrDuplicateClientOrderId) {
+			return 409, errorBody("Duplicate clientOrderId")
+		}
+		if err != nil {
+			return 400, errorBody("Failed to place order: " + err.Error())
+		}
+
+		// 5. 返回結果
+		return placeOrderResult(order)
+	}, func(orderId int64) (int, map[string]interface{}) {
+		// 原始請求已建立訂單但未保存回應：返回訂單目前的狀態
+		order, err := models.GetOrderById(orderId)
+		if err != nil {
+			return 500, errorBody("Failed to get order: " + err.Error())
+		}
+		return placeOrderResult(order)
+	})
+}
+
+// placeOrderResult 下單成功的回應
+func placeOrderResult(order *models.Order) (int, map[string]interface{}) {
+	return 200, map[string]interface{}{
 		"success": true,
 		"message": "Order placed successfully",
 		"order":   order,
-	})
+	}
 }
 
 // GetOrders 查詢使用者訂單
@@ -134,6 +218,10 @@ func (c *TradingController) GetOrders() {
 		orders, err = models.GetOrdersByUser(userId, limit, offset)
 	}
 
+	if err == nil {
+		err = models.LoadOrderAmendments(orders)
+	}
+
 	if err != nil {
 		utils.RespondError(c.Ctx, 500, "Failed to get orders: "+err.Error())
 		return
@@ -253,9 +341,69 @@ func (c *TradingController) GetPrices() {
 	})
 }
 
+// AmendOrder 修改訂單
+// @Title AmendOrder
+// @Description 修改待處理限價單的價格和/或數量，保留原訂單並記錄修改歷史（OCO 子訂單、演算法母單與機器人的訂單不可修改）
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"訂單 ID"
+// @Param	body			body	AmendOrderRequest	true	"新的限價和/或數量"
+// @Success 200 {object} models.Order
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Order not found
+// @router /order/:id [put]
+func (c *TradingController) AmendOrder() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析訂單 ID
+	orderId, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid order ID")
+		return
+	}
+
+	// 3. 解析請求
+	var req AmendOrderRequest
+	if err = json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid request body")
+		return
+	}
+
+	if req.LimitPrice == nil && req.Quantity == nil {
+		utils.RespondError(c.Ctx, 400, "Limit price or quantity is required")
+		return
+	}
+
+	// 4. 修改訂單（與撮合器使用同一個訂單鎖）
+	order, err := services.AmendOrder(userId, orderId, req.LimitPrice, req.Quantity)
+	if err != nil {
+		if err.Error() == "unauthorized: order does not belong to user" {
+			utils.RespondError(c.Ctx, 403, err.Error())
+		} else if err.Error() == "order not found" {
+			utils.RespondError(c.Ctx, 404, err.Error())
+		} else {
+			utils.RespondError(c.Ctx, 400, "Failed to amend order: "+err.Error())
+		}
+		return
+	}
+
+	// 5. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success": true,
+		"message": "Order amended successfully",
+		"order":   order,
+	})
+}
+
 // CancelOrder 取消訂單
 // @Title CancelOrder
-// @Description 取消待處理的訂單（僅限價單可取消）
+// @Description 取消待處理的訂單，OCO 子訂單會取消整個訂單組
 // @Param	Authorization	header	string	true	"Bearer {token}"
 // @Param	id				path	int		true	"訂單 ID"
 // @Success 200 {string} string "Order canceled successfully"
@@ -279,8 +427,8 @@ func (c *TradingController) CancelOrder() {
 		return
 	}
 
-	// 3. 取消訂單
-	err = models.CancelOrder(orderId, userId)
+	// 3. 取消訂單（同時從撮合器移除並解除凍結的保證金）
+	err = services.CancelOrder(userId, orderId)
 	if err != nil {
 		if err.Error() == "unauthorized: order does not belong to user" {
 			utils.RespondError(c.Ctx, 403, err.Error())
@@ -292,12 +440,733 @@ func (c *TradingController) CancelOrder() {
 		return
 	}
 
-	// 4. 從撮合器中移除
-	services.GlobalLimitOrderMatcher.RemoveOrder(orderId)
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success": true,
+		"message": "Order canceled successfully",
+	})
+}
+
+// GetOrderEvents 查詢訂單的狀態變更記錄
+// @Title GetOrderEvents
+// @Description 查詢訂單從建立到成交、失敗或取消的狀態變更記錄（由舊到新）
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"訂單 ID"
+// @Success 200 {array} models.OrderEvent
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 404 Order not found
+// @router /order/:id/events [get]
+func (c *TradingController) GetOrderEvents() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析訂單 ID
+	orderId, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid order ID")
+		return
+	}
+
+	// 3. 檢查訂單所有權（其他使用者的訂單視為不存在）
+	order, err := models.GetOrderById(orderId)
+	if err != nil || order.User.Id != userId {
+		utils.RespondError(c.Ctx, 404, "Order not found")
+		return
+	}
+
+	// 4. 查詢狀態變更記錄
+	events, err := models.GetOrderEvents(orderId)
+	if err != nil {
+		utils.RespondError(c.Ctx, 500, "Failed to get order events: "+err.Error())
+		return
+	}
 
 	// 5. 返回結果
 	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
 		"success": true,
-		"message": "Order canceled successfully",
+		"orderId": orderId,
+		"status":  order.Status,
+		"events":  events,
+	})
+}
+
+// PlaceOrderList 下 OCO 訂單組
+// @Title PlaceOrderList
+// @Description 同時掛出限價單與停損單（OCO），其中一個成交時自動取消另一個
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	body			body	PlaceOrderListRequest	true	"訂單組資訊"
+// @Success 200 {object} models.OrderList
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @router /order-list [post]
+func (c *TradingController) PlaceOrderList() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析請求
+	var req PlaceOrderListRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid request body")
+		return
+	}
+
+	// 3. 驗證輸入
+	if req.Quantity <= 0 {
+		utils.RespondError(c.Ctx, 400, "Quantity must be positive")
+		return
+	}
+
+	if req.LimitPrice <= 0 || req.StopPrice <= 0 {
+		utils.RespondError(c.Ctx, 400, "Limit price and stop price must be positive")
+		return
+	}
+
+	var side models.OrderSide
+	if req.Side == "BUY" {
+		side = models.OrderSideBuy
+	} else if req.Side == "SELL" {
+		side = models.OrderSideSell
+	} else {
+		utils.RespondError(c.Ctx, 400, "Invalid side, must be BUY or SELL")
+		return
+	}
+
+	// 4. 建立訂單組
+	list, err := services.PlaceOCOOrder(userId, req.Symbol, side, req.Quantity, req.LimitPrice, req.StopPrice)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Failed to place order list: "+err.Error())
+		return
+	}
+
+	// 5. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":   true,
+		"message":   "Order list placed successfully",
+		"orderList": list,
+	})
+}
+
+// GetOrderLists 查詢使用者的訂單組
+// @Title GetOrderLists
+// @Description 查詢使用者的 OCO 訂單組與子訂單狀態
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	limit			query	int		false	"每頁數量（預設20）"
+// @Param	offset			query	int		false	"偏移量（預設0）"
+// @Success 200 {array} models.OrderList
+// @Failure 401 Unauthorized
+// @Failure 500 Internal server error
+// @router /order-lists [get]
+func (c *TradingController) GetOrderLists() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析查詢參數
+	limit, _ := strconv.Atoi(c.GetString("limit", "20"))
+	offset, _ := strconv.Atoi(c.GetString("offset", "0"))
+
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	// 3. 查詢訂單組
+	lists, err := models.GetOrderListsByUser(userId, limit, offset)
+	if err != nil {
+		utils.RespondError(c.Ctx, 500, "Failed to get order lists: "+err.Error())
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":    true,
+		"orderLists": lists,
+		"count":      len(lists),
+	})
+}
+
+// GetOrderList 查詢單一訂單組
+// @Title GetOrderList
+// @Description 查詢 OCO 訂單組與子訂單狀態
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"訂單組 ID"
+// @Success 200 {object} models.OrderList
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Order list not found
+// @router /order-list/:id [get]
+func (c *TradingController) GetOrderList() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析訂單組 ID
+	orderListId, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid order list ID")
+		return
+	}
+
+	// 3. 查詢訂單組
+	list, err := services.GetOrderList(userId, orderListId)
+	if err != nil {
+		if err.Error() == "unauthorized: order list does not belong to user" {
+			utils.RespondError(c.Ctx, 403, err.Error())
+		} else if err.Error() == "order list not found" {
+			utils.RespondError(c.Ctx, 404, err.Error())
+		} else {
+			utils.RespondError(c.Ctx, 500, "Failed to get order list: "+err.Error())
+		}
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":   true,
+		"orderList": list,
+	})
+}
+
+// CancelOrderList 取消訂單組
+// @Title CancelOrderList
+// @Description 取消 OCO 訂單組與所有待處理的子訂單
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"訂單組 ID"
+// @Success 200 {string} string "Order list canceled successfully"
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Order list not found
+// @router /order-list/:id/cancel [post]
+func (c *TradingController) CancelOrderList() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析訂單組 ID
+	orderListId, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid order list ID")
+		return
+	}
+
+	// 3. 取消訂單組（同時從撮合器移除子訂單）
+	err = services.CancelOrderList(userId, orderListId)
+	if err != nil {
+		if err.Error() == "unauthorized: order list does not belong to user" {
+			utils.RespondError(c.Ctx, 403, err.Error())
+		} else if err.Error() == "order list cannot be canceled" {
+			utils.RespondError(c.Ctx, 400, err.Error())
+		} else if err.Error() == "order list not found" {
+			utils.RespondError(c.Ctx, 404, err.Error())
+		} else {
+			utils.RespondError(c.Ctx, 500, "Failed to cancel order list: "+err.Error())
+		}
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success": true,
+		"message": "Order list canceled successfully",
 	})
 }
+
+// CancelAllOrders 取消所有訂單
+// @Title CancelAllOrders
+// @Description 取消使用者所有待處理的訂單，可依交易對、方向、類型篩選，返回每筆訂單的結果
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	body			body	CancelAllOrdersRequest	false	"篩選條件"
+// @Success 200 {array} services.OrderCancelResult
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 500 Internal server error
+// @router /orders/cancel-all [post]
+func (c *TradingController) CancelAllOrders() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析請求（可選）
+	var req CancelAllOrdersRequest
+	if len(c.Ctx.Input.RequestBody) > 0 {
+		if err = json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+			utils.RespondError(c.Ctx, 400, "Invalid request body")
+			return
+		}
+	}
+
+	// 3. 驗證篩選條件
+	filter := services.CancelOrderFilter{
+		Symbol: req.Symbol,
+		Side:   models.OrderSide(req.Side),
+		Type:   models.OrderType(req.Type),
+	}
+	if filter.Side != "" && filter.Side != models.OrderSideBuy && filter.Side != models.OrderSideSell {
+		utils.RespondError(c.Ctx, 400, "Invalid side, must be BUY or SELL")
+		return
+	}
+	if filter.Type != "" && filter.Type != models.OrderTypeLimit && filter.Type != models.OrderTypeStop && filter.Type != models.OrderTypeTrailingStop {
+		utils.RespondError(c.Ctx, 400, "Invalid order type, must be LIMIT, STOP or TRAILING_STOP")
+		return
+	}
+
+	// 4. 取消訂單
+	results, err := services.CancelAllOrders(userId, filter)
+	if err != nil {
+		utils.RespondError(c.Ctx, 500, "Failed to cancel orders: "+err.Error())
+		return
+	}
+
+	// 5. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success": true,
+		"results": results,
+		"count":   len(results),
+	})
+}
+
+// BatchCancelOrders 批次取消訂單
+// @Title BatchCancelOrders
+// @Description 依訂單 ID 列表逐筆取消，單筆失敗不影響其他訂單，返回每筆訂單的結果
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	body			body	BatchCancelOrdersRequest	true	"訂單 ID 列表"
+// @Success 200 {array} services.OrderCancelResult
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @router /orders/cancel-batch [post]
+func (c *TradingController) BatchCancelOrders() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析請求
+	var req BatchCancelOrdersRequest
+	if err = json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid request body")
+		return
+	}
+
+	if len(req.OrderIds) == 0 {
+		utils.RespondError(c.Ctx, 400, "Order IDs are required")
+		return
+	}
+	if len(req.OrderIds) > services.MaxBatchCancelOrders {
+		utils.RespondError(c.Ctx, 400, "Too many order IDs, at most 100 per request")
+		return
+	}
+
+	// 3. 逐筆取消
+	results := services.CancelOrders(userId, req.OrderIds)
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success": true,
+		"results": results,
+		"count":   len(results),
+	})
+}
+
+// GetDeadMansSwitch 查詢自動撤單設定
+// @Title GetDeadMansSwitch
+// @Description 查詢斷線撤單與倒數撤單的設定
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Success 200 {object} models.DeadMansSwitch
+// @Failure 401 Unauthorized
+// @Failure 500 Internal server error
+// @router /dead-mans-switch [get]
+func (c *TradingController) GetDeadMansSwitch() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 查詢設定
+	sw, err := services.GlobalDeadMansSwitch.Get(userId)
+	if err != nil {
+		utils.RespondError(c.Ctx, 500, "Failed to get dead man's switch: "+err.Error())
+		return
+	}
+
+	// 3. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":        true,
+		"deadMansSwitch": sw,
+	})
+}
+
+// SetDeadMansSwitch 設定自動撤單
+// @Title SetDeadMansSwitch
+// @Description 開啟或關閉斷線撤單（最後一個 WebSocket 斷開時取消所有限價單），或設定倒數撤單（倒數結束前需再次呼叫，否則取消所有限價單）
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	body			body	DeadMansSwitchRequest	true	"自動撤單設定"
+// @Success 200 {object} models.DeadMansSwitch
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @router /dead-mans-switch [post]
+func (c *TradingController) SetDeadMansSwitch() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析請求
+	var req DeadMansSwitchRequest
+	if err = json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid request body")
+		return
+	}
+
+	if req.CancelOnDisconnect == nil && req.CountdownSeconds == nil {
+		utils.RespondError(c.Ctx, 400, "cancelOnDisconnect or countdownSeconds is required")
+		return
+	}
+
+	// 3. 更新設定並重設倒數
+	sw, err := services.GlobalDeadMansSwitch.Configure(userId, req.CancelOnDisconnect, req.CountdownSeconds)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Failed to set dead man's switch: "+err.Error())
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":        true,
+		"deadMansSwitch": sw,
+	})
+}
+
+// GetOrderByClientOrderId 以自訂訂單 ID 查詢訂單
+// @Title GetOrderByClientOrderId
+// @Description 以下單時指定的 clientOrderId 查詢訂單
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	clientOrderId	path	string	true	"自訂訂單 ID"
+// @Success 200 {object} models.Order
+// @Failure 401 Unauthorized
+// @Failure 404 Order not found
+// @router /order/client/:clientOrderId [get]
+func (c *TradingController) GetOrderByClientOrderId() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 查詢訂單（只查詢自己的訂單）
+	order, err := models.GetOrderByClientOrderId(userId, c.Ctx.Input.Param(":clientOrderId"))
+	if err != nil {
+		if err.Error() == "order not found" {
+			utils.RespondError(c.Ctx, 404, err.Error())
+		} else {
+			utils.RespondError(c.Ctx, 500, "Failed to get order: "+err.Error())
+		}
+		return
+	}
+
+	if err = models.LoadOrderAmendments([]*models.Order{order}); err != nil {
+		utils.RespondError(c.Ctx, 500, "Failed to get order: "+err.Error())
+		return
+	}
+
+	// 3. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success": true,
+		"order":   order,
+	})
+}
+
+// CancelOrderByClientOrderId 以自訂訂單 ID 取消訂單
+// @Title CancelOrderByClientOrderId
+// @Description 以下單時指定的 clientOrderId 取消待處理的訂單
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	clientOrderId	path	string	true	"自訂訂單 ID"
+// @Success 200 {string} string "Order canceled successfully"
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 404 Order not found
+// @router /order/client/:clientOrderId/cancel [post]
+func (c *TradingController) CancelOrderByClientOrderId() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 查詢訂單（只查詢自己的訂單）
+	order, err := models.GetOrderByClientOrderId(userId, c.Ctx.Input.Param(":clientOrderId"))
+	if err != nil {
+		utils.RespondError(c.Ctx, 404, "Order not found")
+		return
+	}
+
+	// 3. 取消訂單（同時從撮合器移除並解除凍結的保證金）
+	if err = services.CancelOrder(userId, order.Id); err != nil {
+		if err.Error() == "order cannot be canceled" {
+			utils.RespondError(c.Ctx, 400, err.Error())
+		} else {
+			utils.RespondError(c.Ctx, 500, "Failed to cancel order: "+err.Error())
+		}
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success": true,
+		"message": "Order canceled successfully",
+	})
+}
+
+// PlaceAlgoOrder 下演算法母單
+// @Title PlaceAlgoOrder
+// @Description 下 TWAP（在指定期間內分批下市價單）或冰山單（每次只掛出可見數量的限價單，成交後補上），進度透過 WebSocket ALGO_ORDER_UPDATE 推送
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	body			body	PlaceAlgoOrderRequest	true	"母單資訊"
+// @Success 200 {object} models.AlgoOrder
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @router /algo-order [post]
+func (c *TradingController) PlaceAlgoOrder() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析請求
+	var req PlaceAlgoOrderRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid request body")
+		return
+	}
+
+	// 3. 建立母單（參數由 service 驗證）
+	algo, err := services.PlaceAlgoOrder(userId, req.Symbol, models.AlgoOrderParams{
+		Algo:            models.AlgoType(req.Algo),
+		Side:            models.OrderSide(req.Side),
+		Quantity:        req.Quantity,
+		DurationSeconds: req.DurationSeconds,
+		IntervalSeconds: req.IntervalSeconds,
+		Randomize:       req.Randomize,
+		LimitPrice:      req.LimitPrice,
+		VisibleQuantity: req.VisibleQuantity,
+	})
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Failed to place algo order: "+err.Error())
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":   true,
+		"message":   "Algo order placed successfully",
+		"algoOrder": algo,
+	})
+}
+
+// GetAlgoOrders 查詢使用者的演算法母單
+// @Title GetAlgoOrders
+// @Description 查詢使用者的 TWAP 與冰山單及執行進度
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	limit			query	int		false	"每頁數量（預設20）"
+// @Param	offset			query	int		false	"偏移量（預設0）"
+// @Success 200 {array} models.AlgoOrder
+// @Failure 401 Unauthorized
+// @Failure 500 Internal server error
+// @router /algo-orders [get]
+func (c *TradingController) GetAlgoOrders() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析查詢參數
+	limit, _ := strconv.Atoi(c.GetString("limit", "20"))
+	offset, _ := strconv.Atoi(c.GetString("offset", "0"))
+
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	// 3. 查詢母單
+	algos, err := models.GetAlgoOrdersByUser(userId, limit, offset)
+	if err != nil {
+		utils.RespondError(c.Ctx, 500, "Failed to get algo orders: "+err.Error())
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":    true,
+		"algoOrders": algos,
+		"count":      len(algos),
+	})
+}
+
+// GetAlgoOrder 查詢單一演算法母單
+// @Title GetAlgoOrder
+// @Description 查詢演算法母單的執行進度與所有子訂單
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"母單 ID"
+// @Success 200 {object} models.AlgoOrder
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Algo order not found
+// @router /algo-order/:id [get]
+func (c *TradingController) GetAlgoOrder() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析母單 ID
+	algoOrderId, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid algo order ID")
+		return
+	}
+
+	// 3. 查詢母單與子訂單
+	algo, err := services.GetAlgoOrder(userId, algoOrderId)
+	if err != nil {
+		respondAlgoOrderError(c, err, "Failed to get algo order: ")
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":   true,
+		"algoOrder": algo,
+	})
+}
+
+// PauseAlgoOrder 暫停演算法母單
+// @Title PauseAlgoOrder
+// @Description 暫停執行中的母單，冰山單同時取消目前掛出的子訂單
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"母單 ID"
+// @Success 200 {object} models.AlgoOrder
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Algo order not found
+// @router /algo-order/:id/pause [post]
+func (c *TradingController) PauseAlgoOrder() {
+	c.updateAlgoOrder(services.GlobalAlgoOrderEngine.PauseAlgoOrder, "Algo order paused successfully")
+}
+
+// ResumeAlgoOrder 恢復演算法母單
+// @Title ResumeAlgoOrder
+// @Description 恢復已暫停的母單，TWAP 剩餘數量分配到剩餘的子訂單，冰山單重新掛出可見數量
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"母單 ID"
+// @Success 200 {object} models.AlgoOrder
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Algo order not found
+// @router /algo-order/:id/resume [post]
+func (c *TradingController) ResumeAlgoOrder() {
+	c.updateAlgoOrder(services.GlobalAlgoOrderEngine.ResumeAlgoOrder, "Algo order resumed successfully")
+}
+
+// CancelAlgoOrder 取消演算法母單
+// @Title CancelAlgoOrder
+// @Description 取消執行中或暫停中的母單，冰山單同時取消目前掛出的子訂單，已成交的子訂單不受影響
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"母單 ID"
+// @Success 200 {object} models.AlgoOrder
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Algo order not found
+// @router /algo-order/:id/cancel [post]
+func (c *TradingController) CancelAlgoOrder() {
+	c.updateAlgoOrder(services.GlobalAlgoOrderEngine.CancelAlgoOrder, "Algo order canceled successfully")
+}
+
+// updateAlgoOrder 暫停、恢復、取消母單的共用流程
+func (c *TradingController) updateAlgoOrder(update func(userId int64, algoOrderId int64) (*models.AlgoOrder, error), message string) {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析母單 ID
+	algoOrderId, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid algo order ID")
+		return
+	}
+
+	// 3. 更新母單
+	algo, err := update(userId, algoOrderId)
+	if err != nil {
+		respondAlgoOrderError(c, err, "Failed to update algo order: ")
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":   true,
+		"message":   message,
+		"algoOrder": algo,
+	})
+}
+
+// respondAlgoOrderError 將母單相關錯誤轉換為 HTTP 狀態碼
+func respondAlgoOrderError(c *TradingController, err error, prefix string) {
+	switch err.Error() {
+	case "unauthorized: algo order does not belong to user":
+		utils.RespondError(c.Ctx, 403, err.Error())
+	case "algo order not found":
+		utils.RespondError(c.Ctx, 404, err.Error())
+	case "only running algo orders can be paused", "only paused algo orders can be resumed", "algo order cannot be canceled":
+		utils.RespondError(c.Ctx, 400, err.Error())
+	default:
+		utils.RespondError(c.Ctx, 500, prefix+err.Error())
+	}
+}
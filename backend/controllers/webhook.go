@@ -0,0 +1,354 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/services"
+	"backend/utils"
+	"encoding/json"
+	"strconv"
+
+	"github.com/beego/beego/v2/server/web"
+)
+
+type WebhookController struct {
+	web.Controller
+}
+
+// WebhookEndpointRequest 註冊或修改 webhook 端點請求
+type WebhookEndpointRequest struct {
+	Url         string   `json:"url" valid:"Required"`  // 接收事件的網址（http 或 https）
+	EventTypes  []string `json:"eventTypes,omitempty"`  // 訂閱的事件類型：ORDER_FILLED, POSITION_CLOSED, POSITION_LIQUIDATED, PRICE_ALERT（空白表示全部）
+	Description string   `json:"description,omitempty"` // 說明
+	Active      *bool    `json:"active,omitempty"`      // 是否啟用（預設啟用）
+}
+
+// params 轉換為 service 參數
+func (req *WebhookEndpointRequest) params() models.WebhookEndpointParams {
+	params := models.WebhookEndpointParams{
+		Url:         req.Url,
+		Description: req.Description,
+		Active:      req.Active == nil || *req.Active,
+	}
+	for _, eventType := range req.EventTypes {
+		params.EventTypes = append(params.EventTypes, models.WebhookEventType(eventType))
+	}
+	return params
+}
+
+// CreateWebhookEndpoint 註冊 webhook 端點
+// @Title CreateWebhookEndpoint
+// @Description 註冊接收帳戶事件的端點。事件以 POST 送出，標頭 X-Webhook-Signature 為 "sha256=" 加上 HMAC-SHA256(secret, "{X-Webhook-Timestamp}.{body}")；簽章金鑰只在建立時回傳
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	body			body	WebhookEndpointRequest	true	"端點設定"
+// @Success 200 {object} models.WebhookEndpoint
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @router / [post]
+func (c *WebhookController) CreateWebhookEndpoint() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析請求
+	var req WebhookEndpointRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid request body")
+		return
+	}
+
+	// 3. 註冊端點（參數由 service 驗證）
+	endpoint, err := services.CreateWebhookEndpoint(userId, req.params())
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Failed to create webhook endpoint: "+err.Error())
+		return
+	}
+
+	// 4. 返回結果（含簽章金鑰）
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":  true,
+		"message":  "Webhook endpoint created successfully",
+		"endpoint": endpoint,
+		"secret":   endpoint.Secret,
+	})
+}
+
+// GetWebhookEndpoints 查詢所有 webhook 端點
+// @Title GetWebhookEndpoints
+// @Description 查詢使用者註冊的 webhook 端點
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Success 200 {array} models.WebhookEndpoint
+// @Failure 401 Unauthorized
+// @Failure 500 Internal server error
+// @router / [get]
+func (c *WebhookController) GetWebhookEndpoints() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 查詢端點
+	endpoints, err := models.GetWebhookEndpointsByUser(userId)
+	if err != nil {
+		utils.RespondError(c.Ctx, 500, "Failed to get webhook endpoints: "+err.Error())
+		return
+	}
+
+	// 3. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":   true,
+		"endpoints": endpoints,
+		"count":     len(endpoints),
+	})
+}
+
+// GetWebhookEndpoint 查詢單一 webhook 端點
+// @Title GetWebhookEndpoint
+// @Description 查詢 webhook 端點的設定
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"端點 ID"
+// @Success 200 {object} models.WebhookEndpoint
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Webhook endpoint not found
+// @router /:id [get]
+func (c *WebhookController) GetWebhookEndpoint() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析端點 ID
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid webhook endpoint ID")
+		return
+	}
+
+	// 3. 查詢端點
+	endpoint, err := services.GetWebhookEndpoint(userId, id)
+	if err != nil {
+		c.respondError(err, "Failed to get webhook endpoint: ")
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":  true,
+		"endpoint": endpoint,
+	})
+}
+
+// UpdateWebhookEndpoint 修改 webhook 端點
+// @Title UpdateWebhookEndpoint
+// @Description 修改端點的網址、訂閱事件與啟用狀態，簽章金鑰不變；停用後等待中的投遞不再送出
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"端點 ID"
+// @Param	body			body	WebhookEndpointRequest	true	"端點設定"
+// @Success 200 {object} models.WebhookEndpoint
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Webhook endpoint not found
+// @router /:id [put]
+func (c *WebhookController) UpdateWebhookEndpoint() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析端點 ID 與請求
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid webhook endpoint ID")
+		return
+	}
+
+	var req WebhookEndpointRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid request body")
+		return
+	}
+
+	// 3. 修改端點
+	endpoint, err := services.UpdateWebhookEndpoint(userId, id, req.params())
+	if err != nil {
+		c.respondError(err, "Failed to update webhook endpoint: ")
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":  true,
+		"message":  "Webhook endpoint updated successfully",
+		"endpoint": endpoint,
+	})
+}
+
+// DeleteWebhookEndpoint 刪除 webhook 端點
+// @Title DeleteWebhookEndpoint
+// @Description 刪除端點與其投遞記錄
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"端點 ID"
+// @Success 200 {string} string "Webhook endpoint deleted successfully"
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Webhook endpoint not found
+// @router /:id [delete]
+func (c *WebhookController) DeleteWebhookEndpoint() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析端點 ID
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid webhook endpoint ID")
+		return
+	}
+
+	// 3. 刪除端點
+	if err = services.DeleteWebhookEndpoint(userId, id); err != nil {
+		c.respondError(err, "Failed to delete webhook endpoint: ")
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success": true,
+		"message": "Webhook endpoint deleted successfully",
+	})
+}
+
+// GetWebhookDeliveries 查詢投遞記錄
+// @Title GetWebhookDeliveries
+// @Description 查詢端點的投遞記錄（由新到舊），包含事件內容、嘗試次數、最近一次的 HTTP 狀態碼與錯誤
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"端點 ID"
+// @Param	status			query	string	false	"狀態篩選：PENDING、DELIVERED 或 FAILED"
+// @Param	limit			query	int		false	"每頁數量（預設20）"
+// @Param	offset			query	int		false	"偏移量（預設0）"
+// @Success 200 {array} models.WebhookDelivery
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Webhook endpoint not found
+// @router /:id/deliveries [get]
+func (c *WebhookController) GetWebhookDeliveries() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析參數
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid webhook endpoint ID")
+		return
+	}
+
+	status := models.WebhookDeliveryStatus(c.GetString("status"))
+	if status != "" && status != models.WebhookDeliveryStatusPending &&
+		status != models.WebhookDeliveryStatusDelivered && status != models.WebhookDeliveryStatusFailed {
+		utils.RespondError(c.Ctx, 400, "Invalid status, must be PENDING, DELIVERED or FAILED")
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.GetString("limit", "20"))
+	offset, _ := strconv.Atoi(c.GetString("offset", "0"))
+
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	// 3. 查詢投遞記錄
+	deliveries, err := services.GetWebhookDeliveries(userId, id, status, limit, offset)
+	if err != nil {
+		c.respondError(err, "Failed to get webhook deliveries: ")
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":    true,
+		"deliveries": deliveries,
+		"count":      len(deliveries),
+	})
+}
+
+// RedeliverWebhook 手動重送
+// @Title RedeliverWebhook
+// @Description 以同一個事件建立新的投遞並立即送出（原本的記錄保留），接收端可用事件 ID 去除重複
+// @Param	Authorization	header	string	true	"Bearer {token}"
+// @Param	id				path	int		true	"端點 ID"
+// @Param	deliveryId		path	int		true	"投遞 ID"
+// @Success 200 {object} models.WebhookDelivery
+// @Failure 400 Bad request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Webhook endpoint or delivery not found
+// @router /:id/deliveries/:deliveryId/redeliver [post]
+func (c *WebhookController) RedeliverWebhook() {
+	// 1. 驗證 JWT
+	userId, err := utils.ValidateJWT(c.Ctx.Request)
+	if err != nil {
+		utils.RespondError(c.Ctx, 401, "Unauthorized: "+err.Error())
+		return
+	}
+
+	// 2. 解析端點與投遞 ID
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid webhook endpoint ID")
+		return
+	}
+	deliveryId, err := strconv.ParseInt(c.Ctx.Input.Param(":deliveryId"), 10, 64)
+	if err != nil {
+		utils.RespondError(c.Ctx, 400, "Invalid webhook delivery ID")
+		return
+	}
+
+	// 3. 建立新的投遞
+	delivery, err := services.RedeliverWebhook(userId, id, deliveryId)
+	if err != nil {
+		c.respondError(err, "Failed to redeliver webhook: ")
+		return
+	}
+
+	// 4. 返回結果
+	utils.RespondJSON(c.Ctx, 200, map[string]interface{}{
+		"success":  true,
+		"message":  "Webhook redelivery scheduled",
+		"delivery": delivery,
+	})
+}
+
+// respondError 將 webhook 相關錯誤轉換為 HTTP 狀態碼（其他錯誤多為參數驗證錯誤）
+func (c *WebhookController) respondError(err error, prefix string) {
+	switch err.Error() {
+	case "unauthorized: webhook endpoint does not belong to user":
+		utils.RespondError(c.Ctx, 403, err.Error())
+	case "webhook endpoint not found", "webhook delivery not found":
+		utils.RespondError(c.Ctx, 404, err.Error())
+	default:
+		utils.RespondError(c.Ctx, 400, prefix+err.Error())
+	}
+}
@@ -2,6 +2,7 @@ package hub
 
 import (
 	"log"
+	"sync"
 
 	"github.com/gorilla/websocket"
 )
@@ -25,6 +26,10 @@ type Hub struct {
 	UserBroadcast   chan UserMessage    // 按用戶廣播消息
 	Register        chan *Client
 	Unregister      chan *Client
+
+	listenersMu         sync.RWMutex
+	disconnectListeners []func(userId int64)                 // 使用者最後一個連線斷開時的回呼
+	messageListeners    []func(userId int64, message []byte) // 發送給使用者的消息的回呼
 }
 
 // UserMessage 用戶特定的消息
@@ -69,6 +74,7 @@ func (h *Hub) Run() {
 					}
 					if len(h.ClientsByUserId[client.UserId]) == 0 {
 						delete(h.ClientsByUserId, client.UserId)
+						h.notifyUserDisconnect(client.UserId)
 					}
 				}
 				close(client.Send)
@@ -102,9 +108,47 @@ func (h *Hub) Run() {
 
 // BroadcastToUser 廣播消息給特定用戶
 func (h *Hub) BroadcastToUser(userId int64, message []byte) {
+	h.notifyUserMessage(userId, message)
+
 	select {
 	case h.UserBroadcast <- UserMessage{UserId: userId, Message: message}:
 	default:
 		log.Printf("User broadcast channel full, dropping message for user %d", userId)
 	}
 }
+
+// OnUserDisconnect 註冊使用者最後一個已驗證連線斷開時的回呼，回呼在 Run 中同步執行，不可阻塞
+func (h *Hub) OnUserDisconnect(listener func(userId int64)) {
+	h.listenersMu.Lock()
+	defer h.listenersMu.Unlock()
+	h.disconnectListeners = append(h.disconnectListeners, listener)
+}
+
+// notifyUserDisconnect 通知使用者已沒有任何連線
+func (h *Hub) notifyUserDisconnect(userId int64) {
+	h.listenersMu.RLock()
+	listeners := h.disconnectListeners
+	h.listenersMu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(userId)
+	}
+}
+
+// OnUserMessage 註冊發送給使用者的消息的回呼（不論使用者是否在線），回呼在 BroadcastToUser 中同步執行，不可阻塞
+func (h *Hub) OnUserMessage(listener func(userId int64, message []byte)) {
+	h.listenersMu.Lock()
+	defer h.listenersMu.Unlock()
+	h.messageListeners = append(h.messageListeners, listener)
+}
+
+// notifyUserMessage 通知有消息發送給使用者
+func (h *Hub) notifyUserMessage(userId int64, message []byte) {
+	h.listenersMu.RLock()
+	listeners := h.messageListeners
+	h.listenersMu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(userId, message)
+	}
+}
@@ -63,3 +63,55 @@ func TestHubRun(t *testing.T) {
 		t.Error("Client was not unregistered from hub.Clients")
 	}
 }
+
+// TestHubUserDisconnect 測試使用者最後一個連線斷開時才觸發回呼
+func TestHubUserDisconnect(t *testing.T) {
+	hub := NewHub()
+	disconnected := make(chan int64, 2)
+	hub.OnUserDisconnect(func(userId int64) {
+		disconnected <- userId
+	})
+	go hub.Run()
+
+	first := &Client{Send: make(chan []byte, 256), UserId: 42}
+	second := &Client{Send: make(chan []byte, 256), UserId: 42}
+	anonymous := &Client{Send: make(chan []byte, 256)}
+	hub.Register <- first
+	hub.Register <- second
+	hub.Register <- anonymous
+
+	hub.Unregister <- first
+	hub.Unregister <- anonymous
+	select {
+	case userId := <-disconnected:
+		t.Errorf("expected no callback while user still connected, got user %d", userId)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	hub.Unregister <- second
+	select {
+	case userId := <-disconnected:
+		if userId != 42 {
+			t.Errorf("expected user 42, got %d", userId)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected disconnect callback for user 42")
+	}
+}
+
+// TestHubUserMessage 測試推送給使用者的消息會通知回呼（即使使用者未連線）
+func TestHubUserMessage(t *testing.T) {
+	hub := NewHub()
+	var gotUser int64
+	var gotMessage string
+	hub.OnUserMessage(func(userId int64, message []byte) {
+		gotUser = userId
+		gotMessage = string(message)
+	})
+
+	hub.BroadcastToUser(7, []byte(`{"type":"PING"}`))
+
+	if gotUser != 7 || gotMessage != `{"type":"PING"}` {
+		t.Errorf("listener got user %d message %q", gotUser, gotMessage)
+	}
+}
@@ -3,15 +3,29 @@ package main
 import (
 	_ "backend/db"
 	"backend/hub"
+	"backend/models"
 	_ "backend/routers"
 	"backend/services"
 	"backend/utils"
-	"time"
+	"log"
 
 	beego "github.com/beego/beego/v2/server/web"
 )
 
 func init() {
+	// 載入維持保證金率階梯設定（未設定時使用預設階梯）
+	if path, err := beego.AppConfig.String("margintiers"); err == nil && path != "" {
+		if err = models.LoadMarginTiers(path); err != nil {
+			log.Fatalf("Failed to load margin tiers: %v", err)
+		}
+	}
+
+	// 建立保險基金帳戶（已存在時不會覆蓋餘額）
+	initialFund, _ := beego.AppConfig.Float("insurancefundinitial")
+	if _, err := models.EnsureInsuranceFund("USDT", initialFund); err != nil {
+		log.Fatalf("Failed to initialize insurance fund: %v", err)
+	}
+
 	hub.GlobalHub = hub.NewHub()
 	go hub.GlobalHub.Run()
 	go services.ConnectToBinance(hub.GlobalHub)
@@ -19,15 +33,41 @@ func init() {
 	// 啟動限價單撮合服務
 	services.GlobalLimitOrderMatcher.Start()
 
-	// 啟動槓桿倉位爆倉檢查服務（每 5 秒檢查一次）
-	go func() {
-		ticker := time.NewTicker(5 * time.Second)
-		defer ticker.Stop()
-		for range ticker.C {
-			services.CheckAndLiquidatePositions()
-			services.UpdateAllPositionsPnL()
-		}
-	}()
+	// 啟動演算法母單執行器（TWAP、冰山單）
+	services.GlobalAlgoOrderEngine.Start()
+
+	// 啟動定期定額排程器
+	services.GlobalRecurringBuyScheduler.Start()
+
+	// 啟動網格機器人服務（監聽限價單成交，掛出反向訂單）
+	services.GlobalGridBotService.Start()
+
+	// 啟動回測執行器（非同步執行 POST /v1/backtests 建立的任務）
+	services.GlobalBacktestRunner.Start()
+
+	// 啟動策略機器人服務（把價格、成交與持倉事件送給執行中的策略）
+	services.GlobalStrategyBotService.Start()
+
+	// 啟動價格提醒服務（每次價格更新時檢查提醒，推送 PRICE_ALERT 並呼叫 webhook）
+	services.GlobalPriceAlertService.Start()
+
+	// 啟動 webhook 投遞服務（將成交、爆倉與入金事件以簽章後的 POST 送到使用者註冊的端點，失敗時重試）
+	services.GlobalWebhookDispatcher.Start()
+
+	// 啟動 WebSocket 消息推送服務（推送交易中寫入 outbox 的消息，在註冊消息回呼的服務之後啟動）
+	services.GlobalNotificationDispatcher.Start()
+
+	// 啟動資產快照服務（每小時與每日記錄資產總值，供 /v1/portfolio/history 查詢）
+	services.GlobalPortfolioSnapshotter.Start()
+
+	// 啟動資金費用結算服務
+	services.GlobalFundingService.Start()
+
+	// 啟動自動撤單服務（斷線或倒數結束時取消限價單）
+	services.GlobalDeadMansSwitch.Start()
+
+	// 啟動爆倉引擎（每次價格更新時檢查爆倉，定期批次寫入未實現盈虧）
+	services.GlobalLiquidationEngine.Start()
 }
 
 func main() {
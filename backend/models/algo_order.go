@@ -0,0 +1,343 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// AlgoType 演算法母單類型
+type AlgoType string
+
+const (
+	AlgoTypeTWAP    AlgoType = "TWAP"    // 時間加權：在指定期間內分批下市價單
+	AlgoTypeIceberg AlgoType = "ICEBERG" // 冰山單：每次只掛出可見數量的限價單，成交後補上
+)
+
+// AlgoOrderStatus 演算法母單狀態
+type AlgoOrderStatus string
+
+const (
+	AlgoOrderStatusRunning   AlgoOrderStatus = "RUNNING"   // 執行中
+	AlgoOrderStatusPaused    AlgoOrderStatus = "PAUSED"    // 已暫停
+	AlgoOrderStatusCompleted AlgoOrderStatus = "COMPLETED" // 已完成
+	AlgoOrderStatusCanceled  AlgoOrderStatus = "CANCELED"  // 已取消
+	AlgoOrderStatusFailed    AlgoOrderStatus = "FAILED"    // 子訂單失敗
+)
+
+const (
+	// MinTWAPIntervalSeconds TWAP 子訂單的最小間隔
+	MinTWAPIntervalSeconds = 5
+	// MaxTWAPDurationSeconds TWAP 的最長執行期間（24 小時）
+	MaxTWAPDurationSeconds = 86400
+	// algoQuantityEpsilon 剩餘數量小於此值視為已完成（避免浮點誤差留下極小的子訂單）
+	algoQuantityEpsilon = 1e-8
+)
+
+// AlgoOrder 演算法母單（TWAP、冰山單），實際成交由子訂單（Order.AlgoOrderId）完成
+// Quantity 的單位與子訂單相同：TWAP 買入為花費的 USDT 金額（與市價單相同），其他為幣種數量
+type AlgoOrder struct {
+	Id              int64           `orm:"auto" json:"id"`
+	User            *User           `orm:"rel(fk)" json:"-"`
+	Symbol          string          `orm:"size(20)" json:"symbol"`
+	Algo            AlgoType        `orm:"size(20)" json:"algo"`                                         // TWAP or ICEBERG
+	Side            OrderSide       `orm:"size(10)" json:"side"`                                         // BUY or SELL
+	Quantity        float64         `orm:"digits(20);decimals(8)" json:"quantity"`                       // 總數量
+	FilledQuantity  float64         `orm:"digits(20);decimals(8)" json:"filledQuantity"`                 // 子訂單已成交的數量
+	ExecutedAmount  float64         `orm:"digits(20);decimals(8)" json:"executedAmount"`                 // 子訂單已成交的總金額（USDT）
+	ChildCount      int             `orm:"default(0)" json:"childCount"`                                 // 已成交的子訂單數量
+	DurationSeconds int             `orm:"default(0)" json:"durationSeconds,omitempty"`                  // TWAP 執行期間
+	IntervalSeconds int             `orm:"default(0)" json:"intervalSeconds,omitempty"`                  // TWAP 子訂單間隔
+	SliceCount      int             `orm:"default(0)" json:"sliceCount,omitempty"`                       // TWAP 子訂單總數
+	Randomize       float64         `orm:"digits(10);decimals(4);default(0)" json:"randomize"`           // 隨機幅度（0~0.5），TWAP 每次的數量與間隔在 ±幅度內隨機調整
+	NextRunAt       *time.Time      `orm:"null;type(datetime)" json:"nextRunAt,omitempty"`               // TWAP 下一個子訂單的時間
+	LimitPrice      float64         `orm:"digits(20);decimals(8);null" json:"limitPrice,omitempty"`      // 冰山單限價
+	VisibleQuantity float64         `orm:"digits(20);decimals(8);null" json:"visibleQuantity,omitempty"` // 冰山單每次掛出的數量
+	ActiveChildId   int64           `orm:"default(0)" json:"activeChildId,omitempty"`                    // 冰山單目前掛出的子訂單
+	Status          AlgoOrderStatus `orm:"size(20);index" json:"status"`
+	ErrorMsg        string          `orm:"size(500);null" json:"errorMsg,omitempty"`
+	Children        []*Order        `orm:"-" json:"children,omitempty"` // 子訂單（查詢單筆時載入）
+	CreatedAt       time.Time       `orm:"auto_now_add;type(datetime)" json:"createdAt"`
+	UpdatedAt       time.Time       `orm:"auto_now;type(datetime)" json:"updatedAt"`
+}
+
+func init() {
+	orm.RegisterModel(new(AlgoOrder))
+}
+
+// TableName 指定資料表名稱
+func (a *AlgoOrder) TableName() string {
+	return "algo_order"
+}
+
+// AlgoOrderParams 下演算法母單的參數
+type AlgoOrderParams struct {
+	Algo            AlgoType
+	Side            OrderSide
+	Quantity        float64
+	DurationSeconds int     // TWAP
+	IntervalSeconds int     // TWAP
+	Randomize       float64 // TWAP
+	LimitPrice      float64 // ICEBERG
+	VisibleQuantity float64 // ICEBERG
+}
+
+// Validate 驗證演算法母單參數
+func (p AlgoOrderParams) Validate() error {
+	if p.Side != OrderSideBuy && p.Side != OrderSideSell {
+		return errors.New("side must be BUY or SELL")
+	}
+	if p.Quantity <= 0 {
+		return errors.New("quantity must be positive")
+	}
+
+	switch p.Algo {
+	case AlgoTypeTWAP:
+		if p.IntervalSeconds < MinTWAPIntervalSeconds {
+			return errors.New("intervalSeconds must be at least 5")
+		}
+		if p.DurationSeconds < p.IntervalSeconds || p.DurationSeconds > MaxTWAPDurationSeconds {
+			return errors.New("durationSeconds must be between intervalSeconds and 86400")
+		}
+		if p.Randomize < 0 || p.Randomize > 0.5 {
+			return errors.New("randomize must be between 0 and 0.5")
+		}
+	case AlgoTypeIceberg:
+		if p.LimitPrice <= 0 {
+			return errors.New("limit price must be positive")
+		}
+		if p.VisibleQuantity <= 0 || p.VisibleQuantity > p.Quantity {
+			return errors.New("visibleQuantity must be positive and not exceed quantity")
+		}
+	default:
+		return errors.New("algo must be TWAP or ICEBERG")
+	}
+	return nil
+}
+
+// NewAlgoOrder 依參數建立演算法母單（尚未寫入資料庫），TWAP 從 now 開始下第一個子訂單
+func NewAlgoOrder(userId int64, symbol string, params AlgoOrderParams, now time.Time) *AlgoOrder {
+	algo := &AlgoOrder{
+		User:     &User{Id: userId},
+		Symbol:   symbol,
+		Algo:     params.Algo,
+		Side:     params.Side,
+		Quantity: params.Quantity,
+		Status:   AlgoOrderStatusRunning,
+	}
+
+	if params.Algo == AlgoTypeTWAP {
+		algo.DurationSeconds = params.DurationSeconds
+		algo.IntervalSeconds = params.IntervalSeconds
+		algo.SliceCount = int(math.Ceil(float64(params.DurationSeconds) / float64(params.IntervalSeconds)))
+		algo.Randomize = params.Randomize
+		algo.NextRunAt = &now
+	} else {
+		algo.LimitPrice = params.LimitPrice
+		algo.VisibleQuantity = params.VisibleQuantity
+	}
+	return algo
+}
+
+// RemainingQuantity 尚未成交的數量
+func (a *AlgoOrder) RemainingQuantity() float64 {
+	remaining := a.Quantity - a.FilledQuantity
+	if remaining < algoQuantityEpsilon {
+		return 0
+	}
+	return remaining
+}
+
+// IsActive 母單是否仍在執行或暫停中
+func (a *AlgoOrder) IsActive() bool {
+	return a.Status == AlgoOrderStatusRunning || a.Status == AlgoOrderStatusPaused
+}
+
+// NextTWAPSlice 下一個 TWAP 子訂單的數量
+// 剩餘數量平均分配到剩餘的子訂單，再依 rnd（-1~1）在 ±Randomize 內調整，最後一個子訂單下完所有剩餘數量
+func (a *AlgoOrder) NextTWAPSlice(rnd float64) float64 {
+	remaining := a.RemainingQuantity()
+	slicesLeft := a.SliceCount - a.ChildCount
+	if slicesLeft <= 1 {
+		return remaining
+	}
+
+	slice := remaining / float64(slicesLeft) * (1 + rnd*a.Randomize)
+	if slice > remaining {
+		return remaining
+	}
+	return slice
+}
+
+// NextTWAPDelay 下一個 TWAP 子訂單的間隔，依 rnd（-1~1）在 ±Randomize 內調整
+func (a *AlgoOrder) NextTWAPDelay(rnd float64) time.Duration {
+	seconds := float64(a.IntervalSeconds) * (1 + rnd*a.Randomize)
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// NextIcebergSlice 下一個冰山子訂單的數量（可見數量與剩餘數量取小）
+func (a *AlgoOrder) NextIcebergSlice() float64 {
+	return math.Min(a.VisibleQuantity, a.RemainingQuantity())
+}
+
+// RecordFill 記錄子訂單成交，返回母單是否已全部成交
+func (a *AlgoOrder) RecordFill(quantity float64, totalAmount float64) bool {
+	a.FilledQuantity += quantity
+	a.ExecutedAmount += totalAmount
+	a.ChildCount++
+	if a.RemainingQuantity() == 0 {
+		a.Status = AlgoOrderStatusCompleted
+		return true
+	}
+	if a.Algo == AlgoTypeTWAP && a.ChildCount >= a.SliceCount {
+		// 所有子訂單都已下完（理論上最後一個子訂單會下完剩餘數量）
+		a.Status = AlgoOrderStatusCompleted
+		return true
+	}
+	return false
+}
+
+// RecoverChildren 以子訂單補上尚未寫回母單的進度（子訂單已建立或成交，但寫回母單前中斷），返回母單是否有變動
+// 子訂單建立時即記錄 AlgoOrderId：超出 ChildCount 的已成交子訂單補記成交，冰山單掛單中的子訂單恢復為目前的子訂單
+// children 需依 ID 排序
+func (a *AlgoOrder) RecoverChildren(children []*Order) bool {
+	changed := false
+	recorded := 0
+	for _, child := range children {
+		if child.Id == a.ActiveChildId || !a.IsActive() {
+			continue
+		}
+		switch child.Status {
+		case OrderStatusCompleted:
+			recorded++
+			if recorded > a.ChildCount {
+				a.RecordFill(child.Quantity, child.TotalAmount)
+				changed = true
+			}
+		case OrderStatusPending:
+			if a.Algo == AlgoTypeIceberg && a.ActiveChildId == 0 {
+				a.ActiveChildId = child.Id
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// Pause 暫停執行中的母單
+func (a *AlgoOrder) Pause() error {
+	if a.Status != AlgoOrderStatusRunning {
+		return errors.New("only running algo orders can be paused")
+	}
+	a.Status = AlgoOrderStatusPaused
+	a.NextRunAt = nil
+	return nil
+}
+
+// Resume 恢復已暫停的母單，TWAP 從 now 開始下一個子訂單（剩餘數量分配到剩餘的子訂單）
+func (a *AlgoOrder) Resume(now time.Time) error {
+	if a.Status != AlgoOrderStatusPaused {
+		return errors.New("only paused algo orders can be resumed")
+	}
+	a.Status = AlgoOrderStatusRunning
+	if a.Algo == AlgoTypeTWAP {
+		a.NextRunAt = &now
+	}
+	return nil
+}
+
+// Cancel 取消執行中或暫停中的母單
+func (a *AlgoOrder) Cancel() error {
+	if !a.IsActive() {
+		return errors.New("algo order cannot be canceled")
+	}
+	a.Status = AlgoOrderStatusCanceled
+	a.NextRunAt = nil
+	return nil
+}
+
+// Fail 子訂單失敗，母單停止執行
+func (a *AlgoOrder) Fail(reason string) {
+	a.Status = AlgoOrderStatusFailed
+	a.NextRunAt = nil
+	a.ErrorMsg = reason
+}
+
+// CreateAlgoOrder 寫入演算法母單
+func CreateAlgoOrder(o orm.QueryExecutor, algo *AlgoOrder) error {
+	id, err := o.Insert(algo)
+	if err != nil {
+		return err
+	}
+	algo.Id = id
+	return nil
+}
+
+// SaveAlgoOrder 寫回母單的進度與狀態
+func SaveAlgoOrder(o orm.QueryExecutor, algo *AlgoOrder) error {
+	_, err := o.Update(algo, "FilledQuantity", "ExecutedAmount", "ChildCount", "NextRunAt",
+		"ActiveChildId", "Status", "ErrorMsg", "UpdatedAt")
+	return err
+}
+
+// SetAlgoOrderActiveChild 記錄冰山單目前掛出的子訂單（需要在建立子訂單的交易中使用）
+func SetAlgoOrderActiveChild(o orm.QueryExecutor, algoOrderId int64, childOrderId int64) error {
+	_, err := o.QueryTable(new(AlgoOrder)).
+		Filter("Id", algoOrderId).
+		Update(orm.Params{"ActiveChildId": childOrderId, "UpdatedAt": time.Now()})
+	return err
+}
+
+// GetAlgoOrderById 根據 ID 查詢演算法母單
+func GetAlgoOrderById(id int64) (*AlgoOrder, error) {
+	o := orm.NewOrm()
+	algo := &AlgoOrder{Id: id}
+	if err := o.Read(algo); err != nil {
+		if err == orm.ErrNoRows {
+			return nil, errors.New("algo order not found")
+		}
+		return nil, err
+	}
+	return algo, nil
+}
+
+// GetRunningAlgoOrders 查詢所有執行中的演算法母單
+func GetRunningAlgoOrders() ([]*AlgoOrder, error) {
+	o := orm.NewOrm()
+	var algos []*AlgoOrder
+	_, err := o.QueryTable(new(AlgoOrder)).
+		Filter("Status", AlgoOrderStatusRunning).
+		OrderBy("Id").
+		Limit(-1).
+		All(&algos)
+	return algos, err
+}
+
+// GetAlgoOrdersByUser 查詢使用者的演算法母單（由新到舊）
+func GetAlgoOrdersByUser(userId int64, limit int, offset int) ([]*AlgoOrder, error) {
+	o := orm.NewOrm()
+	var algos []*AlgoOrder
+	_, err := o.QueryTable(new(AlgoOrder)).
+		Filter("User__Id", userId).
+		OrderBy("-CreatedAt").
+		Limit(limit, offset).
+		All(&algos)
+	return algos, err
+}
+
+// LoadAlgoOrderChildren 載入母單的子訂單（由舊到新）
+func LoadAlgoOrderChildren(algo *AlgoOrder) error {
+	o := orm.NewOrm()
+	var children []*Order
+	if _, err := o.QueryTable(new(Order)).
+		Filter("AlgoOrderId", algo.Id).
+		OrderBy("Id").
+		Limit(-1).
+		All(&children); err != nil {
+		return err
+	}
+	algo.Children = children
+	return nil
+}
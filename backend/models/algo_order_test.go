@@ -0,0 +1,166 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestTWAP(quantity float64, duration int, interval int, randomize float64) *AlgoOrder {
+	return NewAlgoOrder(1, "BTCUSDT", AlgoOrderParams{
+		Algo:            AlgoTypeTWAP,
+		Side:            OrderSideSell,
+		Quantity:        quantity,
+		DurationSeconds: duration,
+		IntervalSeconds: interval,
+		Randomize:       randomize,
+	}, time.Now())
+}
+
+func TestAlgoOrderParamsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  AlgoOrderParams
+		wantErr bool
+	}{
+		{"valid twap", AlgoOrderParams{Algo: AlgoTypeTWAP, Side: OrderSideBuy, Quantity: 1000, DurationSeconds: 600, IntervalSeconds: 60, Randomize: 0.2}, false},
+		{"twap interval too short", AlgoOrderParams{Algo: AlgoTypeTWAP, Side: OrderSideBuy, Quantity: 1000, DurationSeconds: 600, IntervalSeconds: 1}, true},
+		{"twap duration shorter than interval", AlgoOrderParams{Algo: AlgoTypeTWAP, Side: OrderSideBuy, Quantity: 1000, DurationSeconds: 30, IntervalSeconds: 60}, true},
+		{"twap randomize too large", AlgoOrderParams{Algo: AlgoTypeTWAP, Side: OrderSideBuy, Quantity: 1000, DurationSeconds: 600, IntervalSeconds: 60, Randomize: 0.8}, true},
+		{"valid iceberg", AlgoOrderParams{Algo: AlgoTypeIceberg, Side: OrderSideSell, Quantity: 10, LimitPrice: 50000, VisibleQuantity: 1}, false},
+		{"iceberg visible exceeds quantity", AlgoOrderParams{Algo: AlgoTypeIceberg, Side: OrderSideSell, Quantity: 10, LimitPrice: 50000, VisibleQuantity: 11}, true},
+		{"iceberg without price", AlgoOrderParams{Algo: AlgoTypeIceberg, Side: OrderSideSell, Quantity: 10, VisibleQuantity: 1}, true},
+		{"unknown algo", AlgoOrderParams{Algo: "VWAP", Side: OrderSideSell, Quantity: 10}, true},
+		{"invalid side", AlgoOrderParams{Algo: AlgoTypeIceberg, Side: "HOLD", Quantity: 10, LimitPrice: 50000, VisibleQuantity: 1}, true},
+	}
+
+	for _, tt := range tests {
+		err := tt.params.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestTWAPSlicesFillWholeQuantity(t *testing.T) {
+	algo := newTestTWAP(1, 50, 10, 0.5)
+	if algo.SliceCount != 5 {
+		t.Fatalf("SliceCount = %d, want 5", algo.SliceCount)
+	}
+
+	// 隨機調整不影響總數量，最後一個子訂單下完剩餘數量
+	factors := []float64{1, 1, -1, 1, 0}
+	for i, rnd := range factors {
+		slice := algo.NextTWAPSlice(rnd)
+		if slice <= 0 {
+			t.Fatalf("slice %d = %v, want positive", i, slice)
+		}
+		done := algo.RecordFill(slice, slice*50000)
+		if done != (i == len(factors)-1) {
+			t.Fatalf("slice %d: done = %v", i, done)
+		}
+	}
+
+	if !almostEqual(algo.FilledQuantity, 1) {
+		t.Errorf("FilledQuantity = %v, want 1", algo.FilledQuantity)
+	}
+	if algo.Status != AlgoOrderStatusCompleted {
+		t.Errorf("Status = %s, want COMPLETED", algo.Status)
+	}
+}
+
+func TestTWAPSliceWithoutRandomization(t *testing.T) {
+	algo := newTestTWAP(100, 40, 10, 0)
+	if got := algo.NextTWAPSlice(1); !almostEqual(got, 25) {
+		t.Errorf("NextTWAPSlice = %v, want 25", got)
+	}
+	if got := algo.NextTWAPDelay(1); got != 10*time.Second {
+		t.Errorf("NextTWAPDelay = %v, want 10s", got)
+	}
+}
+
+func TestIcebergSlices(t *testing.T) {
+	algo := NewAlgoOrder(1, "BTCUSDT", AlgoOrderParams{
+		Algo:            AlgoTypeIceberg,
+		Side:            OrderSideBuy,
+		Quantity:        2.5,
+		LimitPrice:      50000,
+		VisibleQuantity: 1,
+	}, time.Now())
+
+	want := []float64{1, 1, 0.5}
+	for i, w := range want {
+		slice := algo.NextIcebergSlice()
+		if !almostEqual(slice, w) {
+			t.Fatalf("slice %d = %v, want %v", i, slice, w)
+		}
+		algo.RecordFill(slice, slice*50000)
+	}
+	if algo.Status != AlgoOrderStatusCompleted || algo.ChildCount != 3 {
+		t.Errorf("Status = %s, ChildCount = %d, want COMPLETED, 3", algo.Status, algo.ChildCount)
+	}
+}
+
+// TestAlgoOrderRecoverChildren 測試寫回母單前中斷時，以子訂單補上成交與目前掛出的子訂單
+func TestAlgoOrderRecoverChildren(t *testing.T) {
+	algo := NewAlgoOrder(1, "BTCUSDT", AlgoOrderParams{
+		Algo:            AlgoTypeIceberg,
+		Side:            OrderSideBuy,
+		Quantity:        3,
+		LimitPrice:      50000,
+		VisibleQuantity: 1,
+	}, time.Now())
+	algo.RecordFill(1, 50000)
+
+	children := []*Order{
+		{Id: 1, Quantity: 1, TotalAmount: 50000, Status: OrderStatusCompleted},
+		{Id: 2, Quantity: 1, TotalAmount: 49000, Status: OrderStatusCompleted}, // 成交未寫回
+		{Id: 3, Quantity: 1, Status: OrderStatusPending},                       // 掛出後未寫回
+	}
+	if !algo.RecoverChildren(children) {
+		t.Fatal("expected algo order to change")
+	}
+	if algo.ChildCount != 2 || !almostEqual(algo.FilledQuantity, 2) || !almostEqual(algo.ExecutedAmount, 99000) {
+		t.Errorf("ChildCount = %d, FilledQuantity = %v, ExecutedAmount = %v, want 2, 2, 99000",
+			algo.ChildCount, algo.FilledQuantity, algo.ExecutedAmount)
+	}
+	if algo.ActiveChildId != 3 {
+		t.Errorf("ActiveChildId = %d, want 3", algo.ActiveChildId)
+	}
+
+	// 已補上的進度不重複記錄
+	if algo.RecoverChildren(children) {
+		t.Error("expected no change on second recovery")
+	}
+}
+
+func TestAlgoOrderPauseResumeCancel(t *testing.T) {
+	algo := newTestTWAP(100, 600, 60, 0)
+
+	if err := algo.Resume(time.Now()); err == nil {
+		t.Error("Resume on running algo order should fail")
+	}
+	if err := algo.Pause(); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	if algo.NextRunAt != nil {
+		t.Error("paused TWAP should not be scheduled")
+	}
+	if err := algo.Pause(); err == nil {
+		t.Error("Pause on paused algo order should fail")
+	}
+
+	now := time.Now()
+	if err := algo.Resume(now); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	if algo.NextRunAt == nil || !algo.NextRunAt.Equal(now) {
+		t.Errorf("NextRunAt = %v, want %v", algo.NextRunAt, now)
+	}
+
+	if err := algo.Cancel(); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if err := algo.Cancel(); err == nil {
+		t.Error("Cancel on canceled algo order should fail")
+	}
+}
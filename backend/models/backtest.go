@@ -0,0 +1,182 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// BacktestStatus 回測任務狀態
+type BacktestStatus string
+
+const (
+	BacktestStatusPending   BacktestStatus = "PENDING"   // 排隊中
+	BacktestStatusRunning   BacktestStatus = "RUNNING"   // 執行中
+	BacktestStatusCompleted BacktestStatus = "COMPLETED" // 已完成
+	BacktestStatusFailed    BacktestStatus = "FAILED"    // 失敗
+)
+
+// Backtest 非同步回測任務：以儲存的歷史 K 線重播策略，完成後記錄績效摘要與完整報告
+type Backtest struct {
+	Id             int64              `orm:"auto" json:"id"`
+	User           *User              `orm:"rel(fk)" json:"-"`
+	Symbol         string             `orm:"size(20)" json:"symbol"`                       // 交易對：BTCUSDT, ETHUSDT, SOLUSDT
+	Interval       string             `orm:"size(10)" json:"interval"`                     // K 線週期
+	StartTime      time.Time          `orm:"type(datetime)" json:"startTime"`              // 回測區間起點
+	EndTime        time.Time          `orm:"type(datetime)" json:"endTime"`                // 回測區間終點（不含）
+	Strategy       string             `orm:"size(50)" json:"strategy"`                     // 策略名稱
+	Params         string             `orm:"type(text);null" json:"-"`                     // 策略參數（JSON）
+	StrategyParams map[string]float64 `orm:"-" json:"params,omitempty"`                    // 策略參數
+	InitialBalance float64            `orm:"digits(20);decimals(8)" json:"initialBalance"` // 模擬帳戶的初始 USDT
+	MarginMode     MarginMode         `orm:"size(10)" json:"marginMode"`                   // 模擬帳戶的保證金模式
+	PositionMode   PositionMode       `orm:"size(10)" json:"positionMode"`                 // 模擬帳戶的持倉模式
+	Status         BacktestStatus     `orm:"size(20);index" json:"status"`                 // PENDING, RUNNING, COMPLETED or FAILED
+	ErrorMsg       string             `orm:"size(500);null" json:"errorMsg,omitempty"`     // 失敗原因
+	CandleCount    int                `orm:"default(0)" json:"candleCount"`                // 重播的 K 線數量
+	FinalEquity    float64            `orm:"digits(20);decimals(8)" json:"finalEquity"`    // 期末權益
+	TotalReturn    float64            `orm:"digits(20);decimals(8)" json:"totalReturn"`    // 總報酬率
+	MaxDrawdown    float64            `orm:"digits(20);decimals(8)" json:"maxDrawdown"`    // 最大回撤（比例）
+	SharpeRatio    float64            `orm:"digits(20);decimals(8)" json:"sharpeRatio"`    // 年化夏普比率
+	WinRate        float64            `orm:"digits(20);decimals(8)" json:"winRate"`        // 勝率（獲利的平倉交易 / 平倉交易）
+	TradeCount     int                `orm:"default(0)" json:"tradeCount"`                 // 成交筆數
+	Result         string             `orm:"type(longtext);null" json:"-"`                 // 完整報告（JSON：成交記錄與權益曲線）
+	Report         json.RawMessage    `orm:"-" json:"report,omitempty"`                    // 完整報告（查詢單筆時載入）
+	StartedAt      *time.Time         `orm:"null;type(datetime)" json:"startedAt,omitempty"`
+	FinishedAt     *time.Time         `orm:"null;type(datetime)" json:"finishedAt,omitempty"`
+	CreatedAt      time.Time          `orm:"auto_now_add;type(datetime)" json:"createdAt"`
+	UpdatedAt      time.Time          `orm:"auto_now;type(datetime)" json:"updatedAt"`
+}
+
+func init() {
+	orm.RegisterModel(new(Backtest))
+}
+
+// TableName 指定資料表名稱
+func (b *Backtest) TableName() string {
+	return "backtest"
+}
+
+// IsFinished 任務是否已結束
+func (b *Backtest) IsFinished() bool {
+	return b.Status == BacktestStatusCompleted || b.Status == BacktestStatusFailed
+}
+
+// Start 標記任務開始執行
+func (b *Backtest) Start(now time.Time) {
+	b.Status = BacktestStatusRunning
+	b.ErrorMsg = ""
+	b.StartedAt = &now
+}
+
+// Fail 標記任務失敗
+func (b *Backtest) Fail(reason string, now time.Time) {
+	b.Status = BacktestStatusFailed
+	b.ErrorMsg = reason
+	b.FinishedAt = &now
+}
+
+// Complete 標記任務完成
+func (b *Backtest) Complete(now time.Time) {
+	b.Status = BacktestStatusCompleted
+	b.ErrorMsg = ""
+	b.FinishedAt = &now
+}
+
+// CreateBacktest 寫入回測任務（策略參數以 JSON 儲存）
+func CreateBacktest(backtest *Backtest) error {
+	params, err := json.Marshal(backtest.StrategyParams)
+	if err != nil {
+		return err
+	}
+	backtest.Params = string(params)
+
+	o := orm.NewOrm()
+	id, err := o.Insert(backtest)
+	if err != nil {
+		return err
+	}
+	backtest.Id = id
+	return nil
+}
+
+// SaveBacktest 寫回任務的狀態與結果
+func SaveBacktest(backtest *Backtest) error {
+	o := orm.NewOrm()
+	_, err := o.Update(backtest, "Status", "ErrorMsg", "CandleCount", "FinalEquity", "TotalReturn", "MaxDrawdown",
+		"SharpeRatio", "WinRate", "TradeCount", "Result", "StartedAt", "FinishedAt", "UpdatedAt")
+	return err
+}
+
+// GetBacktestById 根據 ID 查詢回測任務
+func GetBacktestById(id int64) (*Backtest, error) {
+	o := orm.NewOrm()
+	backtest := &Backtest{Id: id}
+	if err := o.Read(backtest); err != nil {
+		if err == orm.ErrNoRows {
+			return nil, errors.New("backtest not found")
+		}
+		return nil, err
+	}
+	if err := backtest.decodeParams(); err != nil {
+		return nil, err
+	}
+	return backtest, nil
+}
+
+// GetBacktestsByUser 查詢使用者的回測任務（由新到舊，不含完整報告）
+func GetBacktestsByUser(userId int64, limit int, offset int) ([]*Backtest, error) {
+	o := orm.NewOrm()
+	var backtests []*Backtest
+	_, err := o.QueryTable(new(Backtest)).
+		Filter("User__Id", userId).
+		OrderBy("-CreatedAt").
+		Limit(limit, offset).
+		All(&backtests, "Id", "Symbol", "Interval", "StartTime", "EndTime", "Strategy", "Params", "InitialBalance",
+			"MarginMode", "PositionMode", "Status", "ErrorMsg", "CandleCount", "FinalEquity", "TotalReturn",
+			"MaxDrawdown", "SharpeRatio", "WinRate", "TradeCount", "StartedAt", "FinishedAt", "CreatedAt", "UpdatedAt")
+	if err != nil {
+		return nil, err
+	}
+	for _, backtest := range backtests {
+		if err = backtest.decodeParams(); err != nil {
+			return nil, err
+		}
+	}
+	return backtests, nil
+}
+
+// GetUnfinishedBacktests 查詢尚未結束的回測任務（重新啟動後重新排隊）
+func GetUnfinishedBacktests() ([]*Backtest, error) {
+	o := orm.NewOrm()
+	var backtests []*Backtest
+	_, err := o.QueryTable(new(Backtest)).
+		Filter("Status__in", BacktestStatusPending, BacktestStatusRunning).
+		OrderBy("CreatedAt").
+		Limit(-1).
+		All(&backtests)
+	if err != nil {
+		return nil, err
+	}
+	for _, backtest := range backtests {
+		if err = backtest.decodeParams(); err != nil {
+			return nil, err
+		}
+	}
+	return backtests, nil
+}
+
+// CountUnfinishedBacktestsByUser 計算使用者尚未結束的回測任務數量
+func CountUnfinishedBacktestsByUser(userId int64) (int64, error) {
+	o := orm.NewOrm()
+	return o.QueryTable(new(Backtest)).
+		Filter("User__Id", userId).
+		Filter("Status__in", BacktestStatusPending, BacktestStatusRunning).
+		Count()
+}
+
+// decodeParams 解析儲存的策略參數
+func (b *Backtest) decodeParams() error {
+	return decodeStrategyParams(b.Params, &b.StrategyParams)
+}
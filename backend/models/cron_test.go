@@ -0,0 +1,75 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func mustParseTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatalf("parse %q: %v", value, err)
+	}
+	return parsed
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	invalid := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	}
+	for _, expr := range invalid {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) should fail", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	tests := []struct {
+		expr  string
+		after string
+		want  string
+	}{
+		{"* * * * *", "2024-01-01T10:00:30Z", "2024-01-01T10:01:00Z"},
+		{"*/15 * * * *", "2024-01-01T10:01:00Z", "2024-01-01T10:15:00Z"},
+		{"0 9 * * 1", "2024-01-01T09:00:00Z", "2024-01-08T09:00:00Z"}, // 2024-01-01 是星期一
+		{"0 9 * * 1", "2024-01-01T08:59:59Z", "2024-01-01T09:00:00Z"},
+		{"30 0 1 * *", "2024-01-15T00:00:00Z", "2024-02-01T00:30:00Z"},
+		{"0 0 29 2 *", "2024-03-01T00:00:00Z", "2028-02-29T00:00:00Z"},
+		{"0 12 * * 7", "2024-01-01T00:00:00Z", "2024-01-07T12:00:00Z"}, // 7 也是星期日
+		{"0 0 15 * 5", "2024-01-01T00:00:00Z", "2024-01-05T00:00:00Z"}, // 日與週符合其一即可
+		{"@daily", "2024-12-31T23:59:00Z", "2025-01-01T00:00:00Z"},
+		{"0-10/5 8,20 * * *", "2024-01-01T08:10:00Z", "2024-01-01T20:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		schedule, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", tt.expr, err)
+		}
+		got := schedule.Next(mustParseTime(t, tt.after))
+		if want := mustParseTime(t, tt.want); !got.Equal(want) {
+			t.Errorf("Next(%q, %s) = %s, want %s", tt.expr, tt.after, got.Format(time.RFC3339), tt.want)
+		}
+	}
+}
+
+func TestCronNextNeverMatches(t *testing.T) {
+	schedule, err := ParseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("ParseCron: %v", err)
+	}
+	if next := schedule.Next(time.Now()); !next.IsZero() {
+		t.Errorf("Next = %v, want zero time", next)
+	}
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// MaxCancelCountdown 倒數取消的最長秒數
+const MaxCancelCountdown = 86400
+
+// DeadMansSwitch 使用者的自動撤單設定（預設關閉）
+// 開啟斷線撤單時，使用者最後一個已驗證的 WebSocket 斷開即取消所有待處理的限價單
+// 設定倒數時，必須在倒數結束前再次設定（心跳），否則取消所有待處理的限價單
+type DeadMansSwitch struct {
+	Id                 int64      `orm:"auto" json:"-"`
+	User               *User      `orm:"rel(fk);unique" json:"-"`
+	CancelOnDisconnect bool       `orm:"default(false)" json:"cancelOnDisconnect"`       // 斷線時取消
+	CountdownSeconds   int        `orm:"default(0)" json:"countdownSeconds"`             // 倒數秒數（0 表示未設定）
+	ExpiresAt          *time.Time `orm:"type(datetime);null" json:"expiresAt,omitempty"` // 倒數結束時間
+	UpdatedAt          time.Time  `orm:"auto_now;type(datetime)" json:"updatedAt"`
+}
+
+func init() {
+	orm.RegisterModel(new(DeadMansSwitch))
+}
+
+// TableName 指定資料表名稱
+func (s *DeadMansSwitch) TableName() string {
+	return "dead_mans_switch"
+}
+
+// SetCountdown 設定或重設倒數，seconds 為 0 時關閉倒數
+func (s *DeadMansSwitch) SetCountdown(seconds int, now time.Time) error {
+	if seconds < 0 || seconds > MaxCancelCountdown {
+		return errors.New("countdown must be between 0 and 86400 seconds")
+	}
+	s.CountdownSeconds = seconds
+	if seconds == 0 {
+		s.ExpiresAt = nil
+		return nil
+	}
+	// 資料庫只保存到秒
+	expiresAt := now.Add(time.Duration(seconds) * time.Second).Truncate(time.Second)
+	s.ExpiresAt = &expiresAt
+	return nil
+}
+
+// Armed 是否有任何自動撤單條件開啟
+func (s *DeadMansSwitch) Armed() bool {
+	return s.CancelOnDisconnect || s.ExpiresAt != nil
+}
+
+// GetDeadMansSwitch 查詢使用者的自動撤單設定，未設定時返回預設值（不寫入資料庫）
+func GetDeadMansSwitch(userId int64) (*DeadMansSwitch, error) {
+	o := orm.NewOrm()
+	s := &DeadMansSwitch{}
+	err := o.QueryTable(new(DeadMansSwitch)).Filter("User__Id", userId).One(s)
+	if err == orm.ErrNoRows {
+		return &DeadMansSwitch{User: &User{Id: userId}}, nil
+	}
+	return s, err
+}
+
+// SaveDeadMansSwitch 寫入使用者的自動撤單設定
+func SaveDeadMansSwitch(s *DeadMansSwitch) error {
+	o := orm.NewOrm()
+	if s.Id == 0 {
+		id, err := o.Insert(s)
+		if err != nil {
+			return err
+		}
+		s.Id = id
+		return nil
+	}
+	_, err := o.Update(s, "CancelOnDisconnect", "CountdownSeconds", "ExpiresAt", "UpdatedAt")
+	return err
+}
+
+// GetArmedDeadMansSwitches 查詢所有開啟斷線撤單或倒數中的設定（啟動時載入）
+func GetArmedDeadMansSwitches() ([]*DeadMansSwitch, error) {
+	o := orm.NewOrm()
+	cond := orm.NewCondition()
+	cond = cond.Or("CancelOnDisconnect", true).Or("ExpiresAt__isnull", false)
+
+	var switches []*DeadMansSwitch
+	_, err := o.QueryTable(new(DeadMansSwitch)).
+		SetCond(cond).
+		Limit(-1).
+		All(&switches)
+	return switches, err
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+// TestDeadMansSwitchCountdown 測試設定、重設與關閉倒數
+func TestDeadMansSwitchCountdown(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 500, time.UTC)
+	sw := &DeadMansSwitch{}
+	if sw.Armed() {
+		t.Errorf("expected switch to be disarmed by default")
+	}
+
+	if err := sw.SetCountdown(60, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := time.Date(2024, 1, 1, 12, 1, 0, 0, time.UTC)
+	if sw.ExpiresAt == nil || !sw.ExpiresAt.Equal(want) || !sw.Armed() {
+		t.Errorf("expected countdown to expire at %v, got %v", want, sw.ExpiresAt)
+	}
+
+	if err := sw.SetCountdown(0, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sw.ExpiresAt != nil || sw.Armed() {
+		t.Errorf("expected countdown to be disabled, got %v", sw.ExpiresAt)
+	}
+
+	sw.CancelOnDisconnect = true
+	if !sw.Armed() {
+		t.Errorf("expected cancel on disconnect to arm the switch")
+	}
+
+	for _, seconds := range []int{-1, MaxCancelCountdown + 1} {
+		if err := sw.SetCountdown(seconds, now); err == nil {
+			t.Errorf("expected error for countdown %d", seconds)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// FundingRateMode 資金費率計算方式
+type FundingRateMode string
+
+const (
+	FundingRateModeFixed   FundingRateMode = "fixed"   // 固定費率（設定檔指定，預設）
+	FundingRateModePremium FundingRateMode = "premium" // 依標記價格相對指數價格的溢價計算（需要獨立的指數價格來源）
+)
+
+// FundingRate 每期資金費率記錄
+type FundingRate struct {
+	Id             int64     `orm:"auto" json:"id"`
+	Symbol         string    `orm:"size(20)" json:"symbol"`                     // 交易對
+	Rate           float64   `orm:"digits(20);decimals(8)" json:"rate"`         // 資金費率（正數多頭付給空頭，負數空頭付給多頭）
+	PremiumIndex   float64   `orm:"digits(20);decimals(8)" json:"premiumIndex"` // 本期平均溢價指數
+	MarkPrice      float64   `orm:"digits(20);decimals(8)" json:"markPrice"`    // 結算時的標記價格
+	PositionsCount int       `orm:"default(0)" json:"positionsCount"`           // 本期收付資金費用的倉位數量
+	FundingTime    time.Time `orm:"type(datetime);index" json:"fundingTime"`    // 結算時間
+	CreatedAt      time.Time `orm:"auto_now_add;type(datetime)" json:"createdAt"`
+}
+
+func init() {
+	orm.RegisterModel(new(FundingRate))
+}
+
+// TableName 指定資料表名稱
+func (f *FundingRate) TableName() string {
+	return "funding_rate"
+}
+
+// CalculateFundingRate 依溢價計算資金費率
+// 費率 = 平均溢價 + clamp(利率 - 平均溢價, -clampRange, clampRange)，再以 ±rateCap 為上下限
+func CalculateFundingRate(premiums []float64, interestRate float64, clampRange float64, rateCap float64) (rate float64, averagePremium float64) {
+	if len(premiums) > 0 {
+		var sum float64
+		for _, premium := range premiums {
+			sum += premium
+		}
+		averagePremium = sum / float64(len(premiums))
+	}
+
+	rate = averagePremium + math.Max(-clampRange, math.Min(clampRange, interestRate-averagePremium))
+	rate = math.Max(-rateCap, math.Min(rateCap, rate))
+	return rate, averagePremium
+}
+
+// CreateFundingRate 記錄一期資金費率
+func CreateFundingRate(fundingRate *FundingRate) error {
+	o := orm.NewOrm()
+	id, err := o.Insert(fundingRate)
+	if err != nil {
+		return err
+	}
+	fundingRate.Id = id
+	return nil
+}
+
+// UpdateFundingRatePositionsCount 更新本期收付資金費用的倉位數量
+func UpdateFundingRatePositionsCount(fundingRate *FundingRate) error {
+	o := orm.NewOrm()
+	_, err := o.Update(fundingRate, "PositionsCount")
+	return err
+}
+
+// GetFundingRates 查詢交易對的歷史資金費率（由新到舊）
+func GetFundingRates(symbol string, limit int, offset int) ([]*FundingRate, error) {
+	o := orm.NewOrm()
+	var rates []*FundingRate
+	_, err := o.QueryTable(new(FundingRate)).
+		Filter("Symbol", symbol).
+		OrderBy("-FundingTime", "-Id").
+		Limit(limit, offset).
+		All(&rates)
+	return rates, err
+}
+
+// FundingPayment 計算倉位本期的資金費用（正數收取，負數支付）
+// 費用 = 以標記價格計算的名義價值 * 費率，費率為正時多頭支付、空頭收取
+func (l *LeveragePosition) FundingPayment(rate float64, markPrice float64) float64 {
+	payment := markPrice * l.Quantity * rate
+	if l.Side == PositionSideLong {
+		return -payment
+	}
+	return payment
+}
+
+// ApplyFunding 將資金費用計入倉位，返回實際收付的金額
+// 逐倉倉位直接增減保證金（支付最多扣到保證金為 0），並重新計算爆倉價格；全倉由錢包結算
+func (l *LeveragePosition) ApplyFunding(amount float64, fundingTime time.Time) (float64, error) {
+	if l.Status != PositionStatusOpen {
+		return 0, errors.New("position is not open")
+	}
+	if l.LastFundingTime != nil && !l.LastFundingTime.Before(fundingTime) {
+		return 0, errors.New("funding already applied")
+	}
+
+	if !l.IsCross() {
+		if amount < 0 && -amount > l.Margin {
+			amount = -l.Margin
+		}
+		l.Margin += amount
+		l.LiquidationPrice = l.CalculateLiquidationPrice()
+	}
+
+	l.FundingFee += amount
+	l.LastFundingTime = &fundingTime
+	return amount, nil
+}
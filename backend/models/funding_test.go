@@ -0,0 +1,96 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+// TestCalculateFundingRate 測試依溢價計算資金費率
+func TestCalculateFundingRate(t *testing.T) {
+	tests := []struct {
+		name     string
+		premiums []float64
+		wantRate float64
+	}{
+		// 溢價接近利率時費率等於利率
+		{"no samples", nil, 0.0001},
+		{"small premium", []float64{0.0002, 0.0004}, 0.0001},
+		// 溢價超出調整範圍時跟隨溢價
+		{"high premium", []float64{0.002, 0.004}, 0.0025},
+		{"discount", []float64{-0.003}, -0.0025},
+		// 以上下限截斷
+		{"capped", []float64{0.05}, 0.0075},
+		{"floored", []float64{-0.05}, -0.0075},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, _ := CalculateFundingRate(tt.premiums, 0.0001, 0.0005, 0.0075)
+			if !almostEqual(rate, tt.wantRate) {
+				t.Errorf("expected rate %.6f, got %.6f", tt.wantRate, rate)
+			}
+		})
+	}
+}
+
+// TestFundingPayment 測試多空雙方的資金費用方向
+func TestFundingPayment(t *testing.T) {
+	long := newTestPosition(PositionSideLong)
+	short := newTestPosition(PositionSideShort)
+
+	// 名義價值 110 * 10 = 1100，費率 0.01%
+	if got := long.FundingPayment(0.0001, 110); !almostEqual(got, -0.11) {
+		t.Errorf("expected long to pay 0.11, got %.4f", got)
+	}
+	if got := short.FundingPayment(0.0001, 110); !almostEqual(got, 0.11) {
+		t.Errorf("expected short to receive 0.11, got %.4f", got)
+	}
+	if got := long.FundingPayment(-0.0001, 110); !almostEqual(got, 0.11) {
+		t.Errorf("expected long to receive 0.11 on negative rate, got %.4f", got)
+	}
+}
+
+// TestApplyFundingIsolated 測試逐倉資金費用由保證金收付並重新計算爆倉價格
+func TestApplyFundingIsolated(t *testing.T) {
+	position := newTestPosition(PositionSideLong)
+	originalLiqPrice := position.LiquidationPrice
+	fundingTime := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+
+	applied, err := position.ApplyFunding(-10, fundingTime)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if applied != -10 || !almostEqual(position.Margin, 90) || !almostEqual(position.FundingFee, -10) {
+		t.Errorf("expected applied -10 / margin 90 / fee -10, got %.2f / %.2f / %.2f", applied, position.Margin, position.FundingFee)
+	}
+	if position.LiquidationPrice <= originalLiqPrice {
+		t.Errorf("expected liquidation price to rise after paying funding, got %.2f", position.LiquidationPrice)
+	}
+
+	// 同一期不可重複收付
+	if _, err := position.ApplyFunding(-10, fundingTime); err == nil {
+		t.Error("expected error when applying the same funding twice")
+	}
+
+	// 支付最多扣到保證金為 0
+	applied, err = position.ApplyFunding(-500, fundingTime.Add(8*time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !almostEqual(applied, -90) || position.Margin != 0 {
+		t.Errorf("expected applied -90 / margin 0, got %.2f / %.2f", applied, position.Margin)
+	}
+}
+
+// TestApplyFundingCross 測試全倉資金費用不影響倉位保證金
+func TestApplyFundingCross(t *testing.T) {
+	position := newTestCrossPosition(PositionSideShort, 100)
+
+	applied, err := position.ApplyFunding(-500, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if applied != -500 || position.Margin != 100 || position.FundingFee != -500 {
+		t.Errorf("expected applied -500 / margin 100 / fee -500, got %.2f / %.2f / %.2f", applied, position.Margin, position.FundingFee)
+	}
+}
@@ -0,0 +1,281 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// GridBotStatus 網格機器人狀態
+type GridBotStatus string
+
+const (
+	GridBotStatusRunning GridBotStatus = "RUNNING" // 執行中
+	GridBotStatusStopped GridBotStatus = "STOPPED" // 已停止（使用者停止，掛單已取消）
+	GridBotStatusFailed  GridBotStatus = "FAILED"  // 建立或掛單失敗，已停止
+)
+
+const (
+	// MinGridCount 最少的網格數量
+	MinGridCount = 2
+	// MaxGridCount 最多的網格數量
+	MaxGridCount = 100
+)
+
+// GridBot 網格機器人：在上下限之間等距掛出買賣限價單，每成交一筆就在相鄰的網格掛出反向訂單
+// 價格從 LowerPrice 到 UpperPrice 分成 GridCount 格，共 GridCount+1 個價位（level 0 ~ GridCount）
+type GridBot struct {
+	Id              int64           `orm:"auto" json:"id"`
+	User            *User           `orm:"rel(fk)" json:"-"`
+	Symbol          string          `orm:"size(20)" json:"symbol"`                         // 交易對：BTCUSDT, ETHUSDT, SOLUSDT
+	LowerPrice      float64         `orm:"digits(20);decimals(8)" json:"lowerPrice"`       // 網格下限
+	UpperPrice      float64         `orm:"digits(20);decimals(8)" json:"upperPrice"`       // 網格上限
+	GridCount       int             `json:"gridCount"`                                     // 網格數量
+	Investment      float64         `orm:"digits(20);decimals(8)" json:"investment"`       // 投入的 USDT 金額
+	QuantityPerGrid float64         `orm:"digits(20);decimals(8)" json:"quantityPerGrid"`  // 每格的下單數量
+	EntryPrice      float64         `orm:"digits(20);decimals(8)" json:"entryPrice"`       // 建立時買入賣單庫存的價格
+	RealizedProfit  float64         `orm:"digits(20);decimals(8)" json:"realizedProfit"`   // 已實現的網格利潤（USDT）
+	CompletedCycles int             `orm:"default(0)" json:"completedCycles"`              // 已成交的賣單數量（完成一次低買高賣）
+	Status          GridBotStatus   `orm:"size(20);index" json:"status"`                   // RUNNING, STOPPED or FAILED
+	ErrorMsg        string          `orm:"size(500);null" json:"errorMsg,omitempty"`       // 失敗原因
+	StoppedAt       *time.Time      `orm:"null;type(datetime)" json:"stoppedAt,omitempty"` // 停止時間
+	Orders          []*GridBotOrder `orm:"-" json:"orders,omitempty"`                      // 目前掛出的訂單（查詢單筆時載入）
+	CreatedAt       time.Time       `orm:"auto_now_add;type(datetime)" json:"createdAt"`
+	UpdatedAt       time.Time       `orm:"auto_now;type(datetime)" json:"updatedAt"`
+}
+
+// GridBotOrder 網格機器人在某個價位的掛單，成交或取消後 Active 為 false
+type GridBotOrder struct {
+	Id        int64     `orm:"auto" json:"id"`
+	Bot       *GridBot  `orm:"rel(fk)" json:"-"`
+	OrderId   int64     `orm:"index" json:"orderId"`                              // 限價單 ID
+	Level     int       `json:"level"`                                            // 價位（0 為下限）
+	Side      OrderSide `orm:"size(10)" json:"side"`                              // BUY or SELL
+	Price     float64   `orm:"digits(20);decimals(8)" json:"price"`               // 限價
+	CostPrice float64   `orm:"digits(20);decimals(8)" json:"costPrice,omitempty"` // 賣單庫存的買入價格（計算利潤用）
+	Active    bool      `orm:"default(true);index" json:"active"`                 // 是否仍在掛單中
+	CreatedAt time.Time `orm:"auto_now_add;type(datetime)" json:"createdAt"`
+	UpdatedAt time.Time `orm:"auto_now;type(datetime)" json:"updatedAt"`
+}
+
+func init() {
+	orm.RegisterModel(new(GridBot), new(GridBotOrder))
+}
+
+// TableName 指定資料表名稱
+func (b *GridBot) TableName() string {
+	return "grid_bot"
+}
+
+// TableName 指定資料表名稱
+func (o *GridBotOrder) TableName() string {
+	return "grid_bot_order"
+}
+
+// GridBotParams 建立網格機器人的參數
+type GridBotParams struct {
+	Symbol     string
+	LowerPrice float64
+	UpperPrice float64
+	GridCount  int
+	Investment float64
+}
+
+// Validate 驗證網格參數，目前價格需在上下限之間
+func (p GridBotParams) Validate(currentPrice float64) error {
+	if p.LowerPrice <= 0 || p.UpperPrice <= p.LowerPrice {
+		return errors.New("upper price must be greater than lower price, and both must be positive")
+	}
+	if p.GridCount < MinGridCount || p.GridCount > MaxGridCount {
+		return errors.New("grid count must be between 2 and 100")
+	}
+	if p.Investment <= 0 {
+		return errors.New("investment must be positive")
+	}
+	if currentPrice <= p.LowerPrice || currentPrice >= p.UpperPrice {
+		return errors.New("current price must be between lower price and upper price")
+	}
+	return nil
+}
+
+// NewGridBot 依參數建立網格機器人（尚未寫入資料庫），依目前價格計算每格數量
+func NewGridBot(userId int64, params GridBotParams, currentPrice float64) *GridBot {
+	bot := &GridBot{
+		User:       &User{Id: userId},
+		Symbol:     params.Symbol,
+		LowerPrice: params.LowerPrice,
+		UpperPrice: params.UpperPrice,
+		GridCount:  params.GridCount,
+		Investment: params.Investment,
+		EntryPrice: currentPrice,
+		Status:     GridBotStatusRunning,
+	}
+
+	// 買單以各自的限價花費 USDT，賣單的庫存以目前價格買入
+	buyLevels, sellLevels := bot.Ladder(currentPrice)
+	cost := float64(len(sellLevels)) * currentPrice
+	for _, level := range buyLevels {
+		cost += bot.LevelPrice(level)
+	}
+	bot.QuantityPerGrid = params.Investment / cost
+	return bot
+}
+
+// LevelPrice 價位的價格
+func (b *GridBot) LevelPrice(level int) float64 {
+	step := (b.UpperPrice - b.LowerPrice) / float64(b.GridCount)
+	return b.LowerPrice + step*float64(level)
+}
+
+// Ladder 初始掛單：低於目前價格的價位掛買單，高於的掛賣單
+// 最接近目前價格的價位留空，之後每次成交都在相鄰的空價位掛出反向訂單，確保每個價位最多一張訂單
+func (b *GridBot) Ladder(currentPrice float64) (buyLevels []int, sellLevels []int) {
+	empty := 0
+	for level := 1; level <= b.GridCount; level++ {
+		if math.Abs(b.LevelPrice(level)-currentPrice) < math.Abs(b.LevelPrice(empty)-currentPrice) {
+			empty = level
+		}
+	}
+
+	for level := 0; level <= b.GridCount; level++ {
+		if level < empty {
+			buyLevels = append(buyLevels, level)
+		} else if level > empty {
+			sellLevels = append(sellLevels, level)
+		}
+	}
+	return buyLevels, sellLevels
+}
+
+// CounterOrder 成交後的反向訂單：買單成交後在上一格掛賣單（成本為買入價），賣單成交後在下一格掛買單
+func (b *GridBot) CounterOrder(filled *GridBotOrder) (level int, side OrderSide, costPrice float64, ok bool) {
+	if filled.Side == OrderSideBuy {
+		level, side, costPrice = filled.Level+1, OrderSideSell, filled.Price
+	} else {
+		level, side = filled.Level-1, OrderSideBuy
+	}
+	if level < 0 || level > b.GridCount {
+		return 0, "", 0, false
+	}
+	return level, side, costPrice, true
+}
+
+// RecordSellFill 記錄賣單成交的利潤
+func (b *GridBot) RecordSellFill(filled *GridBotOrder) float64 {
+	profit := (filled.Price - filled.CostPrice) * b.QuantityPerGrid
+	b.RealizedProfit += profit
+	b.CompletedCycles++
+	return profit
+}
+
+// Stop 停止機器人
+func (b *GridBot) Stop(status GridBotStatus, reason string, now time.Time) {
+	b.Status = status
+	b.ErrorMsg = reason
+	b.StoppedAt = &now
+}
+
+// CreateGridBot 寫入網格機器人
+func CreateGridBot(bot *GridBot) error {
+	o := orm.NewOrm()
+	id, err := o.Insert(bot)
+	if err != nil {
+		return err
+	}
+	bot.Id = id
+	return nil
+}
+
+// SaveGridBot 寫回機器人的狀態與利潤（可在交易中使用）
+func SaveGridBot(o orm.QueryExecutor, bot *GridBot) error {
+	_, err := o.Update(bot, "QuantityPerGrid", "EntryPrice", "RealizedProfit", "CompletedCycles",
+		"Status", "ErrorMsg", "StoppedAt", "UpdatedAt")
+	return err
+}
+
+// GetGridBotById 根據 ID 查詢網格機器人
+func GetGridBotById(id int64) (*GridBot, error) {
+	o := orm.NewOrm()
+	bot := &GridBot{Id: id}
+	if err := o.Read(bot); err != nil {
+		if err == orm.ErrNoRows {
+			return nil, errors.New("grid bot not found")
+		}
+		return nil, err
+	}
+	return bot, nil
+}
+
+// GetGridBotsByUser 查詢使用者的網格機器人（由新到舊）
+func GetGridBotsByUser(userId int64, limit int, offset int) ([]*GridBot, error) {
+	o := orm.NewOrm()
+	var bots []*GridBot
+	_, err := o.QueryTable(new(GridBot)).
+		Filter("User__Id", userId).
+		OrderBy("-CreatedAt").
+		Limit(limit, offset).
+		All(&bots)
+	return bots, err
+}
+
+// GetRunningGridBots 查詢所有執行中的網格機器人
+func GetRunningGridBots() ([]*GridBot, error) {
+	o := orm.NewOrm()
+	var bots []*GridBot
+	_, err := o.QueryTable(new(GridBot)).
+		Filter("Status", GridBotStatusRunning).
+		Limit(-1).
+		All(&bots)
+	return bots, err
+}
+
+// CreateGridBotOrder 寫入機器人的掛單記錄
+func CreateGridBotOrder(o orm.QueryExecutor, order *GridBotOrder) error {
+	id, err := o.Insert(order)
+	if err != nil {
+		return err
+	}
+	order.Id = id
+	return nil
+}
+
+// GetActiveGridBotOrders 查詢機器人目前掛出的訂單（依價位排序）
+func GetActiveGridBotOrders(botId int64) ([]*GridBotOrder, error) {
+	o := orm.NewOrm()
+	var orders []*GridBotOrder
+	_, err := o.QueryTable(new(GridBotOrder)).
+		Filter("Bot__Id", botId).
+		Filter("Active", true).
+		OrderBy("Level").
+		Limit(-1).
+		All(&orders)
+	return orders, err
+}
+
+// GetActiveGridBotOrderByOrderId 以限價單 ID 查詢仍在掛單中的記錄
+func GetActiveGridBotOrderByOrderId(orderId int64) (*GridBotOrder, error) {
+	o := orm.NewOrm()
+	order := &GridBotOrder{}
+	err := o.QueryTable(new(GridBotOrder)).
+		Filter("OrderId", orderId).
+		Filter("Active", true).
+		One(order)
+	if err == orm.ErrNoRows {
+		return nil, errors.New("grid bot order not found")
+	}
+	return order, err
+}
+
+// DeactivateGridBotOrder 以 Active 為條件結束掛單記錄，返回是否由這次呼叫結束（避免成交被處理兩次）
+func DeactivateGridBotOrder(o orm.QueryExecutor, id int64) (bool, error) {
+	num, err := o.QueryTable(new(GridBotOrder)).
+		Filter("Id", id).
+		Filter("Active", true).
+		Update(orm.Params{"Active": false, "UpdatedAt": time.Now()})
+	if err != nil {
+		return false, err
+	}
+	return num > 0, nil
+}
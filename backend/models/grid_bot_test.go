@@ -0,0 +1,117 @@
+package models
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func newTestGridBot(currentPrice float64) *GridBot {
+	return NewGridBot(1, GridBotParams{
+		Symbol:     "BTCUSDT",
+		LowerPrice: 100,
+		UpperPrice: 200,
+		GridCount:  4,
+		Investment: 1000,
+	}, currentPrice)
+}
+
+func TestGridBotParamsValidate(t *testing.T) {
+	valid := GridBotParams{Symbol: "BTCUSDT", LowerPrice: 100, UpperPrice: 200, GridCount: 10, Investment: 1000}
+
+	tests := []struct {
+		name         string
+		modify       func(p *GridBotParams)
+		currentPrice float64
+		wantErr      bool
+	}{
+		{"valid", func(p *GridBotParams) {}, 150, false},
+		{"upper below lower", func(p *GridBotParams) { p.UpperPrice = 50 }, 150, true},
+		{"negative lower", func(p *GridBotParams) { p.LowerPrice = -1 }, 150, true},
+		{"too few grids", func(p *GridBotParams) { p.GridCount = 1 }, 150, true},
+		{"too many grids", func(p *GridBotParams) { p.GridCount = 101 }, 150, true},
+		{"no investment", func(p *GridBotParams) { p.Investment = 0 }, 150, true},
+		{"price below range", func(p *GridBotParams) {}, 90, true},
+		{"price at upper", func(p *GridBotParams) {}, 200, true},
+	}
+
+	for _, tt := range tests {
+		params := valid
+		tt.modify(&params)
+		err := params.Validate(tt.currentPrice)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestGridBotLadderLeavesClosestLevelEmpty(t *testing.T) {
+	// 價位：100, 125, 150, 175, 200
+	tests := []struct {
+		currentPrice float64
+		wantBuys     []int
+		wantSells    []int
+	}{
+		{150, []int{0, 1}, []int{3, 4}},
+		{160, []int{0, 1}, []int{3, 4}},
+		{170, []int{0, 1, 2}, []int{4}},
+		{105, nil, []int{1, 2, 3, 4}},
+	}
+
+	for _, tt := range tests {
+		bot := newTestGridBot(tt.currentPrice)
+		buys, sells := bot.Ladder(tt.currentPrice)
+		if !reflect.DeepEqual(buys, tt.wantBuys) || !reflect.DeepEqual(sells, tt.wantSells) {
+			t.Errorf("Ladder(%v) = %v, %v, want %v, %v", tt.currentPrice, buys, sells, tt.wantBuys, tt.wantSells)
+		}
+	}
+}
+
+func TestNewGridBotSpendsInvestment(t *testing.T) {
+	bot := newTestGridBot(150)
+
+	// 買單 100 + 125，賣單庫存 2 * 150
+	want := 1000.0 / (100 + 125 + 2*150)
+	if math.Abs(bot.QuantityPerGrid-want) > 1e-9 {
+		t.Errorf("QuantityPerGrid = %v, want %v", bot.QuantityPerGrid, want)
+	}
+	if bot.LevelPrice(3) != 175 {
+		t.Errorf("LevelPrice(3) = %v, want 175", bot.LevelPrice(3))
+	}
+}
+
+func TestGridBotCounterOrder(t *testing.T) {
+	bot := newTestGridBot(150)
+
+	level, side, cost, ok := bot.CounterOrder(&GridBotOrder{Level: 1, Side: OrderSideBuy, Price: 125})
+	if !ok || level != 2 || side != OrderSideSell || cost != 125 {
+		t.Errorf("buy fill counter = %d %s %v %v, want 2 SELL 125 true", level, side, cost, ok)
+	}
+
+	level, side, _, ok = bot.CounterOrder(&GridBotOrder{Level: 3, Side: OrderSideSell, Price: 175, CostPrice: 150})
+	if !ok || level != 2 || side != OrderSideBuy {
+		t.Errorf("sell fill counter = %d %s %v, want 2 BUY true", level, side, ok)
+	}
+
+	// 超出網格範圍時不掛反向訂單
+	if _, _, _, ok = bot.CounterOrder(&GridBotOrder{Level: 4, Side: OrderSideBuy, Price: 200}); ok {
+		t.Error("buy fill at top level should not place a counter order")
+	}
+	if _, _, _, ok = bot.CounterOrder(&GridBotOrder{Level: 0, Side: OrderSideSell, Price: 100}); ok {
+		t.Error("sell fill at bottom level should not place a counter order")
+	}
+}
+
+func TestGridBotRecordSellFill(t *testing.T) {
+	bot := newTestGridBot(150)
+	bot.QuantityPerGrid = 2
+
+	profit := bot.RecordSellFill(&GridBotOrder{Level: 2, Side: OrderSideSell, Price: 150, CostPrice: 125})
+	if profit != 50 {
+		t.Errorf("profit = %v, want 50", profit)
+	}
+	bot.RecordSellFill(&GridBotOrder{Level: 3, Side: OrderSideSell, Price: 175, CostPrice: 150})
+	if bot.RealizedProfit != 100 || bot.CompletedCycles != 2 {
+		t.Errorf("RealizedProfit = %v, CompletedCycles = %d, want 100, 2", bot.RealizedProfit, bot.CompletedCycles)
+	}
+}
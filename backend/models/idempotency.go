@@ -0,0 +1,171 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// MaxIdempotencyKeyLength Idempotency-Key 與 clientOrderId 的最大長度
+const MaxIdempotencyKeyLength = 64
+
+// IdempotencyClaimTimeout 處理中的記錄超過此時間未完成時視為原始請求已中斷（例如行程結束）
+// 原始請求已建立訂單時以該訂單返回結果，尚未建立時由重送的請求接手
+// 下單最多等待 10 秒取得價格，逾時遠大於正常的處理時間
+const IdempotencyClaimTimeout = 2 * time.Minute
+
+// IdempotencyRecord 下單請求的冪等記錄，同一使用者的 Key 唯一
+// Key 為 "key:" + Idempotency-Key 或 "cid:" + clientOrderId，StatusCode 為 0 表示請求仍在處理中
+// OrderId 與訂單在同一交易中寫入，原始請求在保存回應前中斷時，重送的請求以此得知訂單已建立
+type IdempotencyRecord struct {
+	Id          int64     `orm:"auto" json:"-"`
+	User        *User     `orm:"rel(fk)" json:"-"`
+	Key         string    `orm:"size(100)" json:"key"`
+	RequestHash string    `orm:"size(64)" json:"-"`            // 請求內容的雜湊，用來拒絕以相同 Key 送出不同的請求
+	StatusCode  int       `orm:"default(0)" json:"statusCode"` // 原始回應的 HTTP 狀態碼
+	Response    string    `orm:"type(text);null" json:"-"`     // 原始回應內容（JSON）
+	OrderId     int64     `orm:"default(0)" json:"-"`          // 請求建立的訂單（0 表示尚未建立）
+	CreatedAt   time.Time `orm:"auto_now_add;type(datetime)" json:"createdAt"`
+	UpdatedAt   time.Time `orm:"auto_now;type(datetime)" json:"updatedAt"`
+}
+
+func init() {
+	orm.RegisterModel(new(IdempotencyRecord))
+}
+
+// TableName 指定資料表名稱
+func (r *IdempotencyRecord) TableName() string {
+	return "idempotency_record"
+}
+
+// TableUnique 同一使用者的 Key 不可重複
+func (r *IdempotencyRecord) TableUnique() [][]string {
+	return [][]string{{"User", "Key"}}
+}
+
+// InProgress 原始請求是否仍在處理中
+func (r *IdempotencyRecord) InProgress() bool {
+	return r.StatusCode == 0
+}
+
+// Abandoned 處理中的記錄是否已逾時（原始請求中斷、回應未記錄）
+func (r *IdempotencyRecord) Abandoned(now time.Time) bool {
+	return r.InProgress() && now.Sub(r.UpdatedAt) > IdempotencyClaimTimeout
+}
+
+// ValidateIdempotencyKey 驗證 Idempotency-Key 或 clientOrderId 的格式
+func ValidateIdempotencyKey(key string) error {
+	if key == "" || len(key) > MaxIdempotencyKeyLength {
+		return errors.New("key must be between 1 and 64 characters")
+	}
+	if strings.TrimSpace(key) != key || strings.ContainsAny(key, "\r\n\t") {
+		return errors.New("key must not contain whitespace at either end or control characters")
+	}
+	return nil
+}
+
+// ClaimIdempotencyKeys 在同一交易中為請求登記所有 Key
+// 任一 Key 已存在時不登記任何 Key，返回已存在的記錄（由呼叫端決定重送原本的結果或拒絕）
+// 相同請求的記錄處理中逾時且尚未建立訂單時由本次請求接手，避免原始請求中斷後永遠無法重試；
+// 已建立訂單時返回記錄，由呼叫端以訂單返回結果，不再重複下單
+func ClaimIdempotencyKeys(userId int64, keys []string, requestHash string) (*IdempotencyRecord, error) {
+	o := orm.NewOrm()
+	to, err := o.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		record := &IdempotencyRecord{User: &User{Id: userId}, Key: key, RequestHash: requestHash}
+		if _, err = to.Insert(record); err == nil {
+			continue
+		}
+
+		// 唯一索引衝突：讀取已存在的記錄（可能由同時送出的請求建立）
+		existing, readErr := GetIdempotencyRecord(userId, key)
+		if readErr != nil {
+			to.Rollback()
+			return nil, err
+		}
+		if existing.RequestHash == requestHash && existing.Abandoned(time.Now()) && existing.OrderId == 0 {
+			taken, takeErr := takeOverIdempotencyRecord(to, existing)
+			if takeErr != nil {
+				to.Rollback()
+				return nil, takeErr
+			}
+			if taken {
+				continue
+			}
+		}
+		to.Rollback()
+		return existing, nil
+	}
+
+	return nil, to.Commit()
+}
+
+// takeOverIdempotencyRecord 接手逾時的處理中記錄，以更新時間為條件，同時重送的請求只有一個能接手
+func takeOverIdempotencyRecord(o orm.QueryExecutor, record *IdempotencyRecord) (bool, error) {
+	num, err := o.QueryTable(new(IdempotencyRecord)).
+		Filter("Id", record.Id).
+		Filter("StatusCode", 0).
+		Filter("UpdatedAt", record.UpdatedAt).
+		Update(orm.Params{"UpdatedAt": time.Now()})
+	if err != nil {
+		return false, err
+	}
+	return num == 1, nil
+}
+
+// LinkIdempotencyKeys 記錄請求建立的訂單（需要在建立訂單的交易中使用，訂單與記錄一併提交）
+func LinkIdempotencyKeys(o orm.QueryExecutor, userId int64, keys []string, orderId int64) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := o.QueryTable(new(IdempotencyRecord)).
+		Filter("User__Id", userId).
+		Filter("Key__in", keys).
+		Filter("StatusCode", 0).
+		Update(orm.Params{"OrderId": orderId, "UpdatedAt": time.Now()})
+	return err
+}
+
+// CompleteIdempotencyKeys 保存請求的回應，之後以相同 Key 重送時直接返回
+func CompleteIdempotencyKeys(userId int64, keys []string, statusCode int, response string) error {
+	o := orm.NewOrm()
+	_, err := o.QueryTable(new(IdempotencyRecord)).
+		Filter("User__Id", userId).
+		Filter("Key__in", keys).
+		Update(orm.Params{
+			"StatusCode": statusCode,
+			"Response":   response,
+			"UpdatedAt":  time.Now(),
+		})
+	return err
+}
+
+// ReleaseIdempotencyKeys 刪除請求的 Key（伺服器錯誤時使用，讓使用者可以用相同 Key 重試）
+func ReleaseIdempotencyKeys(userId int64, keys []string) error {
+	o := orm.NewOrm()
+	_, err := o.QueryTable(new(IdempotencyRecord)).
+		Filter("User__Id", userId).
+		Filter("Key__in", keys).
+		Delete()
+	return err
+}
+
+// GetIdempotencyRecord 查詢使用者的冪等記錄
+func GetIdempotencyRecord(userId int64, key string) (*IdempotencyRecord, error) {
+	o := orm.NewOrm()
+	record := &IdempotencyRecord{}
+	err := o.QueryTable(new(IdempotencyRecord)).
+		Filter("User__Id", userId).
+		Filter("Key", key).
+		One(record)
+	if err == orm.ErrNoRows {
+		return nil, errors.New("idempotency record not found")
+	}
+	return record, err
+}
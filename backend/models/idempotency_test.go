@@ -0,0 +1,54 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestValidateIdempotencyKey 測試 Idempotency-Key 與 clientOrderId 的格式限制
+func TestValidateIdempotencyKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantErr bool
+	}{
+		{"uuid", "4f5c2b1e-8a0d-4d5e-9c3b-2f1a6e7d8c9b", false},
+		{"max length", strings.Repeat("a", MaxIdempotencyKeyLength), false},
+		{"empty", "", true},
+		{"too long", strings.Repeat("a", MaxIdempotencyKeyLength+1), true},
+		{"leading space", " abc", true},
+		{"newline", "abc\ndef", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateIdempotencyKey(tt.key); (err != nil) != tt.wantErr {
+				t.Errorf("expected error %t, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestIdempotencyRecordAbandoned 測試處理中的記錄逾時後可以被重送的請求接手
+func TestIdempotencyRecordAbandoned(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		record IdempotencyRecord
+		want   bool
+	}{
+		{"in progress", IdempotencyRecord{UpdatedAt: now.Add(-time.Minute)}, false},
+		{"in progress timed out", IdempotencyRecord{UpdatedAt: now.Add(-IdempotencyClaimTimeout - time.Second)}, true},
+		{"completed", IdempotencyRecord{StatusCode: 200, UpdatedAt: now.Add(-time.Hour)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.record.Abandoned(now); got != tt.want {
+				t.Errorf("expected abandoned %t, got %t", tt.want, got)
+			}
+		})
+	}
+}
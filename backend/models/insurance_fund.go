@@ -0,0 +1,160 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// InsuranceFundEntryType 保險基金變動類型
+type InsuranceFundEntryType string
+
+const (
+	InsuranceFundEntryLiquidationSurplus InsuranceFundEntryType = "LIQUIDATION_SURPLUS" // 爆倉價格優於破產價格，剩餘保證金轉入基金
+	InsuranceFundEntryBankruptcyCover    InsuranceFundEntryType = "BANKRUPTCY_COVER"    // 價格跳空越過破產價格，虧損由基金承擔
+	InsuranceFundEntryUncoveredLoss      InsuranceFundEntryType = "UNCOVERED_LOSS"      // 基金餘額不足，未承擔的穿倉虧損記為赤字
+	InsuranceFundEntryFunding            InsuranceFundEntryType = "FUNDING"             // 資金費用的對手方：倉位支付的費用轉入基金，收取的費用由基金撥出
+)
+
+// InsuranceFund 系統保險基金帳戶
+type InsuranceFund struct {
+	Id        int64     `orm:"auto" json:"id"`
+	Symbol    string    `orm:"size(20);unique" json:"symbol"`                    // 幣種：USDT
+	Balance   float64   `orm:"digits(20);decimals(8)" json:"balance"`            // 基金餘額
+	Deficit   float64   `orm:"digits(20);decimals(8);default(0)" json:"deficit"` // 基金不足以承擔的穿倉虧損累計（平台壞帳）
+	CreatedAt time.Time `orm:"auto_now_add;type(datetime)" json:"createdAt"`
+	UpdatedAt time.Time `orm:"auto_now;type(datetime)" json:"updatedAt"`
+}
+
+// InsuranceFundHistory 保險基金餘額變動記錄
+type InsuranceFundHistory struct {
+	Id            int64                  `orm:"auto" json:"id"`
+	Symbol        string                 `orm:"size(20)" json:"symbol"`
+	Type          InsuranceFundEntryType `orm:"size(30)" json:"type"`
+	Amount        float64                `orm:"digits(20);decimals(8)" json:"amount"`        // 金額（正數轉入，負數撥出）
+	BalanceBefore float64                `orm:"digits(20);decimals(8)" json:"balanceBefore"` // 變動前餘額
+	BalanceAfter  float64                `orm:"digits(20);decimals(8)" json:"balanceAfter"`  // 變動後餘額
+	Position      *LeveragePosition      `orm:"rel(fk);null" json:"-"`                       // 關聯的爆倉倉位（可為空）
+	User          *User                  `orm:"rel(fk);null" json:"-"`                       // 被爆倉的使用者（可為空）
+	Description   string                 `orm:"size(500);null" json:"description,omitempty"`
+	CreatedAt     time.Time              `orm:"auto_now_add;type(datetime)" json:"createdAt"`
+}
+
+func init() {
+	orm.RegisterModel(new(InsuranceFund), new(InsuranceFundHistory))
+}
+
+// TableName 指定資料表名稱
+func (f *InsuranceFund) TableName() string {
+	return "insurance_fund"
+}
+
+// TableName 指定資料表名稱
+func (h *InsuranceFundHistory) TableName() string {
+	return "insurance_fund_history"
+}
+
+// EnsureInsuranceFund 確保保險基金帳戶存在，不存在時以初始餘額建立
+func EnsureInsuranceFund(symbol string, initialBalance float64) (*InsuranceFund, error) {
+	o := orm.NewOrm()
+	fund := &InsuranceFund{Symbol: symbol, Balance: initialBalance}
+	_, _, err := o.ReadOrCreate(fund, "Symbol")
+	if err != nil {
+		return nil, err
+	}
+	return fund, nil
+}
+
+// GetInsuranceFund 查詢保險基金帳戶
+func GetInsuranceFund(symbol string) (*InsuranceFund, error) {
+	o := orm.NewOrm()
+	fund := &InsuranceFund{}
+	err := o.QueryTable(new(InsuranceFund)).Filter("Symbol", symbol).One(fund)
+	if err == orm.ErrNoRows {
+		return nil, errors.New("insurance fund not found")
+	}
+	return fund, err
+}
+
+// GetInsuranceFundForUpdate 在交易中以 FOR UPDATE 鎖定並讀取保險基金帳戶
+func GetInsuranceFundForUpdate(o orm.QueryExecutor, symbol string) (*InsuranceFund, error) {
+	fund := &InsuranceFund{}
+	err := o.QueryTable(new(InsuranceFund)).Filter("Symbol", symbol).ForUpdate().One(fund)
+	if err == orm.ErrNoRows {
+		return nil, errors.New("insurance fund not found")
+	}
+	return fund, err
+}
+
+// Apply 變動基金餘額，返回實際變動的金額
+// 撥出金額最多為目前餘額，不足的部分累計到赤字
+func (f *InsuranceFund) Apply(amount float64) float64 {
+	if amount < 0 && -amount > f.Balance {
+		f.Deficit += -amount - f.Balance
+		amount = -f.Balance
+	}
+	f.Balance += amount
+	return amount
+}
+
+// ApplyInsuranceFund 變動保險基金餘額並記錄（需要在交易中使用），返回實際變動的金額
+// 基金餘額不足以承擔的虧損累計到赤字，並另外記錄一筆 UNCOVERED_LOSS
+func ApplyInsuranceFund(o orm.QueryExecutor, symbol string, amount float64, entryType InsuranceFundEntryType, position *LeveragePosition, userId int64, description string) (float64, error) {
+	if amount == 0 {
+		return 0, nil
+	}
+
+	fund, err := GetInsuranceFundForUpdate(o, symbol)
+	if err != nil {
+		return 0, err
+	}
+
+	balanceBefore := fund.Balance
+	applied := fund.Apply(amount)
+	if _, err = o.Update(fund, "Balance", "Deficit", "UpdatedAt"); err != nil {
+		return 0, err
+	}
+
+	if applied != 0 {
+		if err = insertInsuranceFundHistory(o, symbol, entryType, applied, balanceBefore, fund.Balance, position, userId, description); err != nil {
+			return 0, err
+		}
+	}
+	if uncovered := amount - applied; uncovered != 0 {
+		if err = insertInsuranceFundHistory(o, symbol, InsuranceFundEntryUncoveredLoss, uncovered, fund.Balance, fund.Balance, position, userId, description); err != nil {
+			return 0, err
+		}
+	}
+	return applied, nil
+}
+
+// insertInsuranceFundHistory 寫入保險基金變動記錄
+func insertInsuranceFundHistory(o orm.QueryExecutor, symbol string, entryType InsuranceFundEntryType, amount float64, balanceBefore float64, balanceAfter float64, position *LeveragePosition, userId int64, description string) error {
+	history := &InsuranceFundHistory{
+		Symbol:        symbol,
+		Type:          entryType,
+		Amount:        amount,
+		BalanceBefore: balanceBefore,
+		BalanceAfter:  balanceAfter,
+		Position:      position,
+		Description:   description,
+	}
+	if userId > 0 {
+		history.User = &User{Id: userId}
+	}
+	_, err := o.Insert(history)
+	return err
+}
+
+// GetInsuranceFundHistory 查詢保險基金餘額變動記錄
+func GetInsuranceFundHistory(symbol string, limit int, offset int) ([]*InsuranceFundHistory, error) {
+	o := orm.NewOrm()
+	var histories []*InsuranceFundHistory
+	_, err := o.QueryTable(new(InsuranceFundHistory)).
+		Filter("Symbol", symbol).
+		OrderBy("-CreatedAt", "-Id").
+		Limit(limit, offset).
+		All(&histories)
+	return histories, err
+}
@@ -0,0 +1,67 @@
+package models
+
+import "testing"
+
+// TestInsuranceFundApply 測試保險基金轉入與撥出
+func TestInsuranceFundApply(t *testing.T) {
+	fund := &InsuranceFund{Symbol: "USDT", Balance: 100}
+
+	if applied := fund.Apply(30); applied != 30 || fund.Balance != 130 {
+		t.Errorf("expected applied 30 / balance 130, got %.2f / %.2f", applied, fund.Balance)
+	}
+	if applied := fund.Apply(-50); applied != -50 || fund.Balance != 80 {
+		t.Errorf("expected applied -50 / balance 80, got %.2f / %.2f", applied, fund.Balance)
+	}
+
+	// 撥出金額最多為基金餘額
+	if applied := fund.Apply(-200); applied != -80 || fund.Balance != 0 {
+		t.Errorf("expected applied -80 / balance 0, got %.2f / %.2f", applied, fund.Balance)
+	}
+
+	// 不足的部分累計到赤字，之後的轉入不會自動抵銷
+	if fund.Deficit != 120 {
+		t.Errorf("expected deficit 120, got %.2f", fund.Deficit)
+	}
+	if applied := fund.Apply(-10); applied != 0 || fund.Deficit != 130 {
+		t.Errorf("expected applied 0 / deficit 130, got %.2f / %.2f", applied, fund.Deficit)
+	}
+	if applied := fund.Apply(20); applied != 20 || fund.Balance != 20 || fund.Deficit != 130 {
+		t.Errorf("expected applied 20 / balance 20 / deficit 130, got %.2f / %.2f / %.2f", applied, fund.Balance, fund.Deficit)
+	}
+}
+
+// TestLiquidationSurplus 測試爆倉時剩餘保證金與穿倉虧損
+func TestLiquidationSurplus(t *testing.T) {
+	tests := []struct {
+		name        string
+		side        PositionSide
+		exitPrice   float64
+		wantSurplus float64
+	}{
+		{"long better than bankruptcy", PositionSideLong, 91, 10},
+		{"long at bankruptcy", PositionSideLong, 90, 0},
+		{"long gap past bankruptcy", PositionSideLong, 85, -50},
+		{"short better than bankruptcy", PositionSideShort, 109, 10},
+		{"short gap past bankruptcy", PositionSideShort, 112, -20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			position := newTestPosition(tt.side)
+			if got := position.LiquidationSurplus(tt.exitPrice); !almostEqual(got, tt.wantSurplus) {
+				t.Errorf("expected surplus %.2f, got %.2f", tt.wantSurplus, got)
+			}
+		})
+	}
+
+	long := newTestPosition(PositionSideLong)
+	short := newTestPosition(PositionSideShort)
+	if !almostEqual(long.BankruptcyPrice(), 90) || !almostEqual(short.BankruptcyPrice(), 110) {
+		t.Errorf("expected bankruptcy prices 90 / 110, got %.2f / %.2f", long.BankruptcyPrice(), short.BankruptcyPrice())
+	}
+
+	// 爆倉價格介於開倉價格與破產價格之間
+	if long.LiquidationPrice <= long.BankruptcyPrice() || short.LiquidationPrice >= short.BankruptcyPrice() {
+		t.Error("liquidation price should be triggered before bankruptcy price")
+	}
+}
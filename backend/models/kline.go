@@ -0,0 +1,98 @@
+package models
+
+import (
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// klineInsertBatchSize 批次寫入 K 線的筆數
+const klineInsertBatchSize = 500
+
+// Kline 儲存的歷史 K 線（回測使用），同一交易對、週期的開盤時間唯一
+type Kline struct {
+	Id        int64     `orm:"auto" json:"-"`
+	Symbol    string    `orm:"size(20)" json:"symbol"`               // 交易對：BTCUSDT, ETHUSDT, SOLUSDT
+	Interval  string    `orm:"size(10)" json:"interval"`             // K 線週期：1m, 5m, 1h, 1d ...
+	OpenTime  time.Time `orm:"type(datetime);index" json:"openTime"` // 開盤時間（UTC）
+	Open      float64   `orm:"digits(20);decimals(8)" json:"open"`
+	High      float64   `orm:"digits(20);decimals(8)" json:"high"`
+	Low       float64   `orm:"digits(20);decimals(8)" json:"low"`
+	Close     float64   `orm:"digits(20);decimals(8)" json:"close"`
+	Volume    float64   `orm:"digits(30);decimals(8)" json:"volume"`
+	CreatedAt time.Time `orm:"auto_now_add;type(datetime)" json:"-"`
+}
+
+func init() {
+	orm.RegisterModel(new(Kline))
+}
+
+// TableName 指定資料表名稱
+func (k *Kline) TableName() string {
+	return "kline"
+}
+
+// TableUnique 同一交易對、週期的開盤時間不可重複
+func (k *Kline) TableUnique() [][]string {
+	return [][]string{{"Symbol", "Interval", "OpenTime"}}
+}
+
+// GetKlines 查詢 [start, end) 之間的 K 線（依開盤時間排序）
+func GetKlines(symbol string, interval string, start time.Time, end time.Time) ([]*Kline, error) {
+	o := orm.NewOrm()
+	var klines []*Kline
+	_, err := o.QueryTable(new(Kline)).
+		Filter("Symbol", symbol).
+		Filter("Interval", interval).
+		Filter("OpenTime__gte", start).
+		Filter("OpenTime__lt", end).
+		OrderBy("OpenTime").
+		Limit(-1).
+		All(&klines)
+	return klines, err
+}
+
+// SaveKlines 寫入 K 線，已儲存的開盤時間略過，返回新寫入的筆數
+func SaveKlines(klines []*Kline) (int, error) {
+	if len(klines) == 0 {
+		return 0, nil
+	}
+
+	// 1. 查詢範圍內已儲存的開盤時間
+	start, end := klines[0].OpenTime, klines[0].OpenTime
+	for _, kline := range klines {
+		if kline.OpenTime.Before(start) {
+			start = kline.OpenTime
+		}
+		if kline.OpenTime.After(end) {
+			end = kline.OpenTime
+		}
+	}
+
+	existing, err := GetKlines(klines[0].Symbol, klines[0].Interval, start, end.Add(time.Second))
+	if err != nil {
+		return 0, err
+	}
+	stored := make(map[int64]bool, len(existing))
+	for _, kline := range existing {
+		stored[kline.OpenTime.Unix()] = true
+	}
+
+	// 2. 批次寫入缺少的 K 線
+	var missing []*Kline
+	for _, kline := range klines {
+		if !stored[kline.OpenTime.Unix()] {
+			stored[kline.OpenTime.Unix()] = true
+			missing = append(missing, kline)
+		}
+	}
+	if len(missing) == 0 {
+		return 0, nil
+	}
+
+	o := orm.NewOrm()
+	if _, err = o.InsertMulti(klineInsertBatchSize, missing); err != nil {
+		return 0, err
+	}
+	return len(missing), nil
+}
@@ -0,0 +1,521 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// LeverageLedger 槓桿帳務的錢包與倉位儲存
+// 實盤以資料庫交易實作（TxLeverageLedger），回測以記憶體中的模擬帳戶實作
+// 成交、減倉、爆倉與資金費用的結算步驟只寫在這個檔案，兩者共用同一套邏輯
+type LeverageLedger interface {
+	// Wallet 鎖定並讀取 USDT 錢包
+	Wallet(userId int64) (*Wallet, error)
+	// SaveWallet 寫回錢包的餘額與凍結金額
+	SaveWallet(wallet *Wallet) error
+	PositionMode(userId int64) (PositionMode, error)
+	MarginMode(userId int64) (MarginMode, error)
+	// OpenPosition 鎖定並讀取某交易對、某方向的持倉（沒有時返回 nil）
+	OpenPosition(userId int64, symbol string, side PositionSide) (*LeveragePosition, error)
+	CreatePosition(position *LeveragePosition) error
+	// UpdatePosition 寫回加倉、部分平倉或收付資金費用後的倉位
+	UpdatePosition(position *LeveragePosition) error
+	// SaveSettledPosition 寫回已平倉或爆倉的倉位（倉位需仍為持倉中）
+	SaveSettledPosition(position *LeveragePosition) error
+	RecordPositionHistory(position *LeveragePosition, action PositionAction, price float64, quantityChange float64, marginChange float64, realizedPnL float64) error
+	RecordTransaction(userId int64, orderId *int64, txType TransactionType, amount float64, balanceBefore float64, balanceAfter float64, description string) error
+	// ApplyInsuranceFund 變動 USDT 保險基金，返回實際變動的金額（不足的部分記為赤字）
+	ApplyInsuranceFund(amount float64, entryType InsuranceFundEntryType, position *LeveragePosition, userId int64, description string) (float64, error)
+}
+
+// TxLeverageLedger 以資料庫交易實作的槓桿帳務
+type TxLeverageLedger struct {
+	o orm.QueryExecutor
+}
+
+// NewTxLeverageLedger 建立資料庫交易中的槓桿帳務（需要在交易中使用）
+func NewTxLeverageLedger(o orm.QueryExecutor) *TxLeverageLedger {
+	return &TxLeverageLedger{o: o}
+}
+
+func (l *TxLeverageLedger) Wallet(userId int64) (*Wallet, error) {
+	wallet, err := GetWalletForUpdate(l.o, userId, "USDT")
+	if err != nil {
+		return nil, errors.New("USDT wallet not found")
+	}
+	return wallet, nil
+}
+
+func (l *TxLeverageLedger) SaveWallet(wallet *Wallet) error {
+	_, err := l.o.Update(wallet, "Balance", "Locked")
+	return err
+}
+
+func (l *TxLeverageLedger) PositionMode(userId int64) (PositionMode, error) {
+	return GetUserPositionMode(l.o, userId)
+}
+
+func (l *TxLeverageLedger) MarginMode(userId int64) (MarginMode, error) {
+	return GetUserMarginMode(l.o, userId)
+}
+
+func (l *TxLeverageLedger) OpenPosition(userId int64, symbol string, side PositionSide) (*LeveragePosition, error) {
+	return GetOpenPositionForUpdate(l.o, userId, symbol, side)
+}
+
+func (l *TxLeverageLedger) CreatePosition(position *LeveragePosition) error {
+	_, err := l.o.Insert(position)
+	return err
+}
+
+func (l *TxLeverageLedger) UpdatePosition(position *LeveragePosition) error {
+	_, err := l.o.Update(position, "EntryPrice", "Quantity", "Margin", "LiquidationPrice", "UnrealizedPnL", "RealizedPnL",
+		"FundingFee", "LastFundingTime", "UpdatedAt")
+	return err
+}
+
+func (l *TxLeverageLedger) SaveSettledPosition(position *LeveragePosition) error {
+	return saveSettledPosition(l.o, position)
+}
+
+func (l *TxLeverageLedger) RecordPositionHistory(position *LeveragePosition, action PositionAction, price float64, quantityChange float64, marginChange float64, realizedPnL float64) error {
+	_, err := CreatePositionHistory(l.o, position, action, price, quantityChange, marginChange, realizedPnL)
+	return err
+}
+
+func (l *TxLeverageLedger) RecordTransaction(userId int64, orderId *int64, txType TransactionType, amount float64, balanceBefore float64, balanceAfter float64, description string) error {
+	_, err := CreateTransaction(l.o, userId, orderId, txType, "USDT", amount, balanceBefore, balanceAfter, description)
+	return err
+}
+
+func (l *TxLeverageLedger) ApplyInsuranceFund(amount float64, entryType InsuranceFundEntryType, position *LeveragePosition, userId int64, description string) (float64, error) {
+	return ApplyInsuranceFund(l.o, "USDT", amount, entryType, position, userId, description)
+}
+
+// LeverageFill 槓桿訂單成交後的倉位變動
+type LeverageFill struct {
+	Reduced        *LeveragePosition // 被減少的反方向持倉（沒有減倉時為 nil）
+	ReducedClosed  bool              // 反方向持倉是否已全部平倉
+	ReduceQuantity float64
+	RealizedPnL    float64
+	Position       *LeveragePosition // 開倉或加倉後的持倉（沒有開倉時為 nil）
+	Increased      bool
+	OpenQuantity   float64
+	Margin         float64 // 轉入新倉位的保證金
+}
+
+// Result 返回給呼叫端顯示的倉位：有開倉時為新倉位，否則為被減少的倉位
+func (f *LeverageFill) Result() *LeveragePosition {
+	if f.Position != nil {
+		return f.Position
+	}
+	return f.Reduced
+}
+
+// FillLeverageOrder 依使用者的持倉模式處理槓桿訂單成交
+// 單向持倉時先減少反方向持倉，剩餘數量才開新倉（反手）；雙向持倉時直接開倉或加倉
+// 只減倉訂單在兩種模式下都只減少反方向持倉，超過持倉的數量不會成交
+// lockedMargin 為限價單掛單時凍結的保證金（市價單為 0），成交時全部解除凍結，再扣除實際開倉所需的保證金
+func FillLeverageOrder(l LeverageLedger, userId int64, order *Order, symbol string, side PositionSide, leverage int, price float64, quantity float64, reduceOnly bool, lockedMargin float64) (*LeverageFill, error) {
+	fill := &LeverageFill{}
+
+	// 1. 先鎖定錢包，與開倉、掛單使用相同的鎖順序
+	if _, err := l.Wallet(userId); err != nil {
+		return nil, err
+	}
+
+	mode, err := l.PositionMode(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. 單向持倉或只減倉訂單先減少反方向持倉
+	var oppositeQuantity float64
+	if reduceOnly || mode == PositionModeOneWay {
+		fill.Reduced, err = l.OpenPosition(userId, symbol, side.Opposite())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get position: %v", err)
+		}
+		if fill.Reduced != nil {
+			oppositeQuantity = fill.Reduced.Quantity
+		}
+	}
+	if reduceOnly && fill.Reduced == nil {
+		return nil, errors.New("reduce-only order has no position to reduce")
+	}
+
+	fill.ReduceQuantity, fill.OpenQuantity = SplitOrderQuantity(quantity, oppositeQuantity, reduceOnly)
+	if fill.ReduceQuantity > 0 {
+		fill.RealizedPnL, fill.ReducedClosed, err = ReducePosition(l, fill.Reduced, userId, fill.ReduceQuantity, price)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		fill.Reduced = nil
+	}
+
+	// 3. 結算後重新讀取錢包，解除凍結並扣除開倉所需的保證金
+	wallet, err := l.Wallet(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if lockedMargin > 0 {
+		if err = wallet.UnlockMargin(lockedMargin); err != nil {
+			return nil, err
+		}
+	}
+
+	balanceBefore := wallet.Balance
+	if fill.OpenQuantity > 0 {
+		fill.Margin = CalculateRequiredMargin(price, fill.OpenQuantity, leverage)
+		if err = wallet.TransferMarginToPosition(fill.Margin, false); err != nil {
+			return nil, err
+		}
+	}
+	if err = l.SaveWallet(wallet); err != nil {
+		return nil, fmt.Errorf("failed to deduct margin: %v", err)
+	}
+
+	if fill.OpenQuantity <= 0 {
+		return fill, nil
+	}
+
+	// 4. 剩餘數量建立新倉位（已有同方向持倉時加倉）
+	fill.Position, fill.Increased, err = openOrIncreasePosition(l, userId, order, symbol, side, leverage, price, fill.OpenQuantity, fill.Margin)
+	if err != nil {
+		return nil, err
+	}
+
+	var orderId *int64
+	if order != nil {
+		orderId = &order.Id
+	}
+	description := fmt.Sprintf("Open %s position #%d with %dx leverage", side, fill.Position.Id, leverage)
+	if fill.Increased {
+		description = fmt.Sprintf("Increase %s position #%d by %.8f with %dx leverage", side, fill.Position.Id, fill.OpenQuantity, leverage)
+	}
+	err = l.RecordTransaction(userId, orderId, TransactionTypeMarginDeposit, -fill.Margin, balanceBefore, wallet.Balance, description)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transaction: %v", err)
+	}
+
+	return fill, nil
+}
+
+// openOrIncreasePosition 建立新倉位，若已有相同交易對與方向的持倉則加倉
+// 保證金需由呼叫端從錢包扣除
+func openOrIncreasePosition(l LeverageLedger, userId int64, order *Order, symbol string, side PositionSide, leverage int, price float64, quantity float64, margin float64) (position *LeveragePosition, increased bool, err error) {
+	position, err = l.OpenPosition(userId, symbol, side)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get position: %v", err)
+	}
+
+	if position == nil {
+		marginMode, err := l.MarginMode(userId)
+		if err != nil {
+			return nil, false, err
+		}
+		position, err = NewLeveragePosition(userId, order, symbol, side, marginMode, leverage, price, quantity, margin)
+		if err != nil {
+			return nil, false, err
+		}
+		if err = l.CreatePosition(position); err != nil {
+			return nil, false, fmt.Errorf("failed to create position: %v", err)
+		}
+		err = l.RecordPositionHistory(position, PositionActionOpen, price, quantity, margin, 0)
+	} else {
+		if err = position.Increase(quantity, price, margin); err != nil {
+			return nil, false, err
+		}
+		if err = l.UpdatePosition(position); err != nil {
+			return nil, false, fmt.Errorf("failed to update position: %v", err)
+		}
+		increased = true
+		err = l.RecordPositionHistory(position, PositionActionIncrease, price, quantity, margin, 0)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to record position history: %v", err)
+	}
+
+	return position, increased, nil
+}
+
+// ReducePosition 以指定價格部分平倉或全部平倉，並將釋放的保證金與盈虧結算至 USDT 錢包
+// quantity 為 0 或不小於持倉數量時全部平倉，返回實現盈虧與是否已全部平倉
+func ReducePosition(l LeverageLedger, position *LeveragePosition, userId int64, quantity float64, price float64) (realizedPnL float64, closed bool, err error) {
+	if position.User == nil || position.User.Id != userId {
+		return 0, false, errors.New("unauthorized: position does not belong to user")
+	}
+	if position.Status != PositionStatusOpen {
+		return 0, false, errors.New("position is not open")
+	}
+
+	partial := quantity > 0 && quantity < position.Quantity
+	quantityBefore := position.Quantity
+	marginBefore := position.Margin
+
+	var returnAmount float64
+	var description string
+	if partial {
+		// 部分平倉：按比例實現盈虧並釋放對應保證金
+		var releasedMargin float64
+		realizedPnL, releasedMargin, err = position.Reduce(quantity, price)
+		if err != nil {
+			return 0, false, err
+		}
+		if err = l.UpdatePosition(position); err != nil {
+			return 0, false, fmt.Errorf("failed to update position: %v", err)
+		}
+		returnAmount = releasedMargin + realizedPnL
+
+		err = l.RecordPositionHistory(position, PositionActionPartialClose, price, -quantity, -releasedMargin, realizedPnL)
+		description = fmt.Sprintf("Partially close %s position #%d (%.8f): PnL %.2f USDT", position.Side, position.Id, quantity, realizedPnL)
+	} else {
+		// 全部平倉（結算保證金帳戶）
+		returnAmount = position.Settle(price, PositionStatusClosed)
+		if err = l.SaveSettledPosition(position); err != nil {
+			return 0, false, err
+		}
+		realizedPnL = returnAmount - marginBefore
+
+		err = l.RecordPositionHistory(position, PositionActionClose, price, -quantityBefore, -marginBefore, realizedPnL)
+		description = fmt.Sprintf("Close %s position #%d: PnL %.2f USDT", position.Side, position.Id, realizedPnL)
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to record position history: %v", err)
+	}
+
+	if err = settlePositionMargin(l, position, returnAmount, TransactionTypeMarginWithdraw, description); err != nil {
+		return 0, false, err
+	}
+	return realizedPnL, !partial, nil
+}
+
+// settlePositionMargin 將倉位保證金帳戶結算後的金額返還 USDT 錢包並記錄交易
+func settlePositionMargin(l LeverageLedger, position *LeveragePosition, returnAmount float64, txType TransactionType, description string) error {
+	wallet, err := l.Wallet(position.User.Id)
+	if err != nil {
+		return err
+	}
+
+	balanceBefore := wallet.Balance
+	if position.IsCross() {
+		err = wallet.SettleCrossMargin(returnAmount)
+	} else {
+		err = wallet.ReceiveMarginFromPosition(returnAmount)
+	}
+	if err != nil {
+		return err
+	}
+	if err = l.SaveWallet(wallet); err != nil {
+		return fmt.Errorf("failed to return funds: %v", err)
+	}
+
+	err = l.RecordTransaction(position.User.Id, nil, txType, returnAmount, balanceBefore, wallet.Balance, description)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %v", err)
+	}
+	return nil
+}
+
+// LiquidationResult 爆倉結算的結果
+type LiquidationResult struct {
+	Surplus float64 // 剩餘權益（正數轉入保險基金，負數為穿倉虧損）
+	Applied float64 // 保險基金實際變動的金額（與 Surplus 不同時差額記為赤字）
+}
+
+// LiquidateIsolatedPosition 逐倉爆倉：以標記價格強制平倉
+// 使用者損失全部保證金，剩餘保證金轉入保險基金，穿倉虧損由保險基金承擔
+// 呼叫端需先依序鎖定錢包與倉位，並以 IsLiquidated 確認應爆倉
+func LiquidateIsolatedPosition(l LeverageLedger, position *LeveragePosition, markPrice float64) (*LiquidationResult, error) {
+	if position.Status != PositionStatusOpen {
+		return nil, errors.New("position is not open")
+	}
+	userId := position.User.Id
+
+	// 先鎖定錢包再結算保險基金，與成交、資金費用使用相同的鎖順序（錢包、倉位、保險基金）
+	if _, err := l.Wallet(userId); err != nil {
+		return nil, err
+	}
+
+	// 1. 平倉（爆倉），逐倉保證金全部虧損
+	quantityBefore := position.Quantity
+	marginBefore := position.Margin
+	result := &LiquidationResult{Surplus: position.LiquidationSurplus(markPrice)}
+	returnAmount := position.Settle(markPrice, PositionStatusLiquidated)
+	if err := l.SaveSettledPosition(position); err != nil {
+		return nil, err
+	}
+
+	err := l.RecordPositionHistory(position, PositionActionLiquidate, position.ExitPrice, -quantityBefore, -marginBefore, returnAmount-marginBefore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record position history: %v", err)
+	}
+
+	// 2. 剩餘保證金轉入或穿倉虧損撥出保險基金
+	if result.Applied, err = settleInsuranceFund(l, position, userId, result.Surplus); err != nil {
+		return nil, err
+	}
+
+	// 3. 結算保證金帳戶並記錄交易
+	description := fmt.Sprintf("Position #%d liquidated at %.2f (bankruptcy price %.2f)", position.Id, markPrice, position.BankruptcyPrice())
+	if err = settlePositionMargin(l, position, returnAmount, TransactionTypeLiquidation, description); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// LiquidateCrossAccount 全倉爆倉：以標記價格強制平掉使用者所有全倉持倉
+// 使用者損失全部可用餘額，剩餘權益轉入保險基金，穿倉虧損由保險基金承擔
+// positions 需已鎖定；以標記價格重新檢查，不應爆倉時返回 nil
+func LiquidateCrossAccount(l LeverageLedger, userId int64, positions []*LeveragePosition, markPrices map[string]float64) (*LiquidationResult, error) {
+	wallet, err := l.Wallet(userId)
+	if err != nil {
+		return nil, err
+	}
+	if len(positions) == 0 {
+		return nil, nil
+	}
+
+	// 1. 鎖定後重新檢查，期間使用者可能已平倉或價格已回升
+	for _, position := range positions {
+		markPrice, ok := markPrices[position.Symbol]
+		if !ok {
+			return nil, fmt.Errorf("price not available for %s", position.Symbol)
+		}
+		position.UnrealizedPnL = position.CalculateUnrealizedPnL(markPrice)
+	}
+	status := CalculateCrossMarginStatus(wallet.GetAvailableBalance(), positions, markPrices)
+	if !status.ShouldLiquidate() {
+		return nil, nil
+	}
+
+	// 2. 逐一強制平倉並累計結算金額
+	var totalReturn float64
+	for _, position := range positions {
+		if position.Status != PositionStatusOpen {
+			return nil, errors.New("position is not open")
+		}
+		quantityBefore := position.Quantity
+		marginBefore := position.Margin
+		returnAmount := position.Settle(markPrices[position.Symbol], PositionStatusLiquidated)
+		if err = l.SaveSettledPosition(position); err != nil {
+			return nil, err
+		}
+
+		err = l.RecordPositionHistory(position, PositionActionLiquidate, position.ExitPrice, -quantityBefore, -marginBefore, returnAmount-marginBefore)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record position history: %v", err)
+		}
+		totalReturn += returnAmount
+	}
+
+	// 3. 結算錢包：可用餘額歸零，剩餘權益或穿倉虧損由保險基金結算
+	result := &LiquidationResult{Surplus: wallet.GetAvailableBalance() + totalReturn}
+	settleAmount := -wallet.GetAvailableBalance()
+
+	balanceBefore := wallet.Balance
+	if err = wallet.SettleCrossMargin(settleAmount); err != nil {
+		return nil, err
+	}
+	if err = l.SaveWallet(wallet); err != nil {
+		return nil, fmt.Errorf("failed to settle wallet: %v", err)
+	}
+
+	if result.Applied, err = settleInsuranceFund(l, nil, userId, result.Surplus); err != nil {
+		return nil, err
+	}
+
+	description := fmt.Sprintf("Cross margin account liquidated: %d positions, equity %.2f below maintenance margin %.2f",
+		len(positions), status.Equity, status.MaintenanceMargin)
+	err = l.RecordTransaction(userId, nil, TransactionTypeLiquidation, settleAmount, balanceBefore, wallet.Balance, description)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transaction: %v", err)
+	}
+	return result, nil
+}
+
+// settleInsuranceFund 將爆倉剩餘的保證金轉入保險基金，或由保險基金承擔穿倉虧損，返回基金實際變動的金額
+// position 為空時表示全倉帳戶爆倉
+func settleInsuranceFund(l LeverageLedger, position *LeveragePosition, userId int64, surplus float64) (float64, error) {
+	if surplus == 0 {
+		return 0, nil
+	}
+
+	entryType := InsuranceFundEntryLiquidationSurplus
+	description := fmt.Sprintf("Liquidation surplus from user %d", userId)
+	if surplus < 0 {
+		entryType = InsuranceFundEntryBankruptcyCover
+		description = fmt.Sprintf("Bankruptcy loss of user %d covered", userId)
+	}
+	if position != nil {
+		description += fmt.Sprintf(" (position #%d)", position.Id)
+	}
+
+	applied, err := l.ApplyInsuranceFund(surplus, entryType, position, userId, description)
+	if err != nil {
+		return 0, fmt.Errorf("failed to settle insurance fund: %v", err)
+	}
+	return applied, nil
+}
+
+// ApplyFundingPayment 向單一倉位收付資金費用，返回實際收付的金額（正數為收取）
+// 逐倉由倉位保證金收付，全倉由錢包收付；對手方為保險基金
+// 呼叫端需先依序鎖定錢包與倉位
+func ApplyFundingPayment(l LeverageLedger, position *LeveragePosition, rate float64, markPrice float64, fundingTime time.Time) (float64, error) {
+	if position.Status != PositionStatusOpen {
+		return 0, errors.New("position is not open")
+	}
+	userId := position.User.Id
+
+	wallet, err := l.Wallet(userId)
+	if err != nil {
+		return 0, err
+	}
+
+	// 1. 計算並收付資金費用
+	amount := position.FundingPayment(rate, markPrice)
+	if position.IsCross() && amount < 0 && -amount > wallet.GetAvailableBalance() {
+		amount = -wallet.GetAvailableBalance()
+	}
+
+	amount, err = position.ApplyFunding(amount, fundingTime)
+	if err != nil {
+		return 0, err
+	}
+	if err = l.UpdatePosition(position); err != nil {
+		return 0, fmt.Errorf("failed to update position: %v", err)
+	}
+
+	description := fmt.Sprintf("Funding for %s position #%d at rate %.6f", position.Side, position.Id, rate)
+	if _, err = l.ApplyInsuranceFund(-amount, InsuranceFundEntryFunding, position, userId, description); err != nil {
+		return 0, fmt.Errorf("failed to settle funding with insurance fund: %v", err)
+	}
+
+	balanceBefore := wallet.Balance
+	marginChange := amount
+	if position.IsCross() {
+		marginChange = 0
+		if err = wallet.SettleCrossMargin(amount); err != nil {
+			return 0, err
+		}
+		if err = l.SaveWallet(wallet); err != nil {
+			return 0, fmt.Errorf("failed to settle funding: %v", err)
+		}
+	}
+
+	// 2. 記錄倉位變更與交易
+	if err = l.RecordPositionHistory(position, PositionActionFunding, markPrice, 0, marginChange, 0); err != nil {
+		return 0, fmt.Errorf("failed to record position history: %v", err)
+	}
+
+	err = l.RecordTransaction(userId, nil, TransactionTypeFunding, amount, balanceBefore, wallet.Balance, description)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create transaction: %v", err)
+	}
+	return amount, nil
+}
@@ -2,6 +2,9 @@ package models
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/beego/beego/v2/client/orm"
@@ -32,12 +35,16 @@ type LeveragePosition struct {
 	Symbol           string         `orm:"size(20)" json:"symbol"`                                 // 交易對：BTCUSDT, ETHUSDT, SOLUSDT
 	Side             PositionSide   `orm:"size(10)" json:"side"`                                   // LONG or SHORT
 	Leverage         int            `orm:"default(1)" json:"leverage"`                             // 槓桿倍數：1-10
+	MarginMode       MarginMode     `orm:"size(10);default(ISOLATED)" json:"marginMode"`           // 保證金模式：ISOLATED 或 CROSS
 	EntryPrice       float64        `orm:"digits(20);decimals(8)" json:"entryPrice"`               // 開倉價格
 	Quantity         float64        `orm:"digits(20);decimals(8)" json:"quantity"`                 // 持倉數量
 	Margin           float64        `orm:"digits(20);decimals(8)" json:"margin"`                   // 保證金（USDT）
 	LiquidationPrice float64        `orm:"digits(20);decimals(8)" json:"liquidationPrice"`         // 爆倉價格
 	UnrealizedPnL    float64        `orm:"digits(20);decimals(8)" json:"unrealizedPnl"`            // 未實現盈虧
 	RealizedPnL      float64        `orm:"digits(20);decimals(8)" json:"realizedPnl"`              // 已實現盈虧
+	FundingFee       float64        `orm:"digits(20);decimals(8)" json:"fundingFee"`               // 累計資金費用（正數收取，負數支付）
+	LastFundingTime  *time.Time     `orm:"type(datetime);null" json:"-"`                           // 最近一次收付資金費用的結算時間
+	MarginCallLevel  int            `orm:"default(0)" json:"marginCallLevel"`                      // 已發送的爆倉警告等級（0 表示未警告）
 	ExitPrice        float64        `orm:"digits(20);decimals(8);null" json:"exitPrice,omitempty"` // 平倉價格
 	Status           PositionStatus `orm:"size(20)" json:"status"`
 	CreatedAt        time.Time      `orm:"auto_now_add;type(datetime)" json:"createdAt"`
@@ -54,20 +61,111 @@ func (l *LeveragePosition) TableName() string {
 	return "leverage_position"
 }
 
+// EffectiveLeverage 實際槓桿倍數 = 倉位名義價值 / 保證金
+// 追加或減少保證金後會與開倉時的 Leverage 不同
+func (l *LeveragePosition) EffectiveLeverage() float64 {
+	if l.Margin <= 0 {
+		return float64(l.Leverage)
+	}
+	return (l.EntryPrice * l.Quantity) / l.Margin
+}
+
+// IsCross 是否為全倉倉位
+func (l *LeveragePosition) IsCross() bool {
+	return l.MarginMode == MarginModeCross
+}
+
+// MaintenanceMargin 以標記價格計算的維持保證金（依名義價值所屬階梯）
+func (l *LeveragePosition) MaintenanceMargin(markPrice float64) float64 {
+	notional := markPrice * l.Quantity
+	return GetMarginTier(l.Symbol, notional).MaintenanceMargin(notional)
+}
+
+// HasSufficientMargin 檢查逐倉保證金在開倉價格下是否高於維持保證金
+// 槓桿相對倉位大小過高時，開倉即會被強制平倉
+func (l *LeveragePosition) HasSufficientMargin() bool {
+	return l.IsCross() || l.Margin > l.MaintenanceMargin(l.EntryPrice)
+}
+
 // CalculateLiquidationPrice 計算爆倉價格
+// 全倉倉位沒有固定的爆倉價格（由帳戶總權益決定），返回 0
 func (l *LeveragePosition) CalculateLiquidationPrice() float64 {
-	// 爆倉價格計算：
-	// 做多：爆倉價 = 開倉價 * (1 - 0.9 / 槓桿)
-	// 做空：爆倉價 = 開倉價 * (1 + 0.9 / 槓桿)
-	// 0.9 是維持保證金率（90%），留 10% 緩衝
+	// 爆倉條件：保證金 + 未實現盈虧 = 維持保證金 = 數量 * 爆倉價 * 維持保證金率 - 速算額
+	// 做多：爆倉價 = (數量 * 開倉價 - 保證金 - 速算額) / (數量 * (1 - 維持保證金率))
+	// 做空：爆倉價 = (數量 * 開倉價 + 保證金 + 速算額) / (數量 * (1 + 維持保證金率))
+	// 維持保證金率依開倉名義價值所屬的階梯決定
+	if l.IsCross() || l.Quantity <= 0 {
+		return 0
+	}
 
-	liquidationRatio := 0.9 / float64(l.Leverage)
+	notional := l.EntryPrice * l.Quantity
+	tier := GetMarginTier(l.Symbol, notional)
+	rate := tier.MaintenanceMarginRate
 
 	if l.Side == PositionSideLong {
-		return l.EntryPrice * (1 - liquidationRatio)
+		price := (notional - l.Margin - tier.MaintenanceAmount) / (l.Quantity * (1 - rate))
+		if price < 0 {
+			return 0
+		}
+		return price
 	} else {
-		return l.EntryPrice * (1 + liquidationRatio)
+		return (notional + l.Margin + tier.MaintenanceAmount) / (l.Quantity * (1 + rate))
+	}
+}
+
+// BankruptcyPrice 破產價格：保證金 + 未實現盈虧 = 0 的價格
+func (l *LeveragePosition) BankruptcyPrice() float64 {
+	if l.Quantity <= 0 {
+		return 0
+	}
+	if l.Side == PositionSideLong {
+		price := l.EntryPrice - l.Margin/l.Quantity
+		if price < 0 {
+			return 0
+		}
+		return price
+	}
+	return l.EntryPrice + l.Margin/l.Quantity
+}
+
+// LiquidationSurplus 以成交價格強制平倉後保證金帳戶剩餘的金額
+// 正數表示優於破產價格（剩餘轉入保險基金），負數表示越過破產價格的穿倉虧損
+func (l *LeveragePosition) LiquidationSurplus(exitPrice float64) float64 {
+	return l.Margin + l.CalculateUnrealizedPnL(exitPrice)
+}
+
+// AdjustMargin 調整倉位保證金（正數追加、負數減少）並重新計算爆倉價格
+// 減少保證金時，若新的爆倉價格會越過標記價格則拒絕
+func (l *LeveragePosition) AdjustMargin(amount float64, markPrice float64) error {
+	if amount == 0 {
+		return errors.New("amount must not be zero")
+	}
+	if l.Status != PositionStatusOpen {
+		return errors.New("position is not open")
 	}
+	if l.IsCross() {
+		return errors.New("margin cannot be adjusted in cross margin mode")
+	}
+
+	newMargin := l.Margin + amount
+	if newMargin <= 0 {
+		return errors.New("cannot remove more margin than the position holds")
+	}
+
+	adjusted := *l
+	adjusted.Margin = newMargin
+	newLiquidationPrice := adjusted.CalculateLiquidationPrice()
+
+	if amount < 0 {
+		adjusted.LiquidationPrice = newLiquidationPrice
+		if adjusted.IsLiquidated(markPrice) {
+			return fmt.Errorf("removing margin would move liquidation price (%.2f) past mark price (%.2f)", newLiquidationPrice, markPrice)
+		}
+	}
+
+	l.Margin = newMargin
+	l.LiquidationPrice = newLiquidationPrice
+	return nil
 }
 
 // CalculateUnrealizedPnL 計算未實現盈虧
@@ -81,21 +179,38 @@ func (l *LeveragePosition) CalculateUnrealizedPnL(currentPrice float64) float64
 	}
 }
 
-// IsLiquidated 檢查是否應該爆倉
-func (l *LeveragePosition) IsLiquidated(currentPrice float64) bool {
+// IsLiquidated 以標記價格檢查是否應該爆倉（僅逐倉，全倉由帳戶總權益判斷）
+func (l *LeveragePosition) IsLiquidated(markPrice float64) bool {
+	if l.IsCross() {
+		return false
+	}
 	if l.Side == PositionSideLong {
-		// 做多：當前價 <= 爆倉價
-		return currentPrice <= l.LiquidationPrice
+		// 做多：標記價 <= 爆倉價
+		return markPrice <= l.LiquidationPrice
 	} else {
-		// 做空：當前價 >= 爆倉價
-		return currentPrice >= l.LiquidationPrice
+		// 做空：標記價 >= 爆倉價
+		return markPrice >= l.LiquidationPrice
 	}
 }
 
-// CreateLeveragePosition 創建槓桿倉位
-func CreateLeveragePosition(userId int64, symbol string, side PositionSide, leverage int, entryPrice float64, quantity float64, margin float64) (*LeveragePosition, error) {
-	o := orm.NewOrm()
+// CreateLeveragePosition 創建槓桿倉位（需要在交易中使用）
+// margin 為轉入此倉位保證金帳戶的金額，呼叫端需在同一交易中從錢包扣除
+func CreateLeveragePosition(o orm.QueryExecutor, userId int64, order *Order, symbol string, side PositionSide, marginMode MarginMode, leverage int, entryPrice float64, quantity float64, margin float64) (*LeveragePosition, error) {
+	position, err := NewLeveragePosition(userId, order, symbol, side, marginMode, leverage, entryPrice, quantity, margin)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = o.Insert(position)
+	if err != nil {
+		return nil, err
+	}
 
+	return position, nil
+}
+
+// NewLeveragePosition 建立新倉位並計算爆倉價格（尚未寫入資料庫，回測也使用同一套檢查）
+func NewLeveragePosition(userId int64, order *Order, symbol string, side PositionSide, marginMode MarginMode, leverage int, entryPrice float64, quantity float64, margin float64) (*LeveragePosition, error) {
 	// 驗證槓桿倍數
 	if leverage < 1 || leverage > 100 {
 		return nil, errors.New("leverage must be between 1 and 100")
@@ -103,8 +218,10 @@ func CreateLeveragePosition(userId int64, symbol string, side PositionSide, leve
 
 	position := &LeveragePosition{
 		User:       &User{Id: userId},
+		Order:      order,
 		Symbol:     symbol,
 		Side:       side,
+		MarginMode: marginMode,
 		Leverage:   leverage,
 		EntryPrice: entryPrice,
 		Quantity:   quantity,
@@ -112,14 +229,12 @@ func CreateLeveragePosition(userId int64, symbol string, side PositionSide, leve
 		Status:     PositionStatusOpen,
 	}
 
-	// 計算爆倉價格
-	position.LiquidationPrice = position.CalculateLiquidationPrice()
-
-	_, err := o.Insert(position)
-	if err != nil {
-		return nil, err
+	if !position.HasSufficientMargin() {
+		return nil, errors.New("leverage too high for position size: margin below maintenance margin")
 	}
 
+	// 計算爆倉價格
+	position.LiquidationPrice = position.CalculateLiquidationPrice()
 	return position, nil
 }
 
@@ -136,13 +251,14 @@ func GetOpenPositionsByUser(userId int64) ([]*LeveragePosition, error) {
 	return positions, err
 }
 
-// GetAllOpenPositions 查詢所有持倉（用於爆倉檢查）
+// GetAllOpenPositions 查詢所有持倉（用於爆倉索引與資金費用結算）
 func GetAllOpenPositions() ([]*LeveragePosition, error) {
 	o := orm.NewOrm()
 	var positions []*LeveragePosition
 	_, err := o.QueryTable(new(LeveragePosition)).
 		Filter("Status", PositionStatusOpen).
 		RelatedSel().
+		Limit(-1).
 		All(&positions)
 	return positions, err
 }
@@ -158,60 +274,190 @@ func GetPositionById(id int64) (*LeveragePosition, error) {
 	return position, err
 }
 
-// ClosePosition 平倉
-func ClosePosition(positionId int64, userId int64, exitPrice float64) error {
-	o := orm.NewOrm()
+// GetPositionForUpdate 在交易中以 FOR UPDATE 鎖定並讀取倉位
+func GetPositionForUpdate(o orm.QueryExecutor, id int64) (*LeveragePosition, error) {
+	position := &LeveragePosition{Id: id}
+	err := o.ReadForUpdate(position)
+	if err == orm.ErrNoRows {
+		return nil, errors.New("position not found")
+	}
+	return position, err
+}
 
-	position, err := GetPositionById(positionId)
-	if err != nil {
-		return err
+// Settle 結算倉位保證金帳戶，返回應返還錢包的金額
+// 逐倉平倉：實現剩餘數量的盈虧，但虧損最多為保證金
+// 逐倉爆倉：保證金全部虧損，不返還任何金額
+// 全倉：以結算價格實現全部盈虧，虧損超過保證金時返回負數，由錢包餘額承擔
+func (l *LeveragePosition) Settle(exitPrice float64, status PositionStatus) (returnAmount float64) {
+	var pnl float64
+	if l.IsCross() {
+		pnl = l.CalculateUnrealizedPnL(exitPrice)
+	} else if status == PositionStatusLiquidated {
+		pnl = -l.Margin
+	} else {
+		pnl = l.CalculateUnrealizedPnL(exitPrice)
+		if pnl < -l.Margin {
+			pnl = -l.Margin
+		}
+	}
+
+	returnAmount = l.Margin + pnl
+	l.RealizedPnL += pnl
+	l.UnrealizedPnL = 0
+	l.ExitPrice = exitPrice
+	l.Status = status
+	now := time.Now()
+	l.ClosedAt = &now
+	return returnAmount
+}
+
+// Reduce 部分平倉：按比例實現盈虧並釋放對應的保證金
+// 返回此次實現的盈虧與釋放的保證金，應返還錢包的金額 = releasedMargin + realizedPnL（全倉時可能為負數）
+func (l *LeveragePosition) Reduce(quantity float64, exitPrice float64) (realizedPnL float64, releasedMargin float64, err error) {
+	if l.Status != PositionStatusOpen {
+		return 0, 0, errors.New("position is not open")
+	}
+	if quantity <= 0 {
+		return 0, 0, errors.New("quantity must be positive")
+	}
+	if quantity >= l.Quantity {
+		return 0, 0, errors.New("quantity must be less than position quantity, use full close instead")
 	}
 
-	// 載入 User 關聯
-	o.LoadRelated(position, "User")
+	ratio := quantity / l.Quantity
+	releasedMargin = l.Margin * ratio
 
-	// 驗證所有權
-	if position.User.Id != userId {
-		return errors.New("unauthorized: position does not belong to user")
+	if l.Side == PositionSideLong {
+		realizedPnL = (exitPrice - l.EntryPrice) * quantity
+	} else {
+		realizedPnL = (l.EntryPrice - exitPrice) * quantity
+	}
+	// 逐倉：這部分的虧損最多為其對應的保證金
+	if !l.IsCross() && realizedPnL < -releasedMargin {
+		realizedPnL = -releasedMargin
 	}
 
-	// 驗證狀態
-	if position.Status != PositionStatusOpen {
+	l.Quantity -= quantity
+	l.Margin -= releasedMargin
+	l.RealizedPnL += realizedPnL
+	l.UnrealizedPnL = l.CalculateUnrealizedPnL(exitPrice)
+	l.LiquidationPrice = l.CalculateLiquidationPrice()
+	return realizedPnL, releasedMargin, nil
+}
+
+// Increase 加倉：以加權平均計算新的開倉價格，並重新計算爆倉價格
+func (l *LeveragePosition) Increase(quantity float64, price float64, margin float64) error {
+	if l.Status != PositionStatusOpen {
 		return errors.New("position is not open")
 	}
+	if quantity <= 0 || margin <= 0 {
+		return errors.New("quantity and margin must be positive")
+	}
 
-	// 計算已實現盈虧
-	position.RealizedPnL = position.CalculateUnrealizedPnL(exitPrice)
-	position.ExitPrice = exitPrice
-	position.Status = PositionStatusClosed
-	now := time.Now()
-	position.ClosedAt = &now
+	increased := *l
+	totalQuantity := l.Quantity + quantity
+	increased.EntryPrice = (l.EntryPrice*l.Quantity + price*quantity) / totalQuantity
+	increased.Quantity = totalQuantity
+	increased.Margin += margin
+	if !increased.HasSufficientMargin() {
+		return errors.New("leverage too high for position size: margin below maintenance margin")
+	}
 
-	_, err = o.Update(position)
-	return err
+	l.EntryPrice = increased.EntryPrice
+	l.Quantity = increased.Quantity
+	l.Margin = increased.Margin
+	l.UnrealizedPnL = l.CalculateUnrealizedPnL(price)
+	l.LiquidationPrice = l.CalculateLiquidationPrice()
+	return nil
 }
 
-// LiquidatePosition 強制平倉（爆倉）
-func LiquidatePosition(positionId int64) error {
-	o := orm.NewOrm()
-
-	position, err := GetPositionById(positionId)
+// saveSettledPosition 以「狀態仍為 OPEN」為條件更新倉位
+// 避免平倉與爆倉同時發生時重複結算保證金
+func saveSettledPosition(o orm.QueryExecutor, position *LeveragePosition) error {
+	num, err := o.QueryTable(new(LeveragePosition)).
+		Filter("Id", position.Id).
+		Filter("Status", PositionStatusOpen).
+		Update(orm.Params{
+			"RealizedPnL":   position.RealizedPnL,
+			"UnrealizedPnL": position.UnrealizedPnL,
+			"ExitPrice":     position.ExitPrice,
+			"Status":        position.Status,
+			"ClosedAt":      *position.ClosedAt,
+			"UpdatedAt":     time.Now(),
+		})
 	if err != nil {
 		return err
 	}
-
-	if position.Status != PositionStatusOpen {
+	if num == 0 {
 		return errors.New("position is not open")
 	}
+	return nil
+}
 
-	// 爆倉時虧損全部保證金
-	position.RealizedPnL = -position.Margin
-	position.ExitPrice = position.LiquidationPrice
-	position.Status = PositionStatusLiquidated
-	now := time.Now()
-	position.ClosedAt = &now
+// GetOpenPosition 讀取使用者某交易對、某方向的持倉（沒有時返回 nil）
+func GetOpenPosition(userId int64, symbol string, side PositionSide) (*LeveragePosition, error) {
+	o := orm.NewOrm()
+	position := &LeveragePosition{}
+	err := o.QueryTable(new(LeveragePosition)).
+		Filter("User__Id", userId).
+		Filter("Symbol", symbol).
+		Filter("Side", side).
+		Filter("Status", PositionStatusOpen).
+		OrderBy("CreatedAt").
+		One(position)
+	if err == orm.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return position, nil
+}
+
+// GetOpenPositionForUpdate 在交易中鎖定並讀取使用者某交易對、某方向的持倉（用於加倉）
+func GetOpenPositionForUpdate(o orm.QueryExecutor, userId int64, symbol string, side PositionSide) (*LeveragePosition, error) {
+	position := &LeveragePosition{}
+	err := o.QueryTable(new(LeveragePosition)).
+		Filter("User__Id", userId).
+		Filter("Symbol", symbol).
+		Filter("Side", side).
+		Filter("Status", PositionStatusOpen).
+		OrderBy("CreatedAt").
+		ForUpdate().
+		One(position)
+	if err == orm.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return position, nil
+}
+
+// GetOpenCrossPositionsForUpdate 在交易中鎖定並讀取使用者所有全倉持倉（用於全倉爆倉）
+func GetOpenCrossPositionsForUpdate(o orm.QueryExecutor, userId int64) ([]*LeveragePosition, error) {
+	var positions []*LeveragePosition
+	_, err := o.QueryTable(new(LeveragePosition)).
+		Filter("User__Id", userId).
+		Filter("MarginMode", MarginModeCross).
+		Filter("Status", PositionStatusOpen).
+		OrderBy("Id").
+		ForUpdate().
+		All(&positions)
+	return positions, err
+}
 
-	_, err = o.Update(position)
+// CountOpenPositionsByUser 統計使用者的持倉數量
+func CountOpenPositionsByUser(o orm.QueryExecutor, userId int64) (int64, error) {
+	return o.QueryTable(new(LeveragePosition)).
+		Filter("User__Id", userId).
+		Filter("Status", PositionStatusOpen).
+		Count()
+}
+
+// UpdatePositionMargin 更新倉位保證金與爆倉價格（需要在交易中使用）
+func UpdatePositionMargin(o orm.QueryExecutor, position *LeveragePosition) error {
+	_, err := o.Update(position, "Margin", "LiquidationPrice", "UpdatedAt")
 	return err
 }
 
@@ -223,6 +469,52 @@ func UpdatePositionPnL(position *LeveragePosition, currentPrice float64) error {
 	return err
 }
 
+// PositionPnL 持倉的未實現盈虧（用於批次更新）
+type PositionPnL struct {
+	Id            int64
+	UnrealizedPnL float64
+}
+
+// positionPnLBatchSize 每條 UPDATE 語句最多更新的倉位數量
+const positionPnLBatchSize = 500
+
+// BatchUpdatePositionPnL 批次更新持倉的未實現盈虧，每批以單一 UPDATE ... CASE 語句寫入（只更新持倉中的倉位）
+func BatchUpdatePositionPnL(pnls []PositionPnL) error {
+	o := orm.NewOrm()
+	now := time.Now()
+	for start := 0; start < len(pnls); start += positionPnLBatchSize {
+		end := start + positionPnLBatchSize
+		if end > len(pnls) {
+			end = len(pnls)
+		}
+		batch := pnls[start:end]
+
+		// 欄位名稱依 beego 的命名規則：UnrealizedPnL -> unrealized_pn_l
+		var sql strings.Builder
+		args := make([]interface{}, 0, len(batch)*3+2)
+		sql.WriteString("UPDATE leverage_position SET unrealized_pn_l = CASE id")
+		for _, pnl := range batch {
+			sql.WriteString(" WHEN ? THEN ?")
+			args = append(args, pnl.Id, pnl.UnrealizedPnL)
+		}
+		sql.WriteString(" END, updated_at = ? WHERE status = ? AND id IN (")
+		args = append(args, now, PositionStatusOpen)
+		for i, pnl := range batch {
+			if i > 0 {
+				sql.WriteString(",")
+			}
+			sql.WriteString("?")
+			args = append(args, pnl.Id)
+		}
+		sql.WriteString(")")
+
+		if _, err := o.Raw(sql.String(), args...).Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // GetPositionsByUserAndSymbol 查詢特定交易對的倉位
 func GetPositionsByUserAndSymbol(userId int64, symbol string, limit int, offset int) ([]*LeveragePosition, error) {
 	o := orm.NewOrm()
@@ -261,6 +553,20 @@ func CalculatePositionValue(price float64, quantity float64) float64 {
 	return price * quantity
 }
 
+// MarkPriceSampleSize 計算標記價格使用的最近成交筆數
+const MarkPriceSampleSize = 51
+
+// CalculateMarkPrice 計算標記價格：最近成交價格的中位數（會排序傳入的切片）
+// 用於未實現盈虧與爆倉判斷，避免單筆異常成交觸發爆倉；回測以模擬的成交價格使用同一套計算
+func CalculateMarkPrice(prices []float64) float64 {
+	sort.Float64s(prices)
+	n := len(prices)
+	if n%2 == 1 {
+		return prices[n/2]
+	}
+	return (prices[n/2-1] + prices[n/2]) / 2
+}
+
 // GetPositionPnLPercentage 計算盈虧百分比
 func GetPositionPnLPercentage(position *LeveragePosition) float64 {
 	if position.Margin == 0 {
@@ -0,0 +1,241 @@
+package models
+
+import "testing"
+
+func newTestPosition(side PositionSide) *LeveragePosition {
+	position := &LeveragePosition{
+		Symbol:     "BTCUSDT",
+		Side:       side,
+		Leverage:   10,
+		EntryPrice: 100,
+		Quantity:   10,
+		Margin:     100, // 100 * 10 / 10
+		Status:     PositionStatusOpen,
+	}
+	position.LiquidationPrice = position.CalculateLiquidationPrice()
+	return position
+}
+
+// TestSettleClose 測試平倉結算：保證金 + 盈虧返還錢包
+func TestSettleClose(t *testing.T) {
+	tests := []struct {
+		name       string
+		side       PositionSide
+		exitPrice  float64
+		wantPnL    float64
+		wantReturn float64
+	}{
+		{"long profit", PositionSideLong, 105, 50, 150},
+		{"long loss", PositionSideLong, 96, -40, 60},
+		{"short profit", PositionSideShort, 95, 50, 150},
+		{"short loss", PositionSideShort, 104, -40, 60},
+		// 逐倉：虧損最多為保證金，不會從錢包倒扣
+		{"long loss beyond margin", PositionSideLong, 80, -100, 0},
+		{"short loss beyond margin", PositionSideShort, 120, -100, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			position := newTestPosition(tt.side)
+			returnAmount := position.Settle(tt.exitPrice, PositionStatusClosed)
+
+			if !almostEqual(position.RealizedPnL, tt.wantPnL) {
+				t.Errorf("expected realized PnL %.2f, got %.2f", tt.wantPnL, position.RealizedPnL)
+			}
+			if !almostEqual(returnAmount, tt.wantReturn) {
+				t.Errorf("expected return amount %.2f, got %.2f", tt.wantReturn, returnAmount)
+			}
+			if position.Status != PositionStatusClosed {
+				t.Errorf("expected status CLOSED, got %s", position.Status)
+			}
+			if position.ExitPrice != tt.exitPrice || position.ClosedAt == nil {
+				t.Error("expected exit price and closedAt to be set")
+			}
+		})
+	}
+}
+
+// TestSettleLiquidation 測試爆倉結算：保證金全部虧損
+func TestSettleLiquidation(t *testing.T) {
+	position := newTestPosition(PositionSideLong)
+	position.UnrealizedPnL = -85
+
+	returnAmount := position.Settle(position.LiquidationPrice, PositionStatusLiquidated)
+
+	if returnAmount != 0 {
+		t.Errorf("expected nothing returned on liquidation, got %.2f", returnAmount)
+	}
+	if position.RealizedPnL != -position.Margin {
+		t.Errorf("expected realized PnL %.2f, got %.2f", -position.Margin, position.RealizedPnL)
+	}
+	if position.UnrealizedPnL != 0 {
+		t.Errorf("expected unrealized PnL reset to 0, got %.2f", position.UnrealizedPnL)
+	}
+	if position.Status != PositionStatusLiquidated {
+		t.Errorf("expected status LIQUIDATED, got %s", position.Status)
+	}
+}
+
+// TestOrderRequiredMargin 測試槓桿限價單凍結的保證金與成交時轉入倉位的保證金一致
+func TestOrderRequiredMargin(t *testing.T) {
+	order := &Order{IsLeverageOrder: true, Leverage: 5, LimitPrice: 200, Quantity: 2}
+	if !almostEqual(order.RequiredMargin(), 80) {
+		t.Errorf("expected margin 80, got %.2f", order.RequiredMargin())
+	}
+
+	spot := &Order{LimitPrice: 200, Quantity: 2}
+	if spot.RequiredMargin() != 0 {
+		t.Errorf("expected spot order to require no margin, got %.2f", spot.RequiredMargin())
+	}
+}
+
+// TestAdjustMargin 測試追加與減少保證金後重新計算爆倉價格
+func TestAdjustMargin(t *testing.T) {
+	position := newTestPosition(PositionSideLong)
+	originalLiqPrice := position.LiquidationPrice // (1000 - 100) / (10 * (1 - 0.004)) ≈ 90.36
+
+	// 追加保證金：實際槓桿降為 5 倍，爆倉價格下移
+	if err := position.AdjustMargin(100, 95); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !almostEqual(position.Margin, 200) {
+		t.Errorf("expected margin 200, got %.2f", position.Margin)
+	}
+	if !almostEqual(position.EffectiveLeverage(), 5) {
+		t.Errorf("expected effective leverage 5, got %.2f", position.EffectiveLeverage())
+	}
+	if !almostEqual(position.LiquidationPrice, 800/9.96) || position.LiquidationPrice >= originalLiqPrice {
+		t.Errorf("expected liquidation price %.2f, got %.2f", 800/9.96, position.LiquidationPrice)
+	}
+
+	// 減少保證金：恢復為 10 倍
+	if err := position.AdjustMargin(-100, 95); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !almostEqual(position.LiquidationPrice, originalLiqPrice) {
+		t.Errorf("expected liquidation price %.2f, got %.2f", originalLiqPrice, position.LiquidationPrice)
+	}
+}
+
+// TestAdjustMarginRejected 測試減少保證金的拒絕條件
+func TestAdjustMarginRejected(t *testing.T) {
+	tests := []struct {
+		name         string
+		side         PositionSide
+		amount       float64
+		currentPrice float64
+	}{
+		{"zero amount", PositionSideLong, 0, 100},
+		{"remove all margin", PositionSideLong, -100, 100},
+		// 保證金 50 → 爆倉價 950 / 9.96 ≈ 95.38，越過標記價 95
+		{"long liquidation past price", PositionSideLong, -50, 95},
+		// 保證金 50 → 爆倉價 1050 / 10.04 ≈ 104.58，越過標記價 105
+		{"short liquidation past price", PositionSideShort, -50, 105},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			position := newTestPosition(tt.side)
+			before := *position
+
+			if err := position.AdjustMargin(tt.amount, tt.currentPrice); err == nil {
+				t.Error("expected error")
+			}
+			if position.Margin != before.Margin || position.LiquidationPrice != before.LiquidationPrice {
+				t.Error("position should be unchanged after rejected adjustment")
+			}
+		})
+	}
+}
+
+// TestReduce 測試部分平倉：按比例實現盈虧並釋放保證金
+func TestReduce(t *testing.T) {
+	position := newTestPosition(PositionSideLong)
+	originalLiqPrice := position.LiquidationPrice
+
+	realizedPnL, releasedMargin, err := position.Reduce(4, 105)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !almostEqual(realizedPnL, 20) || !almostEqual(releasedMargin, 40) {
+		t.Errorf("expected PnL 20 / released margin 40, got %.2f / %.2f", realizedPnL, releasedMargin)
+	}
+	if !almostEqual(position.Quantity, 6) || !almostEqual(position.Margin, 60) {
+		t.Errorf("expected quantity 6 / margin 60, got %.2f / %.2f", position.Quantity, position.Margin)
+	}
+	// 開倉價格與實際槓桿不變，同一階梯內爆倉價格也不變
+	if position.EntryPrice != 100 || !almostEqual(position.LiquidationPrice, originalLiqPrice) {
+		t.Errorf("expected entry 100 / liquidation %.2f, got %.2f / %.2f", originalLiqPrice, position.EntryPrice, position.LiquidationPrice)
+	}
+
+	// 剩餘倉位全部平倉時累計已實現盈虧
+	returnAmount := position.Settle(110, PositionStatusClosed)
+	if !almostEqual(returnAmount, 120) {
+		t.Errorf("expected return amount 120, got %.2f", returnAmount)
+	}
+	if !almostEqual(position.RealizedPnL, 80) {
+		t.Errorf("expected accumulated realized PnL 80, got %.2f", position.RealizedPnL)
+	}
+}
+
+// TestReduceRejected 測試部分平倉的拒絕條件
+func TestReduceRejected(t *testing.T) {
+	position := newTestPosition(PositionSideShort)
+
+	for _, quantity := range []float64{0, -1, 10, 11} {
+		if _, _, err := position.Reduce(quantity, 100); err == nil {
+			t.Errorf("expected error for quantity %.2f", quantity)
+		}
+	}
+	if position.Quantity != 10 || position.Margin != 100 {
+		t.Error("position should be unchanged after rejected reduce")
+	}
+}
+
+// TestIncrease 測試加倉：加權平均開倉價格並重新計算爆倉價格
+func TestIncrease(t *testing.T) {
+	position := newTestPosition(PositionSideShort)
+
+	// 以 130 加倉 10，保證金 130（10 倍）
+	if err := position.Increase(10, 130, 130); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !almostEqual(position.EntryPrice, 115) {
+		t.Errorf("expected weighted entry price 115, got %.2f", position.EntryPrice)
+	}
+	if !almostEqual(position.Quantity, 20) || !almostEqual(position.Margin, 230) {
+		t.Errorf("expected quantity 20 / margin 230, got %.2f / %.2f", position.Quantity, position.Margin)
+	}
+	// 爆倉價格 (2300 + 230) / (20 * (1 + 0.004))
+	if !almostEqual(position.LiquidationPrice, 2530/20.08) {
+		t.Errorf("expected liquidation price %.2f, got %.2f", 2530/20.08, position.LiquidationPrice)
+	}
+
+	if err := position.Increase(0, 130, 10); err == nil {
+		t.Error("expected error for zero quantity")
+	}
+}
+
+// TestIncreaseRejectedBelowMaintenance 測試加倉後保證金低於維持保證金時拒絕
+func TestIncreaseRejectedBelowMaintenance(t *testing.T) {
+	position := newTestPosition(PositionSideLong)
+	position.Symbol = "SOLUSDT"
+
+	// 加倉至名義價值 2,000,000（維持保證金率 10%），保證金僅 100,100
+	if err := position.Increase(19990, 100, 100000); err == nil {
+		t.Error("expected error when margin falls below maintenance margin")
+	}
+	if position.Quantity != 10 || position.Margin != 100 {
+		t.Error("position should be unchanged after rejected increase")
+	}
+}
+
+// TestCalculateMarkPrice 測試標記價格取最近成交價格的中位數
+func TestCalculateMarkPrice(t *testing.T) {
+	if got := CalculateMarkPrice([]float64{3, 1, 2}); got != 2 {
+		t.Errorf("expected 2, got %v", got)
+	}
+	if got := CalculateMarkPrice([]float64{4, 1, 3, 2}); got != 2.5 {
+		t.Errorf("expected 2.5, got %v", got)
+	}
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// LiquidationProgress 逐倉倉位距離爆倉的進度：0 表示位於開倉價格（或獲利中），1 表示到達爆倉價格
+func (l *LeveragePosition) LiquidationProgress(markPrice float64) float64 {
+	var distance, moved float64
+	if l.Side == PositionSideLong {
+		distance = l.EntryPrice - l.LiquidationPrice
+		moved = l.EntryPrice - markPrice
+	} else {
+		distance = l.LiquidationPrice - l.EntryPrice
+		moved = markPrice - l.EntryPrice
+	}
+	if distance <= 0 {
+		return 1
+	}
+	if moved < 0 {
+		return 0
+	}
+	return moved / distance
+}
+
+// LiquidationProgress 全倉帳戶距離爆倉的進度：0 表示沒有未實現虧損，1 表示總權益降至維持保證金
+func (s CrossMarginStatus) LiquidationProgress() float64 {
+	collateral := s.WalletBalance + s.PositionMargin
+	buffer := collateral - s.MaintenanceMargin
+	if buffer <= 0 {
+		return 1
+	}
+	loss := collateral - s.Equity
+	if loss < 0 {
+		return 0
+	}
+	return loss / buffer
+}
+
+// ParseMarginCallThresholds 解析以逗號分隔的警告門檻（例如 "0.7,0.9"），並由小到大排序
+func ParseMarginCallThresholds(value string) ([]float64, error) {
+	var thresholds []float64
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		threshold, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return nil, err
+		}
+		if threshold > 0 && threshold < 1 {
+			thresholds = append(thresholds, threshold)
+		}
+	}
+	sort.Float64s(thresholds)
+	return thresholds, nil
+}
+
+// NextMarginCallLevel 依爆倉進度計算新的警告等級（0 表示未達任何門檻）
+// 進度達到更高門檻時立即升級；需回落到門檻減去 hysteresis 以下才降級，避免在門檻附近反覆警告
+func NextMarginCallLevel(current int, progress float64, thresholds []float64, hysteresis float64) int {
+	if current > len(thresholds) {
+		current = len(thresholds)
+	}
+
+	reached := 0
+	for i, threshold := range thresholds {
+		if progress >= threshold {
+			reached = i + 1
+		}
+	}
+	if reached > current {
+		return reached
+	}
+
+	for current > 0 && progress < thresholds[current-1]-hysteresis {
+		current--
+	}
+	return current
+}
+
+// UpdatePositionMarginCallLevel 更新倉位的警告等級（僅更新持倉中的倉位，可在交易中使用）
+func UpdatePositionMarginCallLevel(o orm.QueryExecutor, positionId int64, level int) error {
+	_, err := o.QueryTable(new(LeveragePosition)).
+		Filter("Id", positionId).
+		Filter("Status", PositionStatusOpen).
+		Update(orm.Params{"MarginCallLevel": level})
+	return err
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"testing"
+)
+
+// TestLiquidationProgress 測試逐倉倉位距離爆倉的進度
+func TestLiquidationProgress(t *testing.T) {
+	long := newTestPosition(PositionSideLong)
+	short := newTestPosition(PositionSideShort)
+
+	if progress := long.LiquidationProgress(110); progress != 0 {
+		t.Errorf("expected 0 progress for profitable long, got %.4f", progress)
+	}
+	midLong := (long.EntryPrice + long.LiquidationPrice) / 2
+	if progress := long.LiquidationProgress(midLong); !almostEqual(progress, 0.5) {
+		t.Errorf("expected 0.5 progress for long, got %.4f", progress)
+	}
+	if progress := long.LiquidationProgress(long.LiquidationPrice); !almostEqual(progress, 1) {
+		t.Errorf("expected 1 progress at liquidation price, got %.4f", progress)
+	}
+
+	midShort := (short.EntryPrice + short.LiquidationPrice) / 2
+	if progress := short.LiquidationProgress(midShort); !almostEqual(progress, 0.5) {
+		t.Errorf("expected 0.5 progress for short, got %.4f", progress)
+	}
+	if progress := short.LiquidationProgress(90); progress != 0 {
+		t.Errorf("expected 0 progress for profitable short, got %.4f", progress)
+	}
+}
+
+// TestCrossLiquidationProgress 測試全倉帳戶距離爆倉的進度
+func TestCrossLiquidationProgress(t *testing.T) {
+	status := CrossMarginStatus{
+		WalletBalance:     900,
+		PositionMargin:    100,
+		MaintenanceMargin: 200,
+	}
+
+	status.Equity = 1000
+	if progress := status.LiquidationProgress(); progress != 0 {
+		t.Errorf("expected 0 progress without loss, got %.4f", progress)
+	}
+	status.Equity = 600
+	if progress := status.LiquidationProgress(); !almostEqual(progress, 0.5) {
+		t.Errorf("expected 0.5 progress, got %.4f", progress)
+	}
+	status.Equity = 200
+	if progress := status.LiquidationProgress(); !almostEqual(progress, 1) {
+		t.Errorf("expected 1 progress at maintenance margin, got %.4f", progress)
+	}
+}
+
+// TestParseMarginCallThresholds 測試解析警告門檻
+func TestParseMarginCallThresholds(t *testing.T) {
+	thresholds, err := ParseMarginCallThresholds(" 0.9, 0.7 ,,1.5")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(thresholds) != 2 || thresholds[0] != 0.7 || thresholds[1] != 0.9 {
+		t.Errorf("expected [0.7 0.9], got %v", thresholds)
+	}
+
+	if _, err = ParseMarginCallThresholds("0.7,abc"); err == nil {
+		t.Error("expected error for invalid threshold")
+	}
+}
+
+// TestNextMarginCallLevel 測試警告等級的升降與遲滯
+func TestNextMarginCallLevel(t *testing.T) {
+	thresholds := []float64{0.7, 0.9}
+	tests := []struct {
+		name     string
+		current  int
+		progress float64
+		want     int
+	}{
+		{"below first threshold", 0, 0.5, 0},
+		{"cross first threshold", 0, 0.7, 1},
+		{"jump to second threshold", 0, 0.95, 2},
+		{"stay within hysteresis", 1, 0.66, 1},
+		{"reset below hysteresis", 1, 0.64, 0},
+		{"drop one level", 2, 0.8, 1},
+		{"drop all levels", 2, 0.3, 0},
+		{"stay at second level", 2, 0.87, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NextMarginCallLevel(tt.current, tt.progress, thresholds, 0.05); got != tt.want {
+				t.Errorf("expected level %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// MarginMode 保證金模式
+type MarginMode string
+
+const (
+	MarginModeIsolated MarginMode = "ISOLATED" // 逐倉：每個倉位獨立保證金與爆倉價格
+	MarginModeCross    MarginMode = "CROSS"    // 全倉：所有持倉共用 USDT 錢包作為擔保
+)
+
+// IsValid 檢查保證金模式是否合法
+func (m MarginMode) IsValid() bool {
+	return m == MarginModeIsolated || m == MarginModeCross
+}
+
+// GetUserMarginMode 查詢使用者目前的保證金模式（未設定時為逐倉）
+func GetUserMarginMode(o orm.QueryExecutor, userId int64) (MarginMode, error) {
+	user := &User{Id: userId}
+	if err := o.Read(user, "MarginMode"); err != nil {
+		if err == orm.ErrNoRows {
+			return "", errors.New("user not found")
+		}
+		return "", err
+	}
+	if user.MarginMode == "" {
+		return MarginModeIsolated, nil
+	}
+	return user.MarginMode, nil
+}
+
+// UpdateUserMarginMode 更新使用者的保證金模式（需要在交易中使用）
+func UpdateUserMarginMode(o orm.QueryExecutor, userId int64, mode MarginMode) error {
+	user := &User{Id: userId}
+	if err := o.ReadForUpdate(user); err != nil {
+		if err == orm.ErrNoRows {
+			return errors.New("user not found")
+		}
+		return err
+	}
+	user.MarginMode = mode
+	_, err := o.Update(user, "MarginMode")
+	return err
+}
+
+// CrossMarginStatus 全倉帳戶狀態
+type CrossMarginStatus struct {
+	WalletBalance     float64 `json:"walletBalance"`     // 錢包可用餘額（USDT）
+	PositionMargin    float64 `json:"positionMargin"`    // 持倉佔用保證金總和
+	UnrealizedPnL     float64 `json:"unrealizedPnl"`     // 未實現盈虧總和
+	Equity            float64 `json:"equity"`            // 總權益 = 可用餘額 + 保證金 + 未實現盈虧
+	MaintenanceMargin float64 `json:"maintenanceMargin"` // 維持保證金總和
+}
+
+// CalculateCrossMarginStatus 計算全倉帳戶狀態
+// positions 需已依標記價格更新 UnrealizedPnL，只計入全倉持倉
+// markPrices 缺少的交易對以開倉價格計算維持保證金
+func CalculateCrossMarginStatus(availableBalance float64, positions []*LeveragePosition, markPrices map[string]float64) CrossMarginStatus {
+	status := CrossMarginStatus{WalletBalance: availableBalance}
+	for _, position := range positions {
+		if !position.IsCross() || position.Status != PositionStatusOpen {
+			continue
+		}
+		status.PositionMargin += position.Margin
+		status.UnrealizedPnL += position.UnrealizedPnL
+		markPrice, ok := markPrices[position.Symbol]
+		if !ok {
+			markPrice = position.EntryPrice
+		}
+		status.MaintenanceMargin += position.MaintenanceMargin(markPrice)
+	}
+	status.Equity = status.WalletBalance + status.PositionMargin + status.UnrealizedPnL
+	return status
+}
+
+// ShouldLiquidate 總權益低於維持保證金總和時應爆倉
+func (s CrossMarginStatus) ShouldLiquidate() bool {
+	return s.MaintenanceMargin > 0 && s.Equity < s.MaintenanceMargin
+}
@@ -0,0 +1,85 @@
+package models
+
+import "testing"
+
+func newTestCrossPosition(side PositionSide, currentPrice float64) *LeveragePosition {
+	position := newTestPosition(side)
+	position.MarginMode = MarginModeCross
+	position.LiquidationPrice = position.CalculateLiquidationPrice()
+	position.UnrealizedPnL = position.CalculateUnrealizedPnL(currentPrice)
+	return position
+}
+
+// TestCrossMarginStatus 測試全倉帳戶總權益與維持保證金
+func TestCrossMarginStatus(t *testing.T) {
+	long := newTestCrossPosition(PositionSideLong, 95)   // -50
+	short := newTestCrossPosition(PositionSideShort, 98) // +20
+	isolated := newTestPosition(PositionSideLong)        // 不計入全倉
+	isolated.UnrealizedPnL = -1000
+
+	markPrices := map[string]float64{"BTCUSDT": 95}
+	status := CalculateCrossMarginStatus(500, []*LeveragePosition{long, short, isolated}, markPrices)
+
+	if !almostEqual(status.PositionMargin, 200) || !almostEqual(status.UnrealizedPnL, -30) {
+		t.Errorf("expected margin 200 / PnL -30, got %.2f / %.2f", status.PositionMargin, status.UnrealizedPnL)
+	}
+	if !almostEqual(status.Equity, 670) {
+		t.Errorf("expected equity 670, got %.2f", status.Equity)
+	}
+	// 兩個倉位名義價值皆以標記價 95 計算：950 * 0.4% * 2
+	if !almostEqual(status.MaintenanceMargin, 7.6) {
+		t.Errorf("expected maintenance margin 7.6, got %.2f", status.MaintenanceMargin)
+	}
+	if status.ShouldLiquidate() {
+		t.Error("account should not be liquidated")
+	}
+}
+
+// TestCrossMarginLiquidation 測試全倉虧損可超過單一倉位保證金，直到總權益低於維持保證金
+func TestCrossMarginLiquidation(t *testing.T) {
+	// 逐倉在 91 就會爆倉，全倉有錢包餘額支撐
+	position := newTestCrossPosition(PositionSideLong, 85) // -150
+	if position.IsLiquidated(85) {
+		t.Error("cross position should not be liquidated by price alone")
+	}
+	if position.LiquidationPrice != 0 {
+		t.Errorf("expected no fixed liquidation price, got %.2f", position.LiquidationPrice)
+	}
+
+	status := CalculateCrossMarginStatus(100, []*LeveragePosition{position}, map[string]float64{"BTCUSDT": 85})
+	if status.ShouldLiquidate() {
+		t.Errorf("equity %.2f should cover maintenance margin %.2f", status.Equity, status.MaintenanceMargin)
+	}
+
+	// 權益 100 + 100 - 199 = 1 < 維持保證金 801 * 0.4% ≈ 3.2
+	position.UnrealizedPnL = position.CalculateUnrealizedPnL(80.1)
+	status = CalculateCrossMarginStatus(100, []*LeveragePosition{position}, map[string]float64{"BTCUSDT": 80.1})
+	if !status.ShouldLiquidate() {
+		t.Errorf("expected liquidation with equity %.2f", status.Equity)
+	}
+
+	// 無全倉持倉時不爆倉
+	if CalculateCrossMarginStatus(0, nil, nil).ShouldLiquidate() {
+		t.Error("empty account should not be liquidated")
+	}
+}
+
+// TestCrossSettle 測試全倉平倉虧損不以保證金為上限
+func TestCrossSettle(t *testing.T) {
+	position := newTestCrossPosition(PositionSideShort, 115)
+	returnAmount := position.Settle(115, PositionStatusClosed)
+	if !almostEqual(returnAmount, -50) || !almostEqual(position.RealizedPnL, -150) {
+		t.Errorf("expected return -50 / PnL -150, got %.2f / %.2f", returnAmount, position.RealizedPnL)
+	}
+
+	// 全倉爆倉以結算價格實現盈虧
+	position = newTestCrossPosition(PositionSideLong, 88)
+	returnAmount = position.Settle(88, PositionStatusLiquidated)
+	if !almostEqual(returnAmount, -20) {
+		t.Errorf("expected return -20, got %.2f", returnAmount)
+	}
+
+	if err := newTestCrossPosition(PositionSideLong, 100).AdjustMargin(10, 100); err == nil {
+		t.Error("expected error when adjusting cross margin")
+	}
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// MarginTier 維持保證金率階梯
+type MarginTier struct {
+	MaxNotional           float64 `json:"maxNotional"`           // 倉位名義價值上限（USDT），0 表示無上限
+	MaintenanceMarginRate float64 `json:"maintenanceMarginRate"` // 維持保證金率
+	MaintenanceAmount     float64 `json:"maintenanceAmount"`     // 維持保證金速算額，使各階梯交界處的維持保證金連續
+}
+
+// DefaultMarginTierSymbol 未單獨設定階梯的交易對使用的設定
+const DefaultMarginTierSymbol = "DEFAULT"
+
+var (
+	marginTiersMu sync.RWMutex
+	marginTiers   = map[string][]MarginTier{
+		"BTCUSDT": {
+			{MaxNotional: 50000, MaintenanceMarginRate: 0.004},
+			{MaxNotional: 250000, MaintenanceMarginRate: 0.005},
+			{MaxNotional: 3000000, MaintenanceMarginRate: 0.01},
+			{MaxNotional: 20000000, MaintenanceMarginRate: 0.025},
+			{MaxNotional: 0, MaintenanceMarginRate: 0.05},
+		},
+		"ETHUSDT": {
+			{MaxNotional: 50000, MaintenanceMarginRate: 0.005},
+			{MaxNotional: 250000, MaintenanceMarginRate: 0.0065},
+			{MaxNotional: 2000000, MaintenanceMarginRate: 0.01},
+			{MaxNotional: 10000000, MaintenanceMarginRate: 0.02},
+			{MaxNotional: 0, MaintenanceMarginRate: 0.05},
+		},
+		"SOLUSDT": {
+			{MaxNotional: 10000, MaintenanceMarginRate: 0.01},
+			{MaxNotional: 50000, MaintenanceMarginRate: 0.015},
+			{MaxNotional: 250000, MaintenanceMarginRate: 0.025},
+			{MaxNotional: 1000000, MaintenanceMarginRate: 0.05},
+			{MaxNotional: 0, MaintenanceMarginRate: 0.1},
+		},
+		DefaultMarginTierSymbol: {
+			{MaxNotional: 10000, MaintenanceMarginRate: 0.01},
+			{MaxNotional: 100000, MaintenanceMarginRate: 0.025},
+			{MaxNotional: 0, MaintenanceMarginRate: 0.05},
+		},
+	}
+)
+
+func init() {
+	for symbol, tiers := range marginTiers {
+		if err := normalizeMarginTiers(tiers); err != nil {
+			panic(fmt.Sprintf("invalid default margin tiers for %s: %v", symbol, err))
+		}
+	}
+}
+
+// normalizeMarginTiers 依名義價值上限排序、檢查設定並計算速算額
+// 速算額 cum[i] = cum[i-1] + MaxNotional[i-1] * (rate[i] - rate[i-1])
+func normalizeMarginTiers(tiers []MarginTier) error {
+	if len(tiers) == 0 {
+		return errors.New("at least one tier is required")
+	}
+
+	sort.SliceStable(tiers, func(i, j int) bool {
+		// 無上限的階梯排在最後
+		if tiers[i].MaxNotional == 0 {
+			return false
+		}
+		if tiers[j].MaxNotional == 0 {
+			return true
+		}
+		return tiers[i].MaxNotional < tiers[j].MaxNotional
+	})
+
+	for i := range tiers {
+		if tiers[i].MaintenanceMarginRate <= 0 || tiers[i].MaintenanceMarginRate >= 1 {
+			return fmt.Errorf("maintenance margin rate must be between 0 and 1, got %v", tiers[i].MaintenanceMarginRate)
+		}
+		if tiers[i].MaxNotional < 0 {
+			return errors.New("max notional must not be negative")
+		}
+		if tiers[i].MaxNotional == 0 && i != len(tiers)-1 {
+			return errors.New("only one tier may be unbounded")
+		}
+
+		if i == 0 {
+			tiers[i].MaintenanceAmount = 0
+			continue
+		}
+		prev := tiers[i-1]
+		tiers[i].MaintenanceAmount = prev.MaintenanceAmount + prev.MaxNotional*(tiers[i].MaintenanceMarginRate-prev.MaintenanceMarginRate)
+	}
+	return nil
+}
+
+// LoadMarginTiers 從 JSON 檔案載入各交易對的維持保證金率階梯，覆蓋預設設定
+// 檔案格式：{"BTCUSDT": [{"maxNotional": 50000, "maintenanceMarginRate": 0.004}, ...]}
+func LoadMarginTiers(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var loaded map[string][]MarginTier
+	if err = json.Unmarshal(data, &loaded); err != nil {
+		return fmt.Errorf("invalid margin tiers file: %v", err)
+	}
+
+	for symbol, tiers := range loaded {
+		if err = normalizeMarginTiers(tiers); err != nil {
+			return fmt.Errorf("invalid margin tiers for %s: %v", symbol, err)
+		}
+	}
+
+	marginTiersMu.Lock()
+	defer marginTiersMu.Unlock()
+	for symbol, tiers := range loaded {
+		marginTiers[symbol] = tiers
+	}
+	return nil
+}
+
+// GetMarginTiers 取得交易對的維持保證金率階梯（未設定時使用預設階梯）
+func GetMarginTiers(symbol string) []MarginTier {
+	marginTiersMu.RLock()
+	defer marginTiersMu.RUnlock()
+
+	tiers, ok := marginTiers[symbol]
+	if !ok {
+		tiers = marginTiers[DefaultMarginTierSymbol]
+	}
+	result := make([]MarginTier, len(tiers))
+	copy(result, tiers)
+	return result
+}
+
+// GetMarginTier 依倉位名義價值取得適用的維持保證金率階梯
+func GetMarginTier(symbol string, notional float64) MarginTier {
+	marginTiersMu.RLock()
+	defer marginTiersMu.RUnlock()
+
+	tiers, ok := marginTiers[symbol]
+	if !ok {
+		tiers = marginTiers[DefaultMarginTierSymbol]
+	}
+	for _, tier := range tiers {
+		if tier.MaxNotional == 0 || notional <= tier.MaxNotional {
+			return tier
+		}
+	}
+	return tiers[len(tiers)-1]
+}
+
+// MaintenanceMargin 計算名義價值對應的維持保證金 = 名義價值 * 維持保證金率 - 速算額
+func (t MarginTier) MaintenanceMargin(notional float64) float64 {
+	return notional*t.MaintenanceMarginRate - t.MaintenanceAmount
+}
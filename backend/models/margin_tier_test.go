@@ -0,0 +1,96 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMarginTierLookup 測試依名義價值取得階梯
+func TestMarginTierLookup(t *testing.T) {
+	tests := []struct {
+		symbol   string
+		notional float64
+		wantRate float64
+	}{
+		{"BTCUSDT", 1000, 0.004},
+		{"BTCUSDT", 50000, 0.004},
+		{"BTCUSDT", 50001, 0.005},
+		{"BTCUSDT", 1e9, 0.05},
+		{"SOLUSDT", 20000, 0.015},
+		{"DOGEUSDT", 500000, 0.05}, // 未設定的交易對使用預設階梯
+	}
+
+	for _, tt := range tests {
+		tier := GetMarginTier(tt.symbol, tt.notional)
+		if tier.MaintenanceMarginRate != tt.wantRate {
+			t.Errorf("%s %.0f: expected rate %v, got %v", tt.symbol, tt.notional, tt.wantRate, tier.MaintenanceMarginRate)
+		}
+	}
+}
+
+// TestMaintenanceMarginContinuous 測試速算額使維持保證金在階梯交界處連續
+func TestMaintenanceMarginContinuous(t *testing.T) {
+	for _, symbol := range []string{"BTCUSDT", "ETHUSDT", "SOLUSDT", DefaultMarginTierSymbol} {
+		for _, tier := range GetMarginTiers(symbol) {
+			if tier.MaxNotional == 0 {
+				continue
+			}
+			below := GetMarginTier(symbol, tier.MaxNotional)
+			above := GetMarginTier(symbol, tier.MaxNotional+1e-6)
+			if diff := above.MaintenanceMargin(tier.MaxNotional) - below.MaintenanceMargin(tier.MaxNotional); diff > 1e-6 || diff < -1e-6 {
+				t.Errorf("%s: maintenance margin jumps by %v at %.0f", symbol, diff, tier.MaxNotional)
+			}
+		}
+	}
+}
+
+// TestLiquidationPriceMatchesMaintenanceMargin 測試在爆倉價格時保證金 + 未實現盈虧恰好等於維持保證金
+func TestLiquidationPriceMatchesMaintenanceMargin(t *testing.T) {
+	for _, side := range []PositionSide{PositionSideLong, PositionSideShort} {
+		position := newTestPosition(side)
+		position.Symbol = "ETHUSDT"
+		position.Quantity = 1000 // 名義價值 100,000，落在第二階梯
+		position.Margin = 10000
+		position.LiquidationPrice = position.CalculateLiquidationPrice()
+
+		liqPrice := position.LiquidationPrice
+		equity := position.Margin + position.CalculateUnrealizedPnL(liqPrice)
+		tier := GetMarginTier(position.Symbol, position.EntryPrice*position.Quantity)
+		maintenance := tier.MaintenanceMargin(liqPrice * position.Quantity)
+		if diff := equity - maintenance; diff > 1e-6 || diff < -1e-6 {
+			t.Errorf("%s: equity %.4f != maintenance margin %.4f at liquidation price %.4f", side, equity, maintenance, liqPrice)
+		}
+	}
+}
+
+// TestLoadMarginTiers 測試從設定檔載入階梯
+func TestLoadMarginTiers(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.json")
+	os.WriteFile(valid, []byte(`{"TESTUSDT": [
+		{"maxNotional": 0, "maintenanceMarginRate": 0.1},
+		{"maxNotional": 1000, "maintenanceMarginRate": 0.02}
+	]}`), 0o644)
+	if err := LoadMarginTiers(valid); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tiers := GetMarginTiers("TESTUSDT")
+	if len(tiers) != 2 || tiers[0].MaxNotional != 1000 || tiers[1].MaxNotional != 0 {
+		t.Fatalf("expected tiers sorted by max notional, got %+v", tiers)
+	}
+	if !almostEqual(tiers[1].MaintenanceAmount, 80) {
+		t.Errorf("expected maintenance amount 80, got %v", tiers[1].MaintenanceAmount)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	os.WriteFile(invalid, []byte(`{"TESTUSDT": [{"maxNotional": 1000, "maintenanceMarginRate": 1.5}]}`), 0o644)
+	if err := LoadMarginTiers(invalid); err == nil {
+		t.Error("expected error for invalid maintenance margin rate")
+	}
+	if GetMarginTier("TESTUSDT", 5000).MaintenanceMarginRate != 0.1 {
+		t.Error("invalid file should not replace loaded tiers")
+	}
+}
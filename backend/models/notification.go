@@ -0,0 +1,76 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// Notification 待推送給使用者的 WebSocket 消息（outbox）
+// 與產生消息的訂單、倉位或錢包變更在同一個資料庫交易中寫入，交易回滾時消息也不存在，提交後由推送服務依 ID 順序推送
+type Notification struct {
+	Id        int64         `orm:"auto" json:"id"`
+	User      *User         `orm:"rel(fk)" json:"-"`
+	Type      WSMessageType `orm:"size(50)" json:"type"`
+	Payload   string        `orm:"type(text)" json:"-"` // 消息內容（WSMessage 的 JSON）
+	CreatedAt time.Time     `orm:"auto_now_add;type(datetime)" json:"createdAt"`
+}
+
+func init() {
+	orm.RegisterModel(new(Notification))
+}
+
+// TableName 指定資料表名稱
+func (n *Notification) TableName() string {
+	return "notification_outbox"
+}
+
+// EnqueueNotification 寫入待推送的消息（需要在產生消息的交易中使用）
+func EnqueueNotification(o orm.QueryExecutor, userId int64, message *WSMessage) error {
+	_, err := o.Insert(&Notification{
+		User:    &User{Id: userId},
+		Type:    message.Type,
+		Payload: string(message.ToJSON()),
+	})
+	return err
+}
+
+// Message 推送的消息內容，帶上事件 ID 讓接收端去除重複
+func (n *Notification) Message() []byte {
+	var message struct {
+		Type      WSMessageType   `json:"type"`
+		Timestamp time.Time       `json:"timestamp"`
+		Data      json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(n.Payload), &message); err != nil {
+		return nil
+	}
+	return (&WSMessage{
+		EventId:   n.Id,
+		Type:      message.Type,
+		Timestamp: message.Timestamp,
+		Data:      message.Data,
+	}).ToJSON()
+}
+
+// GetPendingNotifications 查詢尚未推送的消息（依寫入順序）
+func GetPendingNotifications(limit int) ([]*Notification, error) {
+	o := orm.NewOrm()
+	var notifications []*Notification
+	_, err := o.QueryTable(new(Notification)).
+		OrderBy("Id").
+		Limit(limit).
+		All(&notifications)
+	return notifications, err
+}
+
+// DeleteNotifications 刪除已推送的消息
+func DeleteNotifications(ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	o := orm.NewOrm()
+	_, err := o.QueryTable(new(Notification)).Filter("Id__in", ids).Delete()
+	return err
+}
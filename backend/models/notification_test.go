@@ -0,0 +1,39 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNotificationMessage(t *testing.T) {
+	message := &WSMessage{
+		Type:      WSMessageTypeLimitOrderFilled,
+		Timestamp: time.Date(2024, 1, 1, 0, 0, 0, 123, time.UTC),
+		Data:      map[string]interface{}{"orderId": 7, "symbol": "BTCUSDT"},
+	}
+	notification := &Notification{Id: 42, Type: message.Type, Payload: string(message.ToJSON())}
+
+	var got struct {
+		EventId   int64                  `json:"eventId"`
+		Type      WSMessageType          `json:"type"`
+		Timestamp time.Time              `json:"timestamp"`
+		Data      map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(notification.Message(), &got); err != nil {
+		t.Fatalf("Message() is not valid JSON: %v", err)
+	}
+	if got.EventId != 42 || got.Type != WSMessageTypeLimitOrderFilled {
+		t.Errorf("eventId %d, type %s, want 42 and %s", got.EventId, got.Type, WSMessageTypeLimitOrderFilled)
+	}
+	if !got.Timestamp.Equal(message.Timestamp) {
+		t.Errorf("timestamp %s, want %s", got.Timestamp, message.Timestamp)
+	}
+	if got.Data["symbol"] != "BTCUSDT" || got.Data["orderId"] != float64(7) {
+		t.Errorf("data = %v", got.Data)
+	}
+
+	if (&Notification{Id: 1, Payload: "not json"}).Message() != nil {
+		t.Error("malformed payload should produce no message")
+	}
+}
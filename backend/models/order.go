@@ -19,8 +19,10 @@ const (
 type OrderType string
 
 const (
-	OrderTypeMarket OrderType = "MARKET" // 市價單
-	OrderTypeLimit  OrderType = "LIMIT"  // 限價單
+	OrderTypeMarket       OrderType = "MARKET"        // 市價單
+	OrderTypeLimit        OrderType = "LIMIT"         // 限價單
+	OrderTypeStop         OrderType = "STOP"          // 停損單（價格觸及停損價時以市價成交，用於 OCO 訂單組）
+	OrderTypeTrailingStop OrderType = "TRAILING_STOP" // 追蹤停損單
 )
 
 // OrderStatus 訂單狀態
@@ -35,57 +37,116 @@ const (
 
 // Order 訂單
 type Order struct {
-	Id              int64       `orm:"auto" json:"id"`
-	User            *User       `orm:"rel(fk)" json:"-"`
-	Symbol          string      `orm:"size(20)" json:"symbol"`                                  // 交易對：BTCUSDT, ETHUSDT, SOLUSDT
-	Type            OrderType   `orm:"size(10)" json:"type"`                                    // MARKET or LIMIT
-	Side            OrderSide   `orm:"size(10)" json:"side"`                                    // BUY or SELL
-	Quantity        float64     `orm:"digits(20);decimals(8)" json:"quantity"`                  // 交易數量
-	LimitPrice      float64     `orm:"digits(20);decimals(8);null" json:"limitPrice,omitempty"` // 限價（僅限價單使用）
-	Price           float64     `orm:"digits(20);decimals(8)" json:"price"`                     // 成交價格
-	TotalAmount     float64     `orm:"digits(20);decimals(8)" json:"totalAmount"`               // 總金額
-	IsLeverageOrder bool        `orm:"default(false)" json:"isLeverageOrder"`                   // 是否是槓桿訂單
-	Leverage        int         `orm:"default(1);null" json:"leverage,omitempty"`               // 槓桿倍數（僅槓桿訂單使用）
-	PositionSideStr string      `orm:"size(10);null" json:"positionSide,omitempty"`             // 倉位方向：LONG or SHORT（僅槓桿訂單使用）
-	Status          OrderStatus `orm:"size(20)" json:"status"`
-	ErrorMsg        string      `orm:"size(500);null" json:"errorMsg,omitempty"`
-	CreatedAt       time.Time   `orm:"auto_now_add;type(datetime)" json:"createdAt"`
-	UpdatedAt       time.Time   `orm:"auto_now;type(datetime)" json:"updatedAt"`
+	Id              int64             `orm:"auto" json:"id"`
+	User            *User             `orm:"rel(fk)" json:"-"`
+	ClientOrderId   *string           `orm:"size(64);null" json:"clientOrderId,omitempty"`                 // 使用者自訂的訂單 ID（同一使用者唯一，未指定時為 NULL）
+	Symbol          string            `orm:"size(20)" json:"symbol"`                                       // 交易對：BTCUSDT, ETHUSDT, SOLUSDT
+	Type            OrderType         `orm:"size(20)" json:"type"`                                         // MARKET, LIMIT, STOP or TRAILING_STOP
+	Side            OrderSide         `orm:"size(10)" json:"side"`                                         // BUY or SELL
+	Quantity        float64           `orm:"digits(20);decimals(8)" json:"quantity"`                       // 交易數量
+	LimitPrice      float64           `orm:"digits(20);decimals(8);null" json:"limitPrice,omitempty"`      // 限價（僅限價單使用）
+	Price           float64           `orm:"digits(20);decimals(8)" json:"price"`                          // 成交價格
+	TotalAmount     float64           `orm:"digits(20);decimals(8)" json:"totalAmount"`                    // 總金額
+	IsLeverageOrder bool              `orm:"default(false)" json:"isLeverageOrder"`                        // 是否是槓桿訂單
+	Leverage        int               `orm:"default(1);null" json:"leverage,omitempty"`                    // 槓桿倍數（僅槓桿訂單使用）
+	PositionSideStr string            `orm:"size(10);null" json:"positionSide,omitempty"`                  // 倉位方向：LONG or SHORT（僅槓桿訂單使用）
+	ReduceOnly      bool              `orm:"default(false)" json:"reduceOnly"`                             // 只減倉：只減少反方向持倉，不開新倉（僅槓桿訂單使用）
+	CallbackRate    float64           `orm:"digits(20);decimals(8);null" json:"callbackRate,omitempty"`    // 追蹤停損回撤比例（例如 0.01 表示 1%）
+	CallbackOffset  float64           `orm:"digits(20);decimals(8);null" json:"callbackOffset,omitempty"`  // 追蹤停損回撤金額（與回撤比例擇一）
+	ActivationPrice float64           `orm:"digits(20);decimals(8);null" json:"activationPrice,omitempty"` // 追蹤停損啟動價格（0 表示下單時立即啟動）
+	TrailingActive  bool              `orm:"default(false)" json:"trailingActive"`                         // 追蹤停損是否已啟動
+	TrailingExtreme float64           `orm:"digits(20);decimals(8);null" json:"-"`                         // 啟動後的最高價（賣出）或最低價（買入）
+	TriggerPrice    float64           `orm:"digits(20);decimals(8);null" json:"triggerPrice,omitempty"`    // 目前的觸發價格
+	StopPrice       float64           `orm:"digits(20);decimals(8);null" json:"stopPrice,omitempty"`       // 停損價格（僅停損單使用）
+	OrderListId     int64             `orm:"default(0);index" json:"orderListId,omitempty"`                // 所屬訂單組 ID（0 表示單獨的訂單）
+	AlgoOrderId     int64             `orm:"default(0);index" json:"algoOrderId,omitempty"`                // 所屬演算法母單 ID（TWAP、冰山單的子訂單）
+	GridBotId       int64             `orm:"default(0);index" json:"gridBotId,omitempty"`                  // 所屬網格機器人 ID
+	StrategyBotId   int64             `orm:"default(0);index" json:"strategyBotId,omitempty"`              // 所屬策略機器人 ID
+	Version         int               `orm:"default(0)" json:"version"`                                    // 修改次數，撮合時用來確認訂單未在檢查後被修改
+	Amendments      []*OrderAmendment `orm:"-" json:"amendments,omitempty"`                                // 修改記錄（查詢時載入）
+	Status          OrderStatus       `orm:"size(20)" json:"status"`
+	ErrorMsg        string            `orm:"size(500);null" json:"errorMsg,omitempty"`
+	CreatedAt       time.Time         `orm:"auto_now_add;type(datetime)" json:"createdAt"`
+	UpdatedAt       time.Time         `orm:"auto_now;type(datetime)" json:"updatedAt"`
 }
 
 func init() {
 	orm.RegisterModel(new(Order))
 }
 
-// CreateOrder 建立新訂單
+// TableUnique 同一使用者的自訂訂單 ID 不可重複
+func (order *Order) TableUnique() [][]string {
+	return [][]string{{"User", "ClientOrderId"}}
+}
+
+// ErrDuplicateClientOrderId 使用者已有相同自訂 ID 的訂單
+var ErrDuplicateClientOrderId = errors.New("duplicate clientOrderId")
+
+// CreateOrder 建立新訂單（訂單與建立事件在同一個交易中寫入）
 func CreateOrder(userId int64, symbol string, orderType OrderType, side OrderSide, quantity float64, limitPrice *float64) (*Order, error) {
 	o := orm.NewOrm()
+	to, err := o.Begin()
+	if err != nil {
+		return nil, err
+	}
 
+	order, err := CreateChildOrder(to, userId, OrderParent{}, symbol, orderType, side, quantity, limitPrice)
+	if err != nil {
+		to.Rollback()
+		return nil, err
+	}
+	if err = to.Commit(); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// OrderParent 子訂單所屬的母單或機器人（皆為 0 表示一般訂單）
+type OrderParent struct {
+	AlgoOrderId   int64  // TWAP、冰山單
+	GridBotId     int64  // 網格機器人
+	StrategyBotId int64  // 策略機器人
+	ClientOrderId string // 使用者自訂的訂單 ID（只用於使用者直接下的訂單，空字串表示未指定）
+
+	IdempotencyKeys []string // 下單請求登記的冪等 Key，與訂單在同一交易中記錄訂單 ID（見 LinkIdempotencyKeys）
+}
+
+// clientOrderId 自訂訂單 ID 的欄位值，未指定時為 NULL
+func (p OrderParent) clientOrderId() *string {
+	if p.ClientOrderId == "" {
+		return nil
+	}
+	clientOrderId := p.ClientOrderId
+	return &clientOrderId
+}
+
+// CreateChildOrder 建立母單或機器人的子訂單（可在交易中使用，與母單或機器人的關聯記錄一併提交）
+func CreateChildOrder(o orm.QueryExecutor, userId int64, parent OrderParent, symbol string, orderType OrderType, side OrderSide, quantity float64, limitPrice *float64) (*Order, error) {
 	order := &Order{
-		User:     &User{Id: userId},
-		Symbol:   symbol,
-		Type:     orderType,
-		Side:     side,
-		Quantity: quantity,
-		Status:   OrderStatusPending,
+		User:          &User{Id: userId},
+		Symbol:        symbol,
+		Type:          orderType,
+		Side:          side,
+		Quantity:      quantity,
+		Status:        OrderStatusPending,
+		ClientOrderId: parent.clientOrderId(),
+		AlgoOrderId:   parent.AlgoOrderId,
+		GridBotId:     parent.GridBotId,
+		StrategyBotId: parent.StrategyBotId,
 	}
 
 	if limitPrice != nil {
 		order.LimitPrice = *limitPrice
 	}
 
-	id, err := o.Insert(order)
-	if err != nil {
+	if err := insertOrder(o, order, parent); err != nil {
 		return nil, err
 	}
-	order.Id = id
 	return order, nil
 }
 
-// CreateLeverageOrder 建立槓桿訂單
-func CreateLeverageOrder(userId int64, symbol string, orderType OrderType, side OrderSide, quantity float64, limitPrice *float64, leverage int, positionSide PositionSide) (*Order, error) {
-	o := orm.NewOrm()
-
+// CreateLeverageOrder 建立槓桿訂單（需要在交易中使用，以便與保證金凍結一併提交），parent 不為空時為機器人的子訂單
+func CreateLeverageOrder(o orm.QueryExecutor, userId int64, parent OrderParent, symbol string, orderType OrderType, side OrderSide, quantity float64, limitPrice *float64, leverage int, positionSide PositionSide, reduceOnly bool) (*Order, error) {
 	order := &Order{
 		User:            &User{Id: userId},
 		Symbol:          symbol,
@@ -96,35 +157,47 @@ func CreateLeverageOrder(userId int64, symbol string, orderType OrderType, side
 		IsLeverageOrder: true,
 		Leverage:        leverage,
 		PositionSideStr: string(positionSide),
+		ReduceOnly:      reduceOnly,
+		ClientOrderId:   parent.clientOrderId(),
+		AlgoOrderId:     parent.AlgoOrderId,
+		GridBotId:       parent.GridBotId,
+		StrategyBotId:   parent.StrategyBotId,
 	}
 
 	if limitPrice != nil {
 		order.LimitPrice = *limitPrice
 	}
 
-	id, err := o.Insert(order)
-	if err != nil {
+	if err := insertOrder(o, order, parent); err != nil {
 		return nil, err
 	}
-	order.Id = id
 	return order, nil
 }
 
-// UpdateOrderStatus 更新訂單狀態
-func UpdateOrderStatus(tx orm.QueryExecutor, orderId int64, status OrderStatus, price float64, totalAmount float64, errorMsg string) error {
-	order := &Order{Id: orderId}
-	ormer := orm.NewOrm()
-	if err := ormer.Read(order); err != nil {
+// insertOrder 寫入新訂單並記錄建立事件與冪等 Key 的訂單 ID，自訂訂單 ID 重複時返回 ErrDuplicateClientOrderId
+func insertOrder(o orm.QueryExecutor, order *Order, parent OrderParent) error {
+	id, err := o.Insert(order)
+	if err != nil {
+		// 唯一索引衝突：確認是否為自訂訂單 ID 重複
+		if order.ClientOrderId != nil && o.QueryTable(new(Order)).
+			Filter("User__Id", order.User.Id).
+			Filter("ClientOrderId", *order.ClientOrderId).
+			Exist() {
+			return ErrDuplicateClientOrderId
+		}
 		return err
 	}
+	order.Id = id
+	if err = recordOrderCreated(o, order); err != nil {
+		return err
+	}
+	return LinkIdempotencyKeys(o, order.User.Id, parent.IdempotencyKeys, order.Id)
+}
 
-	order.Status = status
-	order.Price = price
-	order.TotalAmount = totalAmount
-	order.ErrorMsg = errorMsg
-
-	_, err := tx.Update(order, "Status", "Price", "TotalAmount", "ErrorMsg")
-	return err
+// UpdateOrderStatus 將待處理的訂單更新為最終狀態並記錄事件
+// 以目前狀態為條件更新，訂單已被取消或已完成時返回 ErrOrderStatusChanged
+func UpdateOrderStatus(tx orm.QueryExecutor, orderId int64, status OrderStatus, price float64, totalAmount float64, errorMsg string) error {
+	return TransitionOrderStatus(tx, orderId, OrderStatusPending, status, price, totalAmount, errorMsg)
 }
 
 // GetOrderById 根據 ID 查詢訂單
@@ -137,6 +210,20 @@ func GetOrderById(orderId int64) (*Order, error) {
 	return order, nil
 }
 
+// GetOrderByClientOrderId 根據使用者自訂的訂單 ID 查詢訂單
+func GetOrderByClientOrderId(userId int64, clientOrderId string) (*Order, error) {
+	o := orm.NewOrm()
+	order := &Order{}
+	err := o.QueryTable(new(Order)).
+		Filter("User__Id", userId).
+		Filter("ClientOrderId", clientOrderId).
+		One(order)
+	if err == orm.ErrNoRows {
+		return nil, errors.New("order not found")
+	}
+	return order, err
+}
+
 // GetOrdersByUser 查詢使用者的所有訂單
 func GetOrdersByUser(userId int64, limit int, offset int) ([]*Order, error) {
 	o := orm.NewOrm()
@@ -162,40 +249,93 @@ func GetOrdersByUserAndSymbol(userId int64, symbol string, limit int, offset int
 	return orders, err
 }
 
-// GetPendingLimitOrders 查詢所有待執行的限價單
+// GetPendingLimitOrders 查詢所有待執行的限價單與停損單
 func GetPendingLimitOrders() ([]*Order, error) {
 	o := orm.NewOrm()
 	var orders []*Order
 	_, err := o.QueryTable(new(Order)).
-		Filter("Type", OrderTypeLimit).
+		Filter("Type__in", OrderTypeLimit, OrderTypeStop).
 		Filter("Status", OrderStatusPending).
 		RelatedSel().
 		All(&orders)
 	return orders, err
 }
 
-// CancelOrder 取消訂單
-func CancelOrder(orderId int64, userId int64) error {
+// GetPendingOrdersByUser 查詢使用者待處理的訂單，symbol、side、orderType 為空時不篩選
+func GetPendingOrdersByUser(userId int64, symbol string, side OrderSide, orderType OrderType) ([]*Order, error) {
+	o := orm.NewOrm()
+	qs := o.QueryTable(new(Order)).
+		Filter("User__Id", userId).
+		Filter("Status", OrderStatusPending)
+	if symbol != "" {
+		qs = qs.Filter("Symbol", symbol)
+	}
+	if side != "" {
+		qs = qs.Filter("Side", side)
+	}
+	if orderType != "" {
+		qs = qs.Filter("Type", orderType)
+	}
+
+	var orders []*Order
+	_, err := qs.OrderBy("Id").Limit(-1).All(&orders)
+	return orders, err
+}
+
+// GetPendingOrdersByStrategyBot 查詢策略機器人待處理的訂單
+func GetPendingOrdersByStrategyBot(botId int64) ([]*Order, error) {
 	o := orm.NewOrm()
+	var orders []*Order
+	_, err := o.QueryTable(new(Order)).
+		Filter("StrategyBotId", botId).
+		Filter("Status", OrderStatusPending).
+		OrderBy("Id").
+		Limit(-1).
+		All(&orders)
+	return orders, err
+}
+
+// CountPendingLeverageOrdersByUser 統計使用者未成交的槓桿限價單數量
+func CountPendingLeverageOrdersByUser(o orm.QueryExecutor, userId int64) (int64, error) {
+	return o.QueryTable(new(Order)).
+		Filter("User__Id", userId).
+		Filter("IsLeverageOrder", true).
+		Filter("Status", OrderStatusPending).
+		Count()
+}
+
+// CancelOrder 取消訂單（需要在交易中使用），返回被取消的訂單
+func CancelOrder(o orm.QueryExecutor, orderId int64, userId int64) (*Order, error) {
 	order := &Order{Id: orderId}
 
-	if err := o.Read(order); err != nil {
-		return err
+	if err := o.ReadForUpdate(order); err != nil {
+		return nil, err
 	}
 
 	// 檢查訂單所有權
 	if order.User.Id != userId {
-		return errors.New("unauthorized: order does not belong to user")
+		return nil, errors.New("unauthorized: order does not belong to user")
 	}
 
 	// 只能取消待處理的訂單
 	if order.Status != OrderStatusPending {
-		return errors.New("order cannot be canceled")
+		return nil, errors.New("order cannot be canceled")
 	}
 
+	if err := TransitionOrderStatus(o, order.Id, OrderStatusPending, OrderStatusCanceled, 0, 0, "canceled by user"); err != nil {
+		return nil, err
+	}
 	order.Status = OrderStatusCanceled
-	_, err := o.Update(order, "Status")
-	return err
+	return order, nil
+}
+
+// RequiredMargin 槓桿限價單需要凍結的保證金（只減倉訂單不需要保證金）
+// 以錢包精度四捨五入，與掛單時 LockMargin 凍結的金額一致
+func (order *Order) RequiredMargin() float64 {
+	if !order.IsLeverageOrder || order.ReduceOnly || order.Leverage <= 0 {
+		return 0
+	}
+	return RoundWalletAmount(CalculateRequiredMargin(order.LimitPrice, order.Quantity, order.Leverage))
 }
 
 // ParseSymbol 解析交易對，返回 base 和 quote 幣種
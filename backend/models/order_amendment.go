@@ -0,0 +1,119 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// OrderAmendment 訂單修改記錄
+type OrderAmendment struct {
+	Id          int64     `orm:"auto" json:"id"`
+	Order       *Order    `orm:"rel(fk)" json:"-"`
+	Version     int       `json:"version"`                                  // 修改後的訂單版本
+	OldPrice    float64   `orm:"digits(20);decimals(8)" json:"oldPrice"`    // 修改前的限價
+	NewPrice    float64   `orm:"digits(20);decimals(8)" json:"newPrice"`    // 修改後的限價
+	OldQuantity float64   `orm:"digits(20);decimals(8)" json:"oldQuantity"` // 修改前的數量
+	NewQuantity float64   `orm:"digits(20);decimals(8)" json:"newQuantity"` // 修改後的數量
+	CreatedAt   time.Time `orm:"auto_now_add;type(datetime)" json:"createdAt"`
+}
+
+func init() {
+	orm.RegisterModel(new(OrderAmendment))
+}
+
+// TableName 指定資料表名稱
+func (a *OrderAmendment) TableName() string {
+	return "order_amendment"
+}
+
+// Amend 修改待處理限價單的價格與數量（nil 表示不修改），返回修改記錄並遞增版本
+func (order *Order) Amend(limitPrice *float64, quantity *float64) (*OrderAmendment, error) {
+	if order.Status != OrderStatusPending || order.Type != OrderTypeLimit {
+		return nil, errors.New("only pending limit orders can be amended")
+	}
+	if order.OrderListId > 0 {
+		return nil, errors.New("order list legs cannot be amended")
+	}
+	if order.AlgoOrderId > 0 || order.GridBotId > 0 || order.StrategyBotId > 0 {
+		// 母單與機器人依掛單時的價格與數量記帳，修改後會與其記錄不一致
+		return nil, errors.New("orders placed by algo orders or bots cannot be amended")
+	}
+	if limitPrice == nil && quantity == nil {
+		return nil, errors.New("nothing to amend")
+	}
+
+	amendment := &OrderAmendment{
+		Order:       order,
+		OldPrice:    order.LimitPrice,
+		NewPrice:    order.LimitPrice,
+		OldQuantity: order.Quantity,
+		NewQuantity: order.Quantity,
+	}
+	if limitPrice != nil {
+		if *limitPrice <= 0 {
+			return nil, errors.New("limit price must be positive")
+		}
+		amendment.NewPrice = *limitPrice
+	}
+	if quantity != nil {
+		if *quantity <= 0 {
+			return nil, errors.New("quantity must be positive")
+		}
+		amendment.NewQuantity = *quantity
+	}
+	if amendment.NewPrice == amendment.OldPrice && amendment.NewQuantity == amendment.OldQuantity {
+		return nil, errors.New("nothing to amend")
+	}
+
+	order.LimitPrice = amendment.NewPrice
+	order.Quantity = amendment.NewQuantity
+	order.Version++
+	amendment.Version = order.Version
+	return amendment, nil
+}
+
+// SaveOrderAmendment 寫入修改後的訂單與修改記錄（需要在交易中使用）
+func SaveOrderAmendment(o orm.QueryExecutor, order *Order, amendment *OrderAmendment) error {
+	if _, err := o.Update(order, "LimitPrice", "Quantity", "Version", "UpdatedAt"); err != nil {
+		return err
+	}
+	id, err := o.Insert(amendment)
+	if err != nil {
+		return err
+	}
+	amendment.Id = id
+	return nil
+}
+
+// LoadOrderAmendments 一次查詢並填入多筆訂單的修改記錄（由舊到新），只查詢修改過的訂單
+func LoadOrderAmendments(orders []*Order) error {
+	byId := make(map[int64]*Order)
+	ids := make([]int64, 0)
+	for _, order := range orders {
+		if order.Version > 0 {
+			byId[order.Id] = order
+			ids = append(ids, order.Id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	o := orm.NewOrm()
+	var amendments []*OrderAmendment
+	if _, err := o.QueryTable(new(OrderAmendment)).
+		Filter("Order__Id__in", ids).
+		OrderBy("Id").
+		Limit(-1).
+		All(&amendments); err != nil {
+		return err
+	}
+	for _, amendment := range amendments {
+		if order, ok := byId[amendment.Order.Id]; ok {
+			order.Amendments = append(order.Amendments, amendment)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"testing"
+)
+
+func newTestLimitOrder() *Order {
+	return &Order{Id: 1, Type: OrderTypeLimit, Side: OrderSideBuy, Quantity: 2, LimitPrice: 100, Status: OrderStatusPending}
+}
+
+// TestOrderAmend 測試修改價格與數量並遞增版本
+func TestOrderAmend(t *testing.T) {
+	order := newTestLimitOrder()
+	price := 95.0
+	amendment, err := order.Amend(&price, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if order.LimitPrice != 95 || order.Quantity != 2 || order.Version != 1 {
+		t.Errorf("expected price 95 quantity 2 version 1, got price %.2f quantity %.2f version %d", order.LimitPrice, order.Quantity, order.Version)
+	}
+	if amendment.OldPrice != 100 || amendment.NewPrice != 95 || amendment.Version != 1 {
+		t.Errorf("unexpected amendment record: %+v", amendment)
+	}
+
+	quantity := 3.0
+	amendment, err = order.Amend(nil, &quantity)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if order.Quantity != 3 || order.Version != 2 || amendment.OldQuantity != 2 || amendment.NewPrice != 95 {
+		t.Errorf("unexpected state after quantity amendment: order %+v amendment %+v", order, amendment)
+	}
+}
+
+// TestOrderAmendRejected 測試不可修改的訂單與無效的修改內容
+func TestOrderAmendRejected(t *testing.T) {
+	price := 90.0
+	zero := 0.0
+	same := 100.0
+
+	tests := []struct {
+		name     string
+		modify   func(order *Order)
+		price    *float64
+		quantity *float64
+	}{
+		{"completed order", func(order *Order) { order.Status = OrderStatusCompleted }, &price, nil},
+		{"market order", func(order *Order) { order.Type = OrderTypeMarket }, &price, nil},
+		{"order list leg", func(order *Order) { order.OrderListId = 7 }, &price, nil},
+		{"algo order child", func(order *Order) { order.AlgoOrderId = 3 }, &price, nil},
+		{"grid bot order", func(order *Order) { order.GridBotId = 4 }, &price, nil},
+		{"strategy bot order", func(order *Order) { order.StrategyBotId = 5 }, &price, nil},
+		{"nothing to amend", func(order *Order) {}, nil, nil},
+		{"unchanged price", func(order *Order) {}, &same, nil},
+		{"zero quantity", func(order *Order) {}, nil, &zero},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := newTestLimitOrder()
+			tt.modify(order)
+			if _, err := order.Amend(tt.price, tt.quantity); err == nil {
+				t.Errorf("expected error")
+			}
+			if order.Version != 0 || order.LimitPrice != 100 {
+				t.Errorf("expected order unchanged, got version %d price %.2f", order.Version, order.LimitPrice)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// orderTransitions 允許的訂單狀態轉換，只有待處理的訂單可以轉換，其他狀態皆為最終狀態
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusPending: {OrderStatusCompleted, OrderStatusFailed, OrderStatusCanceled},
+}
+
+// ErrOrderStatusChanged 條件更新時訂單狀態已被其他流程改變（例如撮合時訂單已被取消）
+var ErrOrderStatusChanged = errors.New("order status has changed")
+
+// OrderEvent 訂單狀態變更記錄（FromStatus 為空表示建立訂單）
+type OrderEvent struct {
+	Id         int64       `orm:"auto" json:"id"`
+	Order      *Order      `orm:"rel(fk)" json:"-"`
+	FromStatus OrderStatus `orm:"size(20);null" json:"fromStatus,omitempty"`
+	ToStatus   OrderStatus `orm:"size(20)" json:"toStatus"`
+	Price      float64     `orm:"digits(20);decimals(8)" json:"price,omitempty"` // 成交價格（僅成交時記錄）
+	Reason     string      `orm:"size(500);null" json:"reason,omitempty"`        // 失敗或取消的原因
+	CreatedAt  time.Time   `orm:"auto_now_add;type(datetime)" json:"createdAt"`
+}
+
+func init() {
+	orm.RegisterModel(new(OrderEvent))
+}
+
+// TableName 指定資料表名稱
+func (e *OrderEvent) TableName() string {
+	return "order_event"
+}
+
+// CanTransition 訂單狀態是否可以從 from 轉換為 to
+func CanTransition(from OrderStatus, to OrderStatus) bool {
+	for _, status := range orderTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionOrderStatus 以目前狀態為條件更新訂單狀態並記錄事件（需要在交易中使用，以便事件與狀態一併提交）
+// 訂單狀態已不是 from 時不更新，返回 ErrOrderStatusChanged
+func TransitionOrderStatus(o orm.QueryExecutor, orderId int64, from OrderStatus, to OrderStatus, price float64, totalAmount float64, reason string) error {
+	if !CanTransition(from, to) {
+		return errors.New("invalid order status transition: " + string(from) + " -> " + string(to))
+	}
+
+	params := orm.Params{
+		"Status":    to,
+		"UpdatedAt": time.Now(),
+	}
+	if to == OrderStatusCompleted {
+		params["Price"] = price
+		params["TotalAmount"] = totalAmount
+	}
+	if to == OrderStatusFailed {
+		params["ErrorMsg"] = reason
+	}
+
+	num, err := o.QueryTable(new(Order)).
+		Filter("Id", orderId).
+		Filter("Status", from).
+		Update(params)
+	if err != nil {
+		return err
+	}
+	if num == 0 {
+		return ErrOrderStatusChanged
+	}
+
+	return insertOrderEvent(o, orderId, from, to, price, reason)
+}
+
+// recordOrderCreated 記錄訂單建立事件
+func recordOrderCreated(o orm.QueryExecutor, order *Order) error {
+	return insertOrderEvent(o, order.Id, "", order.Status, 0, "")
+}
+
+// insertOrderEvent 寫入訂單事件
+func insertOrderEvent(o orm.QueryExecutor, orderId int64, from OrderStatus, to OrderStatus, price float64, reason string) error {
+	event := &OrderEvent{
+		Order:      &Order{Id: orderId},
+		FromStatus: from,
+		ToStatus:   to,
+		Price:      price,
+		Reason:     reason,
+	}
+	_, err := o.Insert(event)
+	return err
+}
+
+// GetOrderEvents 查詢訂單的狀態變更記錄（由舊到新）
+func GetOrderEvents(orderId int64) ([]*OrderEvent, error) {
+	o := orm.NewOrm()
+	var events []*OrderEvent
+	_, err := o.QueryTable(new(OrderEvent)).
+		Filter("Order__Id", orderId).
+		OrderBy("Id").
+		Limit(-1).
+		All(&events)
+	return events, err
+}
@@ -0,0 +1,28 @@
+package models
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from OrderStatus
+		to   OrderStatus
+		want bool
+	}{
+		{OrderStatusPending, OrderStatusCompleted, true},
+		{OrderStatusPending, OrderStatusFailed, true},
+		{OrderStatusPending, OrderStatusCanceled, true},
+		{OrderStatusPending, OrderStatusPending, false},
+		{OrderStatusCanceled, OrderStatusCompleted, false},
+		{OrderStatusCanceled, OrderStatusPending, false},
+		{OrderStatusCompleted, OrderStatusCanceled, false},
+		{OrderStatusCompleted, OrderStatusFailed, false},
+		{OrderStatusFailed, OrderStatusCompleted, false},
+		{"", OrderStatusPending, false},
+	}
+
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
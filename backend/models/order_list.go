@@ -0,0 +1,229 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// OrderListType 訂單組類型
+type OrderListType string
+
+const (
+	OrderListTypeOCO OrderListType = "OCO" // 一個成交即取消另一個（限價止盈 + 停損）
+)
+
+// OrderListStatus 訂單組狀態
+type OrderListStatus string
+
+const (
+	OrderListStatusActive    OrderListStatus = "ACTIVE"    // 所有子訂單等待成交
+	OrderListStatusCompleted OrderListStatus = "COMPLETED" // 其中一個子訂單已成交，其餘已取消
+	OrderListStatusCanceled  OrderListStatus = "CANCELED"  // 使用者取消
+	OrderListStatusFailed    OrderListStatus = "FAILED"    // 子訂單執行失敗，其餘已取消
+)
+
+// OrderList 訂單組（OCO）
+type OrderList struct {
+	Id        int64           `orm:"auto" json:"id"`
+	User      *User           `orm:"rel(fk)" json:"-"`
+	Symbol    string          `orm:"size(20)" json:"symbol"`                 // 交易對
+	Type      OrderListType   `orm:"size(10)" json:"type"`                   // OCO
+	Side      OrderSide       `orm:"size(10)" json:"side"`                   // 子訂單的方向：BUY or SELL
+	Quantity  float64         `orm:"digits(20);decimals(8)" json:"quantity"` // 子訂單的數量
+	Status    OrderListStatus `orm:"size(20)" json:"status"`
+	Orders    []*Order        `orm:"-" json:"orders"` // 子訂單（查詢時載入）
+	CreatedAt time.Time       `orm:"auto_now_add;type(datetime)" json:"createdAt"`
+	UpdatedAt time.Time       `orm:"auto_now;type(datetime)" json:"updatedAt"`
+}
+
+func init() {
+	orm.RegisterModel(new(OrderList))
+}
+
+// TableName 指定資料表名稱
+func (l *OrderList) TableName() string {
+	return "order_list"
+}
+
+// ValidateOCOPrices 驗證 OCO 價格與目前市價的關係
+// 賣出：止盈限價高於市價、停損價低於市價；買入：限價低於市價、停損價高於市價
+func ValidateOCOPrices(side OrderSide, limitPrice float64, stopPrice float64, currentPrice float64) error {
+	if limitPrice <= 0 || stopPrice <= 0 {
+		return errors.New("limit price and stop price must be positive")
+	}
+	if side == OrderSideSell {
+		if limitPrice <= currentPrice || stopPrice >= currentPrice {
+			return errors.New("sell OCO requires limit price above and stop price below the current price")
+		}
+		return nil
+	}
+	if limitPrice >= currentPrice || stopPrice <= currentPrice {
+		return errors.New("buy OCO requires limit price below and stop price above the current price")
+	}
+	return nil
+}
+
+// StopTriggered 停損單是否已觸發：賣出在價格跌至停損價時觸發，買入在價格漲至停損價時觸發
+func (order *Order) StopTriggered(price float64) bool {
+	if order.Type != OrderTypeStop || order.StopPrice <= 0 {
+		return false
+	}
+	if order.Side == OrderSideSell {
+		return price <= order.StopPrice
+	}
+	return price >= order.StopPrice
+}
+
+// CreateOCOOrderList 建立 OCO 訂單組與兩個子訂單（需要在交易中使用）
+func CreateOCOOrderList(o orm.QueryExecutor, userId int64, symbol string, side OrderSide, quantity float64, limitPrice float64, stopPrice float64) (*OrderList, error) {
+	list := &OrderList{
+		User:     &User{Id: userId},
+		Symbol:   symbol,
+		Type:     OrderListTypeOCO,
+		Side:     side,
+		Quantity: quantity,
+		Status:   OrderListStatusActive,
+	}
+	id, err := o.Insert(list)
+	if err != nil {
+		return nil, err
+	}
+	list.Id = id
+
+	legs := []*Order{
+		{Type: OrderTypeLimit, LimitPrice: limitPrice},
+		{Type: OrderTypeStop, StopPrice: stopPrice},
+	}
+	for _, order := range legs {
+		order.User = &User{Id: userId}
+		order.Symbol = symbol
+		order.Side = side
+		order.Quantity = quantity
+		order.Status = OrderStatusPending
+		order.OrderListId = list.Id
+
+		orderId, err := o.Insert(order)
+		if err != nil {
+			return nil, err
+		}
+		order.Id = orderId
+		if err = recordOrderCreated(o, order); err != nil {
+			return nil, err
+		}
+	}
+	list.Orders = legs
+	return list, nil
+}
+
+// GetOrderListForUpdate 在交易中以 FOR UPDATE 鎖定並讀取訂單組
+// 子訂單成交、失敗或取消時都先鎖定訂單組，再鎖定子訂單，避免兩個子訂單同時成交
+func GetOrderListForUpdate(o orm.QueryExecutor, id int64) (*OrderList, error) {
+	list := &OrderList{Id: id}
+	err := o.ReadForUpdate(list)
+	if err == orm.ErrNoRows {
+		return nil, errors.New("order list not found")
+	}
+	return list, err
+}
+
+// FinishOrderList 結束訂單組並取消其餘待處理的子訂單（需要在交易中使用），返回被取消的子訂單 ID
+// exceptOrderId 為已成交或失敗的子訂單（使用者取消時為 0）
+func FinishOrderList(o orm.QueryExecutor, list *OrderList, status OrderListStatus, exceptOrderId int64) ([]int64, error) {
+	if list.Status != OrderListStatusActive {
+		return nil, errors.New("order list is not active")
+	}
+
+	var pending []*Order
+	_, err := o.QueryTable(new(Order)).
+		Filter("OrderListId", list.Id).
+		Filter("Status", OrderStatusPending).
+		Exclude("Id", exceptOrderId).
+		All(&pending, "Id")
+	if err != nil {
+		return nil, err
+	}
+
+	canceled := make([]int64, 0, len(pending))
+	for _, order := range pending {
+		canceled = append(canceled, order.Id)
+	}
+
+	reason := "order list " + string(status)
+	for _, orderId := range canceled {
+		if err = TransitionOrderStatus(o, orderId, OrderStatusPending, OrderStatusCanceled, 0, 0, reason); err != nil {
+			return nil, err
+		}
+	}
+
+	list.Status = status
+	if _, err = o.Update(list, "Status", "UpdatedAt"); err != nil {
+		return nil, err
+	}
+	return canceled, nil
+}
+
+// GetOrderListById 根據 ID 查詢訂單組（含子訂單）
+func GetOrderListById(id int64) (*OrderList, error) {
+	o := orm.NewOrm()
+	list := &OrderList{Id: id}
+	if err := o.Read(list); err != nil {
+		if err == orm.ErrNoRows {
+			return nil, errors.New("order list not found")
+		}
+		return nil, err
+	}
+	if err := loadOrderListOrders(o, []*OrderList{list}); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetOrderListsByUser 查詢使用者的訂單組（含子訂單，由新到舊）
+func GetOrderListsByUser(userId int64, limit int, offset int) ([]*OrderList, error) {
+	o := orm.NewOrm()
+	var lists []*OrderList
+	_, err := o.QueryTable(new(OrderList)).
+		Filter("User__Id", userId).
+		OrderBy("-CreatedAt", "-Id").
+		Limit(limit, offset).
+		All(&lists)
+	if err != nil {
+		return nil, err
+	}
+	if err = loadOrderListOrders(o, lists); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+// loadOrderListOrders 一次查詢並填入多個訂單組的子訂單
+func loadOrderListOrders(o orm.Ormer, lists []*OrderList) error {
+	if len(lists) == 0 {
+		return nil
+	}
+
+	byId := make(map[int64]*OrderList, len(lists))
+	ids := make([]int64, 0, len(lists))
+	for _, list := range lists {
+		list.Orders = []*Order{}
+		byId[list.Id] = list
+		ids = append(ids, list.Id)
+	}
+
+	var orders []*Order
+	if _, err := o.QueryTable(new(Order)).
+		Filter("OrderListId__in", ids).
+		OrderBy("Id").
+		Limit(-1).
+		All(&orders); err != nil {
+		return err
+	}
+	for _, order := range orders {
+		if list, ok := byId[order.OrderListId]; ok {
+			list.Orders = append(list.Orders, order)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"testing"
+)
+
+// TestValidateOCOPrices 測試 OCO 止盈價與停損價必須位於市價兩側
+func TestValidateOCOPrices(t *testing.T) {
+	tests := []struct {
+		name    string
+		side    OrderSide
+		limit   float64
+		stop    float64
+		wantErr bool
+	}{
+		{"sell bracket", OrderSideSell, 110, 90, false},
+		{"sell limit below market", OrderSideSell, 95, 90, true},
+		{"sell stop above market", OrderSideSell, 110, 105, true},
+		{"buy bracket", OrderSideBuy, 90, 110, false},
+		{"buy limit above market", OrderSideBuy, 105, 110, true},
+		{"buy stop below market", OrderSideBuy, 90, 95, true},
+		{"missing stop price", OrderSideSell, 110, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateOCOPrices(tt.side, tt.limit, tt.stop, 100)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %t, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestStopTriggered 測試停損單的觸發方向
+func TestStopTriggered(t *testing.T) {
+	sell := &Order{Type: OrderTypeStop, Side: OrderSideSell, StopPrice: 90}
+	if sell.StopTriggered(91) {
+		t.Errorf("expected sell stop not triggered above stop price")
+	}
+	if !sell.StopTriggered(90) {
+		t.Errorf("expected sell stop triggered at stop price")
+	}
+
+	buy := &Order{Type: OrderTypeStop, Side: OrderSideBuy, StopPrice: 110}
+	if buy.StopTriggered(109) {
+		t.Errorf("expected buy stop not triggered below stop price")
+	}
+	if !buy.StopTriggered(115) {
+		t.Errorf("expected buy stop triggered above stop price")
+	}
+
+	limit := &Order{Type: OrderTypeLimit, Side: OrderSideSell, LimitPrice: 90, StopPrice: 90}
+	if limit.StopTriggered(80) {
+		t.Errorf("expected limit order never to be stop triggered")
+	}
+}
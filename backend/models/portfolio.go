@@ -0,0 +1,224 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// PortfolioGranularity 資產快照的週期
+type PortfolioGranularity string
+
+const (
+	PortfolioGranularityHourly PortfolioGranularity = "HOURLY" // 每小時整點
+	PortfolioGranularityDaily  PortfolioGranularity = "DAILY"  // 每日 00:00（UTC）
+)
+
+// Period 時間所在週期的開始時間（UTC）
+func (g PortfolioGranularity) Period(t time.Time) time.Time {
+	t = t.UTC()
+	if g == PortfolioGranularityDaily {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return t.Truncate(time.Hour)
+}
+
+// PortfolioHistoryRange 資產曲線的查詢範圍
+type PortfolioHistoryRange struct {
+	Granularity PortfolioGranularity // 使用的快照週期
+	Duration    time.Duration        // 往前查詢的時間，0 表示全部
+}
+
+// portfolioHistoryRanges 可查詢的範圍：一週以內使用每小時快照，更長的範圍使用每日快照
+var portfolioHistoryRanges = map[string]PortfolioHistoryRange{
+	"1d":  {PortfolioGranularityHourly, 24 * time.Hour},
+	"7d":  {PortfolioGranularityHourly, 7 * 24 * time.Hour},
+	"30d": {PortfolioGranularityDaily, 30 * 24 * time.Hour},
+	"90d": {PortfolioGranularityDaily, 90 * 24 * time.Hour},
+	"1y":  {PortfolioGranularityDaily, 365 * 24 * time.Hour},
+	"all": {PortfolioGranularityDaily, 0},
+}
+
+// DefaultPortfolioHistoryRange 未指定範圍時使用
+const DefaultPortfolioHistoryRange = "7d"
+
+// HourlyPortfolioSnapshotRetention 每小時快照的保存時間（更早的只保留每日快照）
+const HourlyPortfolioSnapshotRetention = 30 * 24 * time.Hour
+
+// ParsePortfolioHistoryRange 解析查詢範圍：1d, 7d, 30d, 90d, 1y 或 all
+func ParsePortfolioHistoryRange(value string) (PortfolioHistoryRange, error) {
+	if value == "" {
+		value = DefaultPortfolioHistoryRange
+	}
+	r, ok := portfolioHistoryRanges[value]
+	if !ok {
+		return PortfolioHistoryRange{}, fmt.Errorf("invalid range %q, must be one of 1d, 7d, 30d, 90d, 1y, all", value)
+	}
+	return r, nil
+}
+
+// PortfolioAsset 單一幣種錢包的估值
+type PortfolioAsset struct {
+	Symbol  string  `json:"symbol"`  // 幣種：USDT, BTC, ETH, SOL
+	Balance float64 `json:"balance"` // 餘額（含掛單鎖定的金額）
+	Locked  float64 `json:"locked"`  // 鎖定金額
+	Price   float64 `json:"price"`   // USDT 價格（無價格時為 0）
+	Value   float64 `json:"value"`   // USDT 價值
+}
+
+// PortfolioPosition 持倉的估值：保證金加上以標記價格計算的未實現盈虧
+type PortfolioPosition struct {
+	PositionId    int64        `json:"positionId"`
+	Symbol        string       `json:"symbol"`
+	Side          PositionSide `json:"side"`
+	MarginMode    MarginMode   `json:"marginMode"`
+	Quantity      float64      `json:"quantity"`
+	Margin        float64      `json:"margin"`
+	MarkPrice     float64      `json:"markPrice"`     // 標記價格（無價格時為 0）
+	UnrealizedPnL float64      `json:"unrealizedPnl"` // 未實現盈虧（無價格時為 0）
+	Value         float64      `json:"value"`         // 保證金 + 未實現盈虧
+}
+
+// PortfolioSummary 使用者資產總值（USDT）
+type PortfolioSummary struct {
+	TotalValue     float64             `json:"totalValue"`              // 錢包價值 + 持倉保證金 + 未實現盈虧
+	WalletValue    float64             `json:"walletValue"`             // 所有錢包的價值
+	PositionMargin float64             `json:"positionMargin"`          // 持倉保證金（已從錢包轉出）
+	UnrealizedPnL  float64             `json:"unrealizedPnl"`           // 持倉未實現盈虧
+	Assets         []PortfolioAsset    `json:"assets"`                  // 各幣種錢包
+	Positions      []PortfolioPosition `json:"positions"`               // 持倉
+	MissingPrices  []string            `json:"missingPrices,omitempty"` // 沒有價格而以 0 計價的交易對
+	ValuedAt       time.Time           `json:"valuedAt"`
+}
+
+// Complete 所有資產都有價格
+func (s *PortfolioSummary) Complete() bool {
+	return len(s.MissingPrices) == 0
+}
+
+// ValuePortfolio 以 USDT 計算錢包與持倉的價值
+// prices 為交易對的最新成交價（錢包），markPrices 為標記價格（持倉未實現盈虧），沒有價格的資產以 0 計價並列在 MissingPrices
+func ValuePortfolio(wallets []*Wallet, positions []*LeveragePosition, prices map[string]float64, markPrices map[string]float64, now time.Time) *PortfolioSummary {
+	summary := &PortfolioSummary{
+		Assets:    make([]PortfolioAsset, 0, len(wallets)),
+		Positions: make([]PortfolioPosition, 0, len(positions)),
+		ValuedAt:  now,
+	}
+	missing := make(map[string]bool)
+
+	// 1. 錢包：USDT 以 1 計價，其他幣種使用對 USDT 交易對的價格
+	for _, wallet := range wallets {
+		asset := PortfolioAsset{Symbol: wallet.Symbol, Balance: wallet.Balance, Locked: wallet.Locked}
+		if wallet.Symbol == "USDT" {
+			asset.Price = 1
+		} else if price, ok := prices[wallet.Symbol+"USDT"]; ok {
+			asset.Price = price
+		} else if wallet.Balance != 0 {
+			missing[wallet.Symbol+"USDT"] = true
+		}
+		asset.Value = asset.Balance * asset.Price
+		summary.WalletValue += asset.Value
+		summary.Assets = append(summary.Assets, asset)
+	}
+
+	// 2. 持倉：保證金已從錢包轉出，加回保證金與未實現盈虧
+	for _, position := range positions {
+		item := PortfolioPosition{
+			PositionId: position.Id,
+			Symbol:     position.Symbol,
+			Side:       position.Side,
+			MarginMode: position.MarginMode,
+			Quantity:   position.Quantity,
+			Margin:     position.Margin,
+		}
+		if markPrice, ok := markPrices[position.Symbol]; ok {
+			item.MarkPrice = markPrice
+			item.UnrealizedPnL = position.CalculateUnrealizedPnL(markPrice)
+		} else {
+			missing[position.Symbol] = true
+		}
+		item.Value = item.Margin + item.UnrealizedPnL
+		summary.PositionMargin += item.Margin
+		summary.UnrealizedPnL += item.UnrealizedPnL
+		summary.Positions = append(summary.Positions, item)
+	}
+
+	summary.TotalValue = summary.WalletValue + summary.PositionMargin + summary.UnrealizedPnL
+	for symbol := range missing {
+		summary.MissingPrices = append(summary.MissingPrices, symbol)
+	}
+	sort.Strings(summary.MissingPrices)
+	return summary
+}
+
+// PortfolioSnapshot 資產總值的定期快照（資產曲線），同一使用者、週期的時間唯一
+type PortfolioSnapshot struct {
+	Id             int64                `orm:"auto" json:"-"`
+	User           *User                `orm:"rel(fk)" json:"-"`
+	Granularity    PortfolioGranularity `orm:"size(10)" json:"granularity"`               // HOURLY or DAILY
+	SnapshotAt     time.Time            `orm:"type(datetime);index" json:"snapshotAt"`    // 週期開始時間（UTC）
+	TotalValue     float64              `orm:"digits(24);decimals(8)" json:"totalValue"`  // 資產總值（USDT）
+	WalletValue    float64              `orm:"digits(24);decimals(8)" json:"walletValue"` // 錢包價值
+	PositionMargin float64              `orm:"digits(24);decimals(8)" json:"positionMargin"`
+	UnrealizedPnL  float64              `orm:"digits(24);decimals(8)" json:"unrealizedPnl"`
+	CreatedAt      time.Time            `orm:"auto_now_add;type(datetime)" json:"createdAt"` // 實際估值時間
+}
+
+func init() {
+	orm.RegisterModel(new(PortfolioSnapshot))
+}
+
+// TableName 指定資料表名稱
+func (s *PortfolioSnapshot) TableName() string {
+	return "portfolio_snapshot"
+}
+
+// TableUnique 同一使用者、週期的快照時間不可重複
+func (s *PortfolioSnapshot) TableUnique() [][]string {
+	return [][]string{{"User", "Granularity", "SnapshotAt"}}
+}
+
+// NewPortfolioSnapshot 以資產總值建立週期的快照
+func NewPortfolioSnapshot(userId int64, granularity PortfolioGranularity, summary *PortfolioSummary) *PortfolioSnapshot {
+	return &PortfolioSnapshot{
+		User:           &User{Id: userId},
+		Granularity:    granularity,
+		SnapshotAt:     granularity.Period(summary.ValuedAt),
+		TotalValue:     summary.TotalValue,
+		WalletValue:    summary.WalletValue,
+		PositionMargin: summary.PositionMargin,
+		UnrealizedPnL:  summary.UnrealizedPnL,
+	}
+}
+
+// SavePortfolioSnapshot 寫入快照，該週期已有快照時略過（返回 false）
+func SavePortfolioSnapshot(s *PortfolioSnapshot) (bool, error) {
+	o := orm.NewOrm()
+	created, _, err := o.ReadOrCreate(s, "User", "Granularity", "SnapshotAt")
+	return created, err
+}
+
+// GetPortfolioSnapshots 查詢使用者 since 之後的快照（依時間排序），since 為零值表示全部
+func GetPortfolioSnapshots(userId int64, granularity PortfolioGranularity, since time.Time) ([]*PortfolioSnapshot, error) {
+	o := orm.NewOrm()
+	qs := o.QueryTable(new(PortfolioSnapshot)).
+		Filter("User__Id", userId).
+		Filter("Granularity", granularity)
+	if !since.IsZero() {
+		qs = qs.Filter("SnapshotAt__gte", since)
+	}
+	var snapshots []*PortfolioSnapshot
+	_, err := qs.OrderBy("SnapshotAt").Limit(-1).All(&snapshots)
+	return snapshots, err
+}
+
+// DeletePortfolioSnapshotsBefore 刪除 before 之前的快照，返回刪除筆數
+func DeletePortfolioSnapshotsBefore(granularity PortfolioGranularity, before time.Time) (int64, error) {
+	o := orm.NewOrm()
+	return o.QueryTable(new(PortfolioSnapshot)).
+		Filter("Granularity", granularity).
+		Filter("SnapshotAt__lt", before).
+		Delete()
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValuePortfolio(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 30, 0, 0, time.UTC)
+	wallets := []*Wallet{
+		{Symbol: "USDT", Balance: 1000, Locked: 200},
+		{Symbol: "BTC", Balance: 0.5},
+		{Symbol: "ETH", Balance: 0},
+	}
+	positions := []*LeveragePosition{
+		{Id: 1, Symbol: "BTCUSDT", Side: PositionSideLong, EntryPrice: 40000, Quantity: 0.1, Margin: 400},
+		{Id: 2, Symbol: "SOLUSDT", Side: PositionSideShort, EntryPrice: 100, Quantity: 10, Margin: 100},
+	}
+	prices := map[string]float64{"BTCUSDT": 42000}
+	markPrices := map[string]float64{"BTCUSDT": 41000, "SOLUSDT": 90}
+
+	summary := ValuePortfolio(wallets, positions, prices, markPrices, now)
+
+	// 錢包：1000 + 0.5 × 42000（ETH 餘額為 0，不需要價格）
+	if summary.WalletValue != 22000 {
+		t.Errorf("WalletValue = %.2f, want 22000", summary.WalletValue)
+	}
+	// 持倉：多單 (41000 - 40000) × 0.1 = 100，空單 (100 - 90) × 10 = 100
+	if summary.PositionMargin != 500 || math.Abs(summary.UnrealizedPnL-200) > 1e-9 {
+		t.Errorf("PositionMargin = %.2f, UnrealizedPnL = %.2f, want 500 and 200", summary.PositionMargin, summary.UnrealizedPnL)
+	}
+	if math.Abs(summary.TotalValue-22700) > 1e-9 {
+		t.Errorf("TotalValue = %.2f, want 22700", summary.TotalValue)
+	}
+	if !summary.Complete() {
+		t.Errorf("MissingPrices = %v, want none", summary.MissingPrices)
+	}
+	if summary.Assets[0].Locked != 200 || summary.Positions[1].Value != 200 {
+		t.Errorf("assets %+v, positions %+v", summary.Assets, summary.Positions)
+	}
+}
+
+func TestValuePortfolioMissingPrices(t *testing.T) {
+	wallets := []*Wallet{{Symbol: "USDT", Balance: 100}, {Symbol: "SOL", Balance: 2}}
+	positions := []*LeveragePosition{{Symbol: "ETHUSDT", Side: PositionSideLong, EntryPrice: 2000, Quantity: 1, Margin: 200}}
+
+	summary := ValuePortfolio(wallets, positions, nil, nil, time.Now())
+
+	if want := []string{"ETHUSDT", "SOLUSDT"}; !reflect.DeepEqual(summary.MissingPrices, want) {
+		t.Errorf("MissingPrices = %v, want %v", summary.MissingPrices, want)
+	}
+	// 沒有價格的錢包以 0 計價，持倉只計入保證金
+	if summary.TotalValue != 300 {
+		t.Errorf("TotalValue = %.2f, want 300", summary.TotalValue)
+	}
+}
+
+func TestPortfolioGranularityPeriod(t *testing.T) {
+	at := time.Date(2024, 3, 5, 17, 42, 10, 0, time.FixedZone("UTC+8", 8*3600))
+
+	if got, want := PortfolioGranularityHourly.Period(at), time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("hourly period = %s, want %s", got, want)
+	}
+	if got, want := PortfolioGranularityDaily.Period(at), time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("daily period = %s, want %s", got, want)
+	}
+}
+
+func TestParsePortfolioHistoryRange(t *testing.T) {
+	tests := []struct {
+		value           string
+		wantGranularity PortfolioGranularity
+		wantErr         bool
+	}{
+		{"", PortfolioGranularityHourly, false},
+		{"1d", PortfolioGranularityHourly, false},
+		{"30d", PortfolioGranularityDaily, false},
+		{"all", PortfolioGranularityDaily, false},
+		{"2w", "", true},
+	}
+
+	for _, tt := range tests {
+		r, err := ParsePortfolioHistoryRange(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePortfolioHistoryRange(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if r.Granularity != tt.wantGranularity {
+			t.Errorf("ParsePortfolioHistoryRange(%q) granularity = %s, want %s", tt.value, r.Granularity, tt.wantGranularity)
+		}
+	}
+}
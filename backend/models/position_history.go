@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// PositionAction 倉位變更類型
+type PositionAction string
+
+const (
+	PositionActionOpen         PositionAction = "OPEN"          // 開倉
+	PositionActionIncrease     PositionAction = "INCREASE"      // 加倉
+	PositionActionPartialClose PositionAction = "PARTIAL_CLOSE" // 部分平倉
+	PositionActionClose        PositionAction = "CLOSE"         // 平倉
+	PositionActionLiquidate    PositionAction = "LIQUIDATE"     // 爆倉
+	PositionActionAdjustMargin PositionAction = "ADJUST_MARGIN" // 調整保證金
+	PositionActionFunding      PositionAction = "FUNDING"       // 資金費用
+)
+
+// PositionHistory 倉位變更記錄
+type PositionHistory struct {
+	Id                    int64             `orm:"auto" json:"id"`
+	Position              *LeveragePosition `orm:"rel(fk)" json:"-"`
+	User                  *User             `orm:"rel(fk)" json:"-"`
+	Action                PositionAction    `orm:"size(20)" json:"action"`
+	Price                 float64           `orm:"digits(20);decimals(8)" json:"price"`                 // 變更時的價格
+	QuantityChange        float64           `orm:"digits(20);decimals(8)" json:"quantityChange"`        // 數量變化（正數增加，負數減少）
+	MarginChange          float64           `orm:"digits(20);decimals(8)" json:"marginChange"`          // 保證金變化（正數增加，負數減少）
+	RealizedPnL           float64           `orm:"digits(20);decimals(8)" json:"realizedPnl"`           // 此次變更實現的盈虧
+	QuantityAfter         float64           `orm:"digits(20);decimals(8)" json:"quantityAfter"`         // 變更後數量
+	EntryPriceAfter       float64           `orm:"digits(20);decimals(8)" json:"entryPriceAfter"`       // 變更後開倉均價
+	MarginAfter           float64           `orm:"digits(20);decimals(8)" json:"marginAfter"`           // 變更後保證金
+	LiquidationPriceAfter float64           `orm:"digits(20);decimals(8)" json:"liquidationPriceAfter"` // 變更後爆倉價格
+	CreatedAt             time.Time         `orm:"auto_now_add;type(datetime)" json:"createdAt"`
+}
+
+func init() {
+	orm.RegisterModel(new(PositionHistory))
+}
+
+// TableName 指定資料表名稱
+func (h *PositionHistory) TableName() string {
+	return "position_history"
+}
+
+// CreatePositionHistory 記錄倉位變更（需要在交易中使用，position 需為變更後的狀態）
+func CreatePositionHistory(o orm.QueryExecutor, position *LeveragePosition, action PositionAction, price float64, quantityChange float64, marginChange float64, realizedPnL float64) (*PositionHistory, error) {
+	history := &PositionHistory{
+		Position:              &LeveragePosition{Id: position.Id},
+		User:                  &User{Id: position.User.Id},
+		Action:                action,
+		Price:                 price,
+		QuantityChange:        quantityChange,
+		MarginChange:          marginChange,
+		RealizedPnL:           realizedPnL,
+		QuantityAfter:         position.Quantity,
+		EntryPriceAfter:       position.EntryPrice,
+		MarginAfter:           position.Margin,
+		LiquidationPriceAfter: position.LiquidationPrice,
+	}
+
+	// 已平倉或爆倉的倉位不再持有數量與保證金
+	if position.Status != PositionStatusOpen {
+		history.QuantityAfter = 0
+		history.MarginAfter = 0
+	}
+
+	id, err := o.Insert(history)
+	if err != nil {
+		return nil, err
+	}
+	history.Id = id
+	return history, nil
+}
+
+// GetPositionHistoryByPosition 查詢倉位的所有變更記錄
+func GetPositionHistoryByPosition(positionId int64) ([]*PositionHistory, error) {
+	o := orm.NewOrm()
+	var histories []*PositionHistory
+	_, err := o.QueryTable(new(PositionHistory)).
+		Filter("Position__Id", positionId).
+		OrderBy("CreatedAt", "Id").
+		All(&histories)
+	return histories, err
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"errors"
+	"math"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// PositionMode 持倉模式
+type PositionMode string
+
+const (
+	PositionModeOneWay PositionMode = "ONE_WAY" // 單向持倉：每個交易對只有一個方向的持倉，反向開倉時先減倉或反手
+	PositionModeHedge  PositionMode = "HEDGE"   // 雙向持倉：每個交易對可同時持有一個多頭與一個空頭倉位
+)
+
+// IsValid 檢查持倉模式是否合法
+func (m PositionMode) IsValid() bool {
+	return m == PositionModeOneWay || m == PositionModeHedge
+}
+
+// Opposite 返回相反的倉位方向
+func (s PositionSide) Opposite() PositionSide {
+	if s == PositionSideLong {
+		return PositionSideShort
+	}
+	return PositionSideLong
+}
+
+// OrderSide 開倉方向對應的訂單方向：LONG 為買入，SHORT 為賣出
+func (s PositionSide) OrderSide() OrderSide {
+	if s == PositionSideLong {
+		return OrderSideBuy
+	}
+	return OrderSideSell
+}
+
+// GetUserPositionMode 查詢使用者目前的持倉模式（未設定時為雙向持倉）
+func GetUserPositionMode(o orm.QueryExecutor, userId int64) (PositionMode, error) {
+	user := &User{Id: userId}
+	if err := o.Read(user, "PositionMode"); err != nil {
+		if err == orm.ErrNoRows {
+			return "", errors.New("user not found")
+		}
+		return "", err
+	}
+	if user.PositionMode == "" {
+		return PositionModeHedge, nil
+	}
+	return user.PositionMode, nil
+}
+
+// UpdateUserPositionMode 更新使用者的持倉模式（需要在交易中使用）
+func UpdateUserPositionMode(o orm.QueryExecutor, userId int64, mode PositionMode) error {
+	user := &User{Id: userId}
+	if err := o.ReadForUpdate(user); err != nil {
+		if err == orm.ErrNoRows {
+			return errors.New("user not found")
+		}
+		return err
+	}
+	user.PositionMode = mode
+	_, err := o.Update(user, "PositionMode")
+	return err
+}
+
+// SplitOrderQuantity 將訂單數量拆分為減少反方向持倉的數量與開新倉（或加倉）的數量
+// oppositeQuantity 為需要先減少的反方向持倉數量（雙向持倉的一般訂單為 0）
+// 只減倉（reduceOnly）訂單不會開新倉，超過持倉的部分直接捨棄
+func SplitOrderQuantity(quantity float64, oppositeQuantity float64, reduceOnly bool) (reduceQuantity float64, openQuantity float64) {
+	reduceQuantity = math.Min(quantity, oppositeQuantity)
+	if reduceQuantity < 0 {
+		reduceQuantity = 0
+	}
+	if reduceOnly {
+		return reduceQuantity, 0
+	}
+	return reduceQuantity, quantity - reduceQuantity
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"testing"
+)
+
+// TestPositionSideOpposite 測試相反的倉位方向
+func TestPositionSideOpposite(t *testing.T) {
+	if PositionSideLong.Opposite() != PositionSideShort {
+		t.Errorf("expected SHORT, got %s", PositionSideLong.Opposite())
+	}
+	if PositionSideShort.Opposite() != PositionSideLong {
+		t.Errorf("expected LONG, got %s", PositionSideShort.Opposite())
+	}
+}
+
+// TestSplitOrderQuantity 測試單向持倉與只減倉訂單的數量拆分
+func TestSplitOrderQuantity(t *testing.T) {
+	tests := []struct {
+		name       string
+		quantity   float64
+		opposite   float64
+		reduceOnly bool
+		wantReduce float64
+		wantOpen   float64
+	}{
+		{"no opposite position", 5, 0, false, 0, 5},
+		{"partial reduce", 3, 10, false, 3, 0},
+		{"exact close", 10, 10, false, 10, 0},
+		{"flip", 15, 10, false, 10, 5},
+		{"reduce only partial", 3, 10, true, 3, 0},
+		{"reduce only capped", 15, 10, true, 10, 0},
+		{"reduce only without position", 5, 0, true, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reduce, open := SplitOrderQuantity(tt.quantity, tt.opposite, tt.reduceOnly)
+			if !almostEqual(reduce, tt.wantReduce) || !almostEqual(open, tt.wantOpen) {
+				t.Errorf("expected reduce %.2f open %.2f, got reduce %.2f open %.2f",
+					tt.wantReduce, tt.wantOpen, reduce, open)
+			}
+		})
+	}
+}
+
+// TestReduceOnlyOrderRequiresNoMargin 測試只減倉訂單不需要凍結保證金
+func TestReduceOnlyOrderRequiresNoMargin(t *testing.T) {
+	order := &Order{IsLeverageOrder: true, Leverage: 10, LimitPrice: 100, Quantity: 5}
+	if !almostEqual(order.RequiredMargin(), 50) {
+		t.Errorf("expected margin 50, got %.2f", order.RequiredMargin())
+	}
+
+	order.ReduceOnly = true
+	if order.RequiredMargin() != 0 {
+		t.Errorf("expected no margin for reduce-only order, got %.2f", order.RequiredMargin())
+	}
+}
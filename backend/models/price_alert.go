@@ -0,0 +1,266 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// PriceAlertCondition 價格提醒的觸發條件
+type PriceAlertCondition string
+
+const (
+	PriceAlertConditionAbove         PriceAlertCondition = "ABOVE"          // 價格高於或等於目標價
+	PriceAlertConditionBelow         PriceAlertCondition = "BELOW"          // 價格低於或等於目標價
+	PriceAlertConditionCross         PriceAlertCondition = "CROSS"          // 價格向上或向下穿越目標價
+	PriceAlertConditionPercentChange PriceAlertCondition = "PERCENT_CHANGE" // 時間窗口內漲跌幅達到 ChangePercent（正數為上漲，負數為下跌）
+)
+
+// PriceAlertStatus 價格提醒狀態
+type PriceAlertStatus string
+
+const (
+	PriceAlertStatusActive    PriceAlertStatus = "ACTIVE"    // 監控中
+	PriceAlertStatusTriggered PriceAlertStatus = "TRIGGERED" // 單次提醒已觸發（修改後重新監控）
+)
+
+const (
+	// MinPriceAlertWindowSeconds 漲跌幅提醒的最短時間窗口
+	MinPriceAlertWindowSeconds = 60
+	// MaxPriceAlertWindowSeconds 漲跌幅提醒的最長時間窗口（24 小時）
+	MaxPriceAlertWindowSeconds = 24 * 60 * 60
+	// MaxActivePriceAlertsPerUser 每位使用者監控中的提醒上限
+	MaxActivePriceAlertsPerUser = 50
+)
+
+// PriceAlert 價格提醒：每筆成交價格更新時檢查，觸發時推送 PRICE_ALERT 消息並寫入 PRICE_ALERT webhook 事件（如有設定）
+// 條件成立時觸發一次，之後條件不再成立才重新就緒；單次提醒觸發後即結束，重複提醒可設定最短間隔
+type PriceAlert struct {
+	Id               int64               `orm:"auto" json:"id"`
+	User             *User               `orm:"rel(fk)" json:"-"`
+	Symbol           string              `orm:"size(20);index" json:"symbol"`                          // 交易對：BTCUSDT, ETHUSDT, SOLUSDT
+	Condition        PriceAlertCondition `orm:"size(20)" json:"condition"`                             // ABOVE, BELOW, CROSS or PERCENT_CHANGE
+	TargetPrice      float64             `orm:"digits(20);decimals(8)" json:"targetPrice,omitempty"`   // 目標價（ABOVE、BELOW、CROSS）
+	ChangePercent    float64             `orm:"digits(10);decimals(4)" json:"changePercent,omitempty"` // 漲跌幅百分比（PERCENT_CHANGE）
+	WindowSeconds    int                 `orm:"default(0)" json:"windowSeconds,omitempty"`             // 漲跌幅的時間窗口秒數（PERCENT_CHANGE）
+	Repeat           bool                `orm:"default(false)" json:"repeat"`                          // 是否重複提醒
+	CooldownSeconds  int                 `orm:"default(0)" json:"cooldownSeconds,omitempty"`           // 重複提醒的最短間隔秒數
+	Webhook          bool                `orm:"default(false)" json:"webhook"`                         // 觸發時是否寫入 webhook 事件（投遞到訂閱 PRICE_ALERT 的端點）
+	Note             string              `orm:"size(200);null" json:"note,omitempty"`                  // 備註（隨通知送出）
+	Status           PriceAlertStatus    `orm:"size(20);index" json:"status"`                          // ACTIVE or TRIGGERED
+	Armed            bool                `orm:"default(true)" json:"armed"`                            // 是否就緒（觸發後需等條件不成立才重新就緒）
+	TriggerCount     int                 `orm:"default(0)" json:"triggerCount"`                        // 已觸發次數
+	LastTriggeredAt  *time.Time          `orm:"null;type(datetime)" json:"lastTriggeredAt,omitempty"`
+	LastTriggerPrice float64             `orm:"digits(20);decimals(8)" json:"lastTriggerPrice,omitempty"`
+	CreatedAt        time.Time           `orm:"auto_now_add;type(datetime)" json:"createdAt"`
+	UpdatedAt        time.Time           `orm:"auto_now;type(datetime)" json:"updatedAt"`
+}
+
+func init() {
+	orm.RegisterModel(new(PriceAlert))
+}
+
+// TableName 指定資料表名稱
+func (a *PriceAlert) TableName() string {
+	return "price_alert"
+}
+
+// PriceAlertParams 建立或修改價格提醒的參數
+type PriceAlertParams struct {
+	Symbol          string
+	Condition       PriceAlertCondition
+	TargetPrice     float64
+	ChangePercent   float64
+	WindowSeconds   int
+	Repeat          bool
+	CooldownSeconds int
+	Webhook         bool
+	Note            string
+}
+
+// Validate 驗證價格提醒參數
+func (p PriceAlertParams) Validate() error {
+	if _, _, err := ParseSymbol(p.Symbol); err != nil {
+		return err
+	}
+
+	switch p.Condition {
+	case PriceAlertConditionAbove, PriceAlertConditionBelow, PriceAlertConditionCross:
+		if p.TargetPrice <= 0 {
+			return errors.New("target price must be positive")
+		}
+	case PriceAlertConditionPercentChange:
+		if p.ChangePercent == 0 || p.ChangePercent <= -100 {
+			return errors.New("change percent must be non-zero and greater than -100")
+		}
+		if p.WindowSeconds < MinPriceAlertWindowSeconds || p.WindowSeconds > MaxPriceAlertWindowSeconds {
+			return errors.New("windowSeconds must be between 60 and 86400")
+		}
+	default:
+		return errors.New("condition must be ABOVE, BELOW, CROSS or PERCENT_CHANGE")
+	}
+
+	if p.CooldownSeconds < 0 {
+		return errors.New("cooldownSeconds must not be negative")
+	}
+	return nil
+}
+
+// Apply 將參數寫入提醒（參數需先驗證），並重新開始監控
+func (a *PriceAlert) Apply(params PriceAlertParams) {
+	a.Symbol = params.Symbol
+	a.Condition = params.Condition
+	a.TargetPrice = 0
+	a.ChangePercent = 0
+	a.WindowSeconds = 0
+	if params.Condition == PriceAlertConditionPercentChange {
+		a.ChangePercent = params.ChangePercent
+		a.WindowSeconds = params.WindowSeconds
+	} else {
+		a.TargetPrice = params.TargetPrice
+	}
+	a.Repeat = params.Repeat
+	a.CooldownSeconds = 0
+	if params.Repeat {
+		a.CooldownSeconds = params.CooldownSeconds
+	}
+	a.Webhook = params.Webhook
+	a.Note = params.Note
+	a.Status = PriceAlertStatusActive
+	a.Armed = true
+}
+
+// Window 漲跌幅的時間窗口
+func (a *PriceAlert) Window() time.Duration {
+	return time.Duration(a.WindowSeconds) * time.Second
+}
+
+// Matches 條件是否成立：previous 為上一筆價格（CROSS 使用），reference 為時間窗口起點的價格（PERCENT_CHANGE 使用），0 表示沒有資料
+func (a *PriceAlert) Matches(previous float64, price float64, reference float64) bool {
+	switch a.Condition {
+	case PriceAlertConditionAbove:
+		return price >= a.TargetPrice
+	case PriceAlertConditionBelow:
+		return price <= a.TargetPrice
+	case PriceAlertConditionCross:
+		return previous > 0 && (previous < a.TargetPrice) != (price < a.TargetPrice)
+	case PriceAlertConditionPercentChange:
+		if reference <= 0 {
+			return false
+		}
+		change := (price - reference) / reference * 100
+		if a.ChangePercent > 0 {
+			return change >= a.ChangePercent
+		}
+		return change <= a.ChangePercent
+	}
+	return false
+}
+
+// Check 以新價格檢查提醒，回傳是否觸發與狀態是否改變（需要寫回資料庫）
+// 條件成立且已就緒時觸發；條件不成立時重新就緒；重複提醒在最短間隔內不觸發，但保持就緒
+func (a *PriceAlert) Check(previous float64, price float64, reference float64, now time.Time) (triggered bool, changed bool) {
+	if a.Status != PriceAlertStatusActive {
+		return false, false
+	}
+	if !a.Matches(previous, price, reference) {
+		if !a.Armed {
+			a.Armed = true
+			return false, true
+		}
+		return false, false
+	}
+	if !a.Armed {
+		return false, false
+	}
+	if a.LastTriggeredAt != nil && now.Sub(*a.LastTriggeredAt) < time.Duration(a.CooldownSeconds)*time.Second {
+		return false, false
+	}
+
+	a.Armed = false
+	a.TriggerCount++
+	a.LastTriggeredAt = &now
+	a.LastTriggerPrice = price
+	if !a.Repeat {
+		a.Status = PriceAlertStatusTriggered
+	}
+	return true, true
+}
+
+// CreatePriceAlert 寫入價格提醒
+func CreatePriceAlert(a *PriceAlert) error {
+	o := orm.NewOrm()
+	id, err := o.Insert(a)
+	if err != nil {
+		return err
+	}
+	a.Id = id
+	return nil
+}
+
+// SavePriceAlert 寫回提醒的設定與狀態（不含 webhook 通知的結果）
+func SavePriceAlert(a *PriceAlert) error {
+	o := orm.NewOrm()
+	_, err := o.Update(a, "Symbol", "Condition", "TargetPrice", "ChangePercent", "WindowSeconds", "Repeat",
+		"CooldownSeconds", "Webhook", "Note", "Status", "Armed", "UpdatedAt")
+	return err
+}
+
+// SavePriceAlertTrigger 寫回提醒的觸發狀態（需要在寫入通知的交易中使用）
+func SavePriceAlertTrigger(o orm.QueryExecutor, a *PriceAlert) error {
+	_, err := o.Update(a, "Status", "Armed", "TriggerCount", "LastTriggeredAt", "LastTriggerPrice", "UpdatedAt")
+	return err
+}
+
+// DeletePriceAlert 刪除價格提醒
+func DeletePriceAlert(id int64) error {
+	o := orm.NewOrm()
+	_, err := o.Delete(&PriceAlert{Id: id})
+	return err
+}
+
+// GetPriceAlertById 根據 ID 查詢價格提醒
+func GetPriceAlertById(id int64) (*PriceAlert, error) {
+	o := orm.NewOrm()
+	a := &PriceAlert{Id: id}
+	if err := o.Read(a); err != nil {
+		if err == orm.ErrNoRows {
+			return nil, errors.New("price alert not found")
+		}
+		return nil, err
+	}
+	return a, nil
+}
+
+// GetPriceAlertsByUser 查詢使用者的價格提醒（由新到舊），status 為空表示全部
+func GetPriceAlertsByUser(userId int64, status PriceAlertStatus, limit int, offset int) ([]*PriceAlert, error) {
+	o := orm.NewOrm()
+	qs := o.QueryTable(new(PriceAlert)).Filter("User__Id", userId)
+	if status != "" {
+		qs = qs.Filter("Status", status)
+	}
+	var alerts []*PriceAlert
+	_, err := qs.OrderBy("-CreatedAt").Limit(limit, offset).All(&alerts)
+	return alerts, err
+}
+
+// GetActivePriceAlerts 查詢所有監控中的價格提醒
+func GetActivePriceAlerts() ([]*PriceAlert, error) {
+	o := orm.NewOrm()
+	var alerts []*PriceAlert
+	_, err := o.QueryTable(new(PriceAlert)).
+		Filter("Status", PriceAlertStatusActive).
+		Limit(-1).
+		All(&alerts)
+	return alerts, err
+}
+
+// CountActivePriceAlertsByUser 計算使用者監控中的提醒數量
+func CountActivePriceAlertsByUser(userId int64) (int64, error) {
+	o := orm.NewOrm()
+	return o.QueryTable(new(PriceAlert)).
+		Filter("User__Id", userId).
+		Filter("Status", PriceAlertStatusActive).
+		Count()
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPriceAlertParamsValidate(t *testing.T) {
+	valid := PriceAlertParams{Symbol: "BTCUSDT", Condition: PriceAlertConditionAbove, TargetPrice: 70000}
+
+	tests := []struct {
+		name    string
+		modify  func(p *PriceAlertParams)
+		wantErr bool
+	}{
+		{"valid", func(p *PriceAlertParams) {}, false},
+		{"unknown condition", func(p *PriceAlertParams) { p.Condition = "EQUAL" }, true},
+		{"no target price", func(p *PriceAlertParams) { p.TargetPrice = 0 }, true},
+		{"unsupported symbol", func(p *PriceAlertParams) { p.Symbol = "BTCEUR" }, true},
+		{"percent change", func(p *PriceAlertParams) {
+			p.Condition = PriceAlertConditionPercentChange
+			p.ChangePercent = -5
+			p.WindowSeconds = 3600
+		}, false},
+		{"percent change without window", func(p *PriceAlertParams) {
+			p.Condition = PriceAlertConditionPercentChange
+			p.ChangePercent = 5
+		}, true},
+		{"percent change of zero", func(p *PriceAlertParams) {
+			p.Condition = PriceAlertConditionPercentChange
+			p.WindowSeconds = 3600
+		}, true},
+		{"negative cooldown", func(p *PriceAlertParams) { p.CooldownSeconds = -1 }, true},
+		{"webhook", func(p *PriceAlertParams) { p.Webhook = true }, false},
+	}
+
+	for _, tt := range tests {
+		params := valid
+		tt.modify(&params)
+		err := params.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPriceAlertMatches(t *testing.T) {
+	tests := []struct {
+		name                       string
+		alert                      PriceAlert
+		previous, price, reference float64
+		want                       bool
+	}{
+		{"above at target", PriceAlert{Condition: PriceAlertConditionAbove, TargetPrice: 100}, 0, 100, 0, true},
+		{"above under target", PriceAlert{Condition: PriceAlertConditionAbove, TargetPrice: 100}, 0, 99, 0, false},
+		{"below", PriceAlert{Condition: PriceAlertConditionBelow, TargetPrice: 100}, 0, 99, 0, true},
+		{"cross up", PriceAlert{Condition: PriceAlertConditionCross, TargetPrice: 100}, 99, 101, 0, true},
+		{"cross down", PriceAlert{Condition: PriceAlertConditionCross, TargetPrice: 100}, 101, 99, 0, true},
+		{"no cross", PriceAlert{Condition: PriceAlertConditionCross, TargetPrice: 100}, 101, 102, 0, false},
+		{"cross without previous", PriceAlert{Condition: PriceAlertConditionCross, TargetPrice: 100}, 0, 101, 0, false},
+		{"rise", PriceAlert{Condition: PriceAlertConditionPercentChange, ChangePercent: 5}, 0, 105, 100, true},
+		{"rise too small", PriceAlert{Condition: PriceAlertConditionPercentChange, ChangePercent: 5}, 0, 104, 100, false},
+		{"drop", PriceAlert{Condition: PriceAlertConditionPercentChange, ChangePercent: -5}, 0, 95, 100, true},
+		{"drop on rise", PriceAlert{Condition: PriceAlertConditionPercentChange, ChangePercent: -5}, 0, 110, 100, false},
+		{"no reference", PriceAlert{Condition: PriceAlertConditionPercentChange, ChangePercent: 5}, 0, 105, 0, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.alert.Matches(tt.previous, tt.price, tt.reference); got != tt.want {
+			t.Errorf("%s: Matches() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPriceAlertCheckOneShot(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	alert := &PriceAlert{Condition: PriceAlertConditionAbove, TargetPrice: 100, Status: PriceAlertStatusActive, Armed: true}
+
+	if triggered, changed := alert.Check(0, 99, 0, now); triggered || changed {
+		t.Fatalf("Check(99) = %v, %v, want no trigger", triggered, changed)
+	}
+	if triggered, _ := alert.Check(0, 101, 0, now); !triggered {
+		t.Fatal("expected trigger at 101")
+	}
+	if alert.Status != PriceAlertStatusTriggered || alert.TriggerCount != 1 || alert.LastTriggerPrice != 101 {
+		t.Errorf("after trigger: status %s, count %d, price %.0f", alert.Status, alert.TriggerCount, alert.LastTriggerPrice)
+	}
+
+	alert.Check(0, 99, 0, now)
+	if triggered, _ := alert.Check(0, 102, 0, now); triggered {
+		t.Error("one-shot alert should not trigger again")
+	}
+}
+
+func TestPriceAlertCheckRepeatRearms(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	alert := &PriceAlert{
+		Condition:       PriceAlertConditionAbove,
+		TargetPrice:     100,
+		Repeat:          true,
+		CooldownSeconds: 60,
+		Status:          PriceAlertStatusActive,
+		Armed:           true,
+	}
+
+	steps := []struct {
+		after         time.Duration
+		price         float64
+		wantTriggered bool
+		wantChanged   bool
+	}{
+		{0, 101, true, true},                 // 觸發
+		{time.Second, 102, false, false},     // 條件仍成立，不重複觸發
+		{2 * time.Second, 99, false, true},   // 條件不成立，重新就緒
+		{3 * time.Second, 101, false, false}, // 最短間隔內不觸發
+		{61 * time.Second, 101, true, true},  // 間隔過後仍成立，觸發
+	}
+
+	for i, step := range steps {
+		triggered, changed := alert.Check(0, step.price, 0, now.Add(step.after))
+		if triggered != step.wantTriggered || changed != step.wantChanged {
+			t.Errorf("step %d: Check(%.0f) = %v, %v, want %v, %v", i, step.price, triggered, changed, step.wantTriggered, step.wantChanged)
+		}
+	}
+	if alert.Status != PriceAlertStatusActive || alert.TriggerCount != 2 {
+		t.Errorf("status %s, count %d, want ACTIVE and 2", alert.Status, alert.TriggerCount)
+	}
+}
@@ -0,0 +1,373 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// RecurringBuyCadence 定期定額的排程方式
+type RecurringBuyCadence string
+
+const (
+	RecurringBuyCadenceInterval RecurringBuyCadence = "INTERVAL" // 從 StartAt 開始每隔固定秒數
+	RecurringBuyCadenceCron     RecurringBuyCadence = "CRON"     // cron 表示式（UTC）
+)
+
+// RecurringBuyStatus 定期定額狀態
+type RecurringBuyStatus string
+
+const (
+	RecurringBuyStatusActive RecurringBuyStatus = "ACTIVE" // 執行中
+	RecurringBuyStatusPaused RecurringBuyStatus = "PAUSED" // 已暫停（暫停期間的排程不補買）
+)
+
+// CatchUpPolicy 服務停機期間錯過排程的處理方式
+type CatchUpPolicy string
+
+const (
+	CatchUpPolicySkip    CatchUpPolicy = "SKIP"     // 全部略過，等下一次排程
+	CatchUpPolicyRunOnce CatchUpPolicy = "RUN_ONCE" // 恢復後只補買一次（以最近一次錯過的排程為準），其餘略過
+)
+
+// RecurringBuyRunStatus 單次執行結果
+type RecurringBuyRunStatus string
+
+const (
+	RecurringBuyRunStatusSuccess RecurringBuyRunStatus = "SUCCESS" // 已下單成交
+	RecurringBuyRunStatusFailed  RecurringBuyRunStatus = "FAILED"  // 下單失敗（例如 USDT 不足）
+	RecurringBuyRunStatusSkipped RecurringBuyRunStatus = "SKIPPED" // 停機期間錯過，依補買規則略過
+)
+
+const (
+	// MinRecurringBuyIntervalSeconds 固定間隔的最小秒數
+	MinRecurringBuyIntervalSeconds = 60
+	// MaxRecurringBuyCatchUpRuns 一次最多記錄的錯過排程數量（停機很久時其餘的不再逐筆記錄）
+	MaxRecurringBuyCatchUpRuns = 100
+)
+
+// RecurringBuy 定期定額買入排程，每次以市價單花費 QuoteAmount 買入
+type RecurringBuy struct {
+	Id              int64               `orm:"auto" json:"id"`
+	User            *User               `orm:"rel(fk)" json:"-"`
+	Symbol          string              `orm:"size(20)" json:"symbol"`                               // 交易對：BTCUSDT, ETHUSDT, SOLUSDT
+	QuoteAmount     float64             `orm:"digits(20);decimals(8)" json:"quoteAmount"`            // 每次花費的 USDT 金額
+	Cadence         RecurringBuyCadence `orm:"size(20)" json:"cadence"`                              // INTERVAL or CRON
+	IntervalSeconds int                 `orm:"default(0)" json:"intervalSeconds,omitempty"`          // 固定間隔秒數（INTERVAL）
+	CronExpr        string              `orm:"size(100);null" json:"cronExpr,omitempty"`             // cron 表示式（CRON，UTC）
+	StartAt         time.Time           `orm:"type(datetime)" json:"startAt"`                        // 固定間隔的起點
+	CatchUp         CatchUpPolicy       `orm:"size(20)" json:"catchUp"`                              // SKIP or RUN_ONCE
+	Status          RecurringBuyStatus  `orm:"size(20);index" json:"status"`                         // ACTIVE or PAUSED
+	NextRunAt       *time.Time          `orm:"null;type(datetime);index" json:"nextRunAt,omitempty"` // 下一次排程時間（暫停時為空）
+	LastRunAt       *time.Time          `orm:"null;type(datetime)" json:"lastRunAt,omitempty"`       // 最近一次執行時間
+	CreatedAt       time.Time           `orm:"auto_now_add;type(datetime)" json:"createdAt"`
+	UpdatedAt       time.Time           `orm:"auto_now;type(datetime)" json:"updatedAt"`
+}
+
+// RecurringBuyRun 定期定額的執行記錄
+type RecurringBuyRun struct {
+	Id           int64                 `orm:"auto" json:"id"`
+	RecurringBuy *RecurringBuy         `orm:"rel(fk)" json:"-"`
+	ScheduledAt  time.Time             `orm:"type(datetime)" json:"scheduledAt"`                // 排程時間
+	Status       RecurringBuyRunStatus `orm:"size(20)" json:"status"`                           // SUCCESS, FAILED or SKIPPED
+	OrderId      int64                 `orm:"default(0)" json:"orderId,omitempty"`              // 成交的市價單
+	QuoteAmount  float64               `orm:"digits(20);decimals(8)" json:"quoteAmount"`        // 花費的 USDT 金額
+	Quantity     float64               `orm:"digits(20);decimals(8)" json:"quantity,omitempty"` // 買入的數量
+	Price        float64               `orm:"digits(20);decimals(8)" json:"price,omitempty"`    // 成交價格
+	ErrorMsg     string                `orm:"size(500);null" json:"errorMsg,omitempty"`         // 失敗或略過的原因
+	CreatedAt    time.Time             `orm:"auto_now_add;type(datetime)" json:"createdAt"`
+}
+
+func init() {
+	orm.RegisterModel(new(RecurringBuy), new(RecurringBuyRun))
+}
+
+// TableName 指定資料表名稱
+func (s *RecurringBuy) TableName() string {
+	return "recurring_buy"
+}
+
+// TableName 指定資料表名稱
+func (r *RecurringBuyRun) TableName() string {
+	return "recurring_buy_run"
+}
+
+// RecurringBuyParams 建立或修改定期定額的參數
+type RecurringBuyParams struct {
+	Symbol          string
+	QuoteAmount     float64
+	Cadence         RecurringBuyCadence
+	IntervalSeconds int
+	CronExpr        string
+	StartAt         *time.Time // 固定間隔的起點（不填為現在）
+	CatchUp         CatchUpPolicy
+}
+
+// Validate 驗證定期定額參數
+func (p RecurringBuyParams) Validate() error {
+	if p.QuoteAmount <= 0 {
+		return errors.New("quote amount must be positive")
+	}
+	if _, _, err := ParseSymbol(p.Symbol); err != nil {
+		return err
+	}
+	if p.CatchUp != CatchUpPolicySkip && p.CatchUp != CatchUpPolicyRunOnce {
+		return errors.New("catchUp must be SKIP or RUN_ONCE")
+	}
+
+	switch p.Cadence {
+	case RecurringBuyCadenceInterval:
+		if p.IntervalSeconds < MinRecurringBuyIntervalSeconds {
+			return errors.New("intervalSeconds must be at least 60")
+		}
+	case RecurringBuyCadenceCron:
+		schedule, err := ParseCron(p.CronExpr)
+		if err != nil {
+			return err
+		}
+		if schedule.Next(time.Now()).IsZero() {
+			return errors.New("cron expression never matches")
+		}
+	default:
+		return errors.New("cadence must be INTERVAL or CRON")
+	}
+	return nil
+}
+
+// Apply 將參數寫入排程（參數需先驗證），啟用中的排程從 now 重新計算下一次時間
+func (s *RecurringBuy) Apply(params RecurringBuyParams, now time.Time) {
+	s.Symbol = params.Symbol
+	s.QuoteAmount = params.QuoteAmount
+	s.Cadence = params.Cadence
+	s.IntervalSeconds = 0
+	s.CronExpr = ""
+	if params.Cadence == RecurringBuyCadenceInterval {
+		s.IntervalSeconds = params.IntervalSeconds
+	} else {
+		s.CronExpr = params.CronExpr
+	}
+	s.StartAt = now.Truncate(time.Second)
+	if params.StartAt != nil {
+		s.StartAt = params.StartAt.Truncate(time.Second)
+	}
+	s.CatchUp = params.CatchUp
+
+	if s.Status == RecurringBuyStatusActive {
+		s.scheduleFrom(now)
+	}
+}
+
+// NextAfter 返回 after 之後（不含）的下一次排程時間，不會觸發時返回零值
+func (s *RecurringBuy) NextAfter(after time.Time) time.Time {
+	if s.Cadence == RecurringBuyCadenceCron {
+		schedule, err := ParseCron(s.CronExpr)
+		if err != nil {
+			return time.Time{}
+		}
+		return schedule.Next(after)
+	}
+
+	if s.IntervalSeconds <= 0 {
+		return time.Time{}
+	}
+	start := s.StartAt.UTC()
+	if after.Before(start) {
+		return start
+	}
+	interval := time.Duration(s.IntervalSeconds) * time.Second
+	n := after.Sub(start)/interval + 1
+	return start.Add(n * interval)
+}
+
+// scheduleFrom 從 now（含）開始排定下一次時間
+func (s *RecurringBuy) scheduleFrom(now time.Time) {
+	next := s.NextAfter(now.Truncate(time.Second).Add(-time.Second))
+	if next.IsZero() {
+		s.NextRunAt = nil
+		return
+	}
+	s.NextRunAt = &next
+}
+
+// Pause 暫停排程
+func (s *RecurringBuy) Pause() error {
+	if s.Status != RecurringBuyStatusActive {
+		return errors.New("only active recurring buys can be paused")
+	}
+	s.Status = RecurringBuyStatusPaused
+	s.NextRunAt = nil
+	return nil
+}
+
+// Resume 恢復排程，從 now 開始計算下一次時間（暫停期間的排程不補買）
+func (s *RecurringBuy) Resume(now time.Time) error {
+	if s.Status != RecurringBuyStatusPaused {
+		return errors.New("only paused recurring buys can be resumed")
+	}
+	s.Status = RecurringBuyStatusActive
+	s.scheduleFrom(now)
+	return nil
+}
+
+// RecurringBuyPlan 到期排程的處理方式
+type RecurringBuyPlan struct {
+	Execute *time.Time  // 要執行的排程（nil 表示這次不下單）
+	Skipped []time.Time // 依補買規則略過的排程
+	Next    time.Time   // 下一次排程時間
+}
+
+// PlanRuns 依補買規則決定到期排程的處理方式
+// 只有一筆到期且延遲不超過 grace 時視為準時執行；否則視為停機期間錯過，依 CatchUp 全部略過或只補買最近一次
+func (s *RecurringBuy) PlanRuns(now time.Time, grace time.Duration) RecurringBuyPlan {
+	plan := RecurringBuyPlan{Next: s.NextAfter(now)}
+	if s.NextRunAt == nil || s.NextRunAt.After(now) {
+		return plan
+	}
+
+	due := []time.Time{*s.NextRunAt}
+	for len(due) < MaxRecurringBuyCatchUpRuns {
+		next := s.NextAfter(due[len(due)-1])
+		if next.IsZero() || next.After(now) {
+			break
+		}
+		due = append(due, next)
+	}
+
+	if len(due) == 1 && now.Sub(due[0]) <= grace {
+		plan.Execute = &due[0]
+		return plan
+	}
+
+	if s.CatchUp == CatchUpPolicyRunOnce {
+		latest := due[len(due)-1]
+		plan.Execute = &latest
+		plan.Skipped = due[:len(due)-1]
+	} else {
+		plan.Skipped = due
+	}
+	return plan
+}
+
+// CreateRecurringBuy 寫入定期定額排程
+func CreateRecurringBuy(s *RecurringBuy) error {
+	o := orm.NewOrm()
+	id, err := o.Insert(s)
+	if err != nil {
+		return err
+	}
+	s.Id = id
+	return nil
+}
+
+// SaveRecurringBuy 寫回排程的設定與狀態
+func SaveRecurringBuy(s *RecurringBuy) error {
+	o := orm.NewOrm()
+	_, err := o.Update(s, "Symbol", "QuoteAmount", "Cadence", "IntervalSeconds", "CronExpr", "StartAt",
+		"CatchUp", "Status", "NextRunAt", "UpdatedAt")
+	return err
+}
+
+// ClaimRecurringBuyRun 以目前的下一次時間為條件推進排程，避免同一排程重複執行
+// 排程已被暫停、修改或由其他程序推進時返回 false
+func ClaimRecurringBuyRun(s *RecurringBuy, next time.Time, now time.Time) (bool, error) {
+	o := orm.NewOrm()
+	params := orm.Params{
+		"NextRunAt": nil,
+		"LastRunAt": now,
+		"UpdatedAt": now,
+	}
+	if !next.IsZero() {
+		params["NextRunAt"] = next
+	}
+
+	num, err := o.QueryTable(new(RecurringBuy)).
+		Filter("Id", s.Id).
+		Filter("Status", RecurringBuyStatusActive).
+		Filter("NextRunAt", *s.NextRunAt).
+		Update(params)
+	if err != nil {
+		return false, err
+	}
+	return num > 0, nil
+}
+
+// DeleteRecurringBuy 刪除排程與執行記錄
+func DeleteRecurringBuy(id int64) error {
+	o := orm.NewOrm()
+	to, err := o.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err = to.QueryTable(new(RecurringBuyRun)).Filter("RecurringBuy__Id", id).Delete(); err != nil {
+		to.Rollback()
+		return err
+	}
+	if _, err = to.Delete(&RecurringBuy{Id: id}); err != nil {
+		to.Rollback()
+		return err
+	}
+	return to.Commit()
+}
+
+// GetRecurringBuyById 根據 ID 查詢排程
+func GetRecurringBuyById(id int64) (*RecurringBuy, error) {
+	o := orm.NewOrm()
+	s := &RecurringBuy{Id: id}
+	if err := o.Read(s); err != nil {
+		if err == orm.ErrNoRows {
+			return nil, errors.New("recurring buy not found")
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetRecurringBuysByUser 查詢使用者的所有排程
+func GetRecurringBuysByUser(userId int64) ([]*RecurringBuy, error) {
+	o := orm.NewOrm()
+	var schedules []*RecurringBuy
+	_, err := o.QueryTable(new(RecurringBuy)).
+		Filter("User__Id", userId).
+		OrderBy("Id").
+		Limit(-1).
+		All(&schedules)
+	return schedules, err
+}
+
+// GetDueRecurringBuys 查詢已到期的啟用中排程
+func GetDueRecurringBuys(now time.Time) ([]*RecurringBuy, error) {
+	o := orm.NewOrm()
+	var schedules []*RecurringBuy
+	_, err := o.QueryTable(new(RecurringBuy)).
+		Filter("Status", RecurringBuyStatusActive).
+		Filter("NextRunAt__lte", now).
+		OrderBy("NextRunAt").
+		Limit(-1).
+		All(&schedules)
+	return schedules, err
+}
+
+// CreateRecurringBuyRun 寫入執行記錄
+func CreateRecurringBuyRun(run *RecurringBuyRun) error {
+	o := orm.NewOrm()
+	id, err := o.Insert(run)
+	if err != nil {
+		return err
+	}
+	run.Id = id
+	return nil
+}
+
+// GetRecurringBuyRuns 查詢排程的執行記錄（由新到舊）
+func GetRecurringBuyRuns(recurringBuyId int64, limit int, offset int) ([]*RecurringBuyRun, error) {
+	o := orm.NewOrm()
+	var runs []*RecurringBuyRun
+	_, err := o.QueryTable(new(RecurringBuyRun)).
+		Filter("RecurringBuy__Id", recurringBuyId).
+		OrderBy("-Id").
+		Limit(limit, offset).
+		All(&runs)
+	return runs, err
+}
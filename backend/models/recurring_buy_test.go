@@ -0,0 +1,138 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestRecurringBuy(t *testing.T, catchUp CatchUpPolicy, now time.Time) *RecurringBuy {
+	t.Helper()
+	params := RecurringBuyParams{
+		Symbol:          "BTCUSDT",
+		QuoteAmount:     100,
+		Cadence:         RecurringBuyCadenceInterval,
+		IntervalSeconds: 3600,
+		CatchUp:         catchUp,
+	}
+	if err := params.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	s := &RecurringBuy{Status: RecurringBuyStatusActive}
+	s.Apply(params, now)
+	return s
+}
+
+func TestRecurringBuyParamsValidate(t *testing.T) {
+	base := RecurringBuyParams{Symbol: "BTCUSDT", QuoteAmount: 100, Cadence: RecurringBuyCadenceCron, CronExpr: "0 9 * * 1", CatchUp: CatchUpPolicySkip}
+	if err := base.Validate(); err != nil {
+		t.Fatalf("valid params: %v", err)
+	}
+
+	invalid := []func(p *RecurringBuyParams){
+		func(p *RecurringBuyParams) { p.QuoteAmount = 0 },
+		func(p *RecurringBuyParams) { p.Symbol = "BTC" },
+		func(p *RecurringBuyParams) { p.CatchUp = "ALL" },
+		func(p *RecurringBuyParams) { p.CronExpr = "0 9 * *" },
+		func(p *RecurringBuyParams) { p.CronExpr = "0 0 31 2 *" },
+		func(p *RecurringBuyParams) { p.Cadence = RecurringBuyCadenceInterval; p.IntervalSeconds = 10 },
+		func(p *RecurringBuyParams) { p.Cadence = "DAILY" },
+	}
+	for i, modify := range invalid {
+		params := base
+		modify(&params)
+		if err := params.Validate(); err == nil {
+			t.Errorf("case %d: Validate should fail for %+v", i, params)
+		}
+	}
+}
+
+func TestRecurringBuyIntervalSchedule(t *testing.T) {
+	now := mustParseTime(t, "2024-01-01T10:00:00Z")
+	s := newTestRecurringBuy(t, CatchUpPolicySkip, now)
+
+	// 起點即為第一次排程
+	if s.NextRunAt == nil || !s.NextRunAt.Equal(now) {
+		t.Fatalf("NextRunAt = %v, want %v", s.NextRunAt, now)
+	}
+	if next := s.NextAfter(now.Add(90 * time.Minute)); !next.Equal(now.Add(2 * time.Hour)) {
+		t.Errorf("NextAfter = %v, want %v", next, now.Add(2*time.Hour))
+	}
+}
+
+func TestRecurringBuyPlanOnTime(t *testing.T) {
+	start := mustParseTime(t, "2024-01-01T10:00:00Z")
+	s := newTestRecurringBuy(t, CatchUpPolicySkip, start)
+
+	plan := s.PlanRuns(start.Add(20*time.Second), 5*time.Minute)
+	if plan.Execute == nil || !plan.Execute.Equal(start) {
+		t.Fatalf("Execute = %v, want %v", plan.Execute, start)
+	}
+	if len(plan.Skipped) != 0 {
+		t.Errorf("Skipped = %v, want none", plan.Skipped)
+	}
+	if !plan.Next.Equal(start.Add(time.Hour)) {
+		t.Errorf("Next = %v, want %v", plan.Next, start.Add(time.Hour))
+	}
+}
+
+func TestRecurringBuyPlanNotDue(t *testing.T) {
+	start := mustParseTime(t, "2024-01-01T10:00:00Z")
+	s := newTestRecurringBuy(t, CatchUpPolicyRunOnce, start)
+
+	plan := s.PlanRuns(start.Add(-time.Minute), 5*time.Minute)
+	if plan.Execute != nil || len(plan.Skipped) != 0 {
+		t.Errorf("plan = %+v, want nothing to run", plan)
+	}
+}
+
+func TestRecurringBuyPlanCatchUp(t *testing.T) {
+	start := mustParseTime(t, "2024-01-01T10:00:00Z")
+	// 停機 3.5 小時：10:00、11:00、12:00、13:00 四次排程都錯過
+	now := start.Add(3*time.Hour + 30*time.Minute)
+
+	skip := newTestRecurringBuy(t, CatchUpPolicySkip, start).PlanRuns(now, 5*time.Minute)
+	if skip.Execute != nil || len(skip.Skipped) != 4 {
+		t.Errorf("SKIP: Execute = %v, Skipped = %d, want nil, 4", skip.Execute, len(skip.Skipped))
+	}
+
+	once := newTestRecurringBuy(t, CatchUpPolicyRunOnce, start).PlanRuns(now, 5*time.Minute)
+	if once.Execute == nil || !once.Execute.Equal(start.Add(3*time.Hour)) {
+		t.Errorf("RUN_ONCE: Execute = %v, want %v", once.Execute, start.Add(3*time.Hour))
+	}
+	if len(once.Skipped) != 3 {
+		t.Errorf("RUN_ONCE: Skipped = %d, want 3", len(once.Skipped))
+	}
+	if !once.Next.Equal(start.Add(4 * time.Hour)) {
+		t.Errorf("Next = %v, want %v", once.Next, start.Add(4*time.Hour))
+	}
+
+	// 只錯過一次但延遲超過寬限時間，也依補買規則處理
+	late := newTestRecurringBuy(t, CatchUpPolicySkip, start).PlanRuns(start.Add(10*time.Minute), 5*time.Minute)
+	if late.Execute != nil || len(late.Skipped) != 1 {
+		t.Errorf("late SKIP: Execute = %v, Skipped = %d, want nil, 1", late.Execute, len(late.Skipped))
+	}
+}
+
+func TestRecurringBuyPauseResume(t *testing.T) {
+	start := mustParseTime(t, "2024-01-01T10:00:00Z")
+	s := newTestRecurringBuy(t, CatchUpPolicyRunOnce, start)
+
+	if err := s.Pause(); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	if s.NextRunAt != nil {
+		t.Error("paused schedule should have no next run")
+	}
+	if err := s.Pause(); err == nil {
+		t.Error("Pause on paused schedule should fail")
+	}
+
+	// 暫停期間的排程不補買，從恢復的時間開始
+	resumeAt := start.Add(5*time.Hour + 10*time.Minute)
+	if err := s.Resume(resumeAt); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	if want := start.Add(6 * time.Hour); s.NextRunAt == nil || !s.NextRunAt.Equal(want) {
+		t.Errorf("NextRunAt = %v, want %v", s.NextRunAt, want)
+	}
+}
@@ -0,0 +1,171 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// StrategyBotStatus 策略機器人狀態
+type StrategyBotStatus string
+
+const (
+	StrategyBotStatusRunning StrategyBotStatus = "RUNNING" // 執行中
+	StrategyBotStatusStopped StrategyBotStatus = "STOPPED" // 已停止（使用者停止或觸發風險限制，掛單已取消）
+	StrategyBotStatusFailed  StrategyBotStatus = "FAILED"  // 策略發生錯誤，已停止
+)
+
+// StrategyBot 策略機器人：在伺服器內以使用者的帳戶執行已註冊的策略（與回測使用相同的策略）
+// 策略下的訂單為機器人的子訂單，持倉與錢包則與使用者共用
+type StrategyBot struct {
+	Id                  int64              `orm:"auto" json:"id"`
+	User                *User              `orm:"rel(fk)" json:"-"`
+	Strategy            string             `orm:"size(50)" json:"strategy"`                          // 策略名稱
+	Symbol              string             `orm:"size(20)" json:"symbol"`                            // 交易對：BTCUSDT, ETHUSDT, SOLUSDT
+	Interval            string             `orm:"size(10)" json:"interval"`                          // 呼叫 OnCandle 的 K 線週期
+	Params              string             `orm:"type(text);null" json:"-"`                          // 策略參數（JSON）
+	StrategyParams      map[string]float64 `orm:"-" json:"params,omitempty"`                         // 策略參數
+	MaxOrderNotional    float64            `orm:"digits(20);decimals(8)" json:"maxOrderNotional"`    // 風險限制：單筆訂單的最大名目價值（0 表示不限制）
+	MaxPositionNotional float64            `orm:"digits(20);decimals(8)" json:"maxPositionNotional"` // 風險限制：交易對的最大曝險（0 表示不限制）
+	MaxLeverage         int                `orm:"default(0)" json:"maxLeverage"`                     // 風險限制：最大槓桿倍數（0 表示不限制）
+	MaxDailyLoss        float64            `orm:"digits(20);decimals(8)" json:"maxDailyLoss"`        // 風險限制：單日最大虧損，達到後停止機器人（0 表示不限制）
+	Status              StrategyBotStatus  `orm:"size(20);index" json:"status"`                      // RUNNING, STOPPED or FAILED
+	StopReason          string             `orm:"size(500);null" json:"stopReason,omitempty"`        // 停止原因
+	OrderCount          int                `orm:"default(0)" json:"orderCount"`                      // 策略已送出的訂單數量
+	LastError           string             `orm:"size(500);null" json:"lastError,omitempty"`         // 最近一次下單失敗的原因
+	LastErrorAt         *time.Time         `orm:"null;type(datetime)" json:"lastErrorAt,omitempty"`  // 最近一次下單失敗的時間
+	StoppedAt           *time.Time         `orm:"null;type(datetime)" json:"stoppedAt,omitempty"`    // 停止時間
+	Orders              []*Order           `orm:"-" json:"orders,omitempty"`                         // 掛單中的訂單（查詢單筆時載入）
+	LastPrice           float64            `orm:"-" json:"lastPrice,omitempty"`                      // 策略最近收到的價格（執行中時載入）
+	LastEventAt         *time.Time         `orm:"-" json:"lastEventAt,omitempty"`                    // 策略最近處理事件的時間（執行中時載入）
+	CreatedAt           time.Time          `orm:"auto_now_add;type(datetime)" json:"createdAt"`
+	UpdatedAt           time.Time          `orm:"auto_now;type(datetime)" json:"updatedAt"`
+}
+
+func init() {
+	orm.RegisterModel(new(StrategyBot))
+}
+
+// TableName 指定資料表名稱
+func (b *StrategyBot) TableName() string {
+	return "strategy_bot"
+}
+
+// IsRunning 機器人是否執行中
+func (b *StrategyBot) IsRunning() bool {
+	return b.Status == StrategyBotStatusRunning
+}
+
+// Stop 標記機器人停止
+func (b *StrategyBot) Stop(status StrategyBotStatus, reason string, now time.Time) {
+	b.Status = status
+	b.StopReason = reason
+	b.StoppedAt = &now
+}
+
+// RecordOrder 記錄策略送出的訂單，失敗時保留原因
+func (b *StrategyBot) RecordOrder(err error, now time.Time) {
+	b.OrderCount++
+	if err != nil {
+		b.LastError = err.Error()
+		b.LastErrorAt = &now
+	}
+}
+
+// CreateStrategyBot 寫入策略機器人（策略參數以 JSON 儲存）
+func CreateStrategyBot(bot *StrategyBot) error {
+	params, err := json.Marshal(bot.StrategyParams)
+	if err != nil {
+		return err
+	}
+	bot.Params = string(params)
+
+	o := orm.NewOrm()
+	id, err := o.Insert(bot)
+	if err != nil {
+		return err
+	}
+	bot.Id = id
+	return nil
+}
+
+// SaveStrategyBot 寫回機器人的狀態與下單記錄（可在交易中使用）
+func SaveStrategyBot(o orm.QueryExecutor, bot *StrategyBot) error {
+	_, err := o.Update(bot, "Status", "StopReason", "OrderCount", "LastError", "LastErrorAt", "StoppedAt", "UpdatedAt")
+	return err
+}
+
+// GetStrategyBotById 根據 ID 查詢策略機器人
+func GetStrategyBotById(id int64) (*StrategyBot, error) {
+	o := orm.NewOrm()
+	bot := &StrategyBot{Id: id}
+	if err := o.Read(bot); err != nil {
+		if err == orm.ErrNoRows {
+			return nil, errors.New("strategy bot not found")
+		}
+		return nil, err
+	}
+	if err := decodeStrategyParams(bot.Params, &bot.StrategyParams); err != nil {
+		return nil, err
+	}
+	return bot, nil
+}
+
+// GetStrategyBotsByUser 查詢使用者的策略機器人（由新到舊）
+func GetStrategyBotsByUser(userId int64, limit int, offset int) ([]*StrategyBot, error) {
+	o := orm.NewOrm()
+	var bots []*StrategyBot
+	_, err := o.QueryTable(new(StrategyBot)).
+		Filter("User__Id", userId).
+		OrderBy("-CreatedAt").
+		Limit(limit, offset).
+		All(&bots)
+	if err != nil {
+		return nil, err
+	}
+	for _, bot := range bots {
+		if err = decodeStrategyParams(bot.Params, &bot.StrategyParams); err != nil {
+			return nil, err
+		}
+	}
+	return bots, nil
+}
+
+// GetRunningStrategyBots 查詢所有執行中的策略機器人（重新啟動後恢復執行）
+func GetRunningStrategyBots() ([]*StrategyBot, error) {
+	o := orm.NewOrm()
+	var bots []*StrategyBot
+	_, err := o.QueryTable(new(StrategyBot)).
+		Filter("Status", StrategyBotStatusRunning).
+		OrderBy("Id").
+		Limit(-1).
+		All(&bots)
+	if err != nil {
+		return nil, err
+	}
+	for _, bot := range bots {
+		if err = decodeStrategyParams(bot.Params, &bot.StrategyParams); err != nil {
+			return nil, err
+		}
+	}
+	return bots, nil
+}
+
+// CountRunningStrategyBotsByUser 計算使用者執行中的策略機器人數量
+func CountRunningStrategyBotsByUser(userId int64) (int64, error) {
+	o := orm.NewOrm()
+	return o.QueryTable(new(StrategyBot)).
+		Filter("User__Id", userId).
+		Filter("Status", StrategyBotStatusRunning).
+		Count()
+}
+
+// decodeStrategyParams 解析以 JSON 儲存的策略參數
+func decodeStrategyParams(raw string, params *map[string]float64) error {
+	if raw == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(raw), params)
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// TrailingStopParams 追蹤停損單參數
+type TrailingStopParams struct {
+	CallbackRate    float64 // 回撤比例（0 < rate < 1）
+	CallbackOffset  float64 // 回撤金額（與回撤比例擇一）
+	ActivationPrice float64 // 啟動價格（0 表示下單時立即啟動）
+}
+
+// Validate 驗證追蹤停損參數：回撤比例與回撤金額必須且只能指定一個
+func (p TrailingStopParams) Validate() error {
+	if p.CallbackRate < 0 || p.CallbackOffset < 0 || p.ActivationPrice < 0 {
+		return errors.New("trailing stop parameters must not be negative")
+	}
+	if (p.CallbackRate > 0) == (p.CallbackOffset > 0) {
+		return errors.New("exactly one of callback rate or callback offset is required")
+	}
+	if p.CallbackRate >= 1 {
+		return errors.New("callback rate must be less than 1")
+	}
+	return nil
+}
+
+// UpdateTrailingStop 以最新價格更新追蹤停損狀態
+// 賣出單（平多、現貨賣出）追蹤啟動後的最高價，價格從最高價回撤超過設定值時觸發
+// 買入單（平空）追蹤啟動後的最低價，價格從最低價反彈超過設定值時觸發
+// changed 表示啟動狀態、極值或觸發價格有變動，需要寫回資料庫
+func (order *Order) UpdateTrailingStop(price float64) (changed bool, triggered bool) {
+	if order.Type != OrderTypeTrailingStop || price <= 0 {
+		return false, false
+	}
+	sell := order.Side == OrderSideSell
+
+	if !order.TrailingActive {
+		if order.ActivationPrice > 0 {
+			if sell && price < order.ActivationPrice || !sell && price > order.ActivationPrice {
+				return false, false
+			}
+		}
+		order.TrailingActive = true
+		order.TrailingExtreme = price
+		changed = true
+	} else if sell && price > order.TrailingExtreme || !sell && price < order.TrailingExtreme {
+		order.TrailingExtreme = price
+		changed = true
+	}
+
+	if changed {
+		order.TriggerPrice = order.trailingTriggerPrice()
+	}
+
+	if sell {
+		triggered = price <= order.TriggerPrice
+	} else {
+		triggered = price >= order.TriggerPrice
+	}
+	return changed, triggered
+}
+
+// trailingTriggerPrice 由目前的極值計算觸發價格
+func (order *Order) trailingTriggerPrice() float64 {
+	callback := order.CallbackOffset
+	if order.CallbackRate > 0 {
+		callback = order.TrailingExtreme * order.CallbackRate
+	}
+	if order.Side == OrderSideSell {
+		return order.TrailingExtreme - callback
+	}
+	return order.TrailingExtreme + callback
+}
+
+// CreateTrailingStopOrder 建立追蹤停損單，並以目前價格初始化追蹤狀態
+// leverage 大於 0 時為槓桿平倉單（只減倉），positionSide 為訂單開倉方向（平多為 SHORT）
+func CreateTrailingStopOrder(o orm.QueryExecutor, userId int64, parent OrderParent, symbol string, side OrderSide, quantity float64, params TrailingStopParams, leverage int, positionSide PositionSide, currentPrice float64) (*Order, error) {
+	order := &Order{
+		User:            &User{Id: userId},
+		ClientOrderId:   parent.clientOrderId(),
+		Symbol:          symbol,
+		Type:            OrderTypeTrailingStop,
+		Side:            side,
+		Quantity:        quantity,
+		CallbackRate:    params.CallbackRate,
+		CallbackOffset:  params.CallbackOffset,
+		ActivationPrice: params.ActivationPrice,
+		Status:          OrderStatusPending,
+	}
+	if leverage > 0 {
+		order.IsLeverageOrder = true
+		order.Leverage = leverage
+		order.PositionSideStr = string(positionSide)
+		order.ReduceOnly = true
+	}
+
+	// 下單時只初始化狀態，不在此觸發，交由撮合器統一執行
+	order.UpdateTrailingStop(currentPrice)
+
+	if err := insertOrder(o, order, parent); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// UpdateTrailingStopState 寫回追蹤停損的狀態（只更新仍在等待中的訂單）
+func UpdateTrailingStopState(order *Order) error {
+	o := orm.NewOrm()
+	_, err := o.QueryTable(new(Order)).
+		Filter("Id", order.Id).
+		Filter("Status", OrderStatusPending).
+		Update(orm.Params{
+			"TrailingActive":  order.TrailingActive,
+			"TrailingExtreme": order.TrailingExtreme,
+			"TriggerPrice":    order.TriggerPrice,
+		})
+	return err
+}
+
+// GetPendingTrailingStopOrders 查詢所有等待觸發的追蹤停損單
+func GetPendingTrailingStopOrders() ([]*Order, error) {
+	o := orm.NewOrm()
+	var orders []*Order
+	_, err := o.QueryTable(new(Order)).
+		Filter("Type", OrderTypeTrailingStop).
+		Filter("Status", OrderStatusPending).
+		RelatedSel().
+		Limit(-1).
+		All(&orders)
+	return orders, err
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"testing"
+)
+
+func newTestTrailingStop(side OrderSide, params TrailingStopParams) *Order {
+	return &Order{
+		Type:            OrderTypeTrailingStop,
+		Side:            side,
+		Quantity:        1,
+		CallbackRate:    params.CallbackRate,
+		CallbackOffset:  params.CallbackOffset,
+		ActivationPrice: params.ActivationPrice,
+		Status:          OrderStatusPending,
+	}
+}
+
+// TestTrailingStopParamsValidate 測試回撤比例與回撤金額必須擇一
+func TestTrailingStopParamsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  TrailingStopParams
+		wantErr bool
+	}{
+		{"rate", TrailingStopParams{CallbackRate: 0.01}, false},
+		{"offset with activation", TrailingStopParams{CallbackOffset: 5, ActivationPrice: 110}, false},
+		{"neither", TrailingStopParams{}, true},
+		{"both", TrailingStopParams{CallbackRate: 0.01, CallbackOffset: 5}, true},
+		{"rate too large", TrailingStopParams{CallbackRate: 1}, true},
+		{"negative activation", TrailingStopParams{CallbackRate: 0.01, ActivationPrice: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.params.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("expected error %t, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestTrailingStopSellTracksHigh 測試賣出單追蹤最高價，回撤達回撤比例時觸發
+func TestTrailingStopSellTracksHigh(t *testing.T) {
+	order := newTestTrailingStop(OrderSideSell, TrailingStopParams{CallbackRate: 0.1})
+
+	if changed, triggered := order.UpdateTrailingStop(100); !changed || triggered {
+		t.Errorf("expected activation without trigger, got changed %t triggered %t", changed, triggered)
+	}
+	if !almostEqual(order.TriggerPrice, 90) {
+		t.Errorf("expected trigger price 90, got %.2f", order.TriggerPrice)
+	}
+
+	order.UpdateTrailingStop(120)
+	if !almostEqual(order.TriggerPrice, 108) {
+		t.Errorf("expected trigger price 108 after new high, got %.2f", order.TriggerPrice)
+	}
+
+	// 回落但未達觸發價格，觸發價格不下移
+	if changed, triggered := order.UpdateTrailingStop(110); changed || triggered {
+		t.Errorf("expected no change at 110, got changed %t triggered %t", changed, triggered)
+	}
+	if !almostEqual(order.TriggerPrice, 108) {
+		t.Errorf("expected trigger price to stay at 108, got %.2f", order.TriggerPrice)
+	}
+
+	if _, triggered := order.UpdateTrailingStop(108); !triggered {
+		t.Errorf("expected trigger at 108")
+	}
+}
+
+// TestTrailingStopBuyTracksLow 測試買入單（平空）追蹤最低價，反彈達回撤金額時觸發
+func TestTrailingStopBuyTracksLow(t *testing.T) {
+	order := newTestTrailingStop(OrderSideBuy, TrailingStopParams{CallbackOffset: 5})
+
+	order.UpdateTrailingStop(100)
+	order.UpdateTrailingStop(90)
+	if !almostEqual(order.TriggerPrice, 95) {
+		t.Errorf("expected trigger price 95, got %.2f", order.TriggerPrice)
+	}
+
+	if _, triggered := order.UpdateTrailingStop(94); triggered {
+		t.Errorf("expected no trigger at 94")
+	}
+	if _, triggered := order.UpdateTrailingStop(96); !triggered {
+		t.Errorf("expected trigger at 96")
+	}
+}
+
+// TestTrailingStopActivationPrice 測試設定啟動價格時，價格到達前不追蹤也不觸發
+func TestTrailingStopActivationPrice(t *testing.T) {
+	order := newTestTrailingStop(OrderSideSell, TrailingStopParams{CallbackOffset: 5, ActivationPrice: 110})
+
+	if changed, triggered := order.UpdateTrailingStop(100); changed || triggered {
+		t.Errorf("expected inactive below activation price, got changed %t triggered %t", changed, triggered)
+	}
+	if order.TrailingActive || order.TriggerPrice != 0 {
+		t.Errorf("expected no trigger price before activation, got %.2f", order.TriggerPrice)
+	}
+
+	order.UpdateTrailingStop(111)
+	if !order.TrailingActive || !almostEqual(order.TriggerPrice, 106) {
+		t.Errorf("expected activation at 111 with trigger 106, got active %t trigger %.2f", order.TrailingActive, order.TriggerPrice)
+	}
+
+	buy := newTestTrailingStop(OrderSideBuy, TrailingStopParams{CallbackOffset: 5, ActivationPrice: 90})
+	if _, triggered := buy.UpdateTrailingStop(100); triggered || buy.TrailingActive {
+		t.Errorf("expected buy order inactive above activation price")
+	}
+	buy.UpdateTrailingStop(89)
+	if !buy.TrailingActive || !almostEqual(buy.TriggerPrice, 94) {
+		t.Errorf("expected activation at 89 with trigger 94, got active %t trigger %.2f", buy.TrailingActive, buy.TriggerPrice)
+	}
+}
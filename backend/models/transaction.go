@@ -17,6 +17,7 @@ const (
 	TransactionTypeMarginDeposit  TransactionType = "MARGIN_DEPOSIT"  // 保證金存入
 	TransactionTypeMarginWithdraw TransactionType = "MARGIN_WITHDRAW" // 保證金取出
 	TransactionTypeLiquidation    TransactionType = "LIQUIDATION"     // 爆倉
+	TransactionTypeFunding        TransactionType = "FUNDING"         // 資金費用
 )
 
 // Transaction 交易記錄
@@ -10,11 +10,13 @@ import (
 )
 
 type User struct {
-	Id        int64     `orm:"auto" json:"id"`
-	Name      string    `orm:"size(128)" json:"name" valid:"Required"`
-	Email     string    `orm:"size(128)" json:"email" valid:"Required;Email"`
-	Password  string    `orm:"size(128)" json:"password" valid:"Required"`
-	CreatedAt time.Time `orm:"auto_now_add;type(datetime)" json:"createdAt"`
+	Id           int64        `orm:"auto" json:"id"`
+	Name         string       `orm:"size(128)" json:"name" valid:"Required"`
+	Email        string       `orm:"size(128)" json:"email" valid:"Required;Email"`
+	Password     string       `orm:"size(128)" json:"password" valid:"Required"`
+	MarginMode   MarginMode   `orm:"size(10);default(ISOLATED)" json:"marginMode"` // 槓桿保證金模式：ISOLATED 或 CROSS
+	PositionMode PositionMode `orm:"size(10);default(HEDGE)" json:"positionMode"`  // 持倉模式：ONE_WAY 或 HEDGE
+	CreatedAt    time.Time    `orm:"auto_now_add;type(datetime)" json:"createdAt"`
 }
 
 func init() {
@@ -32,6 +34,8 @@ func GetUserByEmail(email string) (user *User, err error) {
 // last inserted Id on success.
 func AddUser(m *User) (id int64, err error) {
 	o := orm.NewOrm()
+	m.MarginMode = MarginModeIsolated
+	m.PositionMode = PositionModeHedge
 	id, err = o.Insert(m)
 	return
 }
@@ -47,6 +51,20 @@ func GetUserById(id int64) (v *User, err error) {
 	return nil, err
 }
 
+// GetAllUserIds 查詢所有使用者的 ID（定期工作使用）
+func GetAllUserIds() ([]int64, error) {
+	o := orm.NewOrm()
+	var users []*User
+	if _, err := o.QueryTable(new(User)).OrderBy("Id").Limit(-1).All(&users, "Id"); err != nil {
+		return nil, err
+	}
+	ids := make([]int64, len(users))
+	for i, user := range users {
+		ids[i] = user.Id
+	}
+	return ids, nil
+}
+
 // GetAllUser retrieves all User matches certain condition. Returns empty list if
 // no records exist
 func GetAllUser(query map[string]string, fields []string, sortby []string, order []string,
@@ -128,6 +146,9 @@ func UpdateUserById(m *User) (err error) {
 	v := User{Id: m.Id}
 	// ascertain id exists in the database
 	if err = o.Read(&v); err == nil {
+		// 保證金模式與持倉模式只能透過 /v1/leverage 的對應介面切換（需檢查持倉）
+		m.MarginMode = v.MarginMode
+		m.PositionMode = v.PositionMode
 		var num int64
 		if num, err = o.Update(m); err == nil {
 			fmt.Println("Number of records updated in database:", num)
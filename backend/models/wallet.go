@@ -3,6 +3,7 @@ package models
 import (
 	"errors"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/beego/beego/v2/client/orm"
@@ -39,6 +40,21 @@ func GetWalletByUserAndSymbol(userId int64, symbol string) (*Wallet, error) {
 	return wallet, err
 }
 
+// GetWalletForUpdate 在交易中以 FOR UPDATE 鎖定並讀取錢包
+func GetWalletForUpdate(o orm.QueryExecutor, userId int64, symbol string) (*Wallet, error) {
+	wallet := &Wallet{}
+	err := o.QueryTable(new(Wallet)).
+		Filter("User__Id", userId).
+		Filter("Symbol", symbol).
+		ForUpdate().
+		One(wallet)
+
+	if err == orm.ErrNoRows {
+		return nil, errors.New("wallet not found")
+	}
+	return wallet, err
+}
+
 // GetAllWalletsByUser 查詢使用者所有錢包
 func GetAllWalletsByUser(userId int64) ([]*Wallet, error) {
 	o := orm.NewOrm()
@@ -84,8 +100,7 @@ func CreateWallet(userId int64, symbol string, initialBalance float64) (*Wallet,
 // UpdateBalance 更新餘額（需要在交易中使用）
 func UpdateBalance(o orm.QueryExecutor, walletId int64, balanceChange float64, lockedChange float64) error {
 	wallet := &Wallet{Id: walletId}
-	ormer := orm.NewOrm()
-	if err := ormer.Read(wallet); err != nil {
+	if err := o.ReadForUpdate(wallet); err != nil {
 		return err
 	}
 
@@ -111,6 +126,74 @@ func (w *Wallet) GetAvailableBalance() float64 {
 	return w.Balance - w.Locked
 }
 
+// walletAmountEpsilon 錢包金額的最小單位（資料表為 8 位小數）
+const walletAmountEpsilon = 1e-8
+
+// RoundWalletAmount 將金額四捨五入到錢包的精度（8 位小數），凍結與解凍使用相同的金額
+func RoundWalletAmount(amount float64) float64 {
+	return math.Round(amount/walletAmountEpsilon) * walletAmountEpsilon
+}
+
+// LockMargin 凍結保證金（限價槓桿單掛單時使用），餘額不變，只增加鎖定金額
+func (w *Wallet) LockMargin(amount float64) error {
+	amount = RoundWalletAmount(amount)
+	if amount <= 0 {
+		return errors.New("margin must be positive")
+	}
+	if w.GetAvailableBalance() < amount {
+		return fmt.Errorf("insufficient USDT balance: required %.2f, available %.2f", amount, w.GetAvailableBalance())
+	}
+	w.Locked += amount
+	return nil
+}
+
+// UnlockMargin 解除凍結的保證金（限價槓桿單取消或失敗時使用）
+// 金額四捨五入到錢包精度，與鎖定金額只差浮點誤差時解除全部鎖定金額
+func (w *Wallet) UnlockMargin(amount float64) error {
+	amount = RoundWalletAmount(amount)
+	if amount < 0 || w.Locked-amount < -walletAmountEpsilon/2 {
+		return errors.New("invalid locked amount")
+	}
+	w.Locked = math.Max(RoundWalletAmount(w.Locked-amount), 0)
+	return nil
+}
+
+// TransferMarginToPosition 將保證金從錢包轉入倉位的保證金帳戶
+// fromLocked 為 true 時表示扣除的是先前凍結的金額（限價單成交）
+func (w *Wallet) TransferMarginToPosition(amount float64, fromLocked bool) error {
+	if amount <= 0 {
+		return errors.New("margin must be positive")
+	}
+	if fromLocked {
+		if err := w.UnlockMargin(amount); err != nil {
+			return err
+		}
+	}
+	if w.GetAvailableBalance() < amount {
+		return fmt.Errorf("insufficient USDT balance: required %.2f, available %.2f", amount, w.GetAvailableBalance())
+	}
+	w.Balance -= amount
+	return nil
+}
+
+// ReceiveMarginFromPosition 倉位結算後將保證金帳戶的餘額返還錢包
+func (w *Wallet) ReceiveMarginFromPosition(amount float64) error {
+	if amount < 0 {
+		return errors.New("invalid settlement amount")
+	}
+	w.Balance += amount
+	return nil
+}
+
+// SettleCrossMargin 全倉倉位結算：返還保證金與盈虧，虧損超過保證金時從可用餘額扣除
+func (w *Wallet) SettleCrossMargin(amount float64) error {
+	if w.GetAvailableBalance()+amount < 0 {
+		return fmt.Errorf("insufficient USDT balance to cover loss: required %.2f, available %.2f", -amount, w.GetAvailableBalance())
+	}
+	w.Balance += amount
+	return nil
+}
+
 // InitializeDefaultWallets 為新使用者初始化預設錢包
 func InitializeDefaultWallets(userId int64) error {
 	symbols := []string{"USDT", "BTC", "ETH", "SOL"}
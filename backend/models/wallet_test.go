@@ -0,0 +1,146 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// TestTransferMarginToPosition 測試市價開倉：保證金直接從可用餘額轉入倉位
+func TestTransferMarginToPosition(t *testing.T) {
+	wallet := &Wallet{Symbol: "USDT", Balance: 1000}
+
+	if err := wallet.TransferMarginToPosition(200, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !almostEqual(wallet.Balance, 800) || wallet.Locked != 0 {
+		t.Errorf("expected balance 800 / locked 0, got %.2f / %.2f", wallet.Balance, wallet.Locked)
+	}
+
+	// 可用餘額不足
+	if err := wallet.TransferMarginToPosition(900, false); err == nil {
+		t.Error("expected insufficient balance error")
+	}
+	if !almostEqual(wallet.Balance, 800) {
+		t.Errorf("balance should be unchanged after failure, got %.2f", wallet.Balance)
+	}
+}
+
+// TestTransferMarginRespectsLocked 測試市價開倉不能動用已凍結的保證金
+func TestTransferMarginRespectsLocked(t *testing.T) {
+	wallet := &Wallet{Symbol: "USDT", Balance: 1000, Locked: 900}
+
+	if err := wallet.TransferMarginToPosition(200, false); err == nil {
+		t.Error("expected error when margin exceeds available balance")
+	}
+}
+
+// TestLimitOrderMarginLifecycle 測試限價槓桿單：掛單凍結 → 成交轉入倉位
+func TestLimitOrderMarginLifecycle(t *testing.T) {
+	wallet := &Wallet{Symbol: "USDT", Balance: 1000}
+
+	// 掛單：餘額不變，只凍結
+	if err := wallet.LockMargin(300); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !almostEqual(wallet.Balance, 1000) || !almostEqual(wallet.Locked, 300) {
+		t.Errorf("expected balance 1000 / locked 300, got %.2f / %.2f", wallet.Balance, wallet.Locked)
+	}
+	if !almostEqual(wallet.GetAvailableBalance(), 700) {
+		t.Errorf("expected available 700, got %.2f", wallet.GetAvailableBalance())
+	}
+
+	// 成交：凍結金額轉入倉位
+	if err := wallet.TransferMarginToPosition(300, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !almostEqual(wallet.Balance, 700) || !almostEqual(wallet.Locked, 0) {
+		t.Errorf("expected balance 700 / locked 0, got %.2f / %.2f", wallet.Balance, wallet.Locked)
+	}
+}
+
+// TestLimitOrderMarginCancel 測試限價槓桿單取消後解除凍結
+func TestLimitOrderMarginCancel(t *testing.T) {
+	wallet := &Wallet{Symbol: "USDT", Balance: 1000}
+
+	if err := wallet.LockMargin(1200); err == nil {
+		t.Error("expected error when locking more than available")
+	}
+
+	if err := wallet.LockMargin(400); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := wallet.UnlockMargin(400); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !almostEqual(wallet.Balance, 1000) || wallet.Locked != 0 {
+		t.Errorf("expected balance 1000 / locked 0, got %.2f / %.2f", wallet.Balance, wallet.Locked)
+	}
+
+	// 不能解除超過凍結的金額
+	if err := wallet.UnlockMargin(1); err == nil {
+		t.Error("expected error when unlocking more than locked")
+	}
+}
+
+// TestLimitOrderMarginRounding 測試無法整除的保證金：凍結的金額以錢包精度儲存，取消時可以完整解除
+func TestLimitOrderMarginRounding(t *testing.T) {
+	limitPrice := 30000.0
+	order := &Order{IsLeverageOrder: true, LimitPrice: limitPrice, Quantity: 0.0001, Leverage: 9}
+	wallet := &Wallet{Symbol: "USDT", Balance: 1000}
+
+	if err := wallet.LockMargin(CalculateRequiredMargin(limitPrice, 0.0001, 9)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !almostEqual(wallet.Locked, 0.33333333) {
+		t.Errorf("expected locked 0.33333333, got %.10f", wallet.Locked)
+	}
+
+	// 資料庫讀回的鎖定金額為 8 位小數
+	wallet.Locked = 0.33333333
+	if err := wallet.UnlockMargin(order.RequiredMargin()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wallet.Locked != 0 {
+		t.Errorf("expected locked 0, got %.10f", wallet.Locked)
+	}
+}
+
+// TestReceiveMarginFromPosition 測試結算金額返還錢包
+func TestReceiveMarginFromPosition(t *testing.T) {
+	wallet := &Wallet{Symbol: "USDT", Balance: 500}
+
+	if err := wallet.ReceiveMarginFromPosition(250); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !almostEqual(wallet.Balance, 750) {
+		t.Errorf("expected balance 750, got %.2f", wallet.Balance)
+	}
+
+	if err := wallet.ReceiveMarginFromPosition(-1); err == nil {
+		t.Error("expected error for negative settlement amount")
+	}
+}
+
+// TestSettleCrossMargin 測試全倉結算虧損由可用餘額承擔
+func TestSettleCrossMargin(t *testing.T) {
+	wallet := &Wallet{Symbol: "USDT", Balance: 1000, Locked: 300}
+
+	if err := wallet.SettleCrossMargin(-600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !almostEqual(wallet.Balance, 400) {
+		t.Errorf("expected balance 400, got %.2f", wallet.Balance)
+	}
+
+	// 不能動用掛單凍結的金額
+	if err := wallet.SettleCrossMargin(-200); err == nil {
+		t.Error("expected error when loss exceeds available balance")
+	}
+	if !almostEqual(wallet.Balance, 400) {
+		t.Errorf("balance should be unchanged after failure, got %.2f", wallet.Balance)
+	}
+}
@@ -0,0 +1,522 @@
+package models
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// WebhookEventType webhook 事件類型
+type WebhookEventType string
+
+const (
+	WebhookEventOrderFilled        WebhookEventType = "ORDER_FILLED"        // 訂單成交（現貨與槓桿的市價單、限價單、停損單），data 為訂單
+	WebhookEventPositionClosed     WebhookEventType = "POSITION_CLOSED"     // 使用者直接平倉或部分平倉（沒有訂單記錄），data 為平倉後的倉位
+	WebhookEventPositionLiquidated WebhookEventType = "POSITION_LIQUIDATED" // 倉位被強制平倉（逐倉或全倉），data 為倉位
+	WebhookEventPriceAlert         WebhookEventType = "PRICE_ALERT"         // 價格提醒觸發（提醒設定 webhook 時），data 與 PRICE_ALERT 消息相同
+)
+
+// WebhookEventTypes 所有可訂閱的事件類型
+var WebhookEventTypes = []WebhookEventType{WebhookEventOrderFilled, WebhookEventPositionClosed, WebhookEventPositionLiquidated, WebhookEventPriceAlert}
+
+// WebhookDeliveryStatus 投遞狀態
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "PENDING"   // 等待投遞或重試
+	WebhookDeliveryStatusDelivered WebhookDeliveryStatus = "DELIVERED" // 已送達（HTTP 2xx）
+	WebhookDeliveryStatusFailed    WebhookDeliveryStatus = "FAILED"    // 重試次數用完或端點已停用
+)
+
+const (
+	// MaxWebhookEndpointsPerUser 每位使用者的 webhook 端點上限
+	MaxWebhookEndpointsPerUser = 10
+	// MaxWebhookAttempts 每次投遞的最多嘗試次數
+	MaxWebhookAttempts = 8
+	// webhookRetryBaseDelay 第一次重試前的等待時間，之後每次加倍
+	webhookRetryBaseDelay = 10 * time.Second
+	// webhookRetryMaxDelay 重試間隔上限
+	webhookRetryMaxDelay = 1 * time.Hour
+	// webhookResolveTimeout 註冊端點時解析主機名稱的時間上限
+	webhookResolveTimeout = 5 * time.Second
+)
+
+// webhookBlockedNetworks IP 方法沒有涵蓋的內部網段：本網路位址與電信級 NAT 共享位址
+var webhookBlockedNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+}
+
+// WebhookEndpoint 使用者註冊的 webhook 端點，帳戶事件以簽章後的 POST 送出
+type WebhookEndpoint struct {
+	Id          int64              `orm:"auto" json:"id"`
+	User        *User              `orm:"rel(fk)" json:"-"`
+	Url         string             `orm:"size(500)" json:"url"`                        // 接收事件的網址
+	Secret      string             `orm:"size(100)" json:"-"`                          // HMAC-SHA256 簽章金鑰（只在建立時回傳）
+	EventTypes  string             `orm:"size(200)" json:"-"`                          // 訂閱的事件類型（逗號分隔，空白表示全部）
+	Events      []WebhookEventType `orm:"-" json:"eventTypes"`                         // 訂閱的事件類型（空白表示全部）
+	Description string             `orm:"size(200);null" json:"description,omitempty"` // 說明
+	Active      bool               `orm:"default(true)" json:"active"`                 // 停用時不再投遞
+	CreatedAt   time.Time          `orm:"auto_now_add;type(datetime)" json:"createdAt"`
+	UpdatedAt   time.Time          `orm:"auto_now;type(datetime)" json:"updatedAt"`
+}
+
+// WebhookEvent 待投遞的帳戶事件（outbox），與訂單或倉位的變更在同一個資料庫交易中寫入
+type WebhookEvent struct {
+	Id         int64            `orm:"auto" json:"id"`
+	User       *User            `orm:"rel(fk)" json:"-"`
+	Type       WebhookEventType `orm:"size(50)" json:"type"`
+	Payload    string           `orm:"type(text)" json:"-"`           // 事件資料（JSON）
+	Data       json.RawMessage  `orm:"-" json:"data,omitempty"`       // 事件資料
+	Dispatched bool             `orm:"default(false);index" json:"-"` // 是否已為訂閱的端點建立投遞
+	CreatedAt  time.Time        `orm:"auto_now_add;type(datetime)" json:"createdAt"`
+}
+
+// WebhookDelivery 事件對單一端點的投遞記錄
+type WebhookDelivery struct {
+	Id             int64                 `orm:"auto" json:"id"`
+	Endpoint       *WebhookEndpoint      `orm:"rel(fk)" json:"-"`
+	Event          *WebhookEvent         `orm:"rel(fk)" json:"event"`
+	Status         WebhookDeliveryStatus `orm:"size(20);index" json:"status"`                             // PENDING, DELIVERED or FAILED
+	Attempts       int                   `orm:"default(0)" json:"attempts"`                               // 已嘗試次數
+	NextAttemptAt  *time.Time            `orm:"null;type(datetime);index" json:"nextAttemptAt,omitempty"` // 下一次嘗試的時間（PENDING）
+	ResponseStatus int                   `orm:"default(0)" json:"responseStatus,omitempty"`               // 最近一次的 HTTP 狀態碼
+	LastError      string                `orm:"size(500);null" json:"lastError,omitempty"`                // 最近一次失敗的原因
+	DeliveredAt    *time.Time            `orm:"null;type(datetime)" json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time             `orm:"auto_now_add;type(datetime)" json:"createdAt"`
+	UpdatedAt      time.Time             `orm:"auto_now;type(datetime)" json:"updatedAt"`
+}
+
+func init() {
+	orm.RegisterModel(new(WebhookEndpoint), new(WebhookEvent), new(WebhookDelivery))
+}
+
+// TableName 指定資料表名稱
+func (e *WebhookEndpoint) TableName() string {
+	return "webhook_endpoint"
+}
+
+// TableName 指定資料表名稱
+func (e *WebhookEvent) TableName() string {
+	return "webhook_event"
+}
+
+// TableName 指定資料表名稱
+func (d *WebhookDelivery) TableName() string {
+	return "webhook_delivery"
+}
+
+// WebhookEndpointParams 建立或修改 webhook 端點的參數
+type WebhookEndpointParams struct {
+	Url         string
+	EventTypes  []WebhookEventType
+	Description string
+	Active      bool
+}
+
+// Validate 驗證 webhook 端點參數
+func (p WebhookEndpointParams) Validate() error {
+	u, err := url.Parse(p.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("webhook URL must be an absolute http or https URL")
+	}
+	if err := checkWebhookHost(u.Hostname()); err != nil {
+		return err
+	}
+	for _, eventType := range p.EventTypes {
+		if !isWebhookEventType(eventType) {
+			return fmt.Errorf("unknown event type: %s", eventType)
+		}
+	}
+	return nil
+}
+
+// checkWebhookHost 解析主機名稱，任一位址為內部位址時拒絕
+// 投遞時解析結果可能不同（DNS rebinding），投遞服務連線時會再檢查實際連線的位址
+func checkWebhookHost(host string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookResolveTimeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("failed to resolve webhook host: %s", host)
+	}
+	for _, addr := range addrs {
+		if !IsPublicWebhookIP(addr.IP) {
+			return errors.New("webhook URL must not point to a loopback, private or link-local address")
+		}
+	}
+	return nil
+}
+
+// IsPublicWebhookIP 是否為允許投遞的位址
+// 拒絕迴路、私有、鏈路本地（包括雲端中繼資料服務 169.254.169.254）、未指定與多播位址
+func IsPublicWebhookIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, network := range webhookBlockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// mustParseCIDR 解析固定的網段
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// Apply 將參數寫入端點（參數需先驗證）
+func (e *WebhookEndpoint) Apply(params WebhookEndpointParams) {
+	e.Url = params.Url
+	e.Description = params.Description
+	e.Active = params.Active
+	e.Events = params.EventTypes
+
+	types := make([]string, len(params.EventTypes))
+	for i, eventType := range params.EventTypes {
+		types[i] = string(eventType)
+	}
+	e.EventTypes = strings.Join(types, ",")
+}
+
+// Subscribes 端點是否訂閱事件類型
+func (e *WebhookEndpoint) Subscribes(eventType WebhookEventType) bool {
+	if e.EventTypes == "" {
+		return true
+	}
+	for _, subscribed := range strings.Split(e.EventTypes, ",") {
+		if WebhookEventType(subscribed) == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeEvents 解析訂閱的事件類型
+func (e *WebhookEndpoint) decodeEvents() {
+	e.Events = []WebhookEventType{}
+	if e.EventTypes == "" {
+		return
+	}
+	for _, eventType := range strings.Split(e.EventTypes, ",") {
+		e.Events = append(e.Events, WebhookEventType(eventType))
+	}
+}
+
+// isWebhookEventType 是否為可訂閱的事件類型
+func isWebhookEventType(eventType WebhookEventType) bool {
+	for _, known := range WebhookEventTypes {
+		if eventType == known {
+			return true
+		}
+	}
+	return false
+}
+
+// NewWebhookSecret 產生簽章金鑰
+func NewWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// SignWebhookPayload 計算簽章：HMAC-SHA256(secret, "{timestamp}.{body}") 的十六進位字串
+// 接收端以相同方式計算並比對 X-Webhook-Signature，並檢查 X-Webhook-Timestamp 避免重放
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookBody 投遞的內容：事件 ID、類型、時間與資料
+func (e *WebhookEvent) WebhookBody() []byte {
+	body, _ := json.Marshal(map[string]interface{}{
+		"id":        e.Id,
+		"type":      e.Type,
+		"createdAt": e.CreatedAt,
+		"data":      json.RawMessage(e.Payload),
+	})
+	return body
+}
+
+// WebhookRetryDelay 第 attempts 次失敗後到下一次重試的間隔（指數退避）
+func WebhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryBaseDelay
+	for i := 1; i < attempts && delay < webhookRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > webhookRetryMaxDelay {
+		delay = webhookRetryMaxDelay
+	}
+	return delay
+}
+
+// RecordAttempt 記錄一次投遞結果：成功時標記送達，失敗時排定重試，次數用完則標記失敗
+func (d *WebhookDelivery) RecordAttempt(responseStatus int, err error, now time.Time) {
+	d.Attempts++
+	d.ResponseStatus = responseStatus
+	if err == nil {
+		d.Status = WebhookDeliveryStatusDelivered
+		d.DeliveredAt = &now
+		d.NextAttemptAt = nil
+		d.LastError = ""
+		return
+	}
+
+	d.LastError = err.Error()
+	if len(d.LastError) > 500 {
+		d.LastError = d.LastError[:500]
+	}
+	if d.Attempts >= MaxWebhookAttempts {
+		d.Status = WebhookDeliveryStatusFailed
+		d.NextAttemptAt = nil
+		return
+	}
+	next := now.Add(WebhookRetryDelay(d.Attempts))
+	d.NextAttemptAt = &next
+}
+
+// Fail 不再投遞（例如端點已停用或刪除）
+func (d *WebhookDelivery) Fail(reason string) {
+	d.Status = WebhookDeliveryStatusFailed
+	d.NextAttemptAt = nil
+	d.LastError = reason
+}
+
+// EnqueueWebhookEvent 寫入待投遞的事件（需要在產生事件的交易中使用），使用者沒有啟用中的端點時不寫入
+func EnqueueWebhookEvent(o orm.QueryExecutor, userId int64, eventType WebhookEventType, data interface{}) error {
+	if !o.QueryTable(new(WebhookEndpoint)).Filter("User__Id", userId).Filter("Active", true).Exist() {
+		return nil
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = o.Insert(&WebhookEvent{
+		User:    &User{Id: userId},
+		Type:    eventType,
+		Payload: string(payload),
+	})
+	return err
+}
+
+// EnqueueOrderFilledWebhook 寫入訂單成交事件（需要在更新訂單狀態的交易中使用）
+func EnqueueOrderFilledWebhook(o orm.QueryExecutor, userId int64, orderId int64) error {
+	order := &Order{Id: orderId}
+	if err := o.Read(order); err != nil {
+		return err
+	}
+	return EnqueueWebhookEvent(o, userId, WebhookEventOrderFilled, order)
+}
+
+// CreateWebhookEndpoint 寫入 webhook 端點
+func CreateWebhookEndpoint(e *WebhookEndpoint) error {
+	o := orm.NewOrm()
+	id, err := o.Insert(e)
+	if err != nil {
+		return err
+	}
+	e.Id = id
+	return nil
+}
+
+// SaveWebhookEndpoint 寫回端點設定
+func SaveWebhookEndpoint(e *WebhookEndpoint) error {
+	o := orm.NewOrm()
+	_, err := o.Update(e, "Url", "EventTypes", "Description", "Active", "UpdatedAt")
+	return err
+}
+
+// DeleteWebhookEndpoint 刪除端點與其投遞記錄
+func DeleteWebhookEndpoint(id int64) error {
+	o := orm.NewOrm()
+	to, err := o.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err = to.QueryTable(new(WebhookDelivery)).Filter("Endpoint__Id", id).Delete(); err != nil {
+		to.Rollback()
+		return err
+	}
+	if _, err = to.Delete(&WebhookEndpoint{Id: id}); err != nil {
+		to.Rollback()
+		return err
+	}
+	return to.Commit()
+}
+
+// GetWebhookEndpointById 根據 ID 查詢端點
+func GetWebhookEndpointById(id int64) (*WebhookEndpoint, error) {
+	o := orm.NewOrm()
+	e := &WebhookEndpoint{Id: id}
+	if err := o.Read(e); err != nil {
+		if err == orm.ErrNoRows {
+			return nil, errors.New("webhook endpoint not found")
+		}
+		return nil, err
+	}
+	e.decodeEvents()
+	return e, nil
+}
+
+// GetWebhookEndpointsByUser 查詢使用者的端點
+func GetWebhookEndpointsByUser(userId int64) ([]*WebhookEndpoint, error) {
+	o := orm.NewOrm()
+	var endpoints []*WebhookEndpoint
+	_, err := o.QueryTable(new(WebhookEndpoint)).
+		Filter("User__Id", userId).
+		OrderBy("Id").
+		All(&endpoints)
+	for _, e := range endpoints {
+		e.decodeEvents()
+	}
+	return endpoints, err
+}
+
+// CountWebhookEndpointsByUser 計算使用者的端點數量
+func CountWebhookEndpointsByUser(userId int64) (int64, error) {
+	o := orm.NewOrm()
+	return o.QueryTable(new(WebhookEndpoint)).Filter("User__Id", userId).Count()
+}
+
+// GetUndispatchedWebhookEvents 查詢尚未建立投遞的事件（依寫入順序）
+func GetUndispatchedWebhookEvents(limit int) ([]*WebhookEvent, error) {
+	o := orm.NewOrm()
+	var events []*WebhookEvent
+	_, err := o.QueryTable(new(WebhookEvent)).
+		Filter("Dispatched", false).
+		OrderBy("Id").
+		Limit(limit).
+		All(&events)
+	return events, err
+}
+
+// DispatchWebhookEvent 為事件建立各端點的投遞並標記已處理，沒有訂閱的端點時直接刪除事件
+// 事件已被處理時回傳 false
+func DispatchWebhookEvent(event *WebhookEvent, endpoints []*WebhookEndpoint, now time.Time) (bool, error) {
+	o := orm.NewOrm()
+	to, err := o.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	qs := to.QueryTable(new(WebhookEvent)).Filter("Id", event.Id).Filter("Dispatched", false)
+	var claimed int64
+	if len(endpoints) == 0 {
+		claimed, err = qs.Delete()
+	} else {
+		claimed, err = qs.Update(orm.Params{"Dispatched": true})
+	}
+	if err != nil || claimed == 0 {
+		to.Rollback()
+		return false, err
+	}
+
+	for _, endpoint := range endpoints {
+		if _, err = to.Insert(newWebhookDelivery(endpoint, event, now)); err != nil {
+			to.Rollback()
+			return false, err
+		}
+	}
+	return true, to.Commit()
+}
+
+// newWebhookDelivery 建立等待投遞的記錄
+func newWebhookDelivery(endpoint *WebhookEndpoint, event *WebhookEvent, now time.Time) *WebhookDelivery {
+	return &WebhookDelivery{
+		Endpoint:      endpoint,
+		Event:         event,
+		Status:        WebhookDeliveryStatusPending,
+		NextAttemptAt: &now,
+	}
+}
+
+// RedeliverWebhook 以同一個事件建立新的投遞（手動重送），原本的記錄保留
+func RedeliverWebhook(delivery *WebhookDelivery, now time.Time) (*WebhookDelivery, error) {
+	o := orm.NewOrm()
+	redelivery := newWebhookDelivery(delivery.Endpoint, delivery.Event, now)
+	id, err := o.Insert(redelivery)
+	if err != nil {
+		return nil, err
+	}
+	redelivery.Id = id
+	return redelivery, nil
+}
+
+// GetDueWebhookDeliveries 查詢到期的投遞（含端點與事件）
+func GetDueWebhookDeliveries(now time.Time, limit int) ([]*WebhookDelivery, error) {
+	o := orm.NewOrm()
+	var deliveries []*WebhookDelivery
+	_, err := o.QueryTable(new(WebhookDelivery)).
+		Filter("Status", WebhookDeliveryStatusPending).
+		Filter("NextAttemptAt__lte", now).
+		RelatedSel("Endpoint", "Event").
+		OrderBy("NextAttemptAt").
+		Limit(limit).
+		All(&deliveries)
+	return deliveries, err
+}
+
+// SaveWebhookDelivery 寫回投遞結果
+func SaveWebhookDelivery(d *WebhookDelivery) error {
+	o := orm.NewOrm()
+	_, err := o.Update(d, "Status", "Attempts", "NextAttemptAt", "ResponseStatus", "LastError", "DeliveredAt", "UpdatedAt")
+	return err
+}
+
+// GetWebhookDeliveryById 根據 ID 查詢投遞（含事件）
+func GetWebhookDeliveryById(id int64) (*WebhookDelivery, error) {
+	o := orm.NewOrm()
+	d := &WebhookDelivery{}
+	err := o.QueryTable(new(WebhookDelivery)).Filter("Id", id).RelatedSel("Event").One(d)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			return nil, errors.New("webhook delivery not found")
+		}
+		return nil, err
+	}
+	d.Event.Data = json.RawMessage(d.Event.Payload)
+	return d, nil
+}
+
+// GetWebhookDeliveries 查詢端點的投遞記錄（由新到舊，含事件），status 為空表示全部
+func GetWebhookDeliveries(endpointId int64, status WebhookDeliveryStatus, limit int, offset int) ([]*WebhookDelivery, error) {
+	o := orm.NewOrm()
+	qs := o.QueryTable(new(WebhookDelivery)).Filter("Endpoint__Id", endpointId)
+	if status != "" {
+		qs = qs.Filter("Status", status)
+	}
+	var deliveries []*WebhookDelivery
+	_, err := qs.RelatedSel("Event").OrderBy("-Id").Limit(limit, offset).All(&deliveries)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deliveries {
+		d.Event.Data = json.RawMessage(d.Event.Payload)
+	}
+	return deliveries, nil
+}
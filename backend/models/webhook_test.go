@@ -0,0 +1,111 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSignWebhookPayload(t *testing.T) {
+	// echo -n '1700000000.{"id":1}' | openssl dgst -sha256 -hmac whsec_test
+	const want = "2f441ba4b3b2d50d28a9ab9d9fd8880376ecd1eb5d0435401553f5d8d0a5dcf8"
+	got := SignWebhookPayload("whsec_test", 1700000000, []byte(`{"id":1}`))
+	if got != want {
+		t.Errorf("SignWebhookPayload() = %s, want %s", got, want)
+	}
+	if other := SignWebhookPayload("whsec_other", 1700000000, []byte(`{"id":1}`)); other == got {
+		t.Error("different secrets should produce different signatures")
+	}
+	if other := SignWebhookPayload("whsec_test", 1700000001, []byte(`{"id":1}`)); other == got {
+		t.Error("different timestamps should produce different signatures")
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{7, 640 * time.Second},
+		{20, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := WebhookRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("WebhookRetryDelay(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookDeliveryRecordAttempt(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	delivery := &WebhookDelivery{Status: WebhookDeliveryStatusPending, NextAttemptAt: &now}
+
+	delivery.RecordAttempt(500, errors.New("webhook responded with HTTP 500"), now)
+	if delivery.Status != WebhookDeliveryStatusPending || delivery.Attempts != 1 {
+		t.Fatalf("after failure: status %s, attempts %d", delivery.Status, delivery.Attempts)
+	}
+	if !delivery.NextAttemptAt.Equal(now.Add(10 * time.Second)) {
+		t.Errorf("next attempt at %s, want 10s later", delivery.NextAttemptAt)
+	}
+
+	delivery.RecordAttempt(200, nil, now.Add(10*time.Second))
+	if delivery.Status != WebhookDeliveryStatusDelivered || delivery.DeliveredAt == nil || delivery.NextAttemptAt != nil || delivery.LastError != "" {
+		t.Errorf("after success: %+v", delivery)
+	}
+
+	failing := &WebhookDelivery{Status: WebhookDeliveryStatusPending}
+	for i := 0; i < MaxWebhookAttempts; i++ {
+		failing.RecordAttempt(0, errors.New("connection refused"), now)
+	}
+	if failing.Status != WebhookDeliveryStatusFailed || failing.NextAttemptAt != nil {
+		t.Errorf("after %d failures: status %s, next attempt %v", MaxWebhookAttempts, failing.Status, failing.NextAttemptAt)
+	}
+}
+
+func TestWebhookEndpointSubscribes(t *testing.T) {
+	all := &WebhookEndpoint{}
+	all.Apply(WebhookEndpointParams{Url: "https://example.com/hook"})
+	if !all.Subscribes(WebhookEventPriceAlert) || !all.Subscribes(WebhookEventOrderFilled) {
+		t.Error("endpoint without filters should receive every event type")
+	}
+
+	filtered := &WebhookEndpoint{}
+	filtered.Apply(WebhookEndpointParams{
+		Url:        "https://example.com/hook",
+		EventTypes: []WebhookEventType{WebhookEventOrderFilled, WebhookEventPositionLiquidated},
+	})
+	if filtered.EventTypes != "ORDER_FILLED,POSITION_LIQUIDATED" {
+		t.Errorf("EventTypes = %q", filtered.EventTypes)
+	}
+	if !filtered.Subscribes(WebhookEventPositionLiquidated) || filtered.Subscribes(WebhookEventPriceAlert) {
+		t.Error("filtered endpoint should only receive subscribed event types")
+	}
+}
+
+func TestWebhookEndpointParamsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  WebhookEndpointParams
+		wantErr bool
+	}{
+		{"valid", WebhookEndpointParams{Url: "https://93.184.215.14/hook", EventTypes: []WebhookEventType{WebhookEventOrderFilled}}, false},
+		{"relative URL", WebhookEndpointParams{Url: "/hook"}, true},
+		{"unknown event type", WebhookEndpointParams{Url: "https://93.184.215.14/hook", EventTypes: []WebhookEventType{"WITHDRAW"}}, true},
+		{"loopback", WebhookEndpointParams{Url: "http://127.0.0.1:8080/hook"}, true},
+		{"localhost", WebhookEndpointParams{Url: "http://localhost/hook"}, true},
+		{"IPv6 loopback", WebhookEndpointParams{Url: "http://[::1]/hook"}, true},
+		{"private", WebhookEndpointParams{Url: "https://10.0.0.5/hook"}, true},
+		{"cloud metadata", WebhookEndpointParams{Url: "http://169.254.169.254/latest/meta-data"}, true},
+		{"unspecified", WebhookEndpointParams{Url: "http://0.0.0.0/hook"}, true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.params.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
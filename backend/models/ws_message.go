@@ -13,14 +13,22 @@ const (
 	WSMessageTypeLimitOrderFilled       WSMessageType = "LIMIT_ORDER_FILLED"       // 限價單成交
 	WSMessageTypeLeveragePositionOpened WSMessageType = "LEVERAGE_POSITION_OPENED" // 槓桿位置開倉
 	WSMessageTypeLeveragePositionClosed WSMessageType = "LEVERAGE_POSITION_CLOSED" // 槓桿位置平倉
+	WSMessageTypeLeveragePositionUpdate WSMessageType = "LEVERAGE_POSITION_UPDATE" // 槓桿位置變更（保證金、數量等）
+	WSMessageTypeFundingPayment         WSMessageType = "FUNDING_PAYMENT"          // 資金費用收付
+	WSMessageTypeMarginCall             WSMessageType = "MARGIN_CALL"              // 爆倉警告
+	WSMessageTypeAlgoOrderUpdate        WSMessageType = "ALGO_ORDER_UPDATE"        // 演算法母單進度
+	WSMessageTypeGridBotUpdate          WSMessageType = "GRID_BOT_UPDATE"          // 網格機器人成交或狀態變更
+	WSMessageTypeStrategyBotUpdate      WSMessageType = "STRATEGY_BOT_UPDATE"      // 策略機器人狀態變更
+	WSMessageTypePriceAlert             WSMessageType = "PRICE_ALERT"              // 價格提醒觸發
 	WSMessageTypeError                  WSMessageType = "ERROR"                    // 錯誤
 )
 
 // WSMessage WebSocket 消息基礎結構
 type WSMessage struct {
-	Type      WSMessageType `json:"type"`      // 消息類型
-	Timestamp time.Time     `json:"timestamp"` // 時間戳
-	Data      interface{}   `json:"data"`      // 數據
+	EventId   int64         `json:"eventId,omitempty"` // 事件 ID（經由 outbox 推送的消息才有，每個 ID 只推送一次）
+	Type      WSMessageType `json:"type"`              // 消息類型
+	Timestamp time.Time     `json:"timestamp"`         // 時間戳
+	Data      interface{}   `json:"data"`              // 數據
 }
 
 // OrderExecutedData 訂單成交數據
@@ -73,6 +81,74 @@ type LeveragePositionClosedData struct {
 	Status        string  `json:"status"`        // 位置狀態
 }
 
+// FundingPaymentData 資金費用收付數據
+type FundingPaymentData struct {
+	PositionId  int64     `json:"positionId"`  // 位置 ID
+	Symbol      string    `json:"symbol"`      // 交易對
+	Side        string    `json:"side"`        // LONG 或 SHORT
+	Quantity    float64   `json:"quantity"`    // 數量
+	MarkPrice   float64   `json:"markPrice"`   // 標記價格
+	FundingRate float64   `json:"fundingRate"` // 資金費率
+	Amount      float64   `json:"amount"`      // 金額（正數收取，負數支付）
+	Margin      float64   `json:"margin"`      // 收付後保證金
+	FundingTime time.Time `json:"fundingTime"` // 結算時間
+}
+
+// MarginCallData 爆倉警告數據
+type MarginCallData struct {
+	PositionId        int64      `json:"positionId,omitempty"` // 位置 ID（全倉帳戶警告時為空）
+	Symbol            string     `json:"symbol,omitempty"`     // 交易對（全倉帳戶警告時為空）
+	Side              string     `json:"side,omitempty"`       // LONG 或 SHORT
+	MarginMode        MarginMode `json:"marginMode"`           // ISOLATED 或 CROSS
+	Level             int        `json:"level"`                // 警告等級（第幾個門檻）
+	Threshold         float64    `json:"threshold"`            // 觸發的門檻（距離爆倉的進度）
+	Progress          float64    `json:"progress"`             // 目前距離爆倉的進度（1 表示爆倉）
+	MarkPrice         float64    `json:"markPrice,omitempty"`  // 標記價格
+	LiquidationPrice  float64    `json:"liquidationPrice"`     // 爆倉價格（全倉為 0）
+	Equity            float64    `json:"equity"`               // 保證金 + 未實現盈虧（全倉為帳戶總權益）
+	MaintenanceMargin float64    `json:"maintenanceMargin"`    // 維持保證金
+}
+
+// AlgoOrderUpdateData 演算法母單進度數據
+type AlgoOrderUpdateData struct {
+	AlgoOrderId    int64   `json:"algoOrderId"`            // 母單 ID
+	Algo           string  `json:"algo"`                   // TWAP 或 ICEBERG
+	Symbol         string  `json:"symbol"`                 // 交易對
+	Side           string  `json:"side"`                   // 買入或賣出
+	Quantity       float64 `json:"quantity"`               // 總數量
+	FilledQuantity float64 `json:"filledQuantity"`         // 已成交數量
+	ExecutedAmount float64 `json:"executedAmount"`         // 已成交總金額
+	ChildCount     int     `json:"childCount"`             // 已成交的子訂單數量
+	ChildOrderId   int64   `json:"childOrderId,omitempty"` // 本次成交或掛出的子訂單 ID
+	Status         string  `json:"status"`                 // 母單狀態
+	ErrorMsg       string  `json:"errorMsg,omitempty"`     // 失敗原因
+}
+
+// GridBotUpdateData 網格機器人成交或狀態變更數據
+type GridBotUpdateData struct {
+	BotId           int64   `json:"botId"`                   // 機器人 ID
+	Symbol          string  `json:"symbol"`                  // 交易對
+	Status          string  `json:"status"`                  // 機器人狀態
+	RealizedProfit  float64 `json:"realizedProfit"`          // 已實現的網格利潤
+	CompletedCycles int     `json:"completedCycles"`         // 已成交的賣單數量
+	FilledOrderId   int64   `json:"filledOrderId,omitempty"` // 本次成交的訂單 ID
+	FilledSide      string  `json:"filledSide,omitempty"`    // 本次成交的方向
+	FilledPrice     float64 `json:"filledPrice,omitempty"`   // 本次成交的價位
+	Profit          float64 `json:"profit,omitempty"`        // 本次成交的利潤（僅賣單）
+	ErrorMsg        string  `json:"errorMsg,omitempty"`      // 失敗原因
+}
+
+// StrategyBotUpdateData 策略機器人狀態變更數據
+type StrategyBotUpdateData struct {
+	BotId      int64  `json:"botId"`                // 機器人 ID
+	Strategy   string `json:"strategy"`             // 策略名稱
+	Symbol     string `json:"symbol"`               // 交易對
+	Status     string `json:"status"`               // 機器人狀態
+	StopReason string `json:"stopReason,omitempty"` // 停止原因
+	OrderCount int    `json:"orderCount"`           // 已送出的訂單數量
+	LastError  string `json:"lastError,omitempty"`  // 最近一次下單失敗的原因
+}
+
 // NewOrderExecutedMessage 創建訂單成交消息
 func NewOrderExecutedMessage(order *Order) *WSMessage {
 	return &WSMessage{
@@ -127,6 +203,13 @@ func NewLeveragePositionOpenedMessage(position *LeveragePosition) *WSMessage {
 	}
 }
 
+// NewLeveragePositionUpdateMessage 創建槓桿位置變更消息
+func NewLeveragePositionUpdateMessage(position *LeveragePosition) *WSMessage {
+	message := NewLeveragePositionOpenedMessage(position)
+	message.Type = WSMessageTypeLeveragePositionUpdate
+	return message
+}
+
 // NewLeveragePositionClosedMessage 創建槓桿位置平倉消息
 func NewLeveragePositionClosedMessage(position *LeveragePosition, exitPrice float64) *WSMessage {
 	pnl := position.CalculateUnrealizedPnL(exitPrice)
@@ -150,6 +233,131 @@ func NewLeveragePositionClosedMessage(position *LeveragePosition, exitPrice floa
 	}
 }
 
+// NewFundingPaymentMessage 創建資金費用收付消息
+func NewFundingPaymentMessage(position *LeveragePosition, fundingRate *FundingRate, amount float64) *WSMessage {
+	return &WSMessage{
+		Type:      WSMessageTypeFundingPayment,
+		Timestamp: time.Now(),
+		Data: &FundingPaymentData{
+			PositionId:  position.Id,
+			Symbol:      position.Symbol,
+			Side:        string(position.Side),
+			Quantity:    position.Quantity,
+			MarkPrice:   fundingRate.MarkPrice,
+			FundingRate: fundingRate.Rate,
+			Amount:      amount,
+			Margin:      position.Margin,
+			FundingTime: fundingRate.FundingTime,
+		},
+	}
+}
+
+// NewMarginCallMessage 創建爆倉警告消息
+func NewMarginCallMessage(data *MarginCallData) *WSMessage {
+	return &WSMessage{
+		Type:      WSMessageTypeMarginCall,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+}
+
+// NewAlgoOrderUpdateMessage 創建演算法母單進度消息
+func NewAlgoOrderUpdateMessage(algo *AlgoOrder, childOrderId int64) *WSMessage {
+	return &WSMessage{
+		Type:      WSMessageTypeAlgoOrderUpdate,
+		Timestamp: time.Now(),
+		Data: AlgoOrderUpdateData{
+			AlgoOrderId:    algo.Id,
+			Algo:           string(algo.Algo),
+			Symbol:         algo.Symbol,
+			Side:           string(algo.Side),
+			Quantity:       algo.Quantity,
+			FilledQuantity: algo.FilledQuantity,
+			ExecutedAmount: algo.ExecutedAmount,
+			ChildCount:     algo.ChildCount,
+			ChildOrderId:   childOrderId,
+			Status:         string(algo.Status),
+			ErrorMsg:       algo.ErrorMsg,
+		},
+	}
+}
+
+// NewGridBotUpdateMessage 創建網格機器人消息，filled 為 nil 表示只有狀態變更
+func NewGridBotUpdateMessage(bot *GridBot, filled *GridBotOrder, profit float64) *WSMessage {
+	data := GridBotUpdateData{
+		BotId:           bot.Id,
+		Symbol:          bot.Symbol,
+		Status:          string(bot.Status),
+		RealizedProfit:  bot.RealizedProfit,
+		CompletedCycles: bot.CompletedCycles,
+		Profit:          profit,
+		ErrorMsg:        bot.ErrorMsg,
+	}
+	if filled != nil {
+		data.FilledOrderId = filled.OrderId
+		data.FilledSide = string(filled.Side)
+		data.FilledPrice = filled.Price
+	}
+	return &WSMessage{
+		Type:      WSMessageTypeGridBotUpdate,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+}
+
+// NewStrategyBotUpdateMessage 創建策略機器人狀態變更消息
+func NewStrategyBotUpdateMessage(bot *StrategyBot) *WSMessage {
+	return &WSMessage{
+		Type:      WSMessageTypeStrategyBotUpdate,
+		Timestamp: time.Now(),
+		Data: &StrategyBotUpdateData{
+			BotId:      bot.Id,
+			Strategy:   bot.Strategy,
+			Symbol:     bot.Symbol,
+			Status:     string(bot.Status),
+			StopReason: bot.StopReason,
+			OrderCount: bot.OrderCount,
+			LastError:  bot.LastError,
+		},
+	}
+}
+
+// PriceAlertData 價格提醒觸發數據
+type PriceAlertData struct {
+	AlertId        int64   `json:"alertId"`                  // 提醒 ID
+	Symbol         string  `json:"symbol"`                   // 交易對
+	Condition      string  `json:"condition"`                // ABOVE, BELOW, CROSS or PERCENT_CHANGE
+	TargetPrice    float64 `json:"targetPrice,omitempty"`    // 目標價
+	ChangePercent  float64 `json:"changePercent,omitempty"`  // 漲跌幅條件（%）
+	WindowSeconds  int     `json:"windowSeconds,omitempty"`  // 漲跌幅的時間窗口秒數
+	Price          float64 `json:"price"`                    // 觸發時的價格
+	ReferencePrice float64 `json:"referencePrice,omitempty"` // 時間窗口起點的價格（PERCENT_CHANGE）
+	Repeat         bool    `json:"repeat"`                   // 是否重複提醒
+	TriggerCount   int     `json:"triggerCount"`             // 已觸發次數
+	Note           string  `json:"note,omitempty"`           // 備註
+}
+
+// NewPriceAlertMessage 創建價格提醒觸發消息
+func NewPriceAlertMessage(alert *PriceAlert, price float64, referencePrice float64, triggeredAt time.Time) *WSMessage {
+	return &WSMessage{
+		Type:      WSMessageTypePriceAlert,
+		Timestamp: triggeredAt,
+		Data: PriceAlertData{
+			AlertId:        alert.Id,
+			Symbol:         alert.Symbol,
+			Condition:      string(alert.Condition),
+			TargetPrice:    alert.TargetPrice,
+			ChangePercent:  alert.ChangePercent,
+			WindowSeconds:  alert.WindowSeconds,
+			Price:          price,
+			ReferencePrice: referencePrice,
+			Repeat:         alert.Repeat,
+			TriggerCount:   alert.TriggerCount,
+			Note:           alert.Note,
+		},
+	}
+}
+
 // ToJSON 將消息轉換為 JSON
 func (m *WSMessage) ToJSON() []byte {
 	data, _ := json.Marshal(m)
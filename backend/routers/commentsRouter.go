@@ -7,6 +7,15 @@ import (
 
 func init() {
 
+    beego.GlobalControllerRouter["backend/controllers:AdminController"] = append(beego.GlobalControllerRouter["backend/controllers:AdminController"],
+        beego.ControllerComments{
+            Method: "GetInsuranceFund",
+            Router: `/insurance-fund`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
     beego.GlobalControllerRouter["backend/controllers:AuthController"] = append(beego.GlobalControllerRouter["backend/controllers:AuthController"],
         beego.ControllerComments{
             Method: "GetAll",
@@ -61,6 +70,114 @@ func init() {
             Filters: nil,
             Params: nil})
 
+    beego.GlobalControllerRouter["backend/controllers:BacktestController"] = append(beego.GlobalControllerRouter["backend/controllers:BacktestController"],
+        beego.ControllerComments{
+            Method: "CreateBacktest",
+            Router: `/`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:BacktestController"] = append(beego.GlobalControllerRouter["backend/controllers:BacktestController"],
+        beego.ControllerComments{
+            Method: "GetBacktests",
+            Router: `/`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:BacktestController"] = append(beego.GlobalControllerRouter["backend/controllers:BacktestController"],
+        beego.ControllerComments{
+            Method: "GetBacktest",
+            Router: `/:id`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:BacktestController"] = append(beego.GlobalControllerRouter["backend/controllers:BacktestController"],
+        beego.ControllerComments{
+            Method: "GetBacktestStrategies",
+            Router: `/strategies`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:GridBotController"] = append(beego.GlobalControllerRouter["backend/controllers:GridBotController"],
+        beego.ControllerComments{
+            Method: "CreateGridBot",
+            Router: `/`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:GridBotController"] = append(beego.GlobalControllerRouter["backend/controllers:GridBotController"],
+        beego.ControllerComments{
+            Method: "GetGridBots",
+            Router: `/`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:GridBotController"] = append(beego.GlobalControllerRouter["backend/controllers:GridBotController"],
+        beego.ControllerComments{
+            Method: "GetGridBot",
+            Router: `/:id`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:GridBotController"] = append(beego.GlobalControllerRouter["backend/controllers:GridBotController"],
+        beego.ControllerComments{
+            Method: "StopGridBot",
+            Router: `/:id/stop`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:LeverageController"] = append(beego.GlobalControllerRouter["backend/controllers:LeverageController"],
+        beego.ControllerComments{
+            Method: "GetMarginMode",
+            Router: `/margin-mode`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:LeverageController"] = append(beego.GlobalControllerRouter["backend/controllers:LeverageController"],
+        beego.ControllerComments{
+            Method: "SetMarginMode",
+            Router: `/margin-mode`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:LeverageController"] = append(beego.GlobalControllerRouter["backend/controllers:LeverageController"],
+        beego.ControllerComments{
+            Method: "GetPositionMode",
+            Router: `/position-mode`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:LeverageController"] = append(beego.GlobalControllerRouter["backend/controllers:LeverageController"],
+        beego.ControllerComments{
+            Method: "SetPositionMode",
+            Router: `/position-mode`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
     beego.GlobalControllerRouter["backend/controllers:LeverageController"] = append(beego.GlobalControllerRouter["backend/controllers:LeverageController"],
         beego.ControllerComments{
             Method: "GetPositionDetail",
@@ -70,6 +187,15 @@ func init() {
             Filters: nil,
             Params: nil})
 
+    beego.GlobalControllerRouter["backend/controllers:LeverageController"] = append(beego.GlobalControllerRouter["backend/controllers:LeverageController"],
+        beego.ControllerComments{
+            Method: "GetPositionChanges",
+            Router: `/position/:id/changes`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
     beego.GlobalControllerRouter["backend/controllers:LeverageController"] = append(beego.GlobalControllerRouter["backend/controllers:LeverageController"],
         beego.ControllerComments{
             Method: "ClosePosition",
@@ -79,6 +205,24 @@ func init() {
             Filters: nil,
             Params: nil})
 
+    beego.GlobalControllerRouter["backend/controllers:LeverageController"] = append(beego.GlobalControllerRouter["backend/controllers:LeverageController"],
+        beego.ControllerComments{
+            Method: "AdjustMargin",
+            Router: `/position/:id/margin`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:LeverageController"] = append(beego.GlobalControllerRouter["backend/controllers:LeverageController"],
+        beego.ControllerComments{
+            Method: "PlaceTrailingStop",
+            Router: `/position/:id/trailing-stop`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
     beego.GlobalControllerRouter["backend/controllers:LeverageController"] = append(beego.GlobalControllerRouter["backend/controllers:LeverageController"],
         beego.ControllerComments{
             Method: "OpenPosition",
@@ -106,6 +250,15 @@ func init() {
             Filters: nil,
             Params: nil})
 
+    beego.GlobalControllerRouter["backend/controllers:MarketController"] = append(beego.GlobalControllerRouter["backend/controllers:MarketController"],
+        beego.ControllerComments{
+            Method: "GetFundingRates",
+            Router: `/funding-rates`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
     beego.GlobalControllerRouter["backend/controllers:MarketController"] = append(beego.GlobalControllerRouter["backend/controllers:MarketController"],
         beego.ControllerComments{
             Method: "GetKLines",
@@ -115,6 +268,258 @@ func init() {
             Filters: nil,
             Params: nil})
 
+    beego.GlobalControllerRouter["backend/controllers:PortfolioController"] = append(beego.GlobalControllerRouter["backend/controllers:PortfolioController"],
+        beego.ControllerComments{
+            Method: "GetPortfolioHistory",
+            Router: `/history`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:PortfolioController"] = append(beego.GlobalControllerRouter["backend/controllers:PortfolioController"],
+        beego.ControllerComments{
+            Method: "GetPortfolioSummary",
+            Router: `/summary`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:PriceAlertController"] = append(beego.GlobalControllerRouter["backend/controllers:PriceAlertController"],
+        beego.ControllerComments{
+            Method: "CreatePriceAlert",
+            Router: `/`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:PriceAlertController"] = append(beego.GlobalControllerRouter["backend/controllers:PriceAlertController"],
+        beego.ControllerComments{
+            Method: "GetPriceAlerts",
+            Router: `/`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:PriceAlertController"] = append(beego.GlobalControllerRouter["backend/controllers:PriceAlertController"],
+        beego.ControllerComments{
+            Method: "GetPriceAlert",
+            Router: `/:id`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:PriceAlertController"] = append(beego.GlobalControllerRouter["backend/controllers:PriceAlertController"],
+        beego.ControllerComments{
+            Method: "UpdatePriceAlert",
+            Router: `/:id`,
+            AllowHTTPMethods: []string{"put"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:PriceAlertController"] = append(beego.GlobalControllerRouter["backend/controllers:PriceAlertController"],
+        beego.ControllerComments{
+            Method: "DeletePriceAlert",
+            Router: `/:id`,
+            AllowHTTPMethods: []string{"delete"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:RecurringBuyController"] = append(beego.GlobalControllerRouter["backend/controllers:RecurringBuyController"],
+        beego.ControllerComments{
+            Method: "CreateRecurringBuy",
+            Router: `/`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:RecurringBuyController"] = append(beego.GlobalControllerRouter["backend/controllers:RecurringBuyController"],
+        beego.ControllerComments{
+            Method: "GetRecurringBuys",
+            Router: `/`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:RecurringBuyController"] = append(beego.GlobalControllerRouter["backend/controllers:RecurringBuyController"],
+        beego.ControllerComments{
+            Method: "GetRecurringBuy",
+            Router: `/:id`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:RecurringBuyController"] = append(beego.GlobalControllerRouter["backend/controllers:RecurringBuyController"],
+        beego.ControllerComments{
+            Method: "UpdateRecurringBuy",
+            Router: `/:id`,
+            AllowHTTPMethods: []string{"put"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:RecurringBuyController"] = append(beego.GlobalControllerRouter["backend/controllers:RecurringBuyController"],
+        beego.ControllerComments{
+            Method: "DeleteRecurringBuy",
+            Router: `/:id`,
+            AllowHTTPMethods: []string{"delete"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:RecurringBuyController"] = append(beego.GlobalControllerRouter["backend/controllers:RecurringBuyController"],
+        beego.ControllerComments{
+            Method: "PauseRecurringBuy",
+            Router: `/:id/pause`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:RecurringBuyController"] = append(beego.GlobalControllerRouter["backend/controllers:RecurringBuyController"],
+        beego.ControllerComments{
+            Method: "ResumeRecurringBuy",
+            Router: `/:id/resume`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:RecurringBuyController"] = append(beego.GlobalControllerRouter["backend/controllers:RecurringBuyController"],
+        beego.ControllerComments{
+            Method: "GetRecurringBuyRuns",
+            Router: `/:id/runs`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:StrategyBotController"] = append(beego.GlobalControllerRouter["backend/controllers:StrategyBotController"],
+        beego.ControllerComments{
+            Method: "StartStrategyBot",
+            Router: `/`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:StrategyBotController"] = append(beego.GlobalControllerRouter["backend/controllers:StrategyBotController"],
+        beego.ControllerComments{
+            Method: "GetStrategyBots",
+            Router: `/`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:StrategyBotController"] = append(beego.GlobalControllerRouter["backend/controllers:StrategyBotController"],
+        beego.ControllerComments{
+            Method: "GetStrategyBot",
+            Router: `/:id`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:StrategyBotController"] = append(beego.GlobalControllerRouter["backend/controllers:StrategyBotController"],
+        beego.ControllerComments{
+            Method: "StopStrategyBot",
+            Router: `/:id/stop`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:StrategyBotController"] = append(beego.GlobalControllerRouter["backend/controllers:StrategyBotController"],
+        beego.ControllerComments{
+            Method: "GetStrategies",
+            Router: `/strategies`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:TradingController"] = append(beego.GlobalControllerRouter["backend/controllers:TradingController"],
+        beego.ControllerComments{
+            Method: "PlaceAlgoOrder",
+            Router: `/algo-order`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:TradingController"] = append(beego.GlobalControllerRouter["backend/controllers:TradingController"],
+        beego.ControllerComments{
+            Method: "GetAlgoOrder",
+            Router: `/algo-order/:id`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:TradingController"] = append(beego.GlobalControllerRouter["backend/controllers:TradingController"],
+        beego.ControllerComments{
+            Method: "CancelAlgoOrder",
+            Router: `/algo-order/:id/cancel`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:TradingController"] = append(beego.GlobalControllerRouter["backend/controllers:TradingController"],
+        beego.ControllerComments{
+            Method: "PauseAlgoOrder",
+            Router: `/algo-order/:id/pause`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:TradingController"] = append(beego.GlobalControllerRouter["backend/controllers:TradingController"],
+        beego.ControllerComments{
+            Method: "ResumeAlgoOrder",
+            Router: `/algo-order/:id/resume`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:TradingController"] = append(beego.GlobalControllerRouter["backend/controllers:TradingController"],
+        beego.ControllerComments{
+            Method: "GetAlgoOrders",
+            Router: `/algo-orders`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:TradingController"] = append(beego.GlobalControllerRouter["backend/controllers:TradingController"],
+        beego.ControllerComments{
+            Method: "GetDeadMansSwitch",
+            Router: `/dead-mans-switch`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:TradingController"] = append(beego.GlobalControllerRouter["backend/controllers:TradingController"],
+        beego.ControllerComments{
+            Method: "SetDeadMansSwitch",
+            Router: `/dead-mans-switch`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
     beego.GlobalControllerRouter["backend/controllers:TradingController"] = append(beego.GlobalControllerRouter["backend/controllers:TradingController"],
         beego.ControllerComments{
             Method: "PlaceOrder",
@@ -124,6 +529,51 @@ func init() {
             Filters: nil,
             Params: nil})
 
+    beego.GlobalControllerRouter["backend/controllers:TradingController"] = append(beego.GlobalControllerRouter["backend/controllers:TradingController"],
+        beego.ControllerComments{
+            Method: "PlaceOrderList",
+            Router: `/order-list`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:TradingController"] = append(beego.GlobalControllerRouter["backend/controllers:TradingController"],
+        beego.ControllerComments{
+            Method: "GetOrderList",
+            Router: `/order-list/:id`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:TradingController"] = append(beego.GlobalControllerRouter["backend/controllers:TradingController"],
+        beego.ControllerComments{
+            Method: "CancelOrderList",
+            Router: `/order-list/:id/cancel`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:TradingController"] = append(beego.GlobalControllerRouter["backend/controllers:TradingController"],
+        beego.ControllerComments{
+            Method: "GetOrderLists",
+            Router: `/order-lists`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:TradingController"] = append(beego.GlobalControllerRouter["backend/controllers:TradingController"],
+        beego.ControllerComments{
+            Method: "AmendOrder",
+            Router: `/order/:id`,
+            AllowHTTPMethods: []string{"put"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
     beego.GlobalControllerRouter["backend/controllers:TradingController"] = append(beego.GlobalControllerRouter["backend/controllers:TradingController"],
         beego.ControllerComments{
             Method: "CancelOrder",
@@ -133,6 +583,33 @@ func init() {
             Filters: nil,
             Params: nil})
 
+    beego.GlobalControllerRouter["backend/controllers:TradingController"] = append(beego.GlobalControllerRouter["backend/controllers:TradingController"],
+        beego.ControllerComments{
+            Method: "GetOrderEvents",
+            Router: `/order/:id/events`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:TradingController"] = append(beego.GlobalControllerRouter["backend/controllers:TradingController"],
+        beego.ControllerComments{
+            Method: "GetOrderByClientOrderId",
+            Router: `/order/client/:clientOrderId`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:TradingController"] = append(beego.GlobalControllerRouter["backend/controllers:TradingController"],
+        beego.ControllerComments{
+            Method: "CancelOrderByClientOrderId",
+            Router: `/order/client/:clientOrderId/cancel`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
     beego.GlobalControllerRouter["backend/controllers:TradingController"] = append(beego.GlobalControllerRouter["backend/controllers:TradingController"],
         beego.ControllerComments{
             Method: "GetOrders",
@@ -142,6 +619,24 @@ func init() {
             Filters: nil,
             Params: nil})
 
+    beego.GlobalControllerRouter["backend/controllers:TradingController"] = append(beego.GlobalControllerRouter["backend/controllers:TradingController"],
+        beego.ControllerComments{
+            Method: "CancelAllOrders",
+            Router: `/orders/cancel-all`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:TradingController"] = append(beego.GlobalControllerRouter["backend/controllers:TradingController"],
+        beego.ControllerComments{
+            Method: "BatchCancelOrders",
+            Router: `/orders/cancel-batch`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
     beego.GlobalControllerRouter["backend/controllers:TradingController"] = append(beego.GlobalControllerRouter["backend/controllers:TradingController"],
         beego.ControllerComments{
             Method: "GetPrices",
@@ -214,4 +709,67 @@ func init() {
             Filters: nil,
             Params: nil})
 
+    beego.GlobalControllerRouter["backend/controllers:WebhookController"] = append(beego.GlobalControllerRouter["backend/controllers:WebhookController"],
+        beego.ControllerComments{
+            Method: "CreateWebhookEndpoint",
+            Router: `/`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:WebhookController"] = append(beego.GlobalControllerRouter["backend/controllers:WebhookController"],
+        beego.ControllerComments{
+            Method: "GetWebhookEndpoints",
+            Router: `/`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:WebhookController"] = append(beego.GlobalControllerRouter["backend/controllers:WebhookController"],
+        beego.ControllerComments{
+            Method: "GetWebhookEndpoint",
+            Router: `/:id`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:WebhookController"] = append(beego.GlobalControllerRouter["backend/controllers:WebhookController"],
+        beego.ControllerComments{
+            Method: "UpdateWebhookEndpoint",
+            Router: `/:id`,
+            AllowHTTPMethods: []string{"put"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:WebhookController"] = append(beego.GlobalControllerRouter["backend/controllers:WebhookController"],
+        beego.ControllerComments{
+            Method: "DeleteWebhookEndpoint",
+            Router: `/:id`,
+            AllowHTTPMethods: []string{"delete"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:WebhookController"] = append(beego.GlobalControllerRouter["backend/controllers:WebhookController"],
+        beego.ControllerComments{
+            Method: "GetWebhookDeliveries",
+            Router: `/:id/deliveries`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["backend/controllers:WebhookController"] = append(beego.GlobalControllerRouter["backend/controllers:WebhookController"],
+        beego.ControllerComments{
+            Method: "RedeliverWebhook",
+            Router: `/:id/deliveries/:deliveryId/redeliver`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
 }
@@ -20,6 +20,14 @@ func init() {
 		beego.NSNamespace("/market", beego.NSInclude(&controllers.MarketController{})),
 		beego.NSNamespace("/trading", beego.NSInclude(&controllers.TradingController{})),
 		beego.NSNamespace("/leverage", beego.NSInclude(&controllers.LeverageController{})),
+		beego.NSNamespace("/recurring-buy", beego.NSInclude(&controllers.RecurringBuyController{})),
+		beego.NSNamespace("/grid-bot", beego.NSInclude(&controllers.GridBotController{})),
+		beego.NSNamespace("/backtests", beego.NSInclude(&controllers.BacktestController{})),
+		beego.NSNamespace("/strategy-bots", beego.NSInclude(&controllers.StrategyBotController{})),
+		beego.NSNamespace("/price-alerts", beego.NSInclude(&controllers.PriceAlertController{})),
+		beego.NSNamespace("/webhooks", beego.NSInclude(&controllers.WebhookController{})),
+		beego.NSNamespace("/portfolio", beego.NSInclude(&controllers.PortfolioController{})),
+		beego.NSNamespace("/admin", beego.NSInclude(&controllers.AdminController{})),
 	)
 	beego.AddNamespace(ns)
 	beego.Router("/ws", &controllers.WebSocketController{})
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"backend/models"
+	"errors"
+	"strings"
+
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// IsAdmin 檢查使用者是否為管理員
+// 管理員名單由設定檔 adminemails 指定（以逗號分隔的 email），不開放透過 API 修改
+func IsAdmin(userId int64) (bool, error) {
+	user, err := models.GetUserById(userId)
+	if err != nil {
+		return false, errors.New("user not found")
+	}
+
+	adminEmails, _ := beego.AppConfig.String("adminemails")
+	for _, email := range strings.Split(adminEmails, ",") {
+		email = strings.TrimSpace(email)
+		if email != "" && strings.EqualFold(email, user.Email) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
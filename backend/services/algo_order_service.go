@@ -0,0 +1,359 @@
+package services
+
+import (
+	"backend/models"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// AlgoOrderEngine 演算法母單執行器：依排程為 TWAP 下市價子訂單，冰山子訂單成交後補上下一個可見數量
+type AlgoOrderEngine struct {
+	mu            sync.Mutex // 串行化子訂單的下單與暫停、恢復、取消，避免暫停後仍掛出新的子訂單
+	isRunning     bool
+	stopChan      chan struct{}
+	checkInterval time.Duration
+}
+
+var GlobalAlgoOrderEngine *AlgoOrderEngine
+
+func init() {
+	GlobalAlgoOrderEngine = &AlgoOrderEngine{
+		checkInterval: 1 * time.Second, // 每秒檢查一次
+		stopChan:      make(chan struct{}),
+	}
+}
+
+// Start 啟動演算法母單執行器（執行中的母單狀態都在資料庫，重啟後直接接續）
+func (e *AlgoOrderEngine) Start() {
+	e.mu.Lock()
+	if e.isRunning {
+		e.mu.Unlock()
+		return
+	}
+	e.isRunning = true
+	e.mu.Unlock()
+
+	log.Println("Algo order engine started")
+	go e.run()
+}
+
+// Stop 停止演算法母單執行器
+func (e *AlgoOrderEngine) Stop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.isRunning {
+		return
+	}
+
+	e.isRunning = false
+	close(e.stopChan)
+	log.Println("Algo order engine stopped")
+}
+
+// run 主要監控循環
+func (e *AlgoOrderEngine) run() {
+	ticker := time.NewTicker(e.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.stopChan:
+			return
+		case <-ticker.C:
+			e.checkAlgoOrders()
+		}
+	}
+}
+
+// checkAlgoOrders 處理到期的 TWAP 子訂單與冰山單的子訂單
+func (e *AlgoOrderEngine) checkAlgoOrders() {
+	algos, err := models.GetRunningAlgoOrders()
+	if err != nil {
+		log.Printf("Failed to load running algo orders: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, algo := range algos {
+		if algo.Algo == models.AlgoTypeTWAP && (algo.NextRunAt == nil || now.Before(*algo.NextRunAt)) {
+			continue
+		}
+		e.process(algo.Id)
+	}
+}
+
+// process 在鎖內重新讀取母單（可能已被暫停或取消）並執行下一步
+func (e *AlgoOrderEngine) process(algoOrderId int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	algo, err := models.GetAlgoOrderById(algoOrderId)
+	if err != nil || algo.Status != models.AlgoOrderStatusRunning {
+		return
+	}
+	if err = e.recoverChildren(algo); err != nil {
+		log.Printf("Failed to recover child orders of algo order #%d: %v", algo.Id, err)
+		return
+	}
+	if algo.Status != models.AlgoOrderStatusRunning {
+		return
+	}
+
+	switch algo.Algo {
+	case models.AlgoTypeTWAP:
+		e.runTWAPSlice(algo)
+	case models.AlgoTypeIceberg:
+		e.runIceberg(algo)
+	}
+}
+
+// recoverChildren 子訂單建立時已記錄所屬母單，以子訂單補上寫回母單前中斷（例如行程結束）的進度
+// 避免重啟後重複下單造成超額成交
+func (e *AlgoOrderEngine) recoverChildren(algo *models.AlgoOrder) error {
+	if err := models.LoadAlgoOrderChildren(algo); err != nil {
+		return err
+	}
+	children := algo.Children
+	algo.Children = nil
+	if !algo.RecoverChildren(children) {
+		return nil
+	}
+
+	// TWAP 補記的子訂單已下單，從現在重新排定下一個子訂單
+	if algo.Algo == models.AlgoTypeTWAP && algo.Status == models.AlgoOrderStatusRunning {
+		next := time.Now().Add(algo.NextTWAPDelay(randomFactor()))
+		algo.NextRunAt = &next
+	} else if algo.Algo == models.AlgoTypeTWAP {
+		algo.NextRunAt = nil
+	}
+	log.Printf("Algo order #%d recovered from child orders: filled %.8f in %d child orders, active child #%d",
+		algo.Id, algo.FilledQuantity, algo.ChildCount, algo.ActiveChildId)
+	return e.save(algo, 0)
+}
+
+// runTWAPSlice 下一個 TWAP 市價子訂單並排定下一次的時間
+func (e *AlgoOrderEngine) runTWAPSlice(algo *models.AlgoOrder) {
+	quantity := algo.NextTWAPSlice(randomFactor())
+	child, err := placeMarketOrder(algo.User.Id, models.OrderParent{AlgoOrderId: algo.Id}, algo.Symbol, algo.Side, quantity)
+	if err != nil {
+		algo.Fail("child order failed: " + err.Error())
+		e.save(algo, 0)
+		return
+	}
+
+	if !algo.RecordFill(quantity, child.TotalAmount) {
+		next := time.Now().Add(algo.NextTWAPDelay(randomFactor()))
+		algo.NextRunAt = &next
+	} else {
+		algo.NextRunAt = nil
+	}
+	e.save(algo, child.Id)
+}
+
+// runIceberg 檢查冰山單目前的子訂單，已成交時補上下一個可見數量
+func (e *AlgoOrderEngine) runIceberg(algo *models.AlgoOrder) {
+	if algo.ActiveChildId > 0 {
+		childId := algo.ActiveChildId
+		pending, err := settleIcebergChild(algo, false)
+		if err != nil {
+			log.Printf("Failed to check child order #%d of algo order #%d: %v", childId, algo.Id, err)
+			return
+		}
+		if pending {
+			return
+		}
+		e.save(algo, childId)
+		if algo.Status != models.AlgoOrderStatusRunning {
+			return
+		}
+	}
+
+	// 子訂單與母單目前的子訂單在同一交易中寫入
+	child, err := placeLinkedLimitOrder(algo.User.Id, models.OrderParent{AlgoOrderId: algo.Id}, algo.Symbol, algo.Side, algo.NextIcebergSlice(), algo.LimitPrice,
+		func(to orm.TxOrmer, order *models.Order) error {
+			return models.SetAlgoOrderActiveChild(to, algo.Id, order.Id)
+		})
+	if err != nil {
+		algo.Fail("child order failed: " + err.Error())
+		e.save(algo, 0)
+		return
+	}
+	algo.ActiveChildId = child.Id
+	e.save(algo, child.Id)
+}
+
+// settleIcebergChild 處理已結束的冰山子訂單：成交時記錄進度，失敗時母單失敗
+// 子訂單被使用者直接取消時（不是暫停或取消母單造成的），母單一併取消
+// 返回子訂單是否仍在掛單中
+func settleIcebergChild(algo *models.AlgoOrder, canceledByAlgo bool) (bool, error) {
+	child, err := models.GetOrderById(algo.ActiveChildId)
+	if err != nil {
+		return false, err
+	}
+
+	switch child.Status {
+	case models.OrderStatusPending:
+		return true, nil
+	case models.OrderStatusCompleted:
+		algo.RecordFill(child.Quantity, child.TotalAmount)
+	case models.OrderStatusFailed:
+		algo.Fail("child order failed: " + child.ErrorMsg)
+	case models.OrderStatusCanceled:
+		if !canceledByAlgo {
+			algo.Cancel()
+		}
+	}
+	algo.ActiveChildId = 0
+	return false, nil
+}
+
+// detachIcebergChild 取消冰山單目前掛出的子訂單（子訂單已成交時記錄進度）
+func detachIcebergChild(algo *models.AlgoOrder) error {
+	if algo.ActiveChildId == 0 {
+		return nil
+	}
+
+	err := CancelOrder(algo.User.Id, algo.ActiveChildId)
+	if err != nil && err.Error() != "order cannot be canceled" {
+		return fmt.Errorf("failed to cancel child order: %v", err)
+	}
+
+	pending, err := settleIcebergChild(algo, true)
+	if err != nil {
+		return err
+	}
+	if pending {
+		return errors.New("failed to cancel child order")
+	}
+	return nil
+}
+
+// save 在同一個交易中寫回母單並寫入進度通知
+func (e *AlgoOrderEngine) save(algo *models.AlgoOrder, childOrderId int64) error {
+	err := saveAndNotify(algo.User.Id, func(to orm.TxOrmer) (*models.WSMessage, error) {
+		if err := models.SaveAlgoOrder(to, algo); err != nil {
+			return nil, err
+		}
+		return models.NewAlgoOrderUpdateMessage(algo, childOrderId), nil
+	})
+	if err != nil {
+		log.Printf("Failed to save algo order #%d: %v", algo.Id, err)
+		return err
+	}
+
+	if algo.Status != models.AlgoOrderStatusRunning {
+		log.Printf("Algo order #%d %s: %s %s filled %.8f/%.8f in %d child orders",
+			algo.Id, algo.Status, algo.Side, algo.Symbol, algo.FilledQuantity, algo.Quantity, algo.ChildCount)
+	}
+	return nil
+}
+
+// randomFactor 返回 -1~1 的隨機數，用來調整 TWAP 子訂單的數量與間隔
+func randomFactor() float64 {
+	return rand.Float64()*2 - 1
+}
+
+// PlaceAlgoOrder 下演算法母單，子訂單由執行器依排程下單
+func PlaceAlgoOrder(userId int64, symbol string, params models.AlgoOrderParams) (*models.AlgoOrder, error) {
+	// 1. 驗證輸入
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	if _, _, err := models.ParseSymbol(symbol); err != nil {
+		return nil, err
+	}
+
+	// 2. 建立母單並寫入通知（執行器下一次檢查時開始下子訂單）
+	algo := models.NewAlgoOrder(userId, symbol, params, time.Now())
+	err := saveAndNotify(userId, func(to orm.TxOrmer) (*models.WSMessage, error) {
+		if err := models.CreateAlgoOrder(to, algo); err != nil {
+			return nil, fmt.Errorf("failed to create algo order: %v", err)
+		}
+		return models.NewAlgoOrderUpdateMessage(algo, 0), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Algo order #%d created: User=%d, %s %s %s %.8f",
+		algo.Id, userId, algo.Algo, algo.Side, symbol, algo.Quantity)
+
+	return algo, nil
+}
+
+// GetAlgoOrder 查詢使用者的演算法母單（含子訂單）
+func GetAlgoOrder(userId int64, algoOrderId int64) (*models.AlgoOrder, error) {
+	algo, err := models.GetAlgoOrderById(algoOrderId)
+	if err != nil {
+		return nil, err
+	}
+	if algo.User.Id != userId {
+		return nil, errors.New("unauthorized: algo order does not belong to user")
+	}
+	if err = models.LoadAlgoOrderChildren(algo); err != nil {
+		return nil, err
+	}
+	return algo, nil
+}
+
+// PauseAlgoOrder 暫停演算法母單，冰山單同時取消目前掛出的子訂單
+func (e *AlgoOrderEngine) PauseAlgoOrder(userId int64, algoOrderId int64) (*models.AlgoOrder, error) {
+	return e.update(userId, algoOrderId, func(algo *models.AlgoOrder) error {
+		if err := algo.Pause(); err != nil {
+			return err
+		}
+		return detachIcebergChild(algo)
+	})
+}
+
+// ResumeAlgoOrder 恢復已暫停的演算法母單
+func (e *AlgoOrderEngine) ResumeAlgoOrder(userId int64, algoOrderId int64) (*models.AlgoOrder, error) {
+	return e.update(userId, algoOrderId, func(algo *models.AlgoOrder) error {
+		return algo.Resume(time.Now())
+	})
+}
+
+// CancelAlgoOrder 取消演算法母單，冰山單同時取消目前掛出的子訂單（已成交的子訂單不受影響）
+func (e *AlgoOrderEngine) CancelAlgoOrder(userId int64, algoOrderId int64) (*models.AlgoOrder, error) {
+	return e.update(userId, algoOrderId, func(algo *models.AlgoOrder) error {
+		if err := algo.Cancel(); err != nil {
+			return err
+		}
+		return detachIcebergChild(algo)
+	})
+}
+
+// update 在執行器鎖內讀取、修改並寫回使用者的母單
+func (e *AlgoOrderEngine) update(userId int64, algoOrderId int64, apply func(algo *models.AlgoOrder) error) (*models.AlgoOrder, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	algo, err := models.GetAlgoOrderById(algoOrderId)
+	if err != nil {
+		return nil, err
+	}
+	if algo.User.Id != userId {
+		return nil, errors.New("unauthorized: algo order does not belong to user")
+	}
+	if algo.IsActive() {
+		if err = e.recoverChildren(algo); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = apply(algo); err != nil {
+		return nil, err
+	}
+	if err = e.save(algo, 0); err != nil {
+		return nil, err
+	}
+	return algo, nil
+}
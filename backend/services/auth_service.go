@@ -22,9 +22,11 @@ func Registration(m models.Registration) (id int64, err error) {
 		return
 	}
 	user := models.User{
-		Name:     m.Name,
-		Email:    m.Email,
-		Password: hashedPassword,
+		Name:         m.Name,
+		Email:        m.Email,
+		Password:     hashedPassword,
+		MarginMode:   models.MarginModeIsolated,
+		PositionMode: models.PositionModeHedge,
 	}
 	if id, err = models.AddUser(&user); err != nil {
 		return
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"backend/backtest"
+	"backend/models"
+	"backend/strategy"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// maxUnfinishedBacktestsPerUser 每位使用者同時排隊或執行中的回測任務上限
+	maxUnfinishedBacktestsPerUser = 3
+	// backtestQueueSize 回測任務佇列長度
+	backtestQueueSize = 100
+)
+
+// BacktestRunner 非同步回測執行器：任務寫入資料庫後排隊，由固定數量的 worker 依序執行
+type BacktestRunner struct {
+	mu        sync.Mutex
+	isRunning bool
+	stopChan  chan struct{}
+	queue     chan int64
+	workers   int
+}
+
+var GlobalBacktestRunner *BacktestRunner
+
+func init() {
+	GlobalBacktestRunner = &BacktestRunner{
+		workers:  2,
+		stopChan: make(chan struct{}),
+		queue:    make(chan int64, backtestQueueSize),
+	}
+}
+
+// Start 啟動回測執行器，重新排入停機前尚未完成的任務
+func (r *BacktestRunner) Start() {
+	r.mu.Lock()
+	if r.isRunning {
+		r.mu.Unlock()
+		return
+	}
+	r.isRunning = true
+	r.mu.Unlock()
+
+	for i := 0; i < r.workers; i++ {
+		go r.run()
+	}
+
+	unfinished, err := models.GetUnfinishedBacktests()
+	if err != nil {
+		log.Printf("Failed to load unfinished backtests: %v", err)
+	}
+	for _, job := range unfinished {
+		r.enqueue(job)
+	}
+
+	log.Printf("Backtest runner started with %d workers, %d jobs requeued", r.workers, len(unfinished))
+}
+
+// Stop 停止回測執行器（執行中的任務會在重新啟動後重新執行）
+func (r *BacktestRunner) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.isRunning {
+		return
+	}
+
+	r.isRunning = false
+	close(r.stopChan)
+	log.Println("Backtest runner stopped")
+}
+
+// run worker 循環
+func (r *BacktestRunner) run() {
+	for {
+		select {
+		case <-r.stopChan:
+			return
+		case id := <-r.queue:
+			r.execute(id)
+		}
+	}
+}
+
+// enqueue 將任務排入佇列，佇列已滿時標記為失敗
+func (r *BacktestRunner) enqueue(job *models.Backtest) {
+	select {
+	case r.queue <- job.Id:
+	default:
+		job.Fail("backtest queue is full", time.Now())
+		if err := models.SaveBacktest(job); err != nil {
+			log.Printf("Failed to save backtest #%d: %v", job.Id, err)
+		}
+	}
+}
+
+// execute 載入 K 線並執行回測，結果寫回任務
+func (r *BacktestRunner) execute(id int64) {
+	job, err := models.GetBacktestById(id)
+	if err != nil {
+		log.Printf("Failed to load backtest #%d: %v", id, err)
+		return
+	}
+	if job.IsFinished() {
+		return
+	}
+
+	job.Start(time.Now())
+	if err = models.SaveBacktest(job); err != nil {
+		log.Printf("Failed to save backtest #%d: %v", id, err)
+		return
+	}
+
+	report, err := runBacktest(job)
+	if err != nil {
+		job.Fail(err.Error(), time.Now())
+		log.Printf("Backtest #%d failed: %v", id, err)
+	} else {
+		applyBacktestReport(job, report)
+		job.Complete(time.Now())
+		log.Printf("Backtest #%d completed: %s %s %s, %d candles, return %.2f%%, max drawdown %.2f%%, %d trades",
+			id, job.Strategy, job.Symbol, job.Interval, job.CandleCount, job.TotalReturn*100, job.MaxDrawdown*100, job.TradeCount)
+	}
+
+	if err = models.SaveBacktest(job); err != nil {
+		log.Printf("Failed to save backtest #%d: %v", id, err)
+	}
+}
+
+// runBacktest 以儲存的 K 線（不足時從 Binance 補齊）重播策略
+func runBacktest(job *models.Backtest) (*backtest.Report, error) {
+	s, err := strategy.New(job.Strategy, job.StrategyParams)
+	if err != nil {
+		return nil, err
+	}
+
+	candles, err := backtest.LoadKlines(job.Symbol, job.Interval, job.StartTime, job.EndTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load klines: %v", err)
+	}
+
+	return backtest.Run(backtestConfig(job), candles, s)
+}
+
+// backtestConfig 任務的回測設定
+func backtestConfig(job *models.Backtest) backtest.Config {
+	fundingRate, fundingInterval := GlobalFundingService.backtestFunding()
+	return backtest.Config{
+		Symbol:          job.Symbol,
+		Interval:        job.Interval,
+		InitialBalance:  job.InitialBalance,
+		MarginMode:      job.MarginMode,
+		PositionMode:    job.PositionMode,
+		FundingRate:     fundingRate,
+		FundingInterval: fundingInterval,
+	}
+}
+
+// applyBacktestReport 將回測結果寫入任務（摘要欄位與完整報告 JSON）
+func applyBacktestReport(job *models.Backtest, report *backtest.Report) {
+	job.CandleCount = report.CandleCount
+	job.FinalEquity = report.FinalEquity
+	job.TotalReturn = report.TotalReturn
+	job.MaxDrawdown = report.MaxDrawdown
+	job.SharpeRatio = report.SharpeRatio
+	job.WinRate = report.WinRate
+	job.TradeCount = report.TradeCount
+
+	result, err := json.Marshal(report)
+	if err != nil {
+		log.Printf("Failed to encode report of backtest #%d: %v", job.Id, err)
+		return
+	}
+	job.Result = string(result)
+}
+
+// SubmitBacktest 驗證並建立回測任務，排入佇列後立即返回
+func (r *BacktestRunner) SubmitBacktest(userId int64, job *models.Backtest) (*models.Backtest, error) {
+	// 1. 驗證設定與策略參數
+	config := backtestConfig(job)
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	job.MarginMode = config.MarginMode
+	job.PositionMode = config.PositionMode
+
+	if _, err := strategy.New(job.Strategy, job.StrategyParams); err != nil {
+		return nil, err
+	}
+
+	// 2. 驗證回測區間
+	interval, _ := strategy.ParseInterval(job.Interval)
+	if !job.EndTime.After(job.StartTime) {
+		return nil, errors.New("end time must be after start time")
+	}
+	if job.EndTime.After(time.Now()) {
+		return nil, errors.New("end time must not be in the future")
+	}
+	count := backtest.CountCandles(job.StartTime, job.EndTime, interval)
+	if count == 0 {
+		return nil, errors.New("time range must contain at least one candle")
+	}
+	if count > backtest.MaxCandles {
+		return nil, fmt.Errorf("time range too long: at most %d candles per backtest", backtest.MaxCandles)
+	}
+
+	// 3. 限制同時進行的任務數量
+	unfinished, err := models.CountUnfinishedBacktestsByUser(userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count backtests: %v", err)
+	}
+	if unfinished >= maxUnfinishedBacktestsPerUser {
+		return nil, fmt.Errorf("too many unfinished backtests: at most %d at a time", maxUnfinishedBacktestsPerUser)
+	}
+
+	// 4. 建立任務並排隊
+	job.User = &models.User{Id: userId}
+	job.Status = models.BacktestStatusPending
+	if err = models.CreateBacktest(job); err != nil {
+		return nil, fmt.Errorf("failed to create backtest: %v", err)
+	}
+	r.enqueue(job)
+
+	log.Printf("Backtest #%d submitted: User=%d, %s %s %s, %v - %v, %d candles",
+		job.Id, userId, job.Strategy, job.Symbol, job.Interval, job.StartTime, job.EndTime, count)
+	return job, nil
+}
+
+// GetBacktest 查詢使用者的回測任務（含完整報告）
+func GetBacktest(userId int64, id int64) (*models.Backtest, error) {
+	job, err := models.GetBacktestById(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.User.Id != userId {
+		return nil, errors.New("unauthorized: backtest does not belong to user")
+	}
+	if job.Result != "" {
+		job.Report = json.RawMessage(job.Result)
+	}
+	return job, nil
+}
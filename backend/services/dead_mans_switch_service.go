@@ -0,0 +1,158 @@
+package services
+
+import (
+	"backend/hub"
+	"backend/models"
+	"log"
+	"sync"
+	"time"
+)
+
+// DeadMansSwitchService 自動撤單服務：使用者斷線或倒數結束時取消所有待處理的限價單
+type DeadMansSwitchService struct {
+	mu         sync.Mutex
+	disconnect map[int64]bool        // 開啟斷線撤單的使用者
+	timers     map[int64]*time.Timer // userId -> 倒數計時器
+	expiresAt  map[int64]time.Time   // userId -> 倒數結束時間（用來忽略已被重設的計時器）
+}
+
+var GlobalDeadMansSwitch *DeadMansSwitchService
+
+func init() {
+	GlobalDeadMansSwitch = NewDeadMansSwitchService()
+}
+
+// NewDeadMansSwitchService 建立自動撤單服務
+func NewDeadMansSwitchService() *DeadMansSwitchService {
+	return &DeadMansSwitchService{
+		disconnect: make(map[int64]bool),
+		timers:     make(map[int64]*time.Timer),
+		expiresAt:  make(map[int64]time.Time),
+	}
+}
+
+// Start 載入已開啟的設定並監聽 WebSocket 斷線（需在 Hub 建立後呼叫）
+func (s *DeadMansSwitchService) Start() {
+	switches, err := models.GetArmedDeadMansSwitches()
+	if err != nil {
+		log.Printf("Failed to load dead man's switches: %v", err)
+	}
+	for _, sw := range switches {
+		s.apply(sw)
+	}
+	log.Printf("Dead man's switch service started: %d armed users", len(switches))
+
+	hub.GlobalHub.OnUserDisconnect(s.onUserDisconnect)
+}
+
+// Get 查詢使用者的自動撤單設定
+func (s *DeadMansSwitchService) Get(userId int64) (*models.DeadMansSwitch, error) {
+	return models.GetDeadMansSwitch(userId)
+}
+
+// Configure 更新使用者的自動撤單設定，nil 表示不修改
+// countdownSeconds 每次設定都會重新開始倒數，為 0 時關閉倒數
+func (s *DeadMansSwitchService) Configure(userId int64, cancelOnDisconnect *bool, countdownSeconds *int) (*models.DeadMansSwitch, error) {
+	sw, err := models.GetDeadMansSwitch(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if cancelOnDisconnect != nil {
+		sw.CancelOnDisconnect = *cancelOnDisconnect
+	}
+	if countdownSeconds != nil {
+		if err = sw.SetCountdown(*countdownSeconds, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = models.SaveDeadMansSwitch(sw); err != nil {
+		return nil, err
+	}
+	s.apply(sw)
+	return sw, nil
+}
+
+// apply 同步記憶體中的斷線設定與倒數計時器
+func (s *DeadMansSwitchService) apply(sw *models.DeadMansSwitch) {
+	userId := sw.User.Id
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sw.CancelOnDisconnect {
+		s.disconnect[userId] = true
+	} else {
+		delete(s.disconnect, userId)
+	}
+
+	if timer, ok := s.timers[userId]; ok {
+		timer.Stop()
+		delete(s.timers, userId)
+		delete(s.expiresAt, userId)
+	}
+	if sw.ExpiresAt == nil {
+		return
+	}
+
+	expiresAt := *sw.ExpiresAt
+	s.expiresAt[userId] = expiresAt
+	s.timers[userId] = time.AfterFunc(time.Until(expiresAt), func() {
+		s.onCountdownExpired(userId, expiresAt)
+	})
+}
+
+// onUserDisconnect 使用者最後一個 WebSocket 斷開（由 Hub 同步呼叫，不可阻塞）
+func (s *DeadMansSwitchService) onUserDisconnect(userId int64) {
+	s.mu.Lock()
+	enabled := s.disconnect[userId]
+	s.mu.Unlock()
+
+	if enabled {
+		go s.trigger(userId, "websocket disconnected")
+	}
+}
+
+// onCountdownExpired 倒數結束，倒數已被重設或關閉時忽略
+func (s *DeadMansSwitchService) onCountdownExpired(userId int64, expiresAt time.Time) {
+	s.mu.Lock()
+	current, ok := s.expiresAt[userId]
+	if !ok || !current.Equal(expiresAt) {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.timers, userId)
+	delete(s.expiresAt, userId)
+	s.mu.Unlock()
+
+	// 倒數觸發後關閉，需要重新設定才會再次倒數
+	sw, err := models.GetDeadMansSwitch(userId)
+	if err == nil && sw.ExpiresAt != nil && sw.ExpiresAt.Unix() == expiresAt.Unix() {
+		sw.SetCountdown(0, time.Now())
+		err = models.SaveDeadMansSwitch(sw)
+	}
+	if err != nil {
+		log.Printf("Failed to reset countdown for user %d: %v", userId, err)
+	}
+
+	s.trigger(userId, "countdown expired")
+}
+
+// trigger 取消使用者所有待處理的限價單
+func (s *DeadMansSwitchService) trigger(userId int64, reason string) {
+	results, err := CancelAllOrders(userId, CancelOrderFilter{Type: models.OrderTypeLimit})
+	if err != nil {
+		log.Printf("Dead man's switch failed for user %d (%s): %v", userId, reason, err)
+		return
+	}
+
+	canceled := 0
+	for _, result := range results {
+		if result.Success {
+			canceled++
+		}
+	}
+	log.Printf("Dead man's switch triggered for user %d (%s): canceled %d/%d pending limit orders",
+		userId, reason, canceled, len(results))
+}
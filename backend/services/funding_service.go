@@ -0,0 +1,287 @@
+package services
+
+import (
+	"backend/models"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// FundingService 資金費用結算服務
+// 每分鐘取樣一次溢價指數，每個結算週期依費率向所有持倉收付資金費用
+type FundingService struct {
+	mu              sync.Mutex
+	isRunning       bool
+	stopChan        chan struct{}
+	sampleInterval  time.Duration          // 溢價取樣間隔
+	fundingInterval time.Duration          // 結算週期
+	mode            models.FundingRateMode // 費率計算方式
+	interestRate    float64                // 利率（固定模式下即為費率）
+	clampRange      float64                // 利率與溢價差的調整範圍
+	rateCap         float64                // 費率上下限
+	premiums        map[string][]float64   // symbol -> 本期溢價取樣
+	nextFundingTime time.Time
+}
+
+var GlobalFundingService *FundingService
+
+func init() {
+	GlobalFundingService = &FundingService{
+		sampleInterval:  1 * time.Minute,
+		fundingInterval: 8 * time.Hour,
+		mode:            models.FundingRateModeFixed,
+		interestRate:    0.0001,
+		clampRange:      0.0005,
+		rateCap:         0.0075,
+		premiums:        make(map[string][]float64),
+		stopChan:        make(chan struct{}),
+	}
+}
+
+// loadConfig 從設定檔讀取資金費用參數，未設定時使用預設值
+func (s *FundingService) loadConfig() {
+	if interval, err := beego.AppConfig.String("fundinginterval"); err == nil && interval != "" {
+		if d, err := time.ParseDuration(interval); err == nil && d > 0 {
+			s.fundingInterval = d
+		} else {
+			log.Printf("Invalid fundinginterval %q, using %s", interval, s.fundingInterval)
+		}
+	}
+	if mode, err := beego.AppConfig.String("fundingmode"); err == nil && mode != "" {
+		switch models.FundingRateMode(mode) {
+		case models.FundingRateModeFixed:
+			s.mode = models.FundingRateModeFixed
+		case models.FundingRateModePremium:
+			// 標記價格與指數價格目前來自同一個行情來源，溢價只是雜訊，需接上獨立的指數價格來源後再啟用
+			log.Printf("Warning: fundingmode=premium samples mark and index prices from the same feed; use it only with a separate index price source")
+			s.mode = models.FundingRateModePremium
+		default:
+			log.Printf("Invalid fundingmode %q, using %s", mode, s.mode)
+		}
+	}
+	if rate, err := beego.AppConfig.Float("fundingrate"); err == nil {
+		s.interestRate = rate
+	}
+	if clamp, err := beego.AppConfig.Float("fundingclamp"); err == nil {
+		s.clampRange = clamp
+	}
+	if rateCap, err := beego.AppConfig.Float("fundingratecap"); err == nil {
+		s.rateCap = rateCap
+	}
+}
+
+// Start 啟動資金費用結算服務
+func (s *FundingService) Start() {
+	s.mu.Lock()
+	if s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = true
+	s.loadConfig()
+	s.nextFundingTime = time.Now().UTC().Truncate(s.fundingInterval).Add(s.fundingInterval)
+	s.mu.Unlock()
+
+	log.Printf("Funding service started: mode=%s, interval=%s, next funding at %s",
+		s.mode, s.fundingInterval, s.nextFundingTime.Format(time.RFC3339))
+
+	go s.run()
+}
+
+// Stop 停止資金費用結算服務
+func (s *FundingService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isRunning {
+		return
+	}
+
+	s.isRunning = false
+	close(s.stopChan)
+	log.Println("Funding service stopped")
+}
+
+// run 取樣與結算循環
+func (s *FundingService) run() {
+	ticker := time.NewTicker(s.sampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.samplePremiums()
+
+			s.mu.Lock()
+			fundingTime := s.nextFundingTime
+			due := !time.Now().Before(fundingTime)
+			if due {
+				s.nextFundingTime = fundingTime.Add(s.fundingInterval)
+			}
+			s.mu.Unlock()
+
+			if due {
+				s.settle(fundingTime)
+			}
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// samplePremiums 取樣各交易對的溢價指數 = (標記價格 - 指數價格) / 指數價格
+// 指數價格為 Binance 現貨最新成交價，標記價格為同一來源最近成交價的中位數（見 PriceCache.GetMarkPrice）
+// 本系統沒有獨立的永續合約市場，溢價只反映最新成交相對近期中位數的偏離，因此預設使用 fixed 模式，
+// premium 模式只應在接上獨立的指數價格來源後啟用
+func (s *FundingService) samplePremiums() {
+	markPrices := GlobalPriceCache.GetAllMarkPrices()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for symbol, markPrice := range markPrices {
+		indexPrice, ok := GlobalPriceCache.GetPrice(symbol)
+		if !ok || indexPrice <= 0 {
+			continue
+		}
+		s.premiums[symbol] = append(s.premiums[symbol], (markPrice-indexPrice)/indexPrice)
+	}
+}
+
+// nextRate 計算交易對本期資金費率並清空取樣
+func (s *FundingService) nextRate(symbol string) (rate float64, averagePremium float64) {
+	s.mu.Lock()
+	premiums := s.premiums[symbol]
+	delete(s.premiums, symbol)
+	s.mu.Unlock()
+
+	rate, averagePremium = models.CalculateFundingRate(premiums, s.interestRate, s.clampRange, s.rateCap)
+	if s.mode == models.FundingRateModeFixed {
+		rate = s.interestRate
+	}
+	return rate, averagePremium
+}
+
+// backtestFunding 回測使用的資金費率與結算週期
+// 回測沒有指數價格可取樣溢價，以沒有溢價時的費率模擬（fixed 模式即為設定的費率）
+func (s *FundingService) backtestFunding() (rate float64, interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rate, _ = models.CalculateFundingRate(nil, s.interestRate, s.clampRange, s.rateCap)
+	if s.mode == models.FundingRateModeFixed {
+		rate = s.interestRate
+	}
+	return rate, s.fundingInterval
+}
+
+// settle 結算一期資金費用
+func (s *FundingService) settle(fundingTime time.Time) {
+	positions, err := models.GetAllOpenPositions()
+	if err != nil {
+		log.Printf("Failed to get open positions for funding: %v", err)
+		return
+	}
+
+	bySymbol := make(map[string][]*models.LeveragePosition)
+	for _, position := range positions {
+		bySymbol[position.Symbol] = append(bySymbol[position.Symbol], position)
+	}
+
+	for symbol, markPrice := range GlobalPriceCache.GetAllMarkPrices() {
+		rate, averagePremium := s.nextRate(symbol)
+
+		fundingRate := &models.FundingRate{
+			Symbol:       symbol,
+			Rate:         rate,
+			PremiumIndex: averagePremium,
+			MarkPrice:    markPrice,
+			FundingTime:  fundingTime,
+		}
+		if err := models.CreateFundingRate(fundingRate); err != nil {
+			log.Printf("Failed to record funding rate for %s: %v", symbol, err)
+			continue
+		}
+
+		for _, position := range bySymbol[symbol] {
+			if err := applyFundingPayment(position.Id, fundingRate); err != nil {
+				log.Printf("Failed to apply funding to position #%d: %v", position.Id, err)
+				continue
+			}
+			fundingRate.PositionsCount++
+		}
+
+		if err := models.UpdateFundingRatePositionsCount(fundingRate); err != nil {
+			log.Printf("Failed to update funding rate #%d: %v", fundingRate.Id, err)
+		}
+
+		log.Printf("Funding settled: Symbol=%s, Rate=%.6f, MarkPrice=%.2f, Positions=%d",
+			symbol, rate, markPrice, fundingRate.PositionsCount)
+	}
+}
+
+// applyFundingPayment 向單一倉位收付資金費用
+// 逐倉由倉位保證金收付，全倉由 USDT 錢包收付（支付最多扣到可用餘額為 0）
+// 倉位的對手方是平台，多空持倉量不一定相等，以保險基金作為對手帳戶使每筆費用收支相抵：
+// 倉位支付的費用轉入基金，收取的費用由基金撥出，基金不足的部分記為赤字
+func applyFundingPayment(positionId int64, fundingRate *models.FundingRate) error {
+	// 倉位所屬的使用者不會改變，先讀取以便依錢包、倉位的順序鎖定
+	position, err := models.GetPositionById(positionId)
+	if err != nil {
+		return err
+	}
+	userId := position.User.Id
+
+	o := orm.NewOrm()
+	to, err := o.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	shouldRollback := true
+	defer func() {
+		if shouldRollback {
+			to.Rollback()
+		}
+	}()
+
+	// 1. 在交易中依序鎖定錢包與倉位（與開倉、平倉使用相同的鎖順序）
+	if _, err = models.GetWalletForUpdate(to, userId, "USDT"); err != nil {
+		return err
+	}
+	position, err = models.GetPositionForUpdate(to, positionId)
+	if err != nil {
+		return err
+	}
+	if position.Status != models.PositionStatusOpen {
+		return nil
+	}
+
+	// 2. 收付資金費用並記錄倉位變更與交易
+	amount, err := models.ApplyFundingPayment(models.NewTxLeverageLedger(to), position, fundingRate.Rate, fundingRate.MarkPrice, fundingRate.FundingTime)
+	if err != nil {
+		return err
+	}
+
+	// 3. 寫入資金費用通知並提交交易
+	if err = enqueueNotification(to, userId, models.NewFundingPaymentMessage(position, fundingRate, amount)); err != nil {
+		return err
+	}
+
+	err = to.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	shouldRollback = false
+	GlobalNotificationDispatcher.Notify()
+
+	// 逐倉保證金與爆倉價格已變動，同步爆倉索引
+	trackPositions(position)
+
+	return nil
+}
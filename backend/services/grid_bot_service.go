@@ -0,0 +1,386 @@
+package services
+
+import (
+	"backend/models"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// errGridOrderSettled 掛單記錄已由其他呼叫結束，這次成交不再處理
+var errGridOrderSettled = errors.New("grid order already settled")
+
+// GridBotService 網格機器人服務：監聽撮合器的成交，在相鄰價位掛出反向訂單
+type GridBotService struct {
+	mu                sync.Mutex // 串行化所有機器人的掛單、成交處理與停止
+	isRunning         bool
+	stopChan          chan struct{}
+	reconcileInterval time.Duration
+}
+
+var GlobalGridBotService *GridBotService
+
+func init() {
+	GlobalGridBotService = &GridBotService{
+		reconcileInterval: 30 * time.Second,
+		stopChan:          make(chan struct{}),
+	}
+}
+
+// Start 啟動網格機器人服務（需在限價單撮合器啟動後呼叫）
+// 機器人的掛單由撮合器從資料庫載入，這裡補處理停機期間或漏接的成交
+func (s *GridBotService) Start() {
+	s.mu.Lock()
+	if s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = true
+	s.mu.Unlock()
+
+	GlobalLimitOrderMatcher.OnOrderFilled(s.onOrderFilled)
+	s.reconcile()
+
+	log.Println("Grid bot service started")
+	go s.run()
+}
+
+// Stop 停止網格機器人服務
+func (s *GridBotService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isRunning {
+		return
+	}
+
+	s.isRunning = false
+	close(s.stopChan)
+	log.Println("Grid bot service stopped")
+}
+
+// run 定期核對機器人的掛單（處理漏接的成交與被使用者直接取消的訂單）
+func (s *GridBotService) run() {
+	ticker := time.NewTicker(s.reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.reconcile()
+		}
+	}
+}
+
+// onOrderFilled 撮合器的成交回呼（同步執行，不可阻塞）
+func (s *GridBotService) onOrderFilled(order *models.Order) {
+	if order.GridBotId == 0 {
+		return
+	}
+	go s.handleOrderDone(order.Id)
+}
+
+// handleOrderDone 處理機器人訂單的成交
+func (s *GridBotService) handleOrderDone(orderId int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gridOrder, err := models.GetActiveGridBotOrderByOrderId(orderId)
+	if err != nil {
+		return
+	}
+	bot, err := models.GetGridBotById(gridOrder.Bot.Id)
+	if err != nil {
+		log.Printf("Failed to load grid bot for order #%d: %v", orderId, err)
+		return
+	}
+	s.settle(bot, gridOrder)
+}
+
+// reconcile 核對所有執行中機器人的掛單
+func (s *GridBotService) reconcile() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bots, err := models.GetRunningGridBots()
+	if err != nil {
+		log.Printf("Failed to load running grid bots: %v", err)
+		return
+	}
+
+	for _, bot := range bots {
+		gridOrders, err := models.GetActiveGridBotOrders(bot.Id)
+		if err != nil {
+			log.Printf("Failed to load orders of grid bot #%d: %v", bot.Id, err)
+			continue
+		}
+		for _, gridOrder := range gridOrders {
+			s.settle(bot, gridOrder)
+		}
+	}
+}
+
+// settle 處理已結束的機器人訂單：成交時記錄利潤並掛出反向訂單，失敗時停止機器人
+// 需持有 s.mu；以 Active 為條件結束記錄，同一筆成交只會處理一次
+func (s *GridBotService) settle(bot *models.GridBot, gridOrder *models.GridBotOrder) {
+	order, err := models.GetOrderById(gridOrder.OrderId)
+	if err != nil {
+		log.Printf("Failed to load order #%d of grid bot #%d: %v", gridOrder.OrderId, bot.Id, err)
+		return
+	}
+	if order.Status == models.OrderStatusPending {
+		return
+	}
+
+	switch order.Status {
+	case models.OrderStatusCompleted:
+		// 機器人停止時才成交的賣單仍計入利潤；交易失敗時還原記憶體中的狀態，掛單記錄保持有效留待下次處理
+		before := *bot
+		var profit float64
+		if gridOrder.Side == models.OrderSideSell {
+			profit = bot.RecordSellFill(gridOrder)
+		}
+		if err = s.settleFill(bot, gridOrder, profit); err != nil {
+			*bot = before
+			if errors.Is(err, errGridOrderSettled) {
+				return
+			}
+			log.Printf("Failed to settle order #%d of grid bot #%d: %v", gridOrder.OrderId, bot.Id, err)
+			if bot.Status == models.GridBotStatusRunning {
+				s.stopBot(bot, models.GridBotStatusFailed, "failed to place grid order: "+err.Error())
+			}
+			return
+		}
+		log.Printf("Grid bot #%d: %s level %d filled at %.2f, profit %.4f, realized %.4f",
+			bot.Id, gridOrder.Side, gridOrder.Level, gridOrder.Price, profit, bot.RealizedProfit)
+
+	case models.OrderStatusFailed:
+		if settled, err := models.DeactivateGridBotOrder(orm.NewOrm(), gridOrder.Id); err != nil || !settled {
+			return
+		}
+		if bot.Status == models.GridBotStatusRunning {
+			s.stopBot(bot, models.GridBotStatusFailed, fmt.Sprintf("grid order #%d failed: %s", order.Id, order.ErrorMsg))
+		}
+
+	case models.OrderStatusCanceled:
+		if settled, err := models.DeactivateGridBotOrder(orm.NewOrm(), gridOrder.Id); err != nil || !settled {
+			return
+		}
+		// 使用者直接取消機器人的訂單，該價位留空
+		if bot.Status == models.GridBotStatusRunning {
+			log.Printf("Grid bot #%d: order #%d at level %d was canceled outside the bot", bot.Id, order.Id, gridOrder.Level)
+		}
+	}
+}
+
+// settleFill 在同一個交易中結束成交的掛單記錄、掛出反向訂單（機器人運行中時）並寫回利潤與更新通知
+// 掛單記錄已由其他呼叫結束時返回 errGridOrderSettled，整筆交易回滾
+func (s *GridBotService) settleFill(bot *models.GridBot, gridOrder *models.GridBotOrder, profit float64) error {
+	record := func(to orm.TxOrmer) (*models.WSMessage, error) {
+		settled, err := models.DeactivateGridBotOrder(to, gridOrder.Id)
+		if err != nil {
+			return nil, err
+		}
+		if !settled {
+			return nil, errGridOrderSettled
+		}
+		if err = models.SaveGridBot(to, bot); err != nil {
+			return nil, err
+		}
+		return models.NewGridBotUpdateMessage(bot, gridOrder, profit), nil
+	}
+
+	if bot.Status == models.GridBotStatusRunning {
+		if level, side, costPrice, ok := bot.CounterOrder(gridOrder); ok {
+			err := s.placeGridOrder(bot, level, side, costPrice, func(to orm.TxOrmer) error {
+				msg, err := record(to)
+				if err != nil {
+					return err
+				}
+				return enqueueNotification(to, bot.User.Id, msg)
+			})
+			if err != nil {
+				return err
+			}
+			GlobalNotificationDispatcher.Notify()
+			return nil
+		}
+	}
+	return saveAndNotify(bot.User.Id, record)
+}
+
+// placeGridOrder 在價位掛出限價單並在同一交易中寫入掛單記錄與 link 的額外寫入（需持有 s.mu，成交回呼會等到釋放鎖後才處理）
+func (s *GridBotService) placeGridOrder(bot *models.GridBot, level int, side models.OrderSide, costPrice float64, link func(to orm.TxOrmer) error) error {
+	price := bot.LevelPrice(level)
+	_, err := placeLinkedLimitOrder(bot.User.Id, models.OrderParent{GridBotId: bot.Id}, bot.Symbol, side, bot.QuantityPerGrid, price,
+		func(to orm.TxOrmer, order *models.Order) error {
+			if err := models.CreateGridBotOrder(to, &models.GridBotOrder{
+				Bot:       bot,
+				OrderId:   order.Id,
+				Level:     level,
+				Side:      side,
+				Price:     price,
+				CostPrice: costPrice,
+				Active:    true,
+			}); err != nil {
+				return fmt.Errorf("failed to record grid order: %v", err)
+			}
+			if link != nil {
+				return link(to)
+			}
+			return nil
+		})
+	return err
+}
+
+// stopBot 停止機器人並取消所有掛單（需持有 s.mu），取消前已成交的訂單照常記錄利潤
+func (s *GridBotService) stopBot(bot *models.GridBot, status models.GridBotStatus, reason string) {
+	bot.Stop(status, reason, time.Now())
+
+	gridOrders, err := models.GetActiveGridBotOrders(bot.Id)
+	if err != nil {
+		log.Printf("Failed to load orders of grid bot #%d: %v", bot.Id, err)
+	}
+	for _, gridOrder := range gridOrders {
+		if err = CancelOrder(bot.User.Id, gridOrder.OrderId); err != nil && err.Error() != "order cannot be canceled" {
+			log.Printf("Failed to cancel order #%d of grid bot #%d: %v", gridOrder.OrderId, bot.Id, err)
+			continue
+		}
+		s.settle(bot, gridOrder)
+	}
+
+	log.Printf("Grid bot #%d %s: realized profit %.4f in %d cycles (%s)",
+		bot.Id, status, bot.RealizedProfit, bot.CompletedCycles, reason)
+	s.save(bot, nil, 0)
+}
+
+// save 在同一個交易中寫回機器人並寫入更新通知
+func (s *GridBotService) save(bot *models.GridBot, filled *models.GridBotOrder, profit float64) error {
+	err := saveAndNotify(bot.User.Id, func(to orm.TxOrmer) (*models.WSMessage, error) {
+		if err := models.SaveGridBot(to, bot); err != nil {
+			return nil, err
+		}
+		return models.NewGridBotUpdateMessage(bot, filled, profit), nil
+	})
+	if err != nil {
+		log.Printf("Failed to save grid bot #%d: %v", bot.Id, err)
+		return err
+	}
+	return nil
+}
+
+// CreateGridBot 建立網格機器人：以市價買入賣單需要的庫存，再掛出買賣限價單
+func (s *GridBotService) CreateGridBot(userId int64, params models.GridBotParams) (*models.GridBot, error) {
+	// 1. 驗證輸入
+	_, quote, err := models.ParseSymbol(params.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	currentPrice, ok := GlobalPriceCache.GetPrice(params.Symbol)
+	if !ok {
+		return nil, fmt.Errorf("price not available for %s", params.Symbol)
+	}
+
+	if err = params.Validate(currentPrice); err != nil {
+		return nil, err
+	}
+
+	// 2. 檢查 USDT 餘額（限價單不凍結資金，之後的買單成交時再扣款）
+	wallet, err := models.GetWalletByUserAndSymbol(userId, quote)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s wallet: %v", quote, err)
+	}
+	if wallet.GetAvailableBalance() < params.Investment {
+		return nil, errors.New("insufficient USDT balance")
+	}
+
+	// 3. 建立機器人
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bot := models.NewGridBot(userId, params, currentPrice)
+	if err = models.CreateGridBot(bot); err != nil {
+		return nil, fmt.Errorf("failed to create grid bot: %v", err)
+	}
+
+	// 4. 以市價買入賣單的庫存，依實際成交調整每格數量
+	buyLevels, sellLevels := bot.Ladder(currentPrice)
+	if len(sellLevels) > 0 {
+		quoteAmount := bot.QuantityPerGrid * float64(len(sellLevels)) * currentPrice
+		order, err := placeMarketOrder(userId, models.OrderParent{GridBotId: bot.Id}, bot.Symbol, models.OrderSideBuy, quoteAmount)
+		if err != nil {
+			s.stopBot(bot, models.GridBotStatusFailed, "failed to buy base asset: "+err.Error())
+			return nil, fmt.Errorf("failed to buy base asset: %v", err)
+		}
+		bot.EntryPrice = order.Price
+		bot.QuantityPerGrid = order.TotalAmount / order.Price / float64(len(sellLevels))
+	}
+
+	// 5. 掛出買賣限價單
+	for _, level := range buyLevels {
+		if err = s.placeGridOrder(bot, level, models.OrderSideBuy, 0, nil); err != nil {
+			break
+		}
+	}
+	for _, level := range sellLevels {
+		if err != nil {
+			break
+		}
+		err = s.placeGridOrder(bot, level, models.OrderSideSell, bot.EntryPrice, nil)
+	}
+	if err != nil {
+		s.stopBot(bot, models.GridBotStatusFailed, "failed to place grid order: "+err.Error())
+		return nil, fmt.Errorf("failed to place grid order: %v", err)
+	}
+
+	if err = s.save(bot, nil, 0); err != nil {
+		return nil, fmt.Errorf("failed to save grid bot: %v", err)
+	}
+	bot.Orders, _ = models.GetActiveGridBotOrders(bot.Id)
+
+	log.Printf("Grid bot #%d created: User=%d, %s %.2f-%.2f, %d grids, %.8f per grid, %d buys, %d sells",
+		bot.Id, userId, bot.Symbol, bot.LowerPrice, bot.UpperPrice, bot.GridCount, bot.QuantityPerGrid, len(buyLevels), len(sellLevels))
+	return bot, nil
+}
+
+// StopGridBot 停止網格機器人並取消所有掛單（已買入的庫存保留在錢包）
+func (s *GridBotService) StopGridBot(userId int64, botId int64) (*models.GridBot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bot, err := models.GetGridBotById(botId)
+	if err != nil {
+		return nil, err
+	}
+	if bot.User.Id != userId {
+		return nil, errors.New("unauthorized: grid bot does not belong to user")
+	}
+	if bot.Status != models.GridBotStatusRunning {
+		return nil, errors.New("grid bot is not running")
+	}
+
+	s.stopBot(bot, models.GridBotStatusStopped, "stopped by user")
+	return bot, nil
+}
+
+// GetGridBot 查詢使用者的網格機器人（含目前掛出的訂單）
+func GetGridBot(userId int64, botId int64) (*models.GridBot, error) {
+	bot, err := models.GetGridBotById(botId)
+	if err != nil {
+		return nil, err
+	}
+	if bot.User.Id != userId {
+		return nil, errors.New("unauthorized: grid bot does not belong to user")
+	}
+	if bot.Orders, err = models.GetActiveGridBotOrders(bot.Id); err != nil {
+		return nil, err
+	}
+	return bot, nil
+}
@@ -1,7 +1,6 @@
 package services
 
 import (
-	"backend/hub"
 	"backend/models"
 	"errors"
 	"fmt"
@@ -10,13 +9,18 @@ import (
 	"github.com/beego/beego/v2/client/orm"
 )
 
-// OpenLeveragePositionMarket 用市價單開槓桿倉位
-func OpenLeveragePositionMarket(userId int64, symbol string, side models.PositionSide, leverage int, quantity float64) (*models.LeveragePosition, error) {
-	return OpenLeveragePosition(userId, symbol, side, leverage, quantity)
+// OpenLeveragePositionMarket 用市價單開槓桿倉位，clientOrderId 為使用者自訂的訂單 ID，idempotencyKeys 為下單請求登記的冪等 Key（皆可為空）
+func OpenLeveragePositionMarket(userId int64, symbol string, side models.PositionSide, leverage int, quantity float64, reduceOnly bool, clientOrderId string, idempotencyKeys []string) (*models.LeveragePosition, error) {
+	return openLeveragePositionMarket(userId, models.OrderParent{ClientOrderId: clientOrderId, IdempotencyKeys: idempotencyKeys}, symbol, side, leverage, quantity, reduceOnly)
 }
 
-// OpenLeveragePositionLimit 用限價單開槓桿倉位
-func OpenLeveragePositionLimit(userId int64, symbol string, side models.PositionSide, leverage int, quantity float64, limitPrice float64) (*models.LeveragePosition, error) {
+// OpenLeveragePositionLimit 用限價單開槓桿倉位，clientOrderId 為使用者自訂的訂單 ID，idempotencyKeys 為下單請求登記的冪等 Key（皆可為空）
+func OpenLeveragePositionLimit(userId int64, symbol string, side models.PositionSide, leverage int, quantity float64, limitPrice float64, reduceOnly bool, clientOrderId string, idempotencyKeys []string) (*models.LeveragePosition, error) {
+	return openLeveragePositionLimit(userId, models.OrderParent{ClientOrderId: clientOrderId, IdempotencyKeys: idempotencyKeys}, symbol, side, leverage, quantity, limitPrice, reduceOnly)
+}
+
+// openLeveragePositionLimit 用限價單開槓桿倉位，parent 不為空時為機器人的子訂單
+func openLeveragePositionLimit(userId int64, parent models.OrderParent, symbol string, side models.PositionSide, leverage int, quantity float64, limitPrice float64, reduceOnly bool) (*models.LeveragePosition, error) {
 	// 1. 驗證輸入
 	if quantity <= 0 {
 		return nil, errors.New("quantity must be positive")
@@ -39,65 +43,112 @@ func OpenLeveragePositionLimit(userId int64, symbol string, side models.Position
 		return nil, errors.New("only USDT pairs are supported for leverage trading")
 	}
 
-	// 2. 驗證用戶是否有足夠的保證金
+	// 2. 計算並凍結保證金
 	// quantity 代表想要購買的幣種數量，保證金 = (數量 × 限價) / 槓桿倍數
-	margin := (quantity * limitPrice) / float64(leverage)
+	// 只減倉訂單不開新倉，不需要凍結保證金
+	margin := models.CalculateRequiredMargin(limitPrice, quantity, leverage)
+	if reduceOnly {
+		margin = 0
+	}
 
-	wallet, err := models.GetWalletByUserAndSymbol(userId, "USDT")
+	o := orm.NewOrm()
+	to, err := o.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	shouldRollback := true
+	defer func() {
+		if shouldRollback {
+			to.Rollback()
+		}
+	}()
+
+	wallet, err := models.GetWalletForUpdate(to, userId, "USDT")
 	if err != nil {
 		return nil, errors.New("USDT wallet not found")
 	}
 
-	if wallet.Balance < margin {
-		return nil, fmt.Errorf("insufficient USDT balance: required %.2f, available %.2f", margin, wallet.Balance)
+	if reduceOnly {
+		if err = checkReduceOnly(to, userId, symbol, side, quantity); err != nil {
+			return nil, err
+		}
+	} else {
+		// 掛單期間保證金凍結在錢包的 Locked 中，成交時才轉入倉位
+		if err = wallet.LockMargin(margin); err != nil {
+			return nil, err
+		}
+		if _, err = to.Update(wallet, "Locked"); err != nil {
+			return nil, fmt.Errorf("failed to lock margin: %v", err)
+		}
 	}
 
 	// 3. 建立限價訂單
-	order, err := models.CreateLeverageOrder(userId, symbol, models.OrderTypeLimit,
-		func() models.OrderSide {
-			if side == models.PositionSideLong {
-				return models.OrderSideBuy
-			} else {
-				return models.OrderSideSell
-			}
-		}(), quantity, &limitPrice, leverage, side)
+	order, err := models.CreateLeverageOrder(to, userId, parent, symbol, models.OrderTypeLimit,
+		side.OrderSide(), quantity, &limitPrice, leverage, side, reduceOnly)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create order: %v", err)
+		return nil, fmt.Errorf("failed to create order: %w", err)
 	}
 
-	log.Printf("Leverage limit order #%d created: User=%d, Symbol=%s, Side=%s, Leverage=%dx, Quantity=%.8f, LimitPrice=%.2f, RequiredMargin=%.2f",
-		order.Id, userId, symbol, side, leverage, quantity, limitPrice, margin)
+	if err = to.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	shouldRollback = false
+	if !reduceOnly {
+		trackBalance(userId)
+	}
+
+	log.Printf("Leverage limit order #%d created: User=%d, Symbol=%s, Side=%s, Leverage=%dx, Quantity=%.8f, LimitPrice=%.2f, LockedMargin=%.2f, ReduceOnly=%t",
+		order.Id, userId, symbol, side, leverage, quantity, limitPrice, margin, reduceOnly)
 
 	// 4. 返回一個臨時的倉位對象給前端顯示（但不保存到數據庫）
 	// 倉位會在限價單成交時才真正建立
+	position := pendingLeveragePosition(userId, order, margin)
+
+	// 5. 加入限價單撮合器監控（倉位建立的通知在成交時才發送）
+	GlobalLimitOrderMatcher.AddOrder(order)
+
+	log.Printf("Leverage position (pending): User=%d, Symbol=%s, Side=%s, Leverage=%dx, Quantity=%.8f, LimitPrice=%.2f",
+		userId, symbol, side, leverage, quantity, limitPrice)
+
+	return position, nil
+}
+
+// pendingLeveragePosition 限價開倉掛單中時返回給前端顯示的臨時倉位（不保存到數據庫）
+func pendingLeveragePosition(userId int64, order *models.Order, margin float64) *models.LeveragePosition {
 	position := &models.LeveragePosition{
 		User:       &models.User{Id: userId},
 		Order:      order,
-		Symbol:     symbol,
-		Side:       side,
-		Leverage:   leverage,
-		EntryPrice: limitPrice,
-		Quantity:   quantity,
+		Symbol:     order.Symbol,
+		Side:       models.PositionSide(order.PositionSideStr),
+		Leverage:   order.Leverage,
+		EntryPrice: order.LimitPrice,
+		Quantity:   order.Quantity,
 		Margin:     margin,
 		Status:     models.PositionStatusOpen, // 前端顯示為 OPEN（實際上是 PENDING）
 	}
 	position.LiquidationPrice = position.CalculateLiquidationPrice()
+	return position
+}
 
-	// 5. 加入限價單撮合器監控
-	GlobalLimitOrderMatcher.AddOrder(order)
-
-	// 6. 發送 WebSocket 通知給用戶
-	message := models.NewLeveragePositionOpenedMessage(position)
-	hub.GlobalHub.BroadcastToUser(userId, message.ToJSON())
-
-	log.Printf("Leverage position (pending): User=%d, Symbol=%s, Side=%s, Leverage=%dx, Quantity=%.8f, LimitPrice=%.2f",
-		userId, symbol, side, leverage, quantity, limitPrice)
-
-	return position, nil
+// GetLeverageOpenResult 以開倉請求建立的訂單重建開倉結果（冪等重送時原始請求已中斷、未保存回應）
+// 限價單仍在掛單時返回掛單中的臨時倉位，否則返回訂單方向目前的持倉（已平倉時為 nil）
+func GetLeverageOpenResult(userId int64, orderId int64) (*models.LeveragePosition, error) {
+	order, err := models.GetOrderById(orderId)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status == models.OrderStatusPending {
+		return pendingLeveragePosition(userId, order, order.RequiredMargin()), nil
+	}
+	return models.GetOpenPosition(userId, order.Symbol, models.PositionSide(order.PositionSideStr))
 }
 
-// OpenLeveragePosition 開槓桿倉位
-func OpenLeveragePosition(userId int64, symbol string, side models.PositionSide, leverage int, quantity float64) (*models.LeveragePosition, error) {
+// openLeveragePositionMarket 用市價單開槓桿倉位，parent 不為空時為機器人的子訂單
+// 訂單與成交在同一交易中寫入，成交後訂單即為已完成
+// 單向持倉模式下反方向開倉會先減少（或反手）現有持倉；只減倉訂單只減少反方向持倉
+func openLeveragePositionMarket(userId int64, parent models.OrderParent, symbol string, side models.PositionSide, leverage int, quantity float64, reduceOnly bool) (*models.LeveragePosition, error) {
 	// 1. 驗證輸入
 	if quantity <= 0 {
 		return nil, errors.New("quantity must be positive")
@@ -122,14 +173,7 @@ func OpenLeveragePosition(userId int64, symbol string, side models.PositionSide,
 		return nil, fmt.Errorf("price not available for %s", symbol)
 	}
 
-	// 3. 計算所需保證金
-	// quantity 代表想要購買的幣種數量，保證金 = (數量 × 當前市價) / 槓桿倍數
-	margin := (quantity * currentPrice) / float64(leverage)
-
-	// 4. actualQuantity 就是 quantity（因為用戶已經指定想要購買的幣種數量）
-	actualQuantity := quantity
-
-	// 5. 開始資料庫交易
+	// 3. 開始資料庫交易
 	o := orm.NewOrm()
 	to, err := o.Begin()
 	if err != nil {
@@ -143,57 +187,104 @@ func OpenLeveragePosition(userId int64, symbol string, side models.PositionSide,
 		}
 	}()
 
-	// 5. 檢查並扣除保證金（從 USDT 錢包）
-	wallet, err := models.GetWalletByUserAndSymbol(userId, "USDT")
-	if err != nil {
-		return nil, errors.New("USDT wallet not found")
+	// 4. 只減倉訂單的數量不可超過反方向持倉
+	if reduceOnly {
+		if _, err = models.GetWalletForUpdate(to, userId, "USDT"); err != nil {
+			return nil, errors.New("USDT wallet not found")
+		}
+		if err = checkReduceOnly(to, userId, symbol, side, quantity); err != nil {
+			return nil, err
+		}
 	}
 
-	if wallet.Balance < margin {
-		return nil, fmt.Errorf("insufficient USDT balance: required %.2f, available %.2f", margin, wallet.Balance)
+	// 5. 建立市價訂單
+	order, err := models.CreateLeverageOrder(to, userId, parent, symbol, models.OrderTypeMarket,
+		side.OrderSide(), quantity, nil, leverage, side, reduceOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create order: %w", err)
 	}
 
-	// 扣除保證金
-	err = models.UpdateBalance(to, wallet.Id, -margin, 0)
+	// 6. 依持倉模式減倉、開倉或加倉，並在錢包與倉位之間轉移保證金
+	fill, err := models.FillLeverageOrder(models.NewTxLeverageLedger(to), userId, order, symbol, side, leverage, currentPrice, quantity, reduceOnly, 0)
 	if err != nil {
-		return nil, fmt.Errorf("failed to deduct margin: %v", err)
+		return nil, err
 	}
 
-	// 6. 創建槓桿倉位
-	position, err := models.CreateLeveragePosition(userId, symbol, side, leverage, currentPrice, actualQuantity, margin)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create position: %v", err)
+	totalAmount := (fill.ReduceQuantity + fill.OpenQuantity) * currentPrice
+	if err = models.UpdateOrderStatus(to, order.Id, models.OrderStatusCompleted, currentPrice, totalAmount, ""); err != nil {
+		return nil, fmt.Errorf("failed to update order: %v", err)
+	}
+	if err = models.EnqueueOrderFilledWebhook(to, userId, order.Id); err != nil {
+		return nil, fmt.Errorf("failed to enqueue webhook event: %v", err)
 	}
 
-	// 7. 記錄交易
-	transactionType := models.TransactionTypeMarginDeposit
-	description := fmt.Sprintf("Open %s position #%d with %dx leverage", side, position.Id, leverage)
-	_, err = models.CreateTransaction(to, userId, nil, transactionType, "USDT", -margin,
-		wallet.Balance+margin, wallet.Balance, description)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create transaction: %v", err)
+	// 7. 寫入倉位通知並提交交易
+	if err = enqueueLeverageFill(to, userId, fill, currentPrice); err != nil {
+		return nil, err
 	}
 
-	// 8. 提交交易
 	err = to.Commit()
 	if err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
 	shouldRollback = false
+	GlobalNotificationDispatcher.Notify()
 
-	log.Printf("Leverage position opened: User=%d, Symbol=%s, Side=%s, Leverage=%dx, Quantity=%.8f (from USDT=%.2f), EntryPrice=%.2f, Margin=%.2f",
-		userId, symbol, side, leverage, actualQuantity, quantity, currentPrice, margin)
+	log.Printf("Leverage order #%d filled: User=%d, Symbol=%s, Side=%s, Leverage=%dx, Quantity=%.8f, Price=%.2f, Reduced=%.8f, Opened=%.8f, Margin=%.2f",
+		order.Id, userId, symbol, side, leverage, quantity, currentPrice, fill.ReduceQuantity, fill.OpenQuantity, fill.Margin)
 
-	// 發送 WebSocket 通知給用戶
-	message := models.NewLeveragePositionOpenedMessage(position)
-	hub.GlobalHub.BroadcastToUser(userId, message.ToJSON())
+	trackPositions(fill.Reduced, fill.Position)
 
-	return position, nil
+	return fill.Result(), nil
+}
+
+// checkReduceOnly 檢查只減倉訂單是否有足夠的反方向持倉可減少（需要在交易中使用）
+// side 為訂單方向：LONG（買入）減少空頭持倉，SHORT（賣出）減少多頭持倉
+func checkReduceOnly(to orm.TxOrmer, userId int64, symbol string, side models.PositionSide, quantity float64) error {
+	position, err := models.GetOpenPositionForUpdate(to, userId, symbol, side.Opposite())
+	if err != nil {
+		return fmt.Errorf("failed to get position: %v", err)
+	}
+	if position == nil {
+		return errors.New("reduce-only order has no position to reduce")
+	}
+	if quantity > position.Quantity {
+		return errors.New("reduce-only order quantity exceeds position size")
+	}
+	return nil
+}
+
+// enqueueLeverageFill 寫入槓桿訂單成交後的倉位通知（需要在成交的交易中使用）
+func enqueueLeverageFill(to orm.TxOrmer, userId int64, fill *models.LeverageFill, price float64) error {
+	if fill.Reduced != nil {
+		message := models.NewLeveragePositionUpdateMessage(fill.Reduced)
+		if fill.ReducedClosed {
+			message = models.NewLeveragePositionClosedMessage(fill.Reduced, price)
+		}
+		if err := enqueueNotification(to, userId, message); err != nil {
+			return err
+		}
+	}
+	if fill.Position != nil {
+		message := models.NewLeveragePositionOpenedMessage(fill.Position)
+		if fill.Increased {
+			message = models.NewLeveragePositionUpdateMessage(fill.Position)
+		}
+		if err := enqueueNotification(to, userId, message); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // CloseLeveragePosition 平槓桿倉位
-func CloseLeveragePosition(userId int64, positionId int64) (*models.LeveragePosition, error) {
+// quantity 為 0 或不小於持倉數量時全部平倉，否則部分平倉
+func CloseLeveragePosition(userId int64, positionId int64, quantity float64) (*models.LeveragePosition, error) {
+	if quantity < 0 {
+		return nil, errors.New("quantity must not be negative")
+	}
+
 	// 1. 獲取倉位
 	position, err := models.GetPositionById(positionId)
 	if err != nil {
@@ -220,89 +311,397 @@ func CloseLeveragePosition(userId int64, positionId int64) (*models.LeveragePosi
 		}
 	}()
 
-	// 4. 計算盈虧
-	pnl := position.CalculateUnrealizedPnL(currentPrice)
+	// 4. 在交易中依序鎖定錢包與倉位（與開倉、掛單使用相同的鎖順序）
+	if _, err = models.GetWalletForUpdate(to, userId, "USDT"); err != nil {
+		return nil, errors.New("USDT wallet not found")
+	}
+	position, err = models.GetPositionForUpdate(to, positionId)
+	if err != nil {
+		return nil, err
+	}
+	quantityBefore := position.Quantity
 
-	// 5. 平倉
-	err = models.ClosePosition(positionId, userId, currentPrice)
+	// 5. 平倉或部分平倉，並返還保證金 + 盈虧到 USDT 錢包
+	realizedPnL, closed, err := models.ReducePosition(models.NewTxLeverageLedger(to), position, userId, quantity, currentPrice)
 	if err != nil {
 		return nil, err
 	}
 
-	// 6. 返還保證金 + 盈虧到 USDT 錢包
-	returnAmount := position.Margin + pnl
+	// 6. 寫入平倉事件與倉位通知並提交交易
+	if err = models.EnqueueWebhookEvent(to, userId, models.WebhookEventPositionClosed, position); err != nil {
+		return nil, fmt.Errorf("failed to enqueue webhook event: %v", err)
+	}
+	message := models.NewLeveragePositionClosedMessage(position, currentPrice)
+	if !closed {
+		message = models.NewLeveragePositionUpdateMessage(position)
+	}
+	if err = enqueueNotification(to, userId, message); err != nil {
+		return nil, err
+	}
 
-	wallet, err := models.GetWalletByUserAndSymbol(userId, "USDT")
+	err = to.Commit()
 	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	shouldRollback = false
+	GlobalNotificationDispatcher.Notify()
+
+	log.Printf("Leverage position closed: User=%d, Position=#%d, Quantity=%.8f, ExitPrice=%.2f, PnL=%.2f, Partial=%t",
+		userId, positionId, quantityBefore-position.Quantity, currentPrice, realizedPnL, !closed)
+
+	trackPositions(position)
+
+	return position, nil
+}
+
+// PlaceTrailingStopClose 為槓桿倉位下追蹤停損平倉單（只減倉，不凍結保證金）
+// quantity 為 0 或不小於持倉數量時觸發後全部平倉；平多為賣出並追蹤最高價，平空為買入並追蹤最低價
+func PlaceTrailingStopClose(userId int64, positionId int64, quantity float64, params models.TrailingStopParams) (*models.Order, error) {
+	// 1. 驗證輸入
+	if quantity < 0 {
+		return nil, errors.New("quantity must not be negative")
+	}
+
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	o := orm.NewOrm()
+	to, err := o.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	shouldRollback := true
+	defer func() {
+		if shouldRollback {
+			to.Rollback()
+		}
+	}()
+
+	// 2. 依序鎖定錢包與倉位（與開倉、掛單使用相同的鎖順序），並檢查倉位
+	if _, err = models.GetWalletForUpdate(to, userId, "USDT"); err != nil {
 		return nil, errors.New("USDT wallet not found")
 	}
+	position, err := models.GetPositionForUpdate(to, positionId)
+	if err != nil {
+		return nil, err
+	}
+	if position.User.Id != userId {
+		return nil, errors.New("unauthorized: position does not belong to user")
+	}
+	if position.Status != models.PositionStatusOpen {
+		return nil, errors.New("position is not open")
+	}
+	if quantity == 0 || quantity > position.Quantity {
+		quantity = position.Quantity
+	}
+
+	currentPrice, ok := GlobalPriceCache.GetPrice(position.Symbol)
+	if !ok {
+		return nil, fmt.Errorf("price not available for %s", position.Symbol)
+	}
+
+	// 3. 建立反方向的只減倉追蹤停損單
+	side := models.OrderSideSell
+	if position.Side == models.PositionSideShort {
+		side = models.OrderSideBuy
+	}
+	order, err := models.CreateTrailingStopOrder(to, userId, models.OrderParent{}, position.Symbol, side, quantity, params,
+		position.Leverage, position.Side.Opposite(), currentPrice)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create order: %v", err)
+	}
+
+	if err = to.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	shouldRollback = false
+
+	log.Printf("Trailing stop close order #%d created: User=%d, Position=#%d, Side=%s, Quantity=%.8f, TriggerPrice=%.2f",
+		order.Id, userId, positionId, side, quantity, order.TriggerPrice)
+
+	// 4. 加入撮合器監控
+	GlobalLimitOrderMatcher.AddOrder(order)
+
+	return order, nil
+}
 
-	if returnAmount > 0 {
-		err = models.UpdateBalance(to, wallet.Id, returnAmount, 0)
-		if err != nil {
-			return nil, fmt.Errorf("failed to return funds: %v", err)
+// AdjustPositionMargin 追加（amount > 0）或減少（amount < 0）倉位保證金
+func AdjustPositionMargin(userId int64, positionId int64, amount float64) (*models.LeveragePosition, error) {
+	if amount == 0 {
+		return nil, errors.New("amount must not be zero")
+	}
+
+	// 1. 開始資料庫交易
+	o := orm.NewOrm()
+	to, err := o.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	shouldRollback := true
+	defer func() {
+		if shouldRollback {
+			to.Rollback()
 		}
+	}()
+
+	// 2. 依序鎖定錢包與倉位（與開倉、掛單使用相同的鎖順序），並驗證所有權
+	wallet, err := models.GetWalletForUpdate(to, userId, "USDT")
+	if err != nil {
+		return nil, errors.New("USDT wallet not found")
+	}
+	position, err := models.GetPositionForUpdate(to, positionId)
+	if err != nil {
+		return nil, err
+	}
+	if position.User.Id != userId {
+		return nil, errors.New("unauthorized: position does not belong to user")
+	}
+
+	markPrice, ok := GlobalPriceCache.GetMarkPrice(position.Symbol)
+	if !ok {
+		return nil, fmt.Errorf("price not available for %s", position.Symbol)
+	}
+
+	// 3. 調整保證金並重新計算爆倉價格
+	if err = position.AdjustMargin(amount, markPrice); err != nil {
+		return nil, err
+	}
+
+	// 4. 在錢包與倉位保證金帳戶之間轉移 USDT
+	balanceBefore := wallet.Balance
+	transactionType := models.TransactionTypeMarginDeposit
+	if amount > 0 {
+		err = wallet.TransferMarginToPosition(amount, false)
+	} else {
+		transactionType = models.TransactionTypeMarginWithdraw
+		err = wallet.ReceiveMarginFromPosition(-amount)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err = to.Update(wallet, "Balance"); err != nil {
+		return nil, fmt.Errorf("failed to update wallet: %v", err)
+	}
+
+	if err = models.UpdatePositionMargin(to, position); err != nil {
+		return nil, fmt.Errorf("failed to update position: %v", err)
 	}
 
-	// 7. 記錄交易
-	transactionType := models.TransactionTypeMarginWithdraw
-	description := fmt.Sprintf("Close %s position #%d: PnL %.2f USDT", position.Side, position.Id, pnl)
-	_, err = models.CreateTransaction(to, userId, nil, transactionType, "USDT", returnAmount,
-		wallet.Balance, wallet.Balance+returnAmount, description)
+	_, err = models.CreatePositionHistory(to, position, models.PositionActionAdjustMargin, markPrice, 0, amount, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record position history: %v", err)
+	}
+
+	// 5. 記錄交易
+	description := fmt.Sprintf("Adjust margin of %s position #%d by %.2f USDT", position.Side, position.Id, amount)
+	_, err = models.CreateTransaction(to, userId, nil, transactionType, "USDT", -amount,
+		balanceBefore, wallet.Balance, description)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create transaction: %v", err)
 	}
 
-	// 8. 提交交易
+	// 6. 寫入倉位通知並提交交易
+	if err = enqueueNotification(to, userId, models.NewLeveragePositionUpdateMessage(position)); err != nil {
+		return nil, err
+	}
+
+	if err = to.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	shouldRollback = false
+	GlobalNotificationDispatcher.Notify()
+
+	log.Printf("Position margin adjusted: User=%d, Position=#%d, Amount=%.2f, Margin=%.2f, LiqPrice=%.2f",
+		userId, positionId, amount, position.Margin, position.LiquidationPrice)
+
+	trackPositions(position)
+
+	return position, nil
+}
+
+// liquidatePosition 執行逐倉爆倉：以標記價格強制平倉
+// 使用者損失全部保證金，剩餘保證金轉入保險基金，穿倉虧損由保險基金承擔
+func liquidatePosition(position *models.LeveragePosition, markPrice float64) error {
+	o := orm.NewOrm()
+	to, err := o.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	shouldRollback := true
+	defer func() {
+		if shouldRollback {
+			to.Rollback()
+		}
+	}()
+
+	// 在交易中依序鎖定錢包與倉位（與開倉、平倉使用相同的鎖順序），避免與使用者平倉同時發生
+	userId := position.User.Id
+	if _, err = models.GetWalletForUpdate(to, userId, "USDT"); err != nil {
+		return err
+	}
+	position, err = models.GetPositionForUpdate(to, position.Id)
+	if err != nil {
+		return err
+	}
+
+	// 索引中的資料可能已過時（已平倉或已追加保證金），以鎖定後的倉位重新判斷
+	if position.Status != models.PositionStatusOpen || !position.IsLiquidated(markPrice) {
+		trackPositions(position)
+		return nil
+	}
+
+	// 平倉（爆倉），結算保險基金與保證金帳戶
+	result, err := models.LiquidateIsolatedPosition(models.NewTxLeverageLedger(to), position, markPrice)
+	if err != nil {
+		return err
+	}
+	logUncoveredLoss(userId, result)
+
+	if err = models.EnqueueWebhookEvent(to, userId, models.WebhookEventPositionLiquidated, position); err != nil {
+		return fmt.Errorf("failed to enqueue webhook event: %v", err)
+	}
+	if err = enqueueNotification(to, userId, models.NewLeveragePositionClosedMessage(position, markPrice)); err != nil {
+		return err
+	}
+
 	err = to.Commit()
 	if err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+		return fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
 	shouldRollback = false
+	GlobalNotificationDispatcher.Notify()
 
-	// 9. 重新讀取倉位以獲取更新後的數據
-	position, _ = models.GetPositionById(positionId)
+	log.Printf("Position #%d liquidated successfully at %.2f, insurance fund change %.2f", position.Id, markPrice, result.Surplus)
 
-	log.Printf("Leverage position closed: User=%d, Position=#%d, ExitPrice=%.2f, PnL=%.2f",
-		userId, positionId, currentPrice, pnl)
+	trackPositions(position)
 
-	// 發送 WebSocket 通知給用戶
-	message := models.NewLeveragePositionClosedMessage(position, currentPrice)
-	hub.GlobalHub.BroadcastToUser(userId, message.ToJSON())
+	return nil
+}
 
-	return position, nil
+// logUncoveredLoss 記錄保險基金不足以承擔的穿倉虧損
+func logUncoveredLoss(userId int64, result *models.LiquidationResult) {
+	if result.Applied != result.Surplus {
+		log.Printf("Insurance fund insufficient: uncovered loss %.2f USDT from user %d recorded as deficit", result.Applied-result.Surplus, userId)
+	}
 }
 
-// CheckAndLiquidatePositions 檢查並執行爆倉
-func CheckAndLiquidatePositions() {
-	positions, err := models.GetAllOpenPositions()
+// liquidateCrossAccount 全倉爆倉：以標記價格強制平掉使用者所有全倉持倉
+// 使用者損失全部可用餘額，剩餘權益轉入保險基金，穿倉虧損由保險基金承擔
+func liquidateCrossAccount(userId int64) error {
+	o := orm.NewOrm()
+	to, err := o.Begin()
 	if err != nil {
-		log.Printf("Failed to get open positions: %v", err)
-		return
+		return fmt.Errorf("failed to start transaction: %v", err)
 	}
 
+	shouldRollback := true
+	defer func() {
+		if shouldRollback {
+			to.Rollback()
+		}
+	}()
+
+	// 1. 在交易中鎖定錢包與所有全倉持倉
+	ledger := models.NewTxLeverageLedger(to)
+	if _, err = ledger.Wallet(userId); err != nil {
+		return err
+	}
+
+	positions, err := models.GetOpenCrossPositionsForUpdate(to, userId)
+	if err != nil {
+		return fmt.Errorf("failed to get positions: %v", err)
+	}
+	if len(positions) == 0 {
+		return nil
+	}
+
+	markPrices := make(map[string]float64, len(positions))
 	for _, position := range positions {
-		currentPrice, ok := GlobalPriceCache.GetPrice(position.Symbol)
+		markPrice, ok := GlobalPriceCache.GetMarkPrice(position.Symbol)
 		if !ok {
-			continue
+			return fmt.Errorf("price not available for %s", position.Symbol)
 		}
+		markPrices[position.Symbol] = markPrice
+	}
 
-		// 檢查是否觸發爆倉
-		if position.IsLiquidated(currentPrice) {
-			log.Printf("Liquidating position #%d: User=%d, Symbol=%s, Side=%s, LiqPrice=%.2f, CurrentPrice=%.2f",
-				position.Id, position.User.Id, position.Symbol, position.Side, position.LiquidationPrice, currentPrice)
+	// 2. 鎖定後以標記價格重新檢查，強制平倉並結算錢包與保險基金
+	result, err := models.LiquidateCrossAccount(ledger, userId, positions, markPrices)
+	if err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	logUncoveredLoss(userId, result)
 
-			err := liquidatePosition(position)
-			if err != nil {
-				log.Printf("Failed to liquidate position #%d: %v", position.Id, err)
-			}
+	for _, position := range positions {
+		if err = models.EnqueueWebhookEvent(to, userId, models.WebhookEventPositionLiquidated, position); err != nil {
+			return fmt.Errorf("failed to enqueue webhook event: %v", err)
+		}
+		if err = enqueueNotification(to, userId, models.NewLeveragePositionClosedMessage(position, position.ExitPrice)); err != nil {
+			return err
 		}
 	}
+
+	err = to.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	shouldRollback = false
+	GlobalNotificationDispatcher.Notify()
+
+	log.Printf("Cross margin account of user %d liquidated: %d positions, insurance fund change %.2f", userId, len(positions), result.Surplus)
+
+	trackPositions(positions...)
+
+	return nil
 }
 
-// liquidatePosition 執行爆倉
-func liquidatePosition(position *models.LeveragePosition) error {
+// GetMarginMode 查詢使用者的保證金模式，全倉時一併返回帳戶狀態
+func GetMarginMode(userId int64) (models.MarginMode, *models.CrossMarginStatus, error) {
+	mode, err := models.GetUserMarginMode(orm.NewOrm(), userId)
+	if err != nil {
+		return "", nil, err
+	}
+	if mode != models.MarginModeCross {
+		return mode, nil, nil
+	}
+
+	wallet, err := models.GetWalletByUserAndSymbol(userId, "USDT")
+	if err != nil {
+		return "", nil, errors.New("USDT wallet not found")
+	}
+
+	positions, err := models.GetOpenPositionsByUser(userId)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get positions: %v", err)
+	}
+	markPrices := GlobalPriceCache.GetAllMarkPrices()
+	for _, position := range positions {
+		if markPrice, ok := markPrices[position.Symbol]; ok {
+			position.UnrealizedPnL = position.CalculateUnrealizedPnL(markPrice)
+		}
+	}
+
+	status := models.CalculateCrossMarginStatus(wallet.GetAvailableBalance(), positions, markPrices)
+	return mode, &status, nil
+}
+
+// SetMarginMode 切換保證金模式，需沒有持倉與未成交的槓桿限價單
+func SetMarginMode(userId int64, mode models.MarginMode) error {
+	if !mode.IsValid() {
+		return errors.New("invalid margin mode")
+	}
+
 	o := orm.NewOrm()
 	to, err := o.Begin()
 	if err != nil {
@@ -316,22 +715,29 @@ func liquidatePosition(position *models.LeveragePosition) error {
 		}
 	}()
 
-	// 載入 User
-	o.LoadRelated(position, "User")
-	userId := position.User.Id
+	// 先鎖定錢包，與開倉、掛單使用相同的鎖順序
+	if _, err = models.GetWalletForUpdate(to, userId, "USDT"); err != nil {
+		return errors.New("USDT wallet not found")
+	}
 
-	// 平倉（爆倉）
-	err = models.LiquidatePosition(position.Id)
+	openPositions, err := models.CountOpenPositionsByUser(to, userId)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to count positions: %v", err)
+	}
+	if openPositions > 0 {
+		return errors.New("cannot change margin mode with open positions")
 	}
 
-	// 記錄交易（保證金全部虧損）
-	transactionType := models.TransactionTypeLiquidation
-	description := fmt.Sprintf("Position #%d liquidated at %.2f", position.Id, position.LiquidationPrice)
-	_, err = models.CreateTransaction(to, userId, nil, transactionType, "USDT", 0, 0, 0, description)
+	pendingOrders, err := models.CountPendingLeverageOrdersByUser(to, userId)
 	if err != nil {
-		return fmt.Errorf("failed to create transaction: %v", err)
+		return fmt.Errorf("failed to count orders: %v", err)
+	}
+	if pendingOrders > 0 {
+		return errors.New("cannot change margin mode with pending leverage orders")
+	}
+
+	if err = models.UpdateUserMarginMode(to, userId, mode); err != nil {
+		return fmt.Errorf("failed to update margin mode: %v", err)
 	}
 
 	err = to.Commit()
@@ -341,29 +747,84 @@ func liquidatePosition(position *models.LeveragePosition) error {
 
 	shouldRollback = false
 
-	log.Printf("Position #%d liquidated successfully", position.Id)
-
-	// 發送 WebSocket 通知給用戶
-	message := models.NewLeveragePositionClosedMessage(position, position.LiquidationPrice)
-	hub.GlobalHub.BroadcastToUser(userId, message.ToJSON())
-
+	log.Printf("Margin mode changed: User=%d, Mode=%s", userId, mode)
 	return nil
 }
 
-// UpdateAllPositionsPnL 更新所有持倉的盈虧
-func UpdateAllPositionsPnL() {
-	// 這個函數可以定期調用來更新所有持倉的未實現盈虧
-	positions, err := models.GetAllOpenPositions()
+// GetPositionMode 查詢使用者的持倉模式
+func GetPositionMode(userId int64) (models.PositionMode, error) {
+	return models.GetUserPositionMode(orm.NewOrm(), userId)
+}
+
+// SetPositionMode 切換持倉模式，需沒有持倉與未成交的槓桿限價單
+func SetPositionMode(userId int64, mode models.PositionMode) error {
+	if !mode.IsValid() {
+		return errors.New("invalid position mode")
+	}
+
+	o := orm.NewOrm()
+	to, err := o.Begin()
 	if err != nil {
-		return
+		return fmt.Errorf("failed to start transaction: %v", err)
 	}
 
-	for _, position := range positions {
-		currentPrice, ok := GlobalPriceCache.GetPrice(position.Symbol)
-		if !ok {
-			continue
+	shouldRollback := true
+	defer func() {
+		if shouldRollback {
+			to.Rollback()
 		}
+	}()
+
+	// 先鎖定錢包，與開倉、掛單使用相同的鎖順序
+	if _, err = models.GetWalletForUpdate(to, userId, "USDT"); err != nil {
+		return errors.New("USDT wallet not found")
+	}
+
+	openPositions, err := models.CountOpenPositionsByUser(to, userId)
+	if err != nil {
+		return fmt.Errorf("failed to count positions: %v", err)
+	}
+	if openPositions > 0 {
+		return errors.New("cannot change position mode with open positions")
+	}
 
-		models.UpdatePositionPnL(position, currentPrice)
+	pendingOrders, err := models.CountPendingLeverageOrdersByUser(to, userId)
+	if err != nil {
+		return fmt.Errorf("failed to count orders: %v", err)
+	}
+	if pendingOrders > 0 {
+		return errors.New("cannot change position mode with pending leverage orders")
+	}
+
+	if err = models.UpdateUserPositionMode(to, userId, mode); err != nil {
+		return fmt.Errorf("failed to update position mode: %v", err)
+	}
+
+	err = to.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	shouldRollback = false
+
+	log.Printf("Position mode changed: User=%d, Mode=%s", userId, mode)
+	return nil
+}
+
+// releaseOrderMargin 解除槓桿限價單凍結的保證金（需要在交易中使用）
+func releaseOrderMargin(to orm.TxOrmer, order *models.Order) error {
+	margin := order.RequiredMargin()
+	if margin <= 0 {
+		return nil
+	}
+
+	wallet, err := models.GetWalletForUpdate(to, order.User.Id, "USDT")
+	if err != nil {
+		return errors.New("USDT wallet not found")
+	}
+	if err = wallet.UnlockMargin(margin); err != nil {
+		return err
 	}
+	_, err = to.Update(wallet, "Locked")
+	return err
 }
@@ -1,7 +1,6 @@
 package services
 
 import (
-	"backend/hub"
 	"backend/models"
 	"errors"
 	"fmt"
@@ -12,6 +11,9 @@ import (
 	"github.com/beego/beego/v2/client/orm"
 )
 
+// errOrderAmended 訂單在撮合器檢查後被修改，需以新的價格重新檢查，不標記為失敗
+var errOrderAmended = errors.New("order was amended")
+
 // LimitOrderMatcher 限價單撮合器
 type LimitOrderMatcher struct {
 	mu            sync.RWMutex
@@ -19,6 +21,8 @@ type LimitOrderMatcher struct {
 	stopChan      chan struct{}
 	checkInterval time.Duration
 	pendingOrders map[int64]*models.Order // orderId -> Order
+	trailingDirty map[int64]bool          // 追蹤狀態有變動、尚未寫回資料庫的追蹤停損單
+	fillListeners []func(order *models.Order)
 }
 
 var GlobalLimitOrderMatcher *LimitOrderMatcher
@@ -27,6 +31,7 @@ func init() {
 	GlobalLimitOrderMatcher = &LimitOrderMatcher{
 		checkInterval: 1 * time.Second, // 每秒檢查一次
 		pendingOrders: make(map[int64]*models.Order),
+		trailingDirty: make(map[int64]bool),
 		stopChan:      make(chan struct{}),
 	}
 }
@@ -46,6 +51,9 @@ func (m *LimitOrderMatcher) Start() {
 	// 載入現有的待處理限價單
 	m.loadPendingOrders()
 
+	// 追蹤停損單需要每筆成交價格更新最高價/最低價，不能只靠定期檢查
+	GlobalPriceCache.OnPriceUpdate(m.onPriceUpdate)
+
 	// 啟動監控循環
 	go m.run()
 }
@@ -80,6 +88,72 @@ func (m *LimitOrderMatcher) loadPendingOrders() {
 	}
 
 	log.Printf("Loaded %d pending limit orders", len(orders))
+
+	trailingOrders, err := models.GetPendingTrailingStopOrders()
+	if err != nil {
+		log.Printf("Failed to load pending trailing stop orders: %v", err)
+		return
+	}
+
+	for _, order := range trailingOrders {
+		m.pendingOrders[order.Id] = order
+	}
+
+	log.Printf("Loaded %d pending trailing stop orders", len(trailingOrders))
+}
+
+// onPriceUpdate 以最新成交價更新追蹤停損單，觸發的訂單以市價執行（由價格快取呼叫，不可阻塞）
+func (m *LimitOrderMatcher) onPriceUpdate(symbol string) {
+	price, ok := GlobalPriceCache.GetPrice(symbol)
+	if !ok {
+		return
+	}
+
+	var triggered []*models.Order
+	m.mu.Lock()
+	for _, order := range m.pendingOrders {
+		if order.Type != models.OrderTypeTrailingStop || order.Symbol != symbol {
+			continue
+		}
+		changed, fire := order.UpdateTrailingStop(price)
+		if fire {
+			delete(m.pendingOrders, order.Id)
+			delete(m.trailingDirty, order.Id)
+			triggered = append(triggered, order)
+		} else if changed {
+			m.trailingDirty[order.Id] = true
+		}
+	}
+	m.mu.Unlock()
+
+	for _, order := range triggered {
+		log.Printf("Trailing stop order #%d triggered: %s %s at trigger price %.2f, current price %.2f",
+			order.Id, order.Side, order.Symbol, order.TriggerPrice, price)
+		go func(order *models.Order) {
+			if err := m.executeLimitOrder(order, price); err != nil {
+				log.Printf("Failed to execute trailing stop order #%d: %v", order.Id, err)
+			}
+		}(order)
+	}
+}
+
+// flushTrailingStops 寫回有變動的追蹤停損狀態，讓訂單查詢能顯示目前的觸發價格
+func (m *LimitOrderMatcher) flushTrailingStops() {
+	m.mu.Lock()
+	states := make([]models.Order, 0, len(m.trailingDirty))
+	for orderId := range m.trailingDirty {
+		if order, ok := m.pendingOrders[orderId]; ok {
+			states = append(states, *order)
+		}
+	}
+	m.trailingDirty = make(map[int64]bool)
+	m.mu.Unlock()
+
+	for i := range states {
+		if err := models.UpdateTrailingStopState(&states[i]); err != nil {
+			log.Printf("Failed to update trailing stop order #%d: %v", states[i].Id, err)
+		}
+	}
 }
 
 // run 主要監控循環
@@ -93,6 +167,7 @@ func (m *LimitOrderMatcher) run() {
 			return
 		case <-ticker.C:
 			m.checkAndExecuteOrders()
+			m.flushTrailingStops()
 		}
 	}
 }
@@ -102,7 +177,10 @@ func (m *LimitOrderMatcher) checkAndExecuteOrders() {
 	m.mu.RLock()
 	ordersCopy := make([]*models.Order, 0, len(m.pendingOrders))
 	for _, order := range m.pendingOrders {
-		ordersCopy = append(ordersCopy, order)
+		// 追蹤停損單由 onPriceUpdate 處理
+		if order.Type != models.OrderTypeTrailingStop {
+			ordersCopy = append(ordersCopy, order)
+		}
 	}
 	m.mu.RUnlock()
 
@@ -116,7 +194,10 @@ func (m *LimitOrderMatcher) checkAndExecuteOrders() {
 		shouldExecute := false
 
 		// 判斷是否應該執行訂單
-		if order.Side == models.OrderSideBuy {
+		if order.Type == models.OrderTypeStop {
+			// 停損單：價格觸及停損價時以市價執行
+			shouldExecute = order.StopTriggered(currentPrice)
+		} else if order.Side == models.OrderSideBuy {
 			// 買入限價單：當市價 <= 限價時執行
 			if currentPrice <= order.LimitPrice {
 				shouldExecute = true
@@ -129,19 +210,23 @@ func (m *LimitOrderMatcher) checkAndExecuteOrders() {
 		}
 
 		if shouldExecute {
-			log.Printf("Executing limit order #%d: %s %s at limit price %.2f, current price %.2f",
-				order.Id, order.Side, order.Symbol, order.LimitPrice, currentPrice)
+			log.Printf("Executing %s order #%d: %s %s at limit price %.2f, stop price %.2f, current price %.2f",
+				order.Type, order.Id, order.Side, order.Symbol, order.LimitPrice, order.StopPrice, currentPrice)
 
 			// 執行限價單
 			err := m.executeLimitOrder(order, currentPrice)
+			if err == errOrderAmended {
+				// 修改後的訂單已由 AmendOrder 放回撮合器，下一輪以新價格檢查
+				continue
+			}
 			if err != nil {
 				log.Printf("Failed to execute limit order #%d: %v", order.Id, err)
-			} else {
-				// 從待處理列表中移除
-				m.mu.Lock()
-				delete(m.pendingOrders, order.Id)
-				m.mu.Unlock()
 			}
+
+			// 無論成功或失敗（已標記為 FAILED）都從待處理列表中移除
+			m.mu.Lock()
+			delete(m.pendingOrders, order.Id)
+			m.mu.Unlock()
 		}
 	}
 }
@@ -163,7 +248,7 @@ func (m *LimitOrderMatcher) executeLimitOrder(order *models.Order, currentPrice
 	o := orm.NewOrm()
 	to, err := o.Begin()
 	if err != nil {
-		models.UpdateOrderStatus(orm.NewOrm(), order.Id, models.OrderStatusFailed, 0, 0, "Failed to start transaction")
+		failLimitOrder(order, "Failed to start transaction")
 		return fmt.Errorf("failed to start transaction: %v", err)
 	}
 
@@ -171,8 +256,8 @@ func (m *LimitOrderMatcher) executeLimitOrder(order *models.Order, currentPrice
 	defer func() {
 		if shouldRollback {
 			to.Rollback()
-			if err != nil {
-				models.UpdateOrderStatus(orm.NewOrm(), order.Id, models.OrderStatusFailed, 0, 0, err.Error())
+			if err != nil && err != errOrderAmended {
+				failLimitOrder(order, err.Error())
 			}
 		}
 	}()
@@ -181,101 +266,61 @@ func (m *LimitOrderMatcher) executeLimitOrder(order *models.Order, currentPrice
 	var totalAmount float64
 	var actualQuantity float64
 
-	// 取得 User ID（需要先讀取完整的 order 資料）
+	// OCO 子訂單先鎖定訂單組，確保兩個子訂單不會同時成交
+	var orderList *models.OrderList
+	if order.OrderListId > 0 {
+		if orderList, err = models.GetOrderListForUpdate(to, order.OrderListId); err != nil {
+			return fmt.Errorf("failed to read order list: %v", err)
+		}
+	}
+
+	// 取得 User ID（需要先讀取完整的 order 資料，並在交易中鎖定）
 	fullOrder := &models.Order{Id: order.Id}
-	if err := orm.NewOrm().Read(fullOrder); err != nil {
+	if err = to.ReadForUpdate(fullOrder); err != nil {
 		return fmt.Errorf("failed to read order: %v", err)
 	}
 
+	// 訂單可能已被取消，避免重複結算凍結的保證金
+	if fullOrder.Status != models.OrderStatusPending {
+		return fmt.Errorf("order is no longer pending: %s", fullOrder.Status)
+	}
+
+	// 撮合器判斷成交時使用的價格或數量已被修改（與 AmendOrder 使用同一個訂單鎖）
+	if fullOrder.Version != order.Version {
+		err = errOrderAmended
+		return err
+	}
+
 	// 需要載入 User 關聯
 	orm.NewOrm().LoadRelated(fullOrder, "User")
 	userId := fullOrder.User.Id
 
+	// 限價單以限價成交，停損單與追蹤停損單觸發後以市價成交
+	fillPrice := fullOrder.LimitPrice
+	if fullOrder.Type != models.OrderTypeLimit {
+		fillPrice = currentPrice
+	}
+
 	// 區分槓桿訂單和現貨訂單的執行邏輯
+	var fill *models.LeverageFill
 	if fullOrder.IsLeverageOrder {
-		// 槓桿訂單：不扣除完整 USDT，只更新幣種錢包
-		actualQuantity = fullOrder.Quantity
-		totalAmount = fullOrder.Quantity * fullOrder.LimitPrice
-
-		if fullOrder.Side == models.OrderSideBuy {
-			// 買入：增加 base 幣錢包，不扣除 USDT
-			baseWallet := &models.Wallet{}
-			err = to.QueryTable(new(models.Wallet)).
-				Filter("User__Id", userId).
-				Filter("Symbol", base).
-				One(baseWallet)
-
-			if err == orm.ErrNoRows {
-				// 錢包不存在，建立一個
-				baseWallet = &models.Wallet{
-					User:    &models.User{Id: userId},
-					Symbol:  base,
-					Balance: 0,
-					Locked:  0,
-				}
-				_, err = to.Insert(baseWallet)
-				if err != nil {
-					return fmt.Errorf("failed to create %s wallet: %v", base, err)
-				}
-			} else if err != nil {
-				return fmt.Errorf("failed to get %s wallet: %v", base, err)
-			}
-
-			// 增加 base 幣餘額
-			baseWallet.Balance += actualQuantity
-			_, err = to.Update(baseWallet, "Balance")
-			if err != nil {
-				return fmt.Errorf("failed to update %s balance: %v", base, err)
-			}
-		} else {
-			// 賣出：減少 base 幣錢包，增加 USDT
-			baseWallet := &models.Wallet{}
-			err = to.QueryTable(new(models.Wallet)).
-				Filter("User__Id", userId).
-				Filter("Symbol", base).
-				One(baseWallet)
-
-			if err != nil {
-				return fmt.Errorf("failed to get %s wallet: %v", base, err)
-			}
-
-			if baseWallet.GetAvailableBalance() < actualQuantity {
-				return errors.New("insufficient coin balance")
-			}
-
-			// 減少 base 幣餘額
-			baseWallet.Balance -= actualQuantity
-			_, err = to.Update(baseWallet, "Balance")
-			if err != nil {
-				return fmt.Errorf("failed to update %s balance: %v", base, err)
-			}
-
-			// 增加 USDT 錢包（不扣除保證金）
-			quoteWallet := &models.Wallet{}
-			err = to.QueryTable(new(models.Wallet)).
-				Filter("User__Id", userId).
-				Filter("Symbol", quote).
-				One(quoteWallet)
-
-			if err != nil {
-				return fmt.Errorf("failed to get %s wallet: %v", quote, err)
-			}
-
-			quoteWallet.Balance += totalAmount
-			_, err = to.Update(quoteWallet, "Balance")
-			if err != nil {
-				return fmt.Errorf("failed to update %s balance: %v", quote, err)
-			}
+		// 槓桿訂單：不動用現貨錢包，依持倉模式減倉或開倉，掛單時凍結的保證金轉入新倉位的保證金帳戶
+		fill, err = models.FillLeverageOrder(models.NewTxLeverageLedger(to), userId, fullOrder, fullOrder.Symbol, models.PositionSide(fullOrder.PositionSideStr),
+			fullOrder.Leverage, fillPrice, fullOrder.Quantity, fullOrder.ReduceOnly, fullOrder.RequiredMargin())
+		if err != nil {
+			return err
 		}
+		actualQuantity = fill.ReduceQuantity + fill.OpenQuantity
+		totalAmount = actualQuantity * fillPrice
 	} else {
 		// 現貨訂單：正常執行，扣除完整 USDT
 		if fullOrder.Side == models.OrderSideBuy {
 			// 買入：計算需要的 USDT 金額 = 幣種數量 × 限價
-			usdtAmount := fullOrder.Quantity * fullOrder.LimitPrice
-			totalAmount, actualQuantity, err = executeBuyOrder(to, userId, base, quote, usdtAmount, fullOrder.LimitPrice, fullOrder.Id)
+			usdtAmount := fullOrder.Quantity * fillPrice
+			totalAmount, actualQuantity, err = executeBuyOrder(to, userId, base, quote, usdtAmount, fillPrice, fullOrder.Id)
 		} else {
 			// 賣出：直接使用幣種數量
-			totalAmount, actualQuantity, err = executeSellOrder(to, userId, base, quote, fullOrder.Quantity, fullOrder.LimitPrice, fullOrder.Id)
+			totalAmount, actualQuantity, err = executeSellOrder(to, userId, base, quote, fullOrder.Quantity, fillPrice, fullOrder.Id)
 		}
 
 		if err != nil {
@@ -289,6 +334,47 @@ func (m *LimitOrderMatcher) executeLimitOrder(order *models.Order, currentPrice
 		return fmt.Errorf("failed to update order: %v", err)
 	}
 
+	// 寫入訂單成交的 webhook 事件（與成交在同一個交易中）
+	if err = models.EnqueueOrderFilledWebhook(to, userId, fullOrder.Id); err != nil {
+		return fmt.Errorf("failed to enqueue webhook event: %v", err)
+	}
+
+	// OCO 子訂單成交後取消另一個子訂單
+	var canceledOrderIds []int64
+	if orderList != nil {
+		if canceledOrderIds, err = models.FinishOrderList(to, orderList, models.OrderListStatusCompleted, fullOrder.Id); err != nil {
+			return fmt.Errorf("failed to finish order list: %v", err)
+		}
+	}
+
+	// 寫入成交通知（槓桿訂單另有倉位通知），與成交在同一個交易中
+	var message *models.WSMessage
+	if fullOrder.Type != models.OrderTypeLimit {
+		fullOrder.Status = models.OrderStatusCompleted
+		fullOrder.Price = fillPrice
+		fullOrder.Quantity = actualQuantity
+		fullOrder.TotalAmount = totalAmount
+		message = models.NewOrderExecutedMessage(fullOrder)
+	} else {
+		message = models.NewLimitOrderFilledMessage(
+			fullOrder.Id,
+			fullOrder.Symbol,
+			fullOrder.Side,
+			fullOrder.LimitPrice,
+			currentPrice,
+			actualQuantity,
+			totalAmount,
+		)
+	}
+	if err = enqueueNotification(to, userId, message); err != nil {
+		return err
+	}
+	if fill != nil {
+		if err = enqueueLeverageFill(to, userId, fill, fillPrice); err != nil {
+			return err
+		}
+	}
+
 	// 提交交易
 	err = to.Commit()
 	if err != nil {
@@ -296,85 +382,164 @@ func (m *LimitOrderMatcher) executeLimitOrder(order *models.Order, currentPrice
 	}
 
 	shouldRollback = false
+	GlobalNotificationDispatcher.Notify()
 
-	log.Printf("Limit order #%d executed successfully: %s %s %.8f at price %.2f, total %.2f",
-		order.Id, order.Side, order.Symbol, actualQuantity, currentPrice, totalAmount)
-
-	// 發送 WebSocket 通知給用戶
-	message := models.NewLimitOrderFilledMessage(
-		fullOrder.Id,
-		fullOrder.Symbol,
-		fullOrder.Side,
-		fullOrder.LimitPrice,
-		currentPrice,
-		actualQuantity,
-		totalAmount,
-	)
-	hub.GlobalHub.BroadcastToUser(userId, message.ToJSON())
-
-	// 如果這是一個槓桿訂單，建立槓桿倉位
-	if fullOrder.IsLeverageOrder {
-		positionSide := models.PositionSide(fullOrder.PositionSideStr)
-		// fullOrder.Quantity 代表想要購買的幣種數量
-		// 保證金 = (數量 × 限價) / 槓桿倍數
-		margin := (fullOrder.Quantity * fullOrder.LimitPrice) / float64(fullOrder.Leverage)
-
-		// 計算爆倉價格
-		var liquidationPrice float64
-		liquidationRatio := 0.9 / float64(fullOrder.Leverage)
-		if positionSide == models.PositionSideLong {
-			liquidationPrice = fullOrder.LimitPrice * (1 - liquidationRatio)
-		} else {
-			liquidationPrice = fullOrder.LimitPrice * (1 + liquidationRatio)
+	for _, canceledId := range canceledOrderIds {
+		m.RemoveOrder(canceledId)
+	}
+
+	log.Printf("%s order #%d executed successfully: %s %s %.8f at price %.2f, total %.2f",
+		fullOrder.Type, order.Id, order.Side, order.Symbol, actualQuantity, currentPrice, totalAmount)
+
+	fullOrder.Status = models.OrderStatusCompleted
+	fullOrder.TotalAmount = totalAmount
+	m.notifyOrderFilled(fullOrder)
+	trackBalance(userId)
+
+	// 如果這是一個槓桿訂單，同步爆倉索引
+	if fill != nil {
+		trackPositions(fill.Reduced, fill.Position)
+		log.Printf("Leverage limit order #%d filled: User=%d, Symbol=%s, Side=%s, Leverage=%dx, Reduced=%.8f, Opened=%.8f, Margin=%.2f",
+			fullOrder.Id, userId, fullOrder.Symbol, fullOrder.PositionSideStr, fullOrder.Leverage, fill.ReduceQuantity, fill.OpenQuantity, fill.Margin)
+	}
+
+	return nil
+}
+
+// OnOrderFilled 註冊訂單成交（交易提交後）的回呼，回呼在撮合流程中同步執行，不可阻塞
+func (m *LimitOrderMatcher) OnOrderFilled(listener func(order *models.Order)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.fillListeners = append(m.fillListeners, listener)
+}
+
+// notifyOrderFilled 通知訂單已成交
+func (m *LimitOrderMatcher) notifyOrderFilled(order *models.Order) {
+	m.mu.RLock()
+	listeners := m.fillListeners
+	m.mu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(order)
+	}
+}
+
+// failLimitOrder 將限價單標記為失敗，槓桿單同時解除凍結的保證金
+func failLimitOrder(order *models.Order, reason string) {
+	o := orm.NewOrm()
+	to, err := o.Begin()
+	if err != nil {
+		log.Printf("Failed to mark limit order #%d as failed: %v", order.Id, err)
+		return
+	}
+
+	// OCO 子訂單與成交時相同，先鎖定訂單組
+	var orderList *models.OrderList
+	if order.OrderListId > 0 {
+		orderList, err = models.GetOrderListForUpdate(to, order.OrderListId)
+	}
+
+	// 只有仍在 PENDING 的訂單需要處理，已取消的訂單保證金已解除
+	current := &models.Order{Id: order.Id}
+	if err == nil {
+		err = to.ReadForUpdate(current)
+	}
+	if err == nil && current.Status != models.OrderStatusPending {
+		to.Rollback()
+		return
+	}
+
+	if err == nil {
+		err = models.UpdateOrderStatus(to, order.Id, models.OrderStatusFailed, 0, 0, reason)
+	}
+	if err == nil && current.IsLeverageOrder {
+		err = releaseOrderMargin(to, current)
+	}
+
+	// 子訂單失敗時整個訂單組失敗，取消另一個子訂單
+	var canceledOrderIds []int64
+	if err == nil && orderList != nil && orderList.Status == models.OrderListStatusActive {
+		canceledOrderIds, err = models.FinishOrderList(to, orderList, models.OrderListStatusFailed, order.Id)
+	}
+	if err != nil {
+		to.Rollback()
+		log.Printf("Failed to mark limit order #%d as failed: %v", order.Id, err)
+		return
+	}
+
+	if err = to.Commit(); err != nil {
+		log.Printf("Failed to mark limit order #%d as failed: %v", order.Id, err)
+		return
+	}
+	if current.IsLeverageOrder {
+		trackBalance(current.User.Id)
+	}
+
+	for _, canceledId := range canceledOrderIds {
+		GlobalLimitOrderMatcher.RemoveOrder(canceledId)
+	}
+}
+
+// CancelOrder 取消待處理的訂單，槓桿限價單同時解除凍結的保證金
+// OCO 子訂單會取消整個訂單組
+func CancelOrder(userId int64, orderId int64) error {
+	existing, err := models.GetOrderById(orderId)
+	if err != nil {
+		return err
+	}
+	if existing.OrderListId > 0 {
+		if existing.User.Id != userId {
+			return errors.New("unauthorized: order does not belong to user")
+		}
+		err = CancelOrderList(userId, existing.OrderListId)
+		if err != nil && err.Error() == "order list cannot be canceled" {
+			return errors.New("order cannot be canceled")
 		}
+		return err
+	}
+
+	o := orm.NewOrm()
+	to, err := o.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
 
-		position := &models.LeveragePosition{
-			User:             &models.User{Id: userId},
-			Order:            fullOrder,
-			Symbol:           fullOrder.Symbol,
-			Side:             positionSide,
-			Leverage:         fullOrder.Leverage,
-			EntryPrice:       fullOrder.LimitPrice,
-			Quantity:         actualQuantity, // 實際購買的幣種數量
-			Margin:           margin,
-			LiquidationPrice: liquidationPrice,
-			UnrealizedPnL:    0,
-			RealizedPnL:      0,
-			Status:           models.PositionStatusOpen,
+	shouldRollback := true
+	defer func() {
+		if shouldRollback {
+			to.Rollback()
 		}
+	}()
 
-		// 保存槓桿倉位
-		o := orm.NewOrm()
-		_, err := o.Insert(position)
-		if err != nil {
-			log.Printf("Warning: Failed to create leverage position for order #%d: %v", fullOrder.Id, err)
-		} else {
-			log.Printf("Leverage position #%d created: User=%d, Symbol=%s, Side=%s, Leverage=%dx, Quantity=%.8f, EntryPrice=%.2f, Margin=%.2f",
-				position.Id, userId, fullOrder.Symbol, positionSide, fullOrder.Leverage, fullOrder.Quantity, fullOrder.LimitPrice, margin)
-
-			// 從 USDT 錢包扣除保證金
-			wallet, err := models.GetWalletByUserAndSymbol(userId, "USDT")
-			if err == nil {
-				wallet.Balance -= margin
-				wallet.Locked += margin
-				_, err = o.Update(wallet, "Balance", "Locked")
-				if err != nil {
-					log.Printf("Warning: Failed to deduct margin from user %d: %v", userId, err)
-				}
-			}
+	order, err := models.CancelOrder(to, orderId, userId)
+	if err != nil {
+		return err
+	}
 
-			// 發送槓桿倉位開倉通知給用戶
-			posMessage := models.NewLeveragePositionOpenedMessage(position)
-			hub.GlobalHub.BroadcastToUser(userId, posMessage.ToJSON())
+	if order.IsLeverageOrder {
+		if err = releaseOrderMargin(to, order); err != nil {
+			return fmt.Errorf("failed to release margin: %v", err)
 		}
 	}
 
+	if err = to.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	shouldRollback = false
+	if order.IsLeverageOrder {
+		trackBalance(userId)
+	}
+
+	// 從撮合器中移除
+	GlobalLimitOrderMatcher.RemoveOrder(orderId)
 	return nil
 }
 
 // AddOrder 新增限價單到監控列表
 func (m *LimitOrderMatcher) AddOrder(order *models.Order) {
-	if order.Type != models.OrderTypeLimit || order.Status != models.OrderStatusPending {
+	if order.Type == models.OrderTypeMarket || order.Status != models.OrderStatusPending {
 		return
 	}
 
@@ -391,11 +556,23 @@ func (m *LimitOrderMatcher) RemoveOrder(orderId int64) {
 	defer m.mu.Unlock()
 
 	delete(m.pendingOrders, orderId)
+	delete(m.trailingDirty, orderId)
 	log.Printf("Removed order #%d from matcher", orderId)
 }
 
-// PlaceLimitOrder 下限價單
-func PlaceLimitOrder(userId int64, symbol string, side models.OrderSide, quantity float64, limitPrice float64) (*models.Order, error) {
+// PlaceLimitOrder 下限價單，clientOrderId 為使用者自訂的訂單 ID，idempotencyKeys 為下單請求登記的冪等 Key（皆可為空）
+func PlaceLimitOrder(userId int64, symbol string, side models.OrderSide, quantity float64, limitPrice float64, clientOrderId string, idempotencyKeys []string) (*models.Order, error) {
+	return placeLimitOrder(userId, models.OrderParent{ClientOrderId: clientOrderId, IdempotencyKeys: idempotencyKeys}, symbol, side, quantity, limitPrice)
+}
+
+// placeLimitOrder 下限價單，parent 不為空時為母單或機器人的子訂單
+func placeLimitOrder(userId int64, parent models.OrderParent, symbol string, side models.OrderSide, quantity float64, limitPrice float64) (*models.Order, error) {
+	return placeLinkedLimitOrder(userId, parent, symbol, side, quantity, limitPrice, nil)
+}
+
+// placeLinkedLimitOrder 下限價單，link 不為空時在建立訂單的同一交易中寫入母單或機器人的關聯記錄
+// 訂單與關聯記錄一併提交，不會留下沒有關聯記錄的子訂單
+func placeLinkedLimitOrder(userId int64, parent models.OrderParent, symbol string, side models.OrderSide, quantity float64, limitPrice float64, link func(to orm.TxOrmer, order *models.Order) error) (*models.Order, error) {
 	// 1. 驗證輸入
 	if quantity <= 0 {
 		return nil, errors.New("quantity must be positive")
@@ -410,12 +587,36 @@ func PlaceLimitOrder(userId int64, symbol string, side models.OrderSide, quantit
 		return nil, err
 	}
 
-	// 2. 建立限價單
-	order, err := models.CreateOrder(userId, symbol, models.OrderTypeLimit, side, quantity, &limitPrice)
+	// 2. 建立限價單與關聯記錄
+	o := orm.NewOrm()
+	to, err := o.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	shouldRollback := true
+	defer func() {
+		if shouldRollback {
+			to.Rollback()
+		}
+	}()
+
+	order, err := models.CreateChildOrder(to, userId, parent, symbol, models.OrderTypeLimit, side, quantity, &limitPrice)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create order: %v", err)
+		return nil, fmt.Errorf("failed to create order: %w", err)
+	}
+	if link != nil {
+		if err = link(to, order); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = to.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
+	shouldRollback = false
+
 	// 3. 獲取當前市價（用於日誌記錄）
 	currentPrice, _ := GlobalPriceCache.GetPrice(symbol)
 
@@ -431,3 +632,134 @@ func PlaceLimitOrder(userId int64, symbol string, side models.OrderSide, quantit
 
 	return order, nil
 }
+
+// PlaceTrailingStopOrder 下現貨追蹤停損賣單，clientOrderId 為使用者自訂的訂單 ID，idempotencyKeys 為下單請求登記的冪等 Key（皆可為空）
+func PlaceTrailingStopOrder(userId int64, symbol string, quantity float64, params models.TrailingStopParams, clientOrderId string, idempotencyKeys []string) (*models.Order, error) {
+	// 1. 驗證輸入
+	if quantity <= 0 {
+		return nil, errors.New("quantity must be positive")
+	}
+
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	if _, _, err := models.ParseSymbol(symbol); err != nil {
+		return nil, err
+	}
+
+	// 2. 以目前價格初始化追蹤狀態（未設定啟動價格時立即開始追蹤）
+	currentPrice, ok := GlobalPriceCache.GetPrice(symbol)
+	if !ok {
+		return nil, fmt.Errorf("price not available for %s", symbol)
+	}
+
+	// 3. 建立追蹤停損單並加入撮合器
+	order, err := createOrder(func(to orm.TxOrmer) (*models.Order, error) {
+		return models.CreateTrailingStopOrder(to, userId, models.OrderParent{ClientOrderId: clientOrderId, IdempotencyKeys: idempotencyKeys}, symbol, models.OrderSideSell, quantity, params, 0, "", currentPrice)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create order: %w", err)
+	}
+
+	log.Printf("Trailing stop order #%d added to matcher: SELL %s, trigger price %.2f (current price: %.2f)",
+		order.Id, symbol, order.TriggerPrice, currentPrice)
+	GlobalLimitOrderMatcher.AddOrder(order)
+
+	return order, nil
+}
+
+// AmendOrder 修改待處理限價單的價格和/或數量，保留原訂單（ID 與建立時間不變）
+// 與撮合器使用同一個訂單鎖（FOR UPDATE），並遞增版本，撮合器以舊版本判斷的成交會被放棄
+func AmendOrder(userId int64, orderId int64, limitPrice *float64, quantity *float64) (*models.Order, error) {
+	o := orm.NewOrm()
+	to, err := o.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	shouldRollback := true
+	defer func() {
+		if shouldRollback {
+			to.Rollback()
+		}
+	}()
+
+	// 1. 鎖定訂單並檢查所有權
+	order := &models.Order{Id: orderId}
+	if err = to.ReadForUpdate(order); err != nil {
+		if err == orm.ErrNoRows {
+			return nil, errors.New("order not found")
+		}
+		return nil, err
+	}
+	if order.User.Id != userId {
+		return nil, errors.New("unauthorized: order does not belong to user")
+	}
+
+	// 2. 套用修改
+	oldMargin := order.RequiredMargin()
+	amendment, err := order.Amend(limitPrice, quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. 槓桿限價單調整凍結的保證金，只減倉訂單檢查數量不超過持倉
+	if order.IsLeverageOrder {
+		side := models.PositionSide(order.PositionSideStr)
+		if order.ReduceOnly {
+			if err = checkReduceOnly(to, userId, order.Symbol, side, order.Quantity); err != nil {
+				return nil, err
+			}
+		} else if err = adjustOrderMargin(to, userId, order.RequiredMargin()-oldMargin); err != nil {
+			return nil, err
+		}
+	}
+
+	// 4. 寫入訂單與修改記錄
+	if err = models.SaveOrderAmendment(to, order, amendment); err != nil {
+		return nil, fmt.Errorf("failed to amend order: %v", err)
+	}
+
+	if err = to.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	shouldRollback = false
+	if order.IsLeverageOrder {
+		trackBalance(userId)
+	}
+
+	log.Printf("Limit order #%d amended: price %.2f -> %.2f, quantity %.8f -> %.8f, version %d",
+		order.Id, amendment.OldPrice, amendment.NewPrice, amendment.OldQuantity, amendment.NewQuantity, order.Version)
+
+	// 5. 以新版本取代撮合器中的訂單
+	GlobalLimitOrderMatcher.AddOrder(order)
+
+	if err = models.LoadOrderAmendments([]*models.Order{order}); err != nil {
+		log.Printf("Failed to load amendments of order #%d: %v", order.Id, err)
+	}
+	return order, nil
+}
+
+// adjustOrderMargin 修改槓桿限價單後凍結或解除保證金的差額（需要在交易中使用）
+func adjustOrderMargin(to orm.TxOrmer, userId int64, delta float64) error {
+	if delta == 0 {
+		return nil
+	}
+
+	wallet, err := models.GetWalletForUpdate(to, userId, "USDT")
+	if err != nil {
+		return errors.New("USDT wallet not found")
+	}
+	if delta > 0 {
+		err = wallet.LockMargin(delta)
+	} else {
+		err = wallet.UnlockMargin(-delta)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = to.Update(wallet, "Locked")
+	return err
+}
@@ -0,0 +1,514 @@
+package services
+
+import (
+	"backend/models"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// LiquidationEngine 事件驅動的爆倉引擎
+// 在記憶體中維護持倉索引，每次價格更新時只檢查該交易對觸發爆倉的倉位；
+// 未實現盈虧定期批次寫入資料庫，並定期與資料庫重新同步索引
+type LiquidationEngine struct {
+	mu             sync.Mutex
+	isRunning      bool
+	stopChan       chan struct{}
+	flushInterval  time.Duration // 未實現盈虧寫入與爆倉警告的檢查間隔
+	resyncInterval time.Duration // 與資料庫重新同步索引的間隔
+	index          *liquidationIndex
+	crossBalances  map[int64]float64 // userId -> 全倉使用者的 USDT 可用餘額（錢包變動後重新讀取）
+	persistedPnL   map[int64]float64 // positionId -> 最近一次寫入資料庫的未實現盈虧
+	resyncing      bool
+	touched        map[int64]*models.LeveragePosition // 重新同步期間有變動的倉位，同步完成後重新套用
+	touchedUsers   map[int64]struct{}                 // 重新同步期間已更新可用餘額的使用者
+
+	dirtyMu    sync.Mutex
+	dirty      map[string]struct{} // 價格已更新、尚未檢查的交易對
+	dirtyUsers map[int64]struct{}  // USDT 錢包已變動、尚未重新讀取餘額的使用者
+	signal     chan struct{}
+}
+
+var GlobalLiquidationEngine *LiquidationEngine
+
+func init() {
+	GlobalLiquidationEngine = NewLiquidationEngine()
+}
+
+// NewLiquidationEngine 建立爆倉引擎
+func NewLiquidationEngine() *LiquidationEngine {
+	return &LiquidationEngine{
+		stopChan:       make(chan struct{}),
+		flushInterval:  5 * time.Second,
+		resyncInterval: 1 * time.Minute,
+		index:          newLiquidationIndex(),
+		crossBalances:  make(map[int64]float64),
+		persistedPnL:   make(map[int64]float64),
+		dirty:          make(map[string]struct{}),
+		dirtyUsers:     make(map[int64]struct{}),
+		signal:         make(chan struct{}, 1),
+	}
+}
+
+// loadConfig 從設定檔讀取檢查間隔，未設定時使用預設值
+func (e *LiquidationEngine) loadConfig() {
+	if interval, err := beego.AppConfig.String("pnlflushinterval"); err == nil && interval != "" {
+		if d, err := time.ParseDuration(interval); err == nil && d > 0 {
+			e.flushInterval = d
+		} else {
+			log.Printf("Invalid pnlflushinterval %q, using %s", interval, e.flushInterval)
+		}
+	}
+	if interval, err := beego.AppConfig.String("liquidationresyncinterval"); err == nil && interval != "" {
+		if d, err := time.ParseDuration(interval); err == nil && d > 0 {
+			e.resyncInterval = d
+		} else {
+			log.Printf("Invalid liquidationresyncinterval %q, using %s", interval, e.resyncInterval)
+		}
+	}
+}
+
+// Start 載入所有持倉並開始監聽價格更新
+func (e *LiquidationEngine) Start() {
+	e.mu.Lock()
+	if e.isRunning {
+		e.mu.Unlock()
+		return
+	}
+	e.isRunning = true
+	e.loadConfig()
+	e.mu.Unlock()
+
+	e.resync()
+	GlobalPriceCache.OnPriceUpdate(e.OnPriceUpdate)
+
+	log.Printf("Liquidation engine started: %d open positions, flush interval %s, resync interval %s",
+		e.Len(), e.flushInterval, e.resyncInterval)
+
+	go e.run()
+}
+
+// Stop 停止爆倉引擎
+func (e *LiquidationEngine) Stop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.isRunning {
+		return
+	}
+
+	e.isRunning = false
+	close(e.stopChan)
+	log.Println("Liquidation engine stopped")
+}
+
+// Len 返回索引中的持倉數量
+func (e *LiquidationEngine) Len() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.index.len()
+}
+
+// OnPriceUpdate 標記交易對需要檢查（由價格快取在每筆成交時呼叫，不阻塞）
+// 同一交易對在檢查前的多次更新只會檢查一次
+func (e *LiquidationEngine) OnPriceUpdate(symbol string) {
+	e.dirtyMu.Lock()
+	e.dirty[symbol] = struct{}{}
+	e.dirtyMu.Unlock()
+
+	select {
+	case e.signal <- struct{}{}:
+	default:
+	}
+}
+
+// OnBalanceUpdate 標記使用者的 USDT 錢包已變動（交易提交後呼叫，不阻塞）
+// 全倉使用者的可用餘額在檢查時重新讀取，並立即檢查是否需要爆倉或發送警告
+func (e *LiquidationEngine) OnBalanceUpdate(userId int64) {
+	e.dirtyMu.Lock()
+	e.dirtyUsers[userId] = struct{}{}
+	e.dirtyMu.Unlock()
+
+	select {
+	case e.signal <- struct{}{}:
+	default:
+	}
+}
+
+// Track 倉位變動提交後同步到索引，非持倉中的倉位會從索引移除
+func (e *LiquidationEngine) Track(position *models.LeveragePosition) {
+	if position == nil || position.Id == 0 {
+		return
+	}
+
+	// 全倉使用者的可用餘額會隨開平倉變動，一併更新
+	var available float64
+	var hasBalance bool
+	if position.User != nil && (position.IsCross() || e.hasCrossPositions(position.User.Id)) {
+		if wallet, err := models.GetWalletByUserAndSymbol(position.User.Id, "USDT"); err == nil {
+			available = wallet.GetAvailableBalance()
+			hasBalance = true
+		}
+	}
+
+	e.mu.Lock()
+	e.index.upsert(position)
+	if position.Status != models.PositionStatusOpen {
+		delete(e.persistedPnL, position.Id)
+	}
+	if hasBalance {
+		e.setCrossBalance(position.User.Id, available)
+	}
+	if e.resyncing {
+		copied := *position
+		e.touched[position.Id] = &copied
+	}
+	e.mu.Unlock()
+
+	// 逐倉保證金的變動也會影響全倉可用餘額，重新檢查全倉帳戶
+	if hasBalance {
+		e.OnBalanceUpdate(position.User.Id)
+	}
+}
+
+// hasCrossPositions 使用者在索引中是否有全倉持倉
+func (e *LiquidationEngine) hasCrossPositions(userId int64) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return len(e.index.cross[userId]) > 0
+}
+
+// setCrossBalance 更新快取的全倉可用餘額（需持有 e.mu）
+func (e *LiquidationEngine) setCrossBalance(userId int64, available float64) {
+	e.crossBalances[userId] = available
+	if e.resyncing {
+		e.touchedUsers[userId] = struct{}{}
+	}
+}
+
+// trackPositions 將交易中變動的倉位同步到爆倉引擎（需在交易提交後呼叫）
+func trackPositions(positions ...*models.LeveragePosition) {
+	for _, position := range positions {
+		GlobalLiquidationEngine.Track(position)
+	}
+}
+
+// trackBalance 通知爆倉引擎使用者的 USDT 錢包已變動（需在交易提交後呼叫）
+func trackBalance(userId int64) {
+	GlobalLiquidationEngine.OnBalanceUpdate(userId)
+}
+
+// run 事件處理循環
+func (e *LiquidationEngine) run() {
+	flushTicker := time.NewTicker(e.flushInterval)
+	defer flushTicker.Stop()
+	resyncTicker := time.NewTicker(e.resyncInterval)
+	defer resyncTicker.Stop()
+
+	for {
+		select {
+		case <-e.signal:
+			e.checkDirtyUsers()
+			e.checkDirtySymbols()
+		case <-flushTicker.C:
+			e.flush()
+		case <-resyncTicker.C:
+			e.resync()
+		case <-e.stopChan:
+			return
+		}
+	}
+}
+
+// checkDirtySymbols 檢查價格已更新的交易對，執行觸發的爆倉
+func (e *LiquidationEngine) checkDirtySymbols() {
+	e.dirtyMu.Lock()
+	symbols := make([]string, 0, len(e.dirty))
+	for symbol := range e.dirty {
+		symbols = append(symbols, symbol)
+	}
+	e.dirty = make(map[string]struct{})
+	e.dirtyMu.Unlock()
+
+	var markPrices map[string]float64
+	for _, symbol := range symbols {
+		markPrice, ok := GlobalPriceCache.GetMarkPrice(symbol)
+		if !ok {
+			continue
+		}
+
+		e.mu.Lock()
+		triggered := e.index.triggered(symbol, markPrice)
+		crossUsers := e.index.crossUsers(symbol)
+		e.mu.Unlock()
+
+		// 1. 逐倉：爆倉價格已被標記價格越過的倉位
+		for _, position := range triggered {
+			log.Printf("Liquidating position #%d: User=%d, Symbol=%s, Side=%s, LiqPrice=%.2f, MarkPrice=%.2f",
+				position.Id, position.User.Id, position.Symbol, position.Side, position.LiquidationPrice, markPrice)
+
+			if err := liquidatePosition(position, markPrice); err != nil {
+				log.Printf("Failed to liquidate position #%d: %v", position.Id, err)
+			}
+		}
+
+		// 2. 全倉：持有該交易對的帳戶重新計算總權益
+		if len(crossUsers) == 0 {
+			continue
+		}
+		if markPrices == nil {
+			markPrices = GlobalPriceCache.GetAllMarkPrices()
+		}
+		for _, userId := range crossUsers {
+			status, ok := e.crossStatus(userId, markPrices)
+			if !ok || !status.ShouldLiquidate() {
+				continue
+			}
+
+			log.Printf("Liquidating cross margin account: User=%d, Equity=%.2f, MaintenanceMargin=%.2f",
+				userId, status.Equity, status.MaintenanceMargin)
+
+			if err := liquidateCrossAccount(userId); err != nil {
+				log.Printf("Failed to liquidate cross margin account of user %d: %v", userId, err)
+			}
+		}
+	}
+}
+
+// checkDirtyUsers 重新讀取錢包已變動的全倉使用者的可用餘額，檢查是否需要爆倉或發送警告
+func (e *LiquidationEngine) checkDirtyUsers() {
+	e.dirtyMu.Lock()
+	userIds := make([]int64, 0, len(e.dirtyUsers))
+	for userId := range e.dirtyUsers {
+		userIds = append(userIds, userId)
+	}
+	e.dirtyUsers = make(map[int64]struct{})
+	e.dirtyMu.Unlock()
+
+	var markPrices map[string]float64
+	for _, userId := range userIds {
+		if !e.hasCrossPositions(userId) {
+			continue
+		}
+
+		// 1. 重新讀取可用餘額
+		wallet, err := models.GetWalletByUserAndSymbol(userId, "USDT")
+		if err != nil {
+			log.Printf("Failed to refresh USDT balance of user %d: %v", userId, err)
+			continue
+		}
+		e.mu.Lock()
+		e.setCrossBalance(userId, wallet.GetAvailableBalance())
+		positions := e.index.crossPositions(userId)
+		e.mu.Unlock()
+
+		// 2. 以新的餘額計算帳戶狀態
+		if markPrices == nil {
+			markPrices = GlobalPriceCache.GetAllMarkPrices()
+		}
+		status, ok := e.crossStatus(userId, markPrices)
+		if !ok {
+			continue
+		}
+		if status.ShouldLiquidate() {
+			log.Printf("Liquidating cross margin account after balance change: User=%d, Equity=%.2f, MaintenanceMargin=%.2f",
+				userId, status.Equity, status.MaintenanceMargin)
+
+			if err := liquidateCrossAccount(userId); err != nil {
+				log.Printf("Failed to liquidate cross margin account of user %d: %v", userId, err)
+			}
+			continue
+		}
+
+		// 3. 爆倉警告
+		GlobalMarginCallNotifier.CheckCrossAccount(userId, positions, status)
+		e.setMarginCallLevels(positions)
+	}
+}
+
+// setMarginCallLevels 將警告等級寫回索引
+func (e *LiquidationEngine) setMarginCallLevels(positions []*models.LeveragePosition) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, position := range positions {
+		if entry, ok := e.index.positions[position.Id]; ok {
+			entry.MarginCallLevel = position.MarginCallLevel
+		}
+	}
+}
+
+// crossStatus 以索引中的持倉與快取的可用餘額計算全倉帳戶狀態
+func (e *LiquidationEngine) crossStatus(userId int64, markPrices map[string]float64) (models.CrossMarginStatus, bool) {
+	e.mu.Lock()
+	positions := e.index.crossPositions(userId)
+	available, ok := e.crossBalances[userId]
+	e.mu.Unlock()
+
+	if !ok || len(positions) == 0 {
+		return models.CrossMarginStatus{}, false
+	}
+	for _, position := range positions {
+		if markPrice, ok := markPrices[position.Symbol]; ok {
+			position.UnrealizedPnL = position.CalculateUnrealizedPnL(markPrice)
+		}
+	}
+	return models.CalculateCrossMarginStatus(available, positions, markPrices), true
+}
+
+// flush 批次寫入有變動的未實現盈虧，並檢查是否需要發送爆倉警告
+func (e *LiquidationEngine) flush() {
+	markPrices := GlobalPriceCache.GetAllMarkPrices()
+
+	e.mu.Lock()
+	positions := e.index.snapshot()
+	e.mu.Unlock()
+
+	// 1. 計算未實現盈虧，只寫入與上次寫入不同的倉位
+	updates := collectPnLUpdates(positions, markPrices, e.persistedSnapshot())
+	if len(updates) > 0 {
+		if err := models.BatchUpdatePositionPnL(updates); err != nil {
+			log.Printf("Failed to update unrealized PnL of %d positions: %v", len(updates), err)
+		} else {
+			e.mu.Lock()
+			for _, update := range updates {
+				if _, ok := e.index.positions[update.Id]; ok {
+					e.persistedPnL[update.Id] = update.UnrealizedPnL
+				}
+			}
+			e.mu.Unlock()
+		}
+	}
+
+	// 2. 爆倉警告：逐倉依倉位、全倉依帳戶檢查
+	crossPositions := make(map[int64][]*models.LeveragePosition)
+	for _, position := range positions {
+		markPrice, ok := markPrices[position.Symbol]
+		if !ok {
+			continue
+		}
+		if position.IsCross() {
+			crossPositions[position.User.Id] = append(crossPositions[position.User.Id], position)
+			continue
+		}
+		if position.IsLiquidated(markPrice) {
+			continue
+		}
+		GlobalMarginCallNotifier.CheckPosition(position, markPrice)
+	}
+
+	e.mu.Lock()
+	balances := make(map[int64]float64, len(crossPositions))
+	for userId := range crossPositions {
+		if available, ok := e.crossBalances[userId]; ok {
+			balances[userId] = available
+		}
+	}
+	e.mu.Unlock()
+
+	for userId, userPositions := range crossPositions {
+		available, ok := balances[userId]
+		if !ok {
+			continue
+		}
+		status := models.CalculateCrossMarginStatus(available, userPositions, markPrices)
+		if status.ShouldLiquidate() {
+			continue
+		}
+		GlobalMarginCallNotifier.CheckCrossAccount(userId, userPositions, status)
+	}
+
+	// 3. 將警告等級寫回索引
+	e.setMarginCallLevels(positions)
+}
+
+// persistedSnapshot 返回最近一次寫入資料庫的未實現盈虧副本
+func (e *LiquidationEngine) persistedSnapshot() map[int64]float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	persisted := make(map[int64]float64, len(e.persistedPnL))
+	for id, pnl := range e.persistedPnL {
+		persisted[id] = pnl
+	}
+	return persisted
+}
+
+// collectPnLUpdates 以標記價格計算持倉的未實現盈虧，返回與上次寫入不同的倉位
+// positions 的 UnrealizedPnL 會更新為最新值
+func collectPnLUpdates(positions []*models.LeveragePosition, markPrices map[string]float64, persisted map[int64]float64) []models.PositionPnL {
+	var updates []models.PositionPnL
+	for _, position := range positions {
+		markPrice, ok := markPrices[position.Symbol]
+		if !ok {
+			continue
+		}
+		position.UnrealizedPnL = position.CalculateUnrealizedPnL(markPrice)
+		if last, ok := persisted[position.Id]; ok && math.Abs(last-position.UnrealizedPnL) < 1e-8 {
+			continue
+		}
+		updates = append(updates, models.PositionPnL{Id: position.Id, UnrealizedPnL: position.UnrealizedPnL})
+	}
+	return updates
+}
+
+// resync 從資料庫重新載入所有持倉與全倉使用者的可用餘額
+// 載入期間由 Track 同步的變動在載入完成後重新套用，避免被較舊的資料覆蓋
+func (e *LiquidationEngine) resync() {
+	e.mu.Lock()
+	e.resyncing = true
+	e.touched = make(map[int64]*models.LeveragePosition)
+	e.touchedUsers = make(map[int64]struct{})
+	e.mu.Unlock()
+
+	positions, err := models.GetAllOpenPositions()
+	if err != nil {
+		log.Printf("Failed to load open positions: %v", err)
+		e.mu.Lock()
+		e.resyncing = false
+		e.touched = nil
+		e.touchedUsers = nil
+		e.mu.Unlock()
+		return
+	}
+
+	index := buildLiquidationIndex(positions)
+	balances := make(map[int64]float64)
+	for _, position := range positions {
+		if position.IsCross() {
+			if _, ok := balances[position.User.Id]; ok {
+				continue
+			}
+			if wallet, err := models.GetWalletByUserAndSymbol(position.User.Id, "USDT"); err == nil {
+				balances[position.User.Id] = wallet.GetAvailableBalance()
+			}
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, position := range e.touched {
+		index.upsert(position)
+	}
+	for userId := range e.touchedUsers {
+		if available, ok := e.crossBalances[userId]; ok {
+			balances[userId] = available
+		}
+	}
+	for id := range e.persistedPnL {
+		if _, ok := index.positions[id]; !ok {
+			delete(e.persistedPnL, id)
+		}
+	}
+	e.index = index
+	e.crossBalances = balances
+	e.resyncing = false
+	e.touched = nil
+	e.touchedUsers = nil
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"backend/models"
+	"sort"
+)
+
+// liquidationIndex 持倉的記憶體索引（非執行緒安全，由 LiquidationEngine 加鎖保護）
+// 逐倉持倉依交易對與方向按爆倉價格排序，價格變動時只需檢查排序最前面的倉位
+// 全倉持倉依使用者分組，由帳戶總權益判斷是否爆倉
+type liquidationIndex struct {
+	positions     map[int64]*models.LeveragePosition           // positionId -> 倉位副本
+	longs         map[string][]*models.LeveragePosition        // symbol -> 逐倉多頭，依爆倉價格由高到低
+	shorts        map[string][]*models.LeveragePosition        // symbol -> 逐倉空頭，依爆倉價格由低到高
+	cross         map[int64]map[int64]*models.LeveragePosition // userId -> 全倉持倉
+	crossBySymbol map[string]map[int64]int                     // symbol -> userId -> 該交易對的全倉持倉數量
+}
+
+func newLiquidationIndex() *liquidationIndex {
+	return &liquidationIndex{
+		positions:     make(map[int64]*models.LeveragePosition),
+		longs:         make(map[string][]*models.LeveragePosition),
+		shorts:        make(map[string][]*models.LeveragePosition),
+		cross:         make(map[int64]map[int64]*models.LeveragePosition),
+		crossBySymbol: make(map[string]map[int64]int),
+	}
+}
+
+// buildLiquidationIndex 由持倉列表建立索引，先加入再一次排序，避免逐筆插入的搬移成本
+func buildLiquidationIndex(positions []*models.LeveragePosition) *liquidationIndex {
+	idx := newLiquidationIndex()
+	for _, position := range positions {
+		if position.Status != models.PositionStatusOpen {
+			continue
+		}
+		if _, ok := idx.positions[position.Id]; ok || position.IsCross() {
+			idx.upsert(position)
+			continue
+		}
+
+		copied := *position
+		entry := &copied
+		idx.positions[entry.Id] = entry
+		if entry.Side == models.PositionSideLong {
+			idx.longs[entry.Symbol] = append(idx.longs[entry.Symbol], entry)
+		} else {
+			idx.shorts[entry.Symbol] = append(idx.shorts[entry.Symbol], entry)
+		}
+	}
+
+	for _, list := range idx.longs {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].LiquidationPrice > list[j].LiquidationPrice })
+	}
+	for _, list := range idx.shorts {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].LiquidationPrice < list[j].LiquidationPrice })
+	}
+	return idx
+}
+
+// len 返回索引中的持倉數量
+func (idx *liquidationIndex) len() int {
+	return len(idx.positions)
+}
+
+// upsert 加入或更新持倉，非持倉中的倉位會從索引移除
+func (idx *liquidationIndex) upsert(position *models.LeveragePosition) {
+	idx.remove(position.Id)
+	if position.Status != models.PositionStatusOpen {
+		return
+	}
+
+	copied := *position
+	entry := &copied
+	idx.positions[entry.Id] = entry
+
+	if entry.IsCross() {
+		userId := entry.User.Id
+		if idx.cross[userId] == nil {
+			idx.cross[userId] = make(map[int64]*models.LeveragePosition)
+		}
+		idx.cross[userId][entry.Id] = entry
+		if idx.crossBySymbol[entry.Symbol] == nil {
+			idx.crossBySymbol[entry.Symbol] = make(map[int64]int)
+		}
+		idx.crossBySymbol[entry.Symbol][userId]++
+		return
+	}
+
+	if entry.Side == models.PositionSideLong {
+		list := idx.longs[entry.Symbol]
+		i := sort.Search(len(list), func(i int) bool { return list[i].LiquidationPrice < entry.LiquidationPrice })
+		idx.longs[entry.Symbol] = insertPosition(list, i, entry)
+	} else {
+		list := idx.shorts[entry.Symbol]
+		i := sort.Search(len(list), func(i int) bool { return list[i].LiquidationPrice > entry.LiquidationPrice })
+		idx.shorts[entry.Symbol] = insertPosition(list, i, entry)
+	}
+}
+
+// remove 從索引移除持倉
+func (idx *liquidationIndex) remove(positionId int64) {
+	entry, ok := idx.positions[positionId]
+	if !ok {
+		return
+	}
+	delete(idx.positions, positionId)
+
+	if entry.IsCross() {
+		userId := entry.User.Id
+		delete(idx.cross[userId], positionId)
+		if len(idx.cross[userId]) == 0 {
+			delete(idx.cross, userId)
+		}
+		counts := idx.crossBySymbol[entry.Symbol]
+		counts[userId]--
+		if counts[userId] <= 0 {
+			delete(counts, userId)
+		}
+		return
+	}
+
+	if entry.Side == models.PositionSideLong {
+		list := idx.longs[entry.Symbol]
+		start := sort.Search(len(list), func(i int) bool { return list[i].LiquidationPrice <= entry.LiquidationPrice })
+		idx.longs[entry.Symbol] = removePosition(list, start, positionId)
+	} else {
+		list := idx.shorts[entry.Symbol]
+		start := sort.Search(len(list), func(i int) bool { return list[i].LiquidationPrice >= entry.LiquidationPrice })
+		idx.shorts[entry.Symbol] = removePosition(list, start, positionId)
+	}
+}
+
+// triggered 返回在標記價格下觸發爆倉的逐倉持倉副本
+// 多頭依爆倉價格由高到低、空頭由低到高排序，只需掃描到第一個未觸發的倉位為止
+func (idx *liquidationIndex) triggered(symbol string, markPrice float64) []*models.LeveragePosition {
+	var result []*models.LeveragePosition
+	for _, entry := range idx.longs[symbol] {
+		if !entry.IsLiquidated(markPrice) {
+			break
+		}
+		copied := *entry
+		result = append(result, &copied)
+	}
+	for _, entry := range idx.shorts[symbol] {
+		if !entry.IsLiquidated(markPrice) {
+			break
+		}
+		copied := *entry
+		result = append(result, &copied)
+	}
+	return result
+}
+
+// crossUsers 返回持有該交易對全倉持倉的使用者
+func (idx *liquidationIndex) crossUsers(symbol string) []int64 {
+	users := make([]int64, 0, len(idx.crossBySymbol[symbol]))
+	for userId := range idx.crossBySymbol[symbol] {
+		users = append(users, userId)
+	}
+	return users
+}
+
+// crossPositions 返回使用者所有全倉持倉的副本
+func (idx *liquidationIndex) crossPositions(userId int64) []*models.LeveragePosition {
+	positions := make([]*models.LeveragePosition, 0, len(idx.cross[userId]))
+	for _, entry := range idx.cross[userId] {
+		copied := *entry
+		positions = append(positions, &copied)
+	}
+	return positions
+}
+
+// snapshot 返回所有持倉的副本
+func (idx *liquidationIndex) snapshot() []*models.LeveragePosition {
+	positions := make([]*models.LeveragePosition, 0, len(idx.positions))
+	for _, entry := range idx.positions {
+		copied := *entry
+		positions = append(positions, &copied)
+	}
+	return positions
+}
+
+// insertPosition 在排序切片的位置 i 插入倉位
+func insertPosition(list []*models.LeveragePosition, i int, entry *models.LeveragePosition) []*models.LeveragePosition {
+	list = append(list, nil)
+	copy(list[i+1:], list[i:])
+	list[i] = entry
+	return list
+}
+
+// removePosition 從位置 start 開始（爆倉價格相同的倉位相鄰）找到並移除倉位
+func removePosition(list []*models.LeveragePosition, start int, positionId int64) []*models.LeveragePosition {
+	for i := start; i < len(list); i++ {
+		if list[i].Id == positionId {
+			copy(list[i:], list[i+1:])
+			list[len(list)-1] = nil
+			return list[:len(list)-1]
+		}
+	}
+	return list
+}
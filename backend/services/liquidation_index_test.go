@@ -0,0 +1,226 @@
+package services
+
+import (
+	"backend/models"
+	"math/rand"
+	"testing"
+)
+
+// benchmarkPositions 基準測試使用的持倉數量
+const benchmarkPositions = 100000
+
+// newIndexedPosition 建立測試用的逐倉持倉，直接指定爆倉價格
+func newIndexedPosition(id int64, side models.PositionSide, liquidationPrice float64) *models.LeveragePosition {
+	return &models.LeveragePosition{
+		Id:               id,
+		User:             &models.User{Id: id%1000 + 1},
+		Symbol:           "BTCUSDT",
+		Side:             side,
+		Leverage:         10,
+		MarginMode:       models.MarginModeIsolated,
+		EntryPrice:       100,
+		Quantity:         1,
+		Margin:           10,
+		LiquidationPrice: liquidationPrice,
+		Status:           models.PositionStatusOpen,
+	}
+}
+
+// newRandomPositions 建立多空各半、爆倉價格隨機分佈的持倉
+func newRandomPositions(n int) []*models.LeveragePosition {
+	r := rand.New(rand.NewSource(1))
+	positions := make([]*models.LeveragePosition, n)
+	for i := range positions {
+		if i%2 == 0 {
+			positions[i] = newIndexedPosition(int64(i+1), models.PositionSideLong, 50+r.Float64()*45)
+		} else {
+			positions[i] = newIndexedPosition(int64(i+1), models.PositionSideShort, 105+r.Float64()*45)
+		}
+	}
+	return positions
+}
+
+// TestLiquidationIndexTriggered 測試只返回爆倉價格已被越過的倉位
+func TestLiquidationIndexTriggered(t *testing.T) {
+	idx := newLiquidationIndex()
+	idx.upsert(newIndexedPosition(1, models.PositionSideLong, 90))
+	idx.upsert(newIndexedPosition(2, models.PositionSideLong, 95))
+	idx.upsert(newIndexedPosition(3, models.PositionSideLong, 80))
+	idx.upsert(newIndexedPosition(4, models.PositionSideShort, 110))
+	idx.upsert(newIndexedPosition(5, models.PositionSideShort, 105))
+
+	if triggered := idx.triggered("BTCUSDT", 100); len(triggered) != 0 {
+		t.Errorf("expected no liquidation at 100, got %d", len(triggered))
+	}
+
+	triggered := idx.triggered("BTCUSDT", 90)
+	if len(triggered) != 2 || triggered[0].Id != 2 || triggered[1].Id != 1 {
+		t.Errorf("expected positions 2 and 1 at 90, got %v", positionIds(triggered))
+	}
+
+	triggered = idx.triggered("BTCUSDT", 107)
+	if len(triggered) != 1 || triggered[0].Id != 5 {
+		t.Errorf("expected position 5 at 107, got %v", positionIds(triggered))
+	}
+
+	if triggered := idx.triggered("ETHUSDT", 1); len(triggered) != 0 {
+		t.Errorf("expected no liquidation for other symbol, got %d", len(triggered))
+	}
+}
+
+// TestLiquidationIndexUpsert 測試更新爆倉價格與移除已平倉的倉位
+func TestLiquidationIndexUpsert(t *testing.T) {
+	idx := newLiquidationIndex()
+	idx.upsert(newIndexedPosition(1, models.PositionSideLong, 90))
+	idx.upsert(newIndexedPosition(2, models.PositionSideLong, 90))
+
+	// 追加保證金後爆倉價格下降
+	updated := newIndexedPosition(1, models.PositionSideLong, 70)
+	idx.upsert(updated)
+	if idx.len() != 2 {
+		t.Errorf("expected 2 positions, got %d", idx.len())
+	}
+	triggered := idx.triggered("BTCUSDT", 85)
+	if len(triggered) != 1 || triggered[0].Id != 2 {
+		t.Errorf("expected only position 2 at 85, got %v", positionIds(triggered))
+	}
+
+	// 索引保存副本，修改原倉位不影響索引
+	updated.LiquidationPrice = 99
+	if triggered := idx.triggered("BTCUSDT", 95); len(triggered) != 0 {
+		t.Errorf("expected index to keep its own copy, got %v", positionIds(triggered))
+	}
+
+	closed := newIndexedPosition(2, models.PositionSideLong, 90)
+	closed.Status = models.PositionStatusClosed
+	idx.upsert(closed)
+	if idx.len() != 1 {
+		t.Errorf("expected closed position to be removed, got %d positions", idx.len())
+	}
+	if triggered := idx.triggered("BTCUSDT", 85); len(triggered) != 0 {
+		t.Errorf("expected no liquidation after close, got %v", positionIds(triggered))
+	}
+}
+
+// TestLiquidationIndexCross 測試全倉持倉依使用者分組
+func TestLiquidationIndexCross(t *testing.T) {
+	idx := newLiquidationIndex()
+	first := newIndexedPosition(1, models.PositionSideLong, 0)
+	first.MarginMode = models.MarginModeCross
+	first.User = &models.User{Id: 7}
+	second := newIndexedPosition(2, models.PositionSideShort, 0)
+	second.MarginMode = models.MarginModeCross
+	second.User = &models.User{Id: 7}
+	idx.upsert(first)
+	idx.upsert(second)
+
+	if triggered := idx.triggered("BTCUSDT", 1); len(triggered) != 0 {
+		t.Errorf("expected cross positions not to be triggered by price, got %v", positionIds(triggered))
+	}
+	if users := idx.crossUsers("BTCUSDT"); len(users) != 1 || users[0] != 7 {
+		t.Errorf("expected user 7, got %v", users)
+	}
+	if positions := idx.crossPositions(7); len(positions) != 2 {
+		t.Errorf("expected 2 cross positions, got %d", len(positions))
+	}
+
+	idx.remove(1)
+	if users := idx.crossUsers("BTCUSDT"); len(users) != 1 {
+		t.Errorf("expected user 7 to remain with one position, got %v", users)
+	}
+	idx.remove(2)
+	if users := idx.crossUsers("BTCUSDT"); len(users) != 0 {
+		t.Errorf("expected no cross users, got %v", users)
+	}
+}
+
+// TestCollectPnLUpdates 測試只寫入有變動的未實現盈虧
+func TestCollectPnLUpdates(t *testing.T) {
+	positions := []*models.LeveragePosition{
+		newIndexedPosition(1, models.PositionSideLong, 90),
+		newIndexedPosition(2, models.PositionSideShort, 110),
+	}
+	markPrices := map[string]float64{"BTCUSDT": 102}
+
+	updates := collectPnLUpdates(positions, markPrices, map[int64]float64{})
+	if len(updates) != 2 || updates[0].UnrealizedPnL != 2 || updates[1].UnrealizedPnL != -2 {
+		t.Errorf("expected PnL 2 and -2, got %v", updates)
+	}
+
+	updates = collectPnLUpdates(positions, markPrices, map[int64]float64{1: 2, 2: -2})
+	if len(updates) != 0 {
+		t.Errorf("expected no updates for unchanged PnL, got %v", updates)
+	}
+}
+
+// TestBuildLiquidationIndex 測試批次建立的索引與逐筆加入的結果一致
+func TestBuildLiquidationIndex(t *testing.T) {
+	positions := newRandomPositions(1000)
+	built := buildLiquidationIndex(positions)
+	inserted := newLiquidationIndex()
+	for _, position := range positions {
+		inserted.upsert(position)
+	}
+
+	for _, price := range []float64{60, 80, 94, 100, 106, 120, 140} {
+		got := positionIds(built.triggered("BTCUSDT", price))
+		want := positionIds(inserted.triggered("BTCUSDT", price))
+		if len(got) != len(want) {
+			t.Errorf("at %.0f expected %d triggered positions, got %d", price, len(want), len(got))
+		}
+	}
+	if built.len() != len(positions) {
+		t.Errorf("expected %d positions, got %d", len(positions), built.len())
+	}
+}
+
+func positionIds(positions []*models.LeveragePosition) []int64 {
+	ids := make([]int64, len(positions))
+	for i, position := range positions {
+		ids[i] = position.Id
+	}
+	return ids
+}
+
+// BenchmarkLiquidationIndexBuild 載入 10 萬筆持倉建立索引
+func BenchmarkLiquidationIndexBuild(b *testing.B) {
+	positions := newRandomPositions(benchmarkPositions)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buildLiquidationIndex(positions)
+	}
+}
+
+// BenchmarkLiquidationIndexTick 10 萬筆持倉下每次價格更新的爆倉檢查
+func BenchmarkLiquidationIndexTick(b *testing.B) {
+	idx := buildLiquidationIndex(newRandomPositions(benchmarkPositions))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// 價格在沒有倉位觸發的區間內波動
+		idx.triggered("BTCUSDT", 96+float64(i%8))
+	}
+}
+
+// BenchmarkLiquidationIndexUpsert 10 萬筆持倉下更新單一倉位（開平倉、調整保證金）
+func BenchmarkLiquidationIndexUpsert(b *testing.B) {
+	positions := newRandomPositions(benchmarkPositions)
+	idx := buildLiquidationIndex(positions)
+	r := rand.New(rand.NewSource(2))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		position := *positions[r.Intn(len(positions))]
+		position.LiquidationPrice *= 0.99 + r.Float64()*0.02
+		idx.upsert(&position)
+	}
+}
+
+// BenchmarkCollectPnLUpdates 10 萬筆持倉計算一輪未實現盈虧批次寫入的內容
+func BenchmarkCollectPnLUpdates(b *testing.B) {
+	positions := newRandomPositions(benchmarkPositions)
+	persisted := make(map[int64]float64, len(positions))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		markPrices := map[string]float64{"BTCUSDT": 100 + float64(i%10)}
+		collectPnLUpdates(positions, markPrices, persisted)
+	}
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"backend/models"
+	"log"
+	"sync"
+
+	"github.com/beego/beego/v2/client/orm"
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// MarginCallNotifier 爆倉警告通知
+// 倉位（或全倉帳戶）距離爆倉的進度越過設定的門檻時，發送一次 MARGIN_CALL 訊息
+// 已發送的警告等級保存在倉位上，服務重啟後不會重複發送
+type MarginCallNotifier struct {
+	once       sync.Once
+	thresholds []float64 // 警告門檻（由小到大）
+	hysteresis float64   // 回落多少才重置警告
+}
+
+var GlobalMarginCallNotifier = &MarginCallNotifier{
+	thresholds: []float64{0.7, 0.9},
+	hysteresis: 0.05,
+}
+
+// loadConfig 從設定檔讀取警告門檻，未設定時使用預設值
+func (n *MarginCallNotifier) loadConfig() {
+	if value, err := beego.AppConfig.String("margincallthresholds"); err == nil && value != "" {
+		if thresholds, err := models.ParseMarginCallThresholds(value); err == nil && len(thresholds) > 0 {
+			n.thresholds = thresholds
+		} else {
+			log.Printf("Invalid margincallthresholds %q, using %v", value, n.thresholds)
+		}
+	}
+	if hysteresis, err := beego.AppConfig.Float("margincallhysteresis"); err == nil && hysteresis >= 0 {
+		n.hysteresis = hysteresis
+	}
+}
+
+// CheckPosition 檢查逐倉倉位是否需要發送爆倉警告
+func (n *MarginCallNotifier) CheckPosition(position *models.LeveragePosition, markPrice float64) {
+	n.once.Do(n.loadConfig)
+
+	progress := position.LiquidationProgress(markPrice)
+	level := models.NextMarginCallLevel(position.MarginCallLevel, progress, n.thresholds, n.hysteresis)
+	if level == position.MarginCallLevel {
+		return
+	}
+
+	var message *models.WSMessage
+	if level > position.MarginCallLevel {
+		unrealizedPnL := position.CalculateUnrealizedPnL(markPrice)
+		message = models.NewMarginCallMessage(&models.MarginCallData{
+			PositionId:        position.Id,
+			Symbol:            position.Symbol,
+			Side:              string(position.Side),
+			MarginMode:        models.MarginModeIsolated,
+			Level:             level,
+			Threshold:         n.thresholds[level-1],
+			Progress:          progress,
+			MarkPrice:         markPrice,
+			LiquidationPrice:  position.LiquidationPrice,
+			Equity:            position.Margin + unrealizedPnL,
+			MaintenanceMargin: position.MaintenanceMargin(markPrice),
+		})
+	}
+
+	if err := saveMarginCallLevel(position.User.Id, []*models.LeveragePosition{position}, level, message); err != nil {
+		log.Printf("Failed to update margin call level of position #%d: %v", position.Id, err)
+		return
+	}
+
+	if message != nil {
+		log.Printf("Margin call sent: Position=#%d, User=%d, Level=%d, Progress=%.2f",
+			position.Id, position.User.Id, level, progress)
+	}
+}
+
+// CheckCrossAccount 檢查全倉帳戶是否需要發送爆倉警告，警告等級保存在帳戶的每個全倉持倉上
+func (n *MarginCallNotifier) CheckCrossAccount(userId int64, positions []*models.LeveragePosition, status models.CrossMarginStatus) {
+	n.once.Do(n.loadConfig)
+
+	current := 0
+	for _, position := range positions {
+		if position.MarginCallLevel > current {
+			current = position.MarginCallLevel
+		}
+	}
+
+	progress := status.LiquidationProgress()
+	level := models.NextMarginCallLevel(current, progress, n.thresholds, n.hysteresis)
+
+	var changed []*models.LeveragePosition
+	for _, position := range positions {
+		if position.MarginCallLevel != level {
+			changed = append(changed, position)
+		}
+	}
+	if len(changed) == 0 {
+		return
+	}
+
+	var message *models.WSMessage
+	if level > current {
+		message = models.NewMarginCallMessage(&models.MarginCallData{
+			MarginMode:        models.MarginModeCross,
+			Level:             level,
+			Threshold:         n.thresholds[level-1],
+			Progress:          progress,
+			Equity:            status.Equity,
+			MaintenanceMargin: status.MaintenanceMargin,
+		})
+	}
+
+	if err := saveMarginCallLevel(userId, changed, level, message); err != nil {
+		log.Printf("Failed to update margin call level of user %d: %v", userId, err)
+		return
+	}
+
+	if message != nil {
+		log.Printf("Margin call sent: Cross account of user %d, Level=%d, Progress=%.2f", userId, level, progress)
+	}
+}
+
+// saveMarginCallLevel 在同一個交易中更新倉位的警告等級並寫入 MARGIN_CALL 通知（message 為 nil 時只更新等級）
+func saveMarginCallLevel(userId int64, positions []*models.LeveragePosition, level int, message *models.WSMessage) error {
+	err := saveAndNotify(userId, func(to orm.TxOrmer) (*models.WSMessage, error) {
+		for _, position := range positions {
+			if err := models.UpdatePositionMarginCallLevel(to, position.Id, level); err != nil {
+				return nil, err
+			}
+		}
+		return message, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, position := range positions {
+		position.MarginCallLevel = level
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"backend/hub"
+	"backend/models"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+const (
+	notificationPollInterval = 1 * time.Second // 沒有喚醒時檢查 outbox 的間隔（重啟後補送、其他行程寫入的消息）
+	notificationBatchSize    = 200             // 每次讀取的消息數量上限
+)
+
+// NotificationDispatcher WebSocket 消息推送服務：依 ID 順序推送 outbox 中的消息，推送後刪除
+// 只有一個 goroutine 推送，已推送但刪除失敗的 ID 記在記憶體中，每個事件 ID 只推送一次
+type NotificationDispatcher struct {
+	mu        sync.Mutex
+	isRunning bool
+	stopChan  chan struct{}
+	wake      chan struct{}
+	published map[int64]bool // 已推送、尚未從 outbox 刪除的事件 ID（只在 run 中存取）
+}
+
+var GlobalNotificationDispatcher *NotificationDispatcher
+
+func init() {
+	GlobalNotificationDispatcher = NewNotificationDispatcher()
+}
+
+// NewNotificationDispatcher 建立消息推送服務
+func NewNotificationDispatcher() *NotificationDispatcher {
+	return &NotificationDispatcher{
+		stopChan:  make(chan struct{}),
+		wake:      make(chan struct{}, 1),
+		published: make(map[int64]bool),
+	}
+}
+
+// Start 啟動消息推送服務（先補送上次停止前未推送的消息）
+func (d *NotificationDispatcher) Start() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.isRunning {
+		return
+	}
+
+	d.isRunning = true
+	log.Println("Notification dispatcher started")
+	go d.run()
+}
+
+// Stop 停止消息推送服務，未推送的消息保留在 outbox 中
+func (d *NotificationDispatcher) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.isRunning {
+		return
+	}
+
+	d.isRunning = false
+	close(d.stopChan)
+	log.Println("Notification dispatcher stopped")
+}
+
+// Notify 通知有新消息已提交（不阻塞），推送服務立即推送而不等下一次檢查
+func (d *NotificationDispatcher) Notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run 在喚醒或定期檢查時推送消息
+func (d *NotificationDispatcher) run() {
+	ticker := time.NewTicker(notificationPollInterval)
+	defer ticker.Stop()
+
+	d.publish()
+	for {
+		select {
+		case <-d.stopChan:
+			return
+		case <-d.wake:
+			d.publish()
+		case <-ticker.C:
+			d.publish()
+		}
+	}
+}
+
+// publish 推送 outbox 中的消息直到清空
+func (d *NotificationDispatcher) publish() {
+	for {
+		notifications, err := models.GetPendingNotifications(notificationBatchSize)
+		if err != nil {
+			log.Printf("Failed to get notifications: %v", err)
+			return
+		}
+
+		ids := make([]int64, 0, len(notifications))
+		for _, notification := range notifications {
+			if !d.published[notification.Id] {
+				if message := notification.Message(); message != nil {
+					hub.GlobalHub.BroadcastToUser(notification.User.Id, message)
+				} else {
+					log.Printf("Dropping malformed notification #%d", notification.Id)
+				}
+				d.published[notification.Id] = true
+			}
+			ids = append(ids, notification.Id)
+		}
+
+		if err = models.DeleteNotifications(ids); err != nil {
+			log.Printf("Failed to delete published notifications: %v", err)
+			return
+		}
+		for _, id := range ids {
+			delete(d.published, id)
+		}
+
+		if len(notifications) < notificationBatchSize {
+			return
+		}
+	}
+}
+
+// enqueueNotification 在交易中寫入推送給使用者的消息，提交後呼叫 GlobalNotificationDispatcher.Notify
+func enqueueNotification(to orm.TxOrmer, userId int64, message *models.WSMessage) error {
+	if err := models.EnqueueNotification(to, userId, message); err != nil {
+		return fmt.Errorf("failed to enqueue notification: %v", err)
+	}
+	return nil
+}
+
+// saveAndNotify 在同一個交易中執行 save 並寫入 save 返回的消息（nil 表示不推送），提交後通知推送服務
+func saveAndNotify(userId int64, save func(to orm.TxOrmer) (*models.WSMessage, error)) error {
+	o := orm.NewOrm()
+	to, err := o.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	shouldRollback := true
+	defer func() {
+		if shouldRollback {
+			to.Rollback()
+		}
+	}()
+
+	message, err := save(to)
+	if err != nil {
+		return err
+	}
+	if message != nil {
+		if err = enqueueNotification(to, userId, message); err != nil {
+			return err
+		}
+	}
+
+	if err = to.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	shouldRollback = false
+
+	if message != nil {
+		GlobalNotificationDispatcher.Notify()
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"backend/models"
+	"errors"
+	"log"
+)
+
+// MaxBatchCancelOrders 批次取消一次最多的訂單數量
+const MaxBatchCancelOrders = 100
+
+// OrderCancelResult 單筆訂單的取消結果
+type OrderCancelResult struct {
+	OrderId int64  `json:"orderId"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// CancelOrderFilter 全部取消的篩選條件（空值表示不篩選）
+type CancelOrderFilter struct {
+	Symbol string
+	Side   models.OrderSide
+	Type   models.OrderType
+}
+
+// CancelAllOrders 取消使用者所有符合條件的待處理訂單，返回每筆訂單的結果
+func CancelAllOrders(userId int64, filter CancelOrderFilter) ([]*OrderCancelResult, error) {
+	orders, err := models.GetPendingOrdersByUser(userId, filter.Symbol, filter.Side, filter.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	orderIds := make([]int64, len(orders))
+	for i, order := range orders {
+		orderIds[i] = order.Id
+	}
+	results := CancelOrders(userId, orderIds)
+
+	log.Printf("Cancel all orders: User=%d, Symbol=%q, Side=%q, Type=%q, Orders=%d",
+		userId, filter.Symbol, filter.Side, filter.Type, len(results))
+	return results, nil
+}
+
+// CancelOrders 逐筆取消指定的訂單，單筆失敗不影響其他訂單
+// 同一個 OCO 訂單組的子訂單只取消一次，其他子訂單沿用相同的結果
+func CancelOrders(userId int64, orderIds []int64) []*OrderCancelResult {
+	results := make([]*OrderCancelResult, 0, len(orderIds))
+	listResults := make(map[int64]error)
+	seen := make(map[int64]bool)
+
+	for _, orderId := range orderIds {
+		if seen[orderId] {
+			continue
+		}
+		seen[orderId] = true
+
+		err := cancelOrderOnce(userId, orderId, listResults)
+		result := &OrderCancelResult{OrderId: orderId, Success: err == nil}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// cancelOrderOnce 取消單筆訂單，OCO 子訂單以訂單組為單位取消
+func cancelOrderOnce(userId int64, orderId int64, listResults map[int64]error) error {
+	order, err := models.GetOrderById(orderId)
+	if err != nil {
+		return errors.New("order not found")
+	}
+	if order.User.Id != userId {
+		return errors.New("unauthorized: order does not belong to user")
+	}
+
+	if order.OrderListId == 0 {
+		return CancelOrder(userId, orderId)
+	}
+
+	if err, ok := listResults[order.OrderListId]; ok {
+		return err
+	}
+	err = CancelOrder(userId, orderId)
+	listResults[order.OrderListId] = err
+	return err
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"backend/models"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// PlaceOCOOrder 下 OCO 訂單組：限價止盈與停損兩個子訂單，一個成交時撮合器取消另一個
+func PlaceOCOOrder(userId int64, symbol string, side models.OrderSide, quantity float64, limitPrice float64, stopPrice float64) (*models.OrderList, error) {
+	// 1. 驗證輸入
+	if quantity <= 0 {
+		return nil, errors.New("quantity must be positive")
+	}
+
+	if _, _, err := models.ParseSymbol(symbol); err != nil {
+		return nil, err
+	}
+
+	currentPrice, ok := GlobalPriceCache.GetPrice(symbol)
+	if !ok {
+		return nil, fmt.Errorf("price not available for %s", symbol)
+	}
+
+	if err := models.ValidateOCOPrices(side, limitPrice, stopPrice, currentPrice); err != nil {
+		return nil, err
+	}
+
+	// 2. 在同一交易中建立訂單組與兩個子訂單
+	o := orm.NewOrm()
+	to, err := o.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	shouldRollback := true
+	defer func() {
+		if shouldRollback {
+			to.Rollback()
+		}
+	}()
+
+	list, err := models.CreateOCOOrderList(to, userId, symbol, side, quantity, limitPrice, stopPrice)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create order list: %v", err)
+	}
+
+	if err = to.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	shouldRollback = false
+
+	log.Printf("OCO order list #%d created: User=%d, %s %s %.8f, LimitPrice=%.2f, StopPrice=%.2f (current price: %.2f)",
+		list.Id, userId, side, symbol, quantity, limitPrice, stopPrice, currentPrice)
+
+	// 3. 兩個子訂單都加入撮合器
+	for _, order := range list.Orders {
+		GlobalLimitOrderMatcher.AddOrder(order)
+	}
+
+	return list, nil
+}
+
+// CancelOrderList 取消訂單組與所有待處理的子訂單
+func CancelOrderList(userId int64, orderListId int64) error {
+	o := orm.NewOrm()
+	to, err := o.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	shouldRollback := true
+	defer func() {
+		if shouldRollback {
+			to.Rollback()
+		}
+	}()
+
+	list, err := models.GetOrderListForUpdate(to, orderListId)
+	if err != nil {
+		return err
+	}
+
+	if list.User.Id != userId {
+		return errors.New("unauthorized: order list does not belong to user")
+	}
+
+	if list.Status != models.OrderListStatusActive {
+		return errors.New("order list cannot be canceled")
+	}
+
+	canceled, err := models.FinishOrderList(to, list, models.OrderListStatusCanceled, 0)
+	if err != nil {
+		return err
+	}
+
+	if err = to.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	shouldRollback = false
+
+	// 從撮合器中移除
+	for _, orderId := range canceled {
+		GlobalLimitOrderMatcher.RemoveOrder(orderId)
+	}
+	return nil
+}
+
+// GetOrderList 查詢使用者的訂單組
+func GetOrderList(userId int64, orderListId int64) (*models.OrderList, error) {
+	list, err := models.GetOrderListById(orderListId)
+	if err != nil {
+		return nil, err
+	}
+	if list.User.Id != userId {
+		return nil, errors.New("unauthorized: order list does not belong to user")
+	}
+	return list, nil
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"backend/models"
+	"log"
+	"sync"
+	"time"
+)
+
+// portfolioSnapshotInterval 檢查是否進入新的快照週期的間隔
+const portfolioSnapshotInterval = 1 * time.Minute
+
+// portfolioGranularities 定期快照的週期
+var portfolioGranularities = []models.PortfolioGranularity{models.PortfolioGranularityHourly, models.PortfolioGranularityDaily}
+
+// PortfolioSnapshotter 資產快照服務：每小時與每日記錄所有使用者的資產總值（資產曲線）
+// 每個週期在第一次檢查時快照，缺少價格的使用者在同一週期內稍後重試，已有快照的週期不會重複寫入
+type PortfolioSnapshotter struct {
+	mu        sync.Mutex
+	isRunning bool
+	stopChan  chan struct{}
+	done      map[models.PortfolioGranularity]time.Time // 所有使用者都已快照的最近週期（只在 run 中存取）
+}
+
+var GlobalPortfolioSnapshotter *PortfolioSnapshotter
+
+func init() {
+	GlobalPortfolioSnapshotter = NewPortfolioSnapshotter()
+}
+
+// NewPortfolioSnapshotter 建立資產快照服務
+func NewPortfolioSnapshotter() *PortfolioSnapshotter {
+	return &PortfolioSnapshotter{
+		stopChan: make(chan struct{}),
+		done:     make(map[models.PortfolioGranularity]time.Time),
+	}
+}
+
+// Start 啟動資產快照服務
+func (s *PortfolioSnapshotter) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isRunning {
+		return
+	}
+
+	s.isRunning = true
+	log.Println("Portfolio snapshotter started")
+	go s.run()
+}
+
+// Stop 停止資產快照服務
+func (s *PortfolioSnapshotter) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isRunning {
+		return
+	}
+
+	s.isRunning = false
+	close(s.stopChan)
+	log.Println("Portfolio snapshotter stopped")
+}
+
+// run 定期檢查並寫入快照
+func (s *PortfolioSnapshotter) run() {
+	ticker := time.NewTicker(portfolioSnapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case now := <-ticker.C:
+			s.snapshot(now)
+		}
+	}
+}
+
+// snapshot 為尚未完成的週期寫入所有使用者的快照（每位使用者只估值一次）
+func (s *PortfolioSnapshotter) snapshot(now time.Time) {
+	var due []models.PortfolioGranularity
+	for _, granularity := range portfolioGranularities {
+		if !s.done[granularity].Equal(granularity.Period(now)) {
+			due = append(due, granularity)
+		}
+	}
+	if len(due) == 0 {
+		return
+	}
+
+	userIds, err := models.GetAllUserIds()
+	if err != nil {
+		log.Printf("Failed to get users for portfolio snapshots: %v", err)
+		return
+	}
+
+	prices := GlobalPriceCache.GetAllPrices()
+	markPrices := GlobalPriceCache.GetAllMarkPrices()
+	complete := true
+	for _, userId := range userIds {
+		summary, err := valuePortfolio(userId, prices, markPrices, now)
+		if err != nil {
+			log.Printf("Failed to value portfolio of user %d: %v", userId, err)
+			complete = false
+			continue
+		}
+		// 缺少價格時的估值不正確，等價格恢復後再快照
+		if !summary.Complete() {
+			complete = false
+			continue
+		}
+
+		for _, granularity := range due {
+			if _, err = models.SavePortfolioSnapshot(models.NewPortfolioSnapshot(userId, granularity, summary)); err != nil {
+				log.Printf("Failed to save %s portfolio snapshot of user %d: %v", granularity, userId, err)
+				complete = false
+			}
+		}
+	}
+	if !complete {
+		return
+	}
+
+	for _, granularity := range due {
+		s.done[granularity] = granularity.Period(now)
+	}
+	log.Printf("Portfolio snapshots taken for %d users (%v)", len(userIds), due)
+
+	// 每小時快照只保留最近一段時間，更早的資產曲線使用每日快照
+	before := models.PortfolioGranularityHourly.Period(now).Add(-models.HourlyPortfolioSnapshotRetention)
+	if _, err = models.DeletePortfolioSnapshotsBefore(models.PortfolioGranularityHourly, before); err != nil {
+		log.Printf("Failed to delete old portfolio snapshots: %v", err)
+	}
+}
+
+// valuePortfolio 以指定價格計算使用者的資產總值
+func valuePortfolio(userId int64, prices map[string]float64, markPrices map[string]float64, now time.Time) (*models.PortfolioSummary, error) {
+	wallets, err := models.GetAllWalletsByUser(userId)
+	if err != nil {
+		return nil, err
+	}
+	positions, err := models.GetOpenPositionsByUser(userId)
+	if err != nil {
+		return nil, err
+	}
+	return models.ValuePortfolio(wallets, positions, prices, markPrices, now), nil
+}
+
+// GetPortfolioSummary 以目前價格計算使用者的資產總值（錢包 + 持倉保證金 + 未實現盈虧）
+func GetPortfolioSummary(userId int64) (*models.PortfolioSummary, error) {
+	return valuePortfolio(userId, GlobalPriceCache.GetAllPrices(), GlobalPriceCache.GetAllMarkPrices(), time.Now())
+}
+
+// GetPortfolioHistory 查詢範圍內的資產快照（資產曲線）
+func GetPortfolioHistory(userId int64, rangeValue string) ([]*models.PortfolioSnapshot, models.PortfolioGranularity, error) {
+	r, err := models.ParsePortfolioHistoryRange(rangeValue)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var since time.Time
+	if r.Duration > 0 {
+		since = r.Granularity.Period(time.Now().Add(-r.Duration))
+	}
+	snapshots, err := models.GetPortfolioSnapshots(userId, r.Granularity, since)
+	if err != nil {
+		return nil, "", err
+	}
+	return snapshots, r.Granularity, nil
+}
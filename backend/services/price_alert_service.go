@@ -0,0 +1,377 @@
+package services
+
+import (
+	"backend/models"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+const (
+	priceAlertEventQueueSize   = 1024            // 待寫入與通知的觸發事件上限
+	priceHistorySampleInterval = 1 * time.Second // 漲跌幅使用的價格紀錄取樣間隔
+)
+
+// PriceAlertService 價格提醒服務：在記憶體中維護監控中的提醒，每筆成交價格更新時檢查
+// 觸發事件交給背景工作在同一個交易中寫回觸發狀態、webhook 事件與 PRICE_ALERT 通知
+// 觸發的提醒在寫回之前保留在記憶體中，寫回失敗或佇列已滿時還原觸發前的狀態，之後的價格重新檢查
+type PriceAlertService struct {
+	mu         sync.Mutex // 保護記憶體中的提醒與價格紀錄
+	storeMu    sync.Mutex // 串行化提醒的資料庫寫入（使用者修改與觸發狀態）
+	isRunning  bool
+	stopChan   chan struct{}
+	alerts     map[string]map[int64]*models.PriceAlert // symbol -> alertId -> 監控中的提醒
+	generation map[int64]int                           // alertId -> 修改次數，修改前的觸發狀態不再寫回
+	histories  map[string]*priceHistory
+	events     chan priceAlertEvent
+}
+
+// priceAlertEvent 提醒狀態改變（觸發或重新就緒）
+type priceAlertEvent struct {
+	alert      models.PriceAlert // 改變後的提醒
+	previous   models.PriceAlert // 改變前的提醒（寫回失敗時還原）
+	generation int               // 改變時的修改次數
+	message    *models.WSMessage // 觸發時的通知，重新就緒時為 nil
+}
+
+var GlobalPriceAlertService *PriceAlertService
+
+func init() {
+	GlobalPriceAlertService = NewPriceAlertService()
+}
+
+// NewPriceAlertService 建立價格提醒服務
+func NewPriceAlertService() *PriceAlertService {
+	return &PriceAlertService{
+		stopChan:   make(chan struct{}),
+		alerts:     make(map[string]map[int64]*models.PriceAlert),
+		generation: make(map[int64]int),
+		histories:  make(map[string]*priceHistory),
+		events:     make(chan priceAlertEvent, priceAlertEventQueueSize),
+	}
+}
+
+// Start 載入監控中的提醒並開始監聽價格更新
+func (s *PriceAlertService) Start() {
+	s.mu.Lock()
+	if s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = true
+	s.mu.Unlock()
+
+	alerts, err := models.GetActivePriceAlerts()
+	if err != nil {
+		log.Printf("Failed to load price alerts: %v", err)
+	}
+	for _, alert := range alerts {
+		s.track(alert)
+	}
+
+	GlobalPriceCache.OnPriceUpdate(s.onPriceUpdate)
+
+	log.Printf("Price alert service started (%d alerts)", len(alerts))
+	go s.run()
+}
+
+// Stop 停止價格提醒服務
+func (s *PriceAlertService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isRunning {
+		return
+	}
+
+	s.isRunning = false
+	close(s.stopChan)
+	log.Println("Price alert service stopped")
+}
+
+// run 依序處理觸發事件
+func (s *PriceAlertService) run() {
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case event := <-s.events:
+			s.handle(event)
+		}
+	}
+}
+
+// onPriceUpdate 價格快取的回呼（同步執行，不可阻塞）：記錄價格並檢查該交易對的提醒
+func (s *PriceAlertService) onPriceUpdate(symbol string) {
+	price, ok := GlobalPriceCache.GetPrice(symbol)
+	if !ok {
+		return
+	}
+	now := time.Now()
+
+	var events []priceAlertEvent
+	s.mu.Lock()
+	history := s.histories[symbol]
+	if history == nil {
+		history = newPriceHistory(models.MaxPriceAlertWindowSeconds * time.Second)
+		s.histories[symbol] = history
+	}
+	previous := history.last
+	history.add(price, now)
+
+	for id, alert := range s.alerts[symbol] {
+		var reference float64
+		if alert.Condition == models.PriceAlertConditionPercentChange {
+			reference = history.priceAt(now.Add(-alert.Window()))
+		}
+
+		before := *alert
+		triggered, changed := alert.Check(previous, price, reference, now)
+		if !changed {
+			continue
+		}
+		event := priceAlertEvent{alert: *alert, previous: before, generation: s.generation[id]}
+		if triggered {
+			event.message = models.NewPriceAlertMessage(alert, price, reference, now)
+		}
+		events = append(events, event)
+	}
+	s.mu.Unlock()
+
+	for _, event := range events {
+		select {
+		case s.events <- event:
+		default:
+			// 佇列已滿：資料庫仍為觸發前的狀態，還原後由之後的價格重新檢查
+			log.Printf("Price alert event queue full, deferring alert #%d", event.alert.Id)
+			s.release(event, false)
+		}
+	}
+}
+
+// handle 在同一個交易中寫回提醒的觸發狀態、webhook 事件與 PRICE_ALERT 通知
+func (s *PriceAlertService) handle(event priceAlertEvent) {
+	alert := &event.alert
+	s.storeMu.Lock()
+	s.mu.Lock()
+	current := s.generation[alert.Id] == event.generation
+	s.mu.Unlock()
+
+	// 提醒已被修改或刪除時不寫回觸發狀態，但已觸發的通知照常送出
+	err := savePriceAlertEvent(event, current)
+	if current {
+		s.release(event, err == nil)
+	}
+	s.storeMu.Unlock()
+
+	if err != nil {
+		log.Printf("Failed to save price alert #%d: %v", alert.Id, err)
+		return
+	}
+	if event.message != nil {
+		log.Printf("Price alert #%d triggered: %s %s at %.8f", alert.Id, alert.Symbol, alert.Condition, alert.LastTriggerPrice)
+	}
+}
+
+// savePriceAlertEvent 寫回觸發狀態（current 時），觸發時在同一個交易中寫入通知與 webhook 事件（設定 webhook 時）
+func savePriceAlertEvent(event priceAlertEvent, current bool) error {
+	alert := &event.alert
+	return saveAndNotify(alert.User.Id, func(to orm.TxOrmer) (*models.WSMessage, error) {
+		if current {
+			if err := models.SavePriceAlertTrigger(to, alert); err != nil {
+				return nil, err
+			}
+		}
+		if event.message != nil && alert.Webhook {
+			if err := models.EnqueueWebhookEvent(to, alert.User.Id, models.WebhookEventPriceAlert, event.message.Data); err != nil {
+				return nil, fmt.Errorf("failed to enqueue webhook event: %v", err)
+			}
+		}
+		return event.message, nil
+	})
+}
+
+// release 觸發狀態寫回後結束監控已觸發的單次提醒；未寫回時還原觸發前的狀態，由之後的價格重新檢查
+// 提醒在事件之後已被修改或再次改變時不處理
+func (s *PriceAlertService) release(event priceAlertEvent, saved bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alert := &event.alert
+	tracked := s.alerts[alert.Symbol][alert.Id]
+	if tracked == nil || s.generation[alert.Id] != event.generation || *tracked != *alert {
+		return
+	}
+	if !saved {
+		*tracked = event.previous
+		return
+	}
+	if tracked.Status != models.PriceAlertStatusActive {
+		delete(s.alerts[alert.Symbol], alert.Id)
+	}
+}
+
+// track 開始監控提醒（保存副本，避免與回傳給使用者的物件共用）
+func (s *PriceAlertService) track(alert *models.PriceAlert) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.untrackLocked(alert.Id)
+	if alert.Status != models.PriceAlertStatusActive {
+		return
+	}
+	tracked := *alert
+	if s.alerts[alert.Symbol] == nil {
+		s.alerts[alert.Symbol] = make(map[int64]*models.PriceAlert)
+	}
+	s.alerts[alert.Symbol][alert.Id] = &tracked
+}
+
+// untrack 停止監控提醒
+func (s *PriceAlertService) untrack(alertId int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.untrackLocked(alertId)
+}
+
+// untrackLocked 停止監控提醒，尚未寫回的觸發狀態不再寫回（需持有 s.mu）
+func (s *PriceAlertService) untrackLocked(alertId int64) {
+	s.generation[alertId]++
+	for _, alerts := range s.alerts {
+		delete(alerts, alertId)
+	}
+}
+
+// CreatePriceAlert 建立價格提醒
+func (s *PriceAlertService) CreatePriceAlert(userId int64, params models.PriceAlertParams) (*models.PriceAlert, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.storeMu.Lock()
+	defer s.storeMu.Unlock()
+
+	if err := checkActivePriceAlertLimit(userId); err != nil {
+		return nil, err
+	}
+
+	alert := &models.PriceAlert{User: &models.User{Id: userId}}
+	alert.Apply(params)
+	if err := models.CreatePriceAlert(alert); err != nil {
+		return nil, fmt.Errorf("failed to create price alert: %v", err)
+	}
+	s.track(alert)
+	return alert, nil
+}
+
+// UpdatePriceAlert 修改價格提醒，已觸發的單次提醒重新開始監控
+func (s *PriceAlertService) UpdatePriceAlert(userId int64, id int64, params models.PriceAlertParams) (*models.PriceAlert, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.storeMu.Lock()
+	defer s.storeMu.Unlock()
+
+	alert, err := GetPriceAlert(userId, id)
+	if err != nil {
+		return nil, err
+	}
+	if alert.Status != models.PriceAlertStatusActive {
+		if err = checkActivePriceAlertLimit(userId); err != nil {
+			return nil, err
+		}
+	}
+
+	alert.Apply(params)
+	if err = models.SavePriceAlert(alert); err != nil {
+		return nil, fmt.Errorf("failed to update price alert: %v", err)
+	}
+	s.track(alert)
+	return alert, nil
+}
+
+// DeletePriceAlert 刪除價格提醒
+func (s *PriceAlertService) DeletePriceAlert(userId int64, id int64) error {
+	s.storeMu.Lock()
+	defer s.storeMu.Unlock()
+
+	if _, err := GetPriceAlert(userId, id); err != nil {
+		return err
+	}
+	if err := models.DeletePriceAlert(id); err != nil {
+		return err
+	}
+	s.untrack(id)
+	return nil
+}
+
+// GetPriceAlert 查詢使用者的價格提醒
+func GetPriceAlert(userId int64, id int64) (*models.PriceAlert, error) {
+	alert, err := models.GetPriceAlertById(id)
+	if err != nil {
+		return nil, err
+	}
+	if alert.User.Id != userId {
+		return nil, errors.New("unauthorized: price alert does not belong to user")
+	}
+	return alert, nil
+}
+
+// checkActivePriceAlertLimit 檢查使用者監控中的提醒數量
+func checkActivePriceAlertLimit(userId int64) error {
+	count, err := models.CountActivePriceAlertsByUser(userId)
+	if err != nil {
+		return err
+	}
+	if count >= models.MaxActivePriceAlertsPerUser {
+		return fmt.Errorf("at most %d active price alerts are allowed", models.MaxActivePriceAlertsPerUser)
+	}
+	return nil
+}
+
+// priceHistory 交易對最近的價格紀錄（每秒最多一筆），用於計算時間窗口內的漲跌幅
+type priceHistory struct {
+	retention time.Duration
+	samples   []priceSample
+	last      float64 // 最新一筆價格（不受取樣間隔限制）
+}
+
+type priceSample struct {
+	at    time.Time
+	price float64
+}
+
+func newPriceHistory(retention time.Duration) *priceHistory {
+	return &priceHistory{retention: retention}
+}
+
+// add 記錄一筆價格並移除超過保留時間的紀錄
+func (h *priceHistory) add(price float64, now time.Time) {
+	h.last = price
+	if n := len(h.samples); n > 0 && now.Sub(h.samples[n-1].at) < priceHistorySampleInterval {
+		return
+	}
+	h.samples = append(h.samples, priceSample{at: now, price: price})
+
+	cutoff := now.Add(-h.retention)
+	drop := sort.Search(len(h.samples), func(i int) bool { return !h.samples[i].at.Before(cutoff) })
+	h.samples = h.samples[drop:]
+}
+
+// priceAt 時間點當下的價格（該時間點之前最後一筆），紀錄不足時回傳最早的價格，沒有紀錄時回傳 0
+func (h *priceHistory) priceAt(at time.Time) float64 {
+	if len(h.samples) == 0 {
+		return 0
+	}
+	i := sort.Search(len(h.samples), func(i int) bool { return h.samples[i].at.After(at) })
+	if i == 0 {
+		return h.samples[0].price
+	}
+	return h.samples[i-1].price
+}
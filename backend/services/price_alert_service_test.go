@@ -0,0 +1,77 @@
+package services
+
+import (
+	"backend/models"
+	"testing"
+	"time"
+)
+
+// TestPriceHistory 測試價格紀錄的取樣、保留時間與時間窗口起點的價格
+func TestPriceHistory(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := newPriceHistory(time.Minute)
+
+	if price := history.priceAt(start); price != 0 {
+		t.Errorf("expected 0 without samples, got %.2f", price)
+	}
+
+	for i := 0; i < 90; i++ {
+		history.add(100+float64(i), start.Add(time.Duration(i)*time.Second))
+		history.add(1000, start.Add(time.Duration(i)*time.Second+500*time.Millisecond)) // 同一秒內不取樣
+	}
+
+	if history.last != 1000 {
+		t.Errorf("expected last price 1000, got %.2f", history.last)
+	}
+	if len(history.samples) != 61 {
+		t.Errorf("expected 61 samples within retention, got %d", len(history.samples))
+	}
+
+	tests := []struct {
+		at   time.Time
+		want float64
+	}{
+		{start.Add(80 * time.Second), 180},
+		{start.Add(80*time.Second + 900*time.Millisecond), 180},
+		{start, 129}, // 超過保留時間，回傳最早的價格
+	}
+	for _, tt := range tests {
+		if got := history.priceAt(tt.at); got != tt.want {
+			t.Errorf("priceAt(%s) = %.2f, want %.2f", tt.at.Format(time.TimeOnly), got, tt.want)
+		}
+	}
+}
+
+// TestPriceAlertQueueFull 測試事件佇列已滿時，觸發的提醒還原為觸發前的狀態並繼續監控
+func TestPriceAlertQueueFull(t *testing.T) {
+	const symbol = "ALERTTESTUSDT"
+	s := NewPriceAlertService()
+	s.events = make(chan priceAlertEvent) // 沒有緩衝也沒有接收者，模擬佇列已滿
+
+	s.track(&models.PriceAlert{
+		Id:          1,
+		User:        &models.User{Id: 1},
+		Symbol:      symbol,
+		Condition:   models.PriceAlertConditionAbove,
+		TargetPrice: 100,
+		Status:      models.PriceAlertStatusActive,
+		Armed:       true,
+	})
+
+	GlobalPriceCache.SetPrice(symbol, 101)
+	s.onPriceUpdate(symbol)
+
+	alert := s.alerts[symbol][1]
+	if alert == nil {
+		t.Fatal("expected alert to remain tracked")
+	}
+	if alert.Status != models.PriceAlertStatusActive {
+		t.Errorf("expected status ACTIVE, got %s", alert.Status)
+	}
+	if !alert.Armed {
+		t.Error("expected alert to remain armed")
+	}
+	if alert.TriggerCount != 0 {
+		t.Errorf("expected trigger count 0, got %d", alert.TriggerCount)
+	}
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"backend/models"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -10,14 +11,24 @@ import (
 
 // PriceCache 價格快取（執行緒安全）
 type PriceCache struct {
-	mu         sync.RWMutex
-	prices     map[string]float64 // symbol -> price
-	lastUpdate map[string]time.Time
+	mu           sync.RWMutex
+	prices       map[string]float64 // symbol -> price
+	lastUpdate   map[string]time.Time
+	recentTrades map[string][]float64 // symbol -> 最近成交價格（環狀緩衝區）
+	tradeCursor  map[string]int
+	listeners    []func(symbol string) // 價格更新時的回呼
 }
 
-var GlobalPriceCache = &PriceCache{
-	prices:     make(map[string]float64),
-	lastUpdate: make(map[string]time.Time),
+var GlobalPriceCache = NewPriceCache()
+
+// NewPriceCache 建立價格快取
+func NewPriceCache() *PriceCache {
+	return &PriceCache{
+		prices:       make(map[string]float64),
+		lastUpdate:   make(map[string]time.Time),
+		recentTrades: make(map[string][]float64),
+		tradeCursor:  make(map[string]int),
+	}
 }
 
 // BinanceTradeMessage Binance 交易訊息格式
@@ -54,16 +65,43 @@ func (pc *PriceCache) UpdatePrice(message []byte) {
 		return
 	}
 
+	pc.SetPrice(symbol, price)
+
+	// 可選：記錄價格更新（用於調試）
+	// log.Printf("Price updated: %s = %.2f", symbol, price)
+}
+
+// SetPrice 記錄一筆成交價格，並通知已註冊的回呼
+func (pc *PriceCache) SetPrice(symbol string, price float64) {
 	pc.mu.Lock()
 	pc.prices[symbol] = price
 	pc.lastUpdate[symbol] = time.Now()
+
+	trades := pc.recentTrades[symbol]
+	if len(trades) < models.MarkPriceSampleSize {
+		pc.recentTrades[symbol] = append(trades, price)
+	} else {
+		cursor := pc.tradeCursor[symbol]
+		trades[cursor] = price
+		pc.tradeCursor[symbol] = (cursor + 1) % models.MarkPriceSampleSize
+	}
+	listeners := pc.listeners
 	pc.mu.Unlock()
 
-	// 可選：記錄價格更新（用於調試）
-	// log.Printf("Price updated: %s = %.2f", symbol, price)
+	for _, listener := range listeners {
+		listener(symbol)
+	}
+}
+
+// OnPriceUpdate 註冊價格更新的回呼，回呼在 SetPrice 中同步執行，不可阻塞
+func (pc *PriceCache) OnPriceUpdate(listener func(symbol string)) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	pc.listeners = append(pc.listeners, listener)
 }
 
-// GetPrice 取得當前價格
+// GetPrice 取得當前價格（最新成交價）
 func (pc *PriceCache) GetPrice(symbol string) (float64, bool) {
 	pc.mu.RLock()
 	defer pc.mu.RUnlock()
@@ -102,3 +140,35 @@ func (pc *PriceCache) GetAllPrices() map[string]float64 {
 	}
 	return result
 }
+
+// GetMarkPrice 取得標記價格：最近成交價格的中位數
+// 用於未實現盈虧與爆倉判斷，避免單筆異常成交觸發爆倉
+func (pc *PriceCache) GetMarkPrice(symbol string) (float64, bool) {
+	pc.mu.RLock()
+	trades := make([]float64, len(pc.recentTrades[symbol]))
+	copy(trades, pc.recentTrades[symbol])
+	pc.mu.RUnlock()
+
+	if len(trades) == 0 {
+		return 0, false
+	}
+	return models.CalculateMarkPrice(trades), true
+}
+
+// GetAllMarkPrices 取得所有交易對的標記價格
+func (pc *PriceCache) GetAllMarkPrices() map[string]float64 {
+	pc.mu.RLock()
+	symbols := make([]string, 0, len(pc.recentTrades))
+	for symbol := range pc.recentTrades {
+		symbols = append(symbols, symbol)
+	}
+	pc.mu.RUnlock()
+
+	result := make(map[string]float64, len(symbols))
+	for _, symbol := range symbols {
+		if markPrice, ok := pc.GetMarkPrice(symbol); ok {
+			result[symbol] = markPrice
+		}
+	}
+	return result
+}
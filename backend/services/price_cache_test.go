@@ -0,0 +1,51 @@
+package services
+
+import (
+	"backend/models"
+	"testing"
+)
+
+// TestMarkPriceIgnoresOutlier 測試標記價格不受單筆異常成交影響
+func TestMarkPriceIgnoresOutlier(t *testing.T) {
+	pc := NewPriceCache()
+
+	if _, ok := pc.GetMarkPrice("BTCUSDT"); ok {
+		t.Error("expected no mark price before any trade")
+	}
+
+	for i := 0; i < 10; i++ {
+		pc.SetPrice("BTCUSDT", 100+float64(i%3)) // 100, 101, 102
+	}
+	pc.SetPrice("BTCUSDT", 50) // 異常成交
+
+	lastPrice, _ := pc.GetPrice("BTCUSDT")
+	if lastPrice != 50 {
+		t.Errorf("expected last price 50, got %.2f", lastPrice)
+	}
+
+	markPrice, ok := pc.GetMarkPrice("BTCUSDT")
+	if !ok || markPrice != 101 {
+		t.Errorf("expected mark price 101, got %.2f", markPrice)
+	}
+}
+
+// TestMarkPriceWindow 測試標記價格只使用最近的成交
+func TestMarkPriceWindow(t *testing.T) {
+	pc := NewPriceCache()
+
+	for i := 0; i < models.MarkPriceSampleSize; i++ {
+		pc.SetPrice("ETHUSDT", 10)
+	}
+	for i := 0; i < models.MarkPriceSampleSize; i++ {
+		pc.SetPrice("ETHUSDT", 20)
+	}
+
+	if markPrice, _ := pc.GetMarkPrice("ETHUSDT"); markPrice != 20 {
+		t.Errorf("expected mark price 20 after window rolled over, got %.2f", markPrice)
+	}
+
+	prices := pc.GetAllMarkPrices()
+	if len(prices) != 1 || prices["ETHUSDT"] != 20 {
+		t.Errorf("unexpected mark prices: %v", prices)
+	}
+}
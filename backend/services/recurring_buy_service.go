@@ -0,0 +1,246 @@
+package services
+
+import (
+	"backend/models"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// RecurringBuyScheduler 定期定額排程器：到期時以市價單買入，停機期間錯過的排程依補買規則處理
+type RecurringBuyScheduler struct {
+	mu             sync.Mutex
+	isRunning      bool
+	stopChan       chan struct{}
+	checkInterval  time.Duration
+	missedRunGrace time.Duration // 排程延遲超過此時間視為停機期間錯過
+}
+
+var GlobalRecurringBuyScheduler *RecurringBuyScheduler
+
+func init() {
+	GlobalRecurringBuyScheduler = &RecurringBuyScheduler{
+		checkInterval:  10 * time.Second,
+		missedRunGrace: 5 * time.Minute,
+		stopChan:       make(chan struct{}),
+	}
+}
+
+// Start 啟動定期定額排程器
+func (s *RecurringBuyScheduler) Start() {
+	s.mu.Lock()
+	if s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = true
+	s.mu.Unlock()
+
+	log.Println("Recurring buy scheduler started")
+	go s.run()
+}
+
+// Stop 停止定期定額排程器
+func (s *RecurringBuyScheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isRunning {
+		return
+	}
+
+	s.isRunning = false
+	close(s.stopChan)
+	log.Println("Recurring buy scheduler stopped")
+}
+
+// run 主要監控循環
+func (s *RecurringBuyScheduler) run() {
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.runDueSchedules()
+		}
+	}
+}
+
+// runDueSchedules 處理所有已到期的排程
+func (s *RecurringBuyScheduler) runDueSchedules() {
+	now := time.Now()
+	schedules, err := models.GetDueRecurringBuys(now)
+	if err != nil {
+		log.Printf("Failed to load due recurring buys: %v", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		s.runSchedule(schedule, now)
+	}
+}
+
+// runSchedule 推進排程後執行（先推進再下單，下單失敗也不會重複執行同一個排程）
+func (s *RecurringBuyScheduler) runSchedule(schedule *models.RecurringBuy, now time.Time) {
+	plan := schedule.PlanRuns(now, s.missedRunGrace)
+
+	claimed, err := models.ClaimRecurringBuyRun(schedule, plan.Next, now)
+	if err != nil {
+		log.Printf("Failed to advance recurring buy #%d: %v", schedule.Id, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	for _, scheduledAt := range plan.Skipped {
+		s.recordRun(schedule, &models.RecurringBuyRun{
+			ScheduledAt: scheduledAt,
+			Status:      models.RecurringBuyRunStatusSkipped,
+			QuoteAmount: schedule.QuoteAmount,
+			ErrorMsg:    "missed while the scheduler was unavailable",
+		})
+	}
+	if len(plan.Skipped) > 0 {
+		log.Printf("Recurring buy #%d: skipped %d missed runs (catch-up policy %s)",
+			schedule.Id, len(plan.Skipped), schedule.CatchUp)
+	}
+
+	if plan.Execute != nil {
+		s.execute(schedule, *plan.Execute)
+	}
+}
+
+// execute 以市價單買入並記錄結果，成交通知與訂單在同一交易中寫入
+func (s *RecurringBuyScheduler) execute(schedule *models.RecurringBuy, scheduledAt time.Time) {
+	userId := schedule.User.Id
+	run := &models.RecurringBuyRun{
+		ScheduledAt: scheduledAt,
+		QuoteAmount: schedule.QuoteAmount,
+	}
+
+	order, err := placeNotifiedMarketOrder(userId, models.OrderParent{}, schedule.Symbol, models.OrderSideBuy, schedule.QuoteAmount)
+	if err != nil {
+		run.Status = models.RecurringBuyRunStatusFailed
+		run.ErrorMsg = err.Error()
+		log.Printf("Recurring buy #%d failed: User=%d, %s %.2f USDT: %v",
+			schedule.Id, userId, schedule.Symbol, schedule.QuoteAmount, err)
+		s.recordRun(schedule, run)
+		return
+	}
+
+	run.Status = models.RecurringBuyRunStatusSuccess
+	run.OrderId = order.Id
+	run.Price = order.Price
+	if order.Price > 0 {
+		run.Quantity = order.TotalAmount / order.Price
+	}
+	s.recordRun(schedule, run)
+
+	log.Printf("Recurring buy #%d executed: User=%d, %s %.2f USDT at %.2f (order #%d)",
+		schedule.Id, userId, schedule.Symbol, schedule.QuoteAmount, order.Price, order.Id)
+}
+
+// recordRun 寫入執行記錄
+func (s *RecurringBuyScheduler) recordRun(schedule *models.RecurringBuy, run *models.RecurringBuyRun) {
+	run.RecurringBuy = schedule
+	if err := models.CreateRecurringBuyRun(run); err != nil {
+		log.Printf("Failed to record run of recurring buy #%d: %v", schedule.Id, err)
+	}
+}
+
+// CreateRecurringBuy 建立定期定額排程
+func CreateRecurringBuy(userId int64, params models.RecurringBuyParams) (*models.RecurringBuy, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	schedule := &models.RecurringBuy{
+		User:   &models.User{Id: userId},
+		Status: models.RecurringBuyStatusActive,
+	}
+	schedule.Apply(params, time.Now())
+
+	if err := models.CreateRecurringBuy(schedule); err != nil {
+		return nil, fmt.Errorf("failed to create recurring buy: %v", err)
+	}
+
+	log.Printf("Recurring buy #%d created: User=%d, %s %.2f USDT, %s, next run at %v",
+		schedule.Id, userId, schedule.Symbol, schedule.QuoteAmount, schedule.Cadence, schedule.NextRunAt)
+	return schedule, nil
+}
+
+// GetRecurringBuy 查詢使用者的排程
+func GetRecurringBuy(userId int64, id int64) (*models.RecurringBuy, error) {
+	schedule, err := models.GetRecurringBuyById(id)
+	if err != nil {
+		return nil, err
+	}
+	if schedule.User.Id != userId {
+		return nil, errors.New("unauthorized: recurring buy does not belong to user")
+	}
+	return schedule, nil
+}
+
+// UpdateRecurringBuy 修改排程的設定，啟用中的排程從現在重新計算下一次時間
+func UpdateRecurringBuy(userId int64, id int64, params models.RecurringBuyParams) (*models.RecurringBuy, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	schedule, err := GetRecurringBuy(userId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule.Apply(params, time.Now())
+	if err = models.SaveRecurringBuy(schedule); err != nil {
+		return nil, fmt.Errorf("failed to update recurring buy: %v", err)
+	}
+	return schedule, nil
+}
+
+// PauseRecurringBuy 暫停排程
+func PauseRecurringBuy(userId int64, id int64) (*models.RecurringBuy, error) {
+	schedule, err := GetRecurringBuy(userId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = schedule.Pause(); err != nil {
+		return nil, err
+	}
+	if err = models.SaveRecurringBuy(schedule); err != nil {
+		return nil, fmt.Errorf("failed to pause recurring buy: %v", err)
+	}
+	return schedule, nil
+}
+
+// ResumeRecurringBuy 恢復排程（暫停期間的排程不補買）
+func ResumeRecurringBuy(userId int64, id int64) (*models.RecurringBuy, error) {
+	schedule, err := GetRecurringBuy(userId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = schedule.Resume(time.Now()); err != nil {
+		return nil, err
+	}
+	if err = models.SaveRecurringBuy(schedule); err != nil {
+		return nil, fmt.Errorf("failed to resume recurring buy: %v", err)
+	}
+	return schedule, nil
+}
+
+// DeleteRecurringBuy 刪除排程與執行記錄（已成交的訂單不受影響）
+func DeleteRecurringBuy(userId int64, id int64) error {
+	if _, err := GetRecurringBuy(userId, id); err != nil {
+		return err
+	}
+	return models.DeleteRecurringBuy(id)
+}
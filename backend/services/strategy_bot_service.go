@@ -0,0 +1,483 @@
+package services
+
+import (
+	"backend/hub"
+	"backend/models"
+	"backend/strategy"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+const (
+	maxRunningStrategyBotsPerUser = 5               // 每位使用者同時執行的策略機器人上限
+	strategyEventQueueSize        = 256             // 每個機器人待處理事件的上限
+	strategyRiskCheckInterval     = 5 * time.Second // 檢查單日虧損的最短間隔
+	defaultStrategyInterval       = "1m"            // 預設的 K 線週期
+)
+
+// StrategyBotService 策略機器人服務：把價格、成交與持倉事件送給各機器人的策略
+// 每個機器人在自己的 goroutine 中依序處理事件，策略不需要處理並行
+type StrategyBotService struct {
+	mu        sync.RWMutex
+	isRunning bool
+	runners   map[int64]*strategyRunner // botId -> runner
+}
+
+var GlobalStrategyBotService *StrategyBotService
+
+func init() {
+	GlobalStrategyBotService = &StrategyBotService{
+		runners: make(map[int64]*strategyRunner),
+	}
+}
+
+// Start 啟動策略機器人服務，恢復執行中的機器人（需在限價單撮合器啟動後呼叫）
+// 策略的內部狀態（例如均線）不會保存，重新啟動後從空白開始累積
+func (s *StrategyBotService) Start() {
+	s.mu.Lock()
+	if s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = true
+	s.mu.Unlock()
+
+	GlobalPriceCache.OnPriceUpdate(s.onPriceUpdate)
+	GlobalLimitOrderMatcher.OnOrderFilled(s.onOrderFilled)
+	hub.GlobalHub.OnUserMessage(s.onUserMessage)
+
+	bots, err := models.GetRunningStrategyBots()
+	if err != nil {
+		log.Printf("Failed to load running strategy bots: %v", err)
+	}
+	resumed := 0
+	for _, bot := range bots {
+		if err = s.launch(bot); err != nil {
+			log.Printf("Failed to resume strategy bot #%d: %v", bot.Id, err)
+			finishBot(bot, models.StrategyBotStatusFailed, "failed to resume: "+err.Error())
+			continue
+		}
+		resumed++
+	}
+
+	log.Printf("Strategy bot service started (%d bots resumed)", resumed)
+}
+
+// Stop 停止策略機器人服務（伺服器關閉），機器人保持 RUNNING 且保留掛單，下次啟動時恢復
+func (s *StrategyBotService) Stop() {
+	s.mu.Lock()
+	if !s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = false
+	runners := make([]*strategyRunner, 0, len(s.runners))
+	for _, r := range s.runners {
+		runners = append(runners, r)
+	}
+	s.runners = make(map[int64]*strategyRunner)
+	s.mu.Unlock()
+
+	for _, r := range runners {
+		r.stop("", "")
+		<-r.done
+	}
+	log.Println("Strategy bot service stopped")
+}
+
+// launch 建立機器人的策略並開始執行
+func (s *StrategyBotService) launch(bot *models.StrategyBot) error {
+	impl, err := strategy.New(bot.Strategy, bot.StrategyParams)
+	if err != nil {
+		return err
+	}
+	interval, err := strategy.ParseInterval(bot.Interval)
+	if err != nil {
+		return err
+	}
+	broker, err := newStrategyBroker(bot)
+	if err != nil {
+		return err
+	}
+
+	r := &strategyRunner{
+		service:  s,
+		bot:      bot,
+		strategy: impl,
+		broker:   broker,
+		guard:    strategy.NewRiskGuard(broker, riskLimits(bot)),
+		candles:  strategy.NewCandleBuilder(interval),
+		tick:     make(chan struct{}, 1),
+		events:   make(chan func(), strategyEventQueueSize),
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	s.runners[bot.Id] = r
+	s.mu.Unlock()
+
+	go r.run()
+	return nil
+}
+
+// remove 移除已停止的機器人
+func (s *StrategyBotService) remove(botId int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.runners, botId)
+}
+
+// runner 查詢執行中的機器人
+func (s *StrategyBotService) runner(botId int64) *strategyRunner {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.runners[botId]
+}
+
+// onPriceUpdate 價格快取的回呼（同步執行，不可阻塞）
+func (s *StrategyBotService) onPriceUpdate(symbol string) {
+	price, ok := GlobalPriceCache.GetPrice(symbol)
+	if !ok {
+		return
+	}
+	now := time.Now()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, r := range s.runners {
+		if r.bot.Symbol == symbol {
+			r.onPrice(price, now)
+		}
+	}
+}
+
+// onOrderFilled 撮合器的成交回呼（同步執行，不可阻塞）
+func (s *StrategyBotService) onOrderFilled(order *models.Order) {
+	if order.StrategyBotId == 0 {
+		return
+	}
+	if r := s.runner(order.StrategyBotId); r != nil {
+		r.post(func() { r.strategy.OnFill(r.guard, order) })
+	}
+}
+
+// positionMessage 持倉推送消息中需要的欄位
+type positionMessage struct {
+	Type models.WSMessageType `json:"type"`
+	Data struct {
+		PositionId int64  `json:"positionId"`
+		Symbol     string `json:"symbol"`
+	} `json:"data"`
+}
+
+// onUserMessage 推送給使用者的消息：持倉開倉、變更、平倉（含強平）時通知該交易對的策略
+func (s *StrategyBotService) onUserMessage(userId int64, message []byte) {
+	var runners []*strategyRunner
+	s.mu.RLock()
+	for _, r := range s.runners {
+		if r.broker.userId == userId {
+			runners = append(runners, r)
+		}
+	}
+	s.mu.RUnlock()
+	if len(runners) == 0 {
+		return
+	}
+
+	var msg positionMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return
+	}
+	switch msg.Type {
+	case models.WSMessageTypeLeveragePositionOpened, models.WSMessageTypeLeveragePositionUpdate, models.WSMessageTypeLeveragePositionClosed:
+	default:
+		return
+	}
+	positionId := msg.Data.PositionId
+	for _, r := range runners {
+		if r.bot.Symbol != msg.Data.Symbol {
+			continue
+		}
+		r := r
+		r.post(func() {
+			position, err := models.GetPositionById(positionId)
+			if err != nil {
+				log.Printf("Strategy bot #%d: failed to load position #%d: %v", r.bot.Id, positionId, err)
+				return
+			}
+			r.strategy.OnPositionUpdate(r.guard, position)
+		})
+	}
+}
+
+// StartStrategyBot 建立並啟動策略機器人
+func (s *StrategyBotService) StartStrategyBot(userId int64, bot *models.StrategyBot) (*models.StrategyBot, error) {
+	// 1. 驗證輸入
+	if _, err := strategy.New(bot.Strategy, bot.StrategyParams); err != nil {
+		return nil, err
+	}
+	if _, _, err := models.ParseSymbol(bot.Symbol); err != nil {
+		return nil, err
+	}
+	if bot.Interval == "" {
+		bot.Interval = defaultStrategyInterval
+	}
+	if _, err := strategy.ParseInterval(bot.Interval); err != nil {
+		return nil, err
+	}
+	if err := riskLimits(bot).Validate(); err != nil {
+		return nil, err
+	}
+
+	// 2. 檢查執行中的數量
+	running, err := models.CountRunningStrategyBotsByUser(userId)
+	if err != nil {
+		return nil, err
+	}
+	if running >= maxRunningStrategyBotsPerUser {
+		return nil, fmt.Errorf("at most %d strategy bots can run at the same time", maxRunningStrategyBotsPerUser)
+	}
+
+	// 3. 建立機器人並開始執行
+	bot.User = &models.User{Id: userId}
+	bot.Status = models.StrategyBotStatusRunning
+	if err = models.CreateStrategyBot(bot); err != nil {
+		return nil, fmt.Errorf("failed to create strategy bot: %v", err)
+	}
+	if err = s.launch(bot); err != nil {
+		finishBot(bot, models.StrategyBotStatusFailed, err.Error())
+		return nil, err
+	}
+
+	log.Printf("Strategy bot #%d started: %s on %s (%s)", bot.Id, bot.Strategy, bot.Symbol, bot.Interval)
+	return bot, nil
+}
+
+// StopStrategyBot 停止使用者的策略機器人並取消掛單（持倉保留，由使用者自行處理）
+func (s *StrategyBotService) StopStrategyBot(userId int64, botId int64) (*models.StrategyBot, error) {
+	bot, err := models.GetStrategyBotById(botId)
+	if err != nil {
+		return nil, err
+	}
+	if bot.User.Id != userId {
+		return nil, errors.New("unauthorized: strategy bot does not belong to user")
+	}
+	if !bot.IsRunning() {
+		return nil, errors.New("strategy bot is not running")
+	}
+
+	r := s.runner(botId)
+	if r == nil {
+		// 機器人未在此伺服器執行（例如恢復失敗），直接結束
+		finishBot(bot, models.StrategyBotStatusStopped, "stopped by user")
+		return bot, nil
+	}
+
+	r.stop(models.StrategyBotStatusStopped, "stopped by user")
+	<-r.done
+	return models.GetStrategyBotById(botId)
+}
+
+// GetStrategyBot 查詢使用者的策略機器人（含掛單與最近處理的價格）
+func (s *StrategyBotService) GetStrategyBot(userId int64, botId int64) (*models.StrategyBot, error) {
+	bot, err := models.GetStrategyBotById(botId)
+	if err != nil {
+		return nil, err
+	}
+	if bot.User.Id != userId {
+		return nil, errors.New("unauthorized: strategy bot does not belong to user")
+	}
+	if bot.Orders, err = models.GetPendingOrdersByStrategyBot(bot.Id); err != nil {
+		return nil, err
+	}
+	if r := s.runner(bot.Id); r != nil {
+		bot.LastPrice, bot.LastEventAt = r.status()
+	}
+	return bot, nil
+}
+
+// riskLimits 機器人的風險限制
+func riskLimits(bot *models.StrategyBot) strategy.RiskLimits {
+	return strategy.RiskLimits{
+		MaxOrderNotional:    bot.MaxOrderNotional,
+		MaxPositionNotional: bot.MaxPositionNotional,
+		MaxLeverage:         bot.MaxLeverage,
+		MaxDailyLoss:        bot.MaxDailyLoss,
+	}
+}
+
+// finishBot 取消機器人的掛單，在同一個交易中寫回停止狀態並寫入更新通知
+func finishBot(bot *models.StrategyBot, status models.StrategyBotStatus, reason string) {
+	orders, err := models.GetPendingOrdersByStrategyBot(bot.Id)
+	if err != nil {
+		log.Printf("Failed to load orders of strategy bot #%d: %v", bot.Id, err)
+	}
+	for _, order := range orders {
+		if err = CancelOrder(bot.User.Id, order.Id); err != nil && err.Error() != "order cannot be canceled" {
+			log.Printf("Failed to cancel order #%d of strategy bot #%d: %v", order.Id, bot.Id, err)
+		}
+	}
+
+	bot.Stop(status, reason, time.Now())
+	err = saveAndNotify(bot.User.Id, func(to orm.TxOrmer) (*models.WSMessage, error) {
+		if err := models.SaveStrategyBot(to, bot); err != nil {
+			return nil, err
+		}
+		return models.NewStrategyBotUpdateMessage(bot), nil
+	})
+	if err != nil {
+		log.Printf("Failed to save strategy bot #%d: %v", bot.Id, err)
+		return
+	}
+	log.Printf("Strategy bot #%d %s (%s)", bot.Id, status, reason)
+}
+
+// strategyRunner 執行中的策略機器人
+type strategyRunner struct {
+	service  *StrategyBotService
+	bot      *models.StrategyBot
+	strategy strategy.Strategy
+	broker   *strategyBroker
+	guard    *strategy.RiskGuard // 傳給策略的 Broker，下單前檢查風險限制
+
+	priceMu     sync.Mutex
+	candles     *strategy.CandleBuilder
+	price       float64
+	lastEventAt *time.Time
+
+	tick          chan struct{} // 有新價格（只保留一個，策略處理較慢時略過中間的價格）
+	events        chan func()   // K 線收盤、成交與持倉事件（依序處理，不略過）
+	stopChan      chan struct{}
+	stopOnce      sync.Once
+	stopStatus    models.StrategyBotStatus
+	stopReason    string
+	done          chan struct{}
+	lastRiskCheck time.Time
+}
+
+// onPrice 收到新價格：更新 K 線，收盤時送出 OnCandle 事件（不可阻塞）
+func (r *strategyRunner) onPrice(price float64, now time.Time) {
+	r.priceMu.Lock()
+	r.price = price
+	closed := r.candles.Update(price, now)
+	r.priceMu.Unlock()
+
+	if closed != nil {
+		candle := *closed
+		r.post(func() { r.strategy.OnCandle(r.guard, candle) })
+	}
+	select {
+	case r.tick <- struct{}{}:
+	default:
+	}
+}
+
+// post 送出事件（不可阻塞，佇列已滿時捨棄）
+func (r *strategyRunner) post(event func()) {
+	select {
+	case r.events <- event:
+	default:
+		log.Printf("Strategy bot #%d: event queue full, event dropped", r.bot.Id)
+	}
+}
+
+// status 策略最近收到的價格與處理事件的時間
+func (r *strategyRunner) status() (float64, *time.Time) {
+	r.priceMu.Lock()
+	defer r.priceMu.Unlock()
+	return r.price, r.lastEventAt
+}
+
+// run 依序處理機器人的事件
+func (r *strategyRunner) run() {
+	defer close(r.done)
+
+	for {
+		select {
+		case <-r.stopChan:
+			r.shutdown()
+			return
+		case <-r.tick:
+			r.priceMu.Lock()
+			price := r.price
+			r.priceMu.Unlock()
+			r.handle(func() { r.strategy.OnTick(r.guard, price) })
+		case event := <-r.events:
+			r.handle(event)
+		}
+	}
+}
+
+// handle 執行一個事件，之後通知策略市價單的成交並檢查單日虧損
+func (r *strategyRunner) handle(event func()) {
+	select {
+	case <-r.stopChan:
+		return
+	default:
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("Strategy bot #%d panicked: %v", r.bot.Id, p)
+			r.halt(models.StrategyBotStatusFailed, fmt.Sprintf("strategy panicked: %v", p))
+		}
+	}()
+
+	event()
+	r.dispatchFills()
+
+	now := time.Now()
+	r.priceMu.Lock()
+	r.lastEventAt = &now
+	r.priceMu.Unlock()
+
+	if now.Sub(r.lastRiskCheck) >= strategyRiskCheckInterval {
+		r.lastRiskCheck = now
+		if r.guard.DailyLossExceeded() {
+			r.halt(models.StrategyBotStatusStopped, "daily loss limit reached")
+		}
+	}
+}
+
+// dispatchFills 通知策略市價單的成交（成交可能產生新的市價單）
+func (r *strategyRunner) dispatchFills() {
+	for {
+		fills := r.broker.takeFills()
+		if len(fills) == 0 {
+			return
+		}
+		for _, order := range fills {
+			r.strategy.OnFill(r.guard, order)
+		}
+	}
+}
+
+// halt 由機器人自己停止（風險限制或策略錯誤），不再接收事件
+func (r *strategyRunner) halt(status models.StrategyBotStatus, reason string) {
+	r.stop(status, reason)
+	r.service.remove(r.bot.Id)
+}
+
+// stop 要求機器人停止，status 為空表示伺服器關閉（保持 RUNNING 並保留掛單）
+func (r *strategyRunner) stop(status models.StrategyBotStatus, reason string) {
+	r.stopOnce.Do(func() {
+		r.stopStatus = status
+		r.stopReason = reason
+		close(r.stopChan)
+	})
+}
+
+// shutdown 結束機器人
+func (r *strategyRunner) shutdown() {
+	r.service.remove(r.bot.Id)
+	if r.stopStatus != "" {
+		finishBot(r.bot, r.stopStatus, r.stopReason)
+	}
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"backend/models"
+	"backend/strategy"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// strategyBroker 策略在實盤的 Broker：以機器人所屬使用者的帳戶下單（與 REST API 相同的流程與驗證）
+// 訂單標記為機器人的子訂單，錢包與持倉則與使用者共用
+type strategyBroker struct {
+	bot    *models.StrategyBot
+	userId int64
+	base   string
+	quote  string
+	fills  []*models.Order // 已成交、尚未通知策略的市價單
+}
+
+var _ strategy.Broker = (*strategyBroker)(nil)
+
+// newStrategyBroker 建立機器人的 Broker
+func newStrategyBroker(bot *models.StrategyBot) (*strategyBroker, error) {
+	base, quote, err := models.ParseSymbol(bot.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	return &strategyBroker{bot: bot, userId: bot.User.Id, base: base, quote: quote}, nil
+}
+
+// parent 策略訂單所屬的機器人
+func (b *strategyBroker) parent() models.OrderParent {
+	return models.OrderParent{StrategyBotId: b.bot.Id}
+}
+
+func (b *strategyBroker) Symbol() string {
+	return b.bot.Symbol
+}
+
+func (b *strategyBroker) Price() float64 {
+	price, _ := GlobalPriceCache.GetPrice(b.bot.Symbol)
+	return price
+}
+
+func (b *strategyBroker) Time() time.Time {
+	return time.Now()
+}
+
+func (b *strategyBroker) Balance(asset string) float64 {
+	wallet, err := models.GetWalletByUserAndSymbol(b.userId, asset)
+	if err != nil {
+		return 0
+	}
+	return wallet.GetAvailableBalance()
+}
+
+func (b *strategyBroker) Position(side models.PositionSide) *models.LeveragePosition {
+	position, err := models.GetOpenPosition(b.userId, b.bot.Symbol, side)
+	if err != nil {
+		log.Printf("Strategy bot #%d: failed to get %s position: %v", b.bot.Id, side, err)
+		return nil
+	}
+	return position
+}
+
+func (b *strategyBroker) OpenOrders() []*models.Order {
+	orders, err := models.GetPendingOrdersByStrategyBot(b.bot.Id)
+	if err != nil {
+		log.Printf("Strategy bot #%d: failed to get open orders: %v", b.bot.Id, err)
+	}
+	return orders
+}
+
+// Equity 總權益 = USDT 餘額 + 現貨市值 + 交易對持倉的保證金與未實現盈虧
+func (b *strategyBroker) Equity() float64 {
+	price := b.Price()
+
+	var equity float64
+	if wallet, err := models.GetWalletByUserAndSymbol(b.userId, b.quote); err == nil {
+		equity += wallet.Balance
+	}
+	if wallet, err := models.GetWalletByUserAndSymbol(b.userId, b.base); err == nil {
+		equity += wallet.Balance * price
+	}
+	for _, side := range []models.PositionSide{models.PositionSideLong, models.PositionSideShort} {
+		if position := b.Position(side); position != nil {
+			equity += position.Margin + position.CalculateUnrealizedPnL(price)
+		}
+	}
+	return equity
+}
+
+func (b *strategyBroker) MarketOrder(side models.OrderSide, quantity float64) (*models.Order, error) {
+	order, err := placeMarketOrder(b.userId, b.parent(), b.bot.Symbol, side, quantity)
+	b.record(err)
+	if err != nil {
+		return nil, err
+	}
+	b.fills = append(b.fills, order)
+	return order, nil
+}
+
+func (b *strategyBroker) LimitOrder(side models.OrderSide, quantity float64, limitPrice float64) (*models.Order, error) {
+	order, err := placeLimitOrder(b.userId, b.parent(), b.bot.Symbol, side, quantity, limitPrice)
+	b.record(err)
+	return order, err
+}
+
+func (b *strategyBroker) OpenPosition(side models.PositionSide, leverage int, quantity float64, reduceOnly bool) error {
+	_, err := openLeveragePositionMarket(b.userId, b.parent(), b.bot.Symbol, side, leverage, quantity, reduceOnly)
+	b.record(err)
+	return err
+}
+
+func (b *strategyBroker) LeverageLimitOrder(side models.PositionSide, leverage int, quantity float64, limitPrice float64, reduceOnly bool) (*models.Order, error) {
+	position, err := openLeveragePositionLimit(b.userId, b.parent(), b.bot.Symbol, side, leverage, quantity, limitPrice, reduceOnly)
+	b.record(err)
+	if err != nil {
+		return nil, err
+	}
+	return position.Order, nil
+}
+
+func (b *strategyBroker) ClosePosition(side models.PositionSide, quantity float64) error {
+	position := b.Position(side)
+	if position == nil {
+		return errors.New("position not found")
+	}
+	_, err := CloseLeveragePosition(b.userId, position.Id, quantity)
+	b.record(err)
+	return err
+}
+
+// CancelOrder 取消策略的掛單（不可取消使用者或其他機器人的訂單）
+func (b *strategyBroker) CancelOrder(orderId int64) error {
+	order, err := models.GetOrderById(orderId)
+	if err != nil || order.StrategyBotId != b.bot.Id {
+		return errors.New("order not found")
+	}
+	return CancelOrder(b.userId, orderId)
+}
+
+// record 記錄策略送出的訂單
+func (b *strategyBroker) record(err error) {
+	b.bot.RecordOrder(err, time.Now())
+	if err != nil {
+		log.Printf("Strategy bot #%d: order failed: %v", b.bot.Id, err)
+	}
+	if saveErr := models.SaveStrategyBot(orm.NewOrm(), b.bot); saveErr != nil {
+		log.Printf("Failed to save strategy bot #%d: %v", b.bot.Id, saveErr)
+	}
+}
+
+// takeFills 取出已成交、尚未通知策略的市價單
+func (b *strategyBroker) takeFills() []*models.Order {
+	fills := b.fills
+	b.fills = nil
+	return fills
+}
@@ -14,7 +14,51 @@ import (
 // symbol: 交易對（如 BTCUSDT）
 // side: BUY 或 SELL
 // quantity: 交易數量（對於 BUY 是指花費的 USDT 金額，對於 SELL 是指賣出的幣數量）
-func PlaceMarketOrder(userId int64, symbol string, side models.OrderSide, quantity float64) (*models.Order, error) {
+// clientOrderId: 使用者自訂的訂單 ID（可為空）
+// idempotencyKeys: 下單請求登記的冪等 Key（可為空），與訂單在同一交易中記錄訂單 ID
+func PlaceMarketOrder(userId int64, symbol string, side models.OrderSide, quantity float64, clientOrderId string, idempotencyKeys []string) (*models.Order, error) {
+	return placeMarketOrder(userId, models.OrderParent{ClientOrderId: clientOrderId, IdempotencyKeys: idempotencyKeys}, symbol, side, quantity)
+}
+
+// placeMarketOrder 執行市價單交易，parent 不為空時為母單或機器人的子訂單
+func placeMarketOrder(userId int64, parent models.OrderParent, symbol string, side models.OrderSide, quantity float64) (*models.Order, error) {
+	return executeMarketOrder(userId, parent, symbol, side, quantity, false)
+}
+
+// placeNotifiedMarketOrder 執行市價單交易，並在成交的同一交易中寫入 ORDER_EXECUTED 通知（用於排程等非使用者直接下的訂單）
+func placeNotifiedMarketOrder(userId int64, parent models.OrderParent, symbol string, side models.OrderSide, quantity float64) (*models.Order, error) {
+	return executeMarketOrder(userId, parent, symbol, side, quantity, true)
+}
+
+// createOrder 在獨立交易中寫入訂單與其建立事件（以及冪等 Key 的訂單 ID），避免只寫入其中一部分
+func createOrder(create func(to orm.TxOrmer) (*models.Order, error)) (*models.Order, error) {
+	o := orm.NewOrm()
+	to, err := o.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	shouldRollback := true
+	defer func() {
+		if shouldRollback {
+			to.Rollback()
+		}
+	}()
+
+	order, err := create(to)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = to.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	shouldRollback = false
+	return order, nil
+}
+
+// executeMarketOrder 執行市價單交易，notify 時在同一交易中寫入成交通知
+func executeMarketOrder(userId int64, parent models.OrderParent, symbol string, side models.OrderSide, quantity float64, notify bool) (*models.Order, error) {
 	// 1. 驗證輸入
 	if quantity <= 0 {
 		return nil, errors.New("quantity must be positive")
@@ -31,10 +75,12 @@ func PlaceMarketOrder(userId int64, symbol string, side models.OrderSide, quanti
 		return nil, fmt.Errorf("failed to get market price: %v", err)
 	}
 
-	// 3. 建立訂單
-	order, err := models.CreateOrder(userId, symbol, models.OrderTypeMarket, side, quantity, nil)
+	// 3. 建立訂單（獨立交易，成交交易回滾後仍保留訂單以記錄失敗原因）
+	order, err := createOrder(func(to orm.TxOrmer) (*models.Order, error) {
+		return models.CreateChildOrder(to, userId, parent, symbol, models.OrderTypeMarket, side, quantity, nil)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create order: %v", err)
+		return nil, fmt.Errorf("failed to create order: %w", err)
 	}
 
 	// 4. 開始資料庫交易（確保原子性）
@@ -79,6 +125,20 @@ func PlaceMarketOrder(userId int64, symbol string, side models.OrderSide, quanti
 		return nil, fmt.Errorf("failed to update order: %v", err)
 	}
 
+	// 寫入訂單成交的 webhook 事件（與成交在同一個交易中）
+	if err = models.EnqueueOrderFilledWebhook(to, userId, order.Id); err != nil {
+		return nil, fmt.Errorf("failed to enqueue webhook event: %v", err)
+	}
+
+	if notify {
+		order.Status = models.OrderStatusCompleted
+		order.Price = price
+		order.TotalAmount = totalAmount
+		if err = enqueueNotification(to, userId, models.NewOrderExecutedMessage(order)); err != nil {
+			return nil, err
+		}
+	}
+
 	// 7. 提交交易
 	err = to.Commit()
 	if err != nil {
@@ -87,6 +147,10 @@ func PlaceMarketOrder(userId int64, symbol string, side models.OrderSide, quanti
 
 	// 標記交易成功，不需要回滾
 	shouldRollback = false
+	trackBalance(userId)
+	if notify {
+		GlobalNotificationDispatcher.Notify()
+	}
 
 	// 8. 重新讀取訂單以返回最新狀態
 	order, _ = models.GetOrderById(order.Id)
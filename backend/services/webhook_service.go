@@ -0,0 +1,308 @@
+package services
+
+import (
+	"backend/models"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	webhookDispatchInterval = 1 * time.Second // 檢查新事件與到期投遞的間隔
+	webhookBatchSize        = 100             // 每次處理的事件與投遞數量上限
+)
+
+// WebhookDispatcher webhook 投遞服務：將 outbox 中的事件展開為各端點的投遞，並以簽章後的 POST 送出
+// 失敗的投遞依指數退避重試，記錄保存在資料庫中，重啟後繼續
+type WebhookDispatcher struct {
+	mu        sync.Mutex
+	isRunning bool
+	stopChan  chan struct{}
+	client    *http.Client
+}
+
+var GlobalWebhookDispatcher *WebhookDispatcher
+
+func init() {
+	GlobalWebhookDispatcher = NewWebhookDispatcher()
+}
+
+// NewWebhookDispatcher 建立 webhook 投遞服務
+func NewWebhookDispatcher() *WebhookDispatcher {
+	return &WebhookDispatcher{
+		stopChan: make(chan struct{}),
+		client:   newWebhookClient(),
+	}
+}
+
+// newWebhookClient 建立投遞用的 HTTP client，連線前檢查實際連線的位址（包括重新導向與 DNS 重新解析的結果），
+// 拒絕連到內部位址；不使用環境變數設定的代理，避免代理替端點連線內部網路
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !models.IsPublicWebhookIP(ip) {
+				return fmt.Errorf("webhook address %s is not allowed", host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
+
+// Start 啟動 webhook 投遞服務
+func (d *WebhookDispatcher) Start() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.isRunning {
+		return
+	}
+
+	d.isRunning = true
+	log.Println("Webhook dispatcher started")
+	go d.run()
+}
+
+// Stop 停止 webhook 投遞服務
+func (d *WebhookDispatcher) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.isRunning {
+		return
+	}
+
+	d.isRunning = false
+	close(d.stopChan)
+	log.Println("Webhook dispatcher stopped")
+}
+
+// run 定期展開新事件並送出到期的投遞
+func (d *WebhookDispatcher) run() {
+	ticker := time.NewTicker(webhookDispatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.stopChan:
+			return
+		case <-ticker.C:
+			d.dispatchEvents()
+			d.deliverDue()
+		}
+	}
+}
+
+// dispatchEvents 為尚未處理的事件建立訂閱端點的投遞
+func (d *WebhookDispatcher) dispatchEvents() {
+	events, err := models.GetUndispatchedWebhookEvents(webhookBatchSize)
+	if err != nil {
+		log.Printf("Failed to get webhook events: %v", err)
+		return
+	}
+
+	for _, event := range events {
+		endpoints, err := models.GetWebhookEndpointsByUser(event.User.Id)
+		if err != nil {
+			log.Printf("Failed to get webhook endpoints of user %d: %v", event.User.Id, err)
+			continue
+		}
+
+		var subscribed []*models.WebhookEndpoint
+		for _, endpoint := range endpoints {
+			if endpoint.Active && endpoint.Subscribes(event.Type) {
+				subscribed = append(subscribed, endpoint)
+			}
+		}
+
+		if _, err := models.DispatchWebhookEvent(event, subscribed, time.Now()); err != nil {
+			log.Printf("Failed to dispatch webhook event #%d: %v", event.Id, err)
+		}
+	}
+}
+
+// deliverDue 送出到期的投遞並記錄結果
+func (d *WebhookDispatcher) deliverDue() {
+	deliveries, err := models.GetDueWebhookDeliveries(time.Now(), webhookBatchSize)
+	if err != nil {
+		log.Printf("Failed to get webhook deliveries: %v", err)
+		return
+	}
+
+	for _, delivery := range deliveries {
+		select {
+		case <-d.stopChan:
+			return
+		default:
+		}
+
+		if !delivery.Endpoint.Active {
+			delivery.Fail("endpoint disabled")
+		} else {
+			responseStatus, err := d.post(delivery)
+			delivery.RecordAttempt(responseStatus, err, time.Now())
+			if delivery.Status == models.WebhookDeliveryStatusFailed {
+				log.Printf("Webhook delivery #%d failed after %d attempts: %v", delivery.Id, delivery.Attempts, err)
+			}
+		}
+
+		if err := models.SaveWebhookDelivery(delivery); err != nil {
+			log.Printf("Failed to save webhook delivery #%d: %v", delivery.Id, err)
+		}
+	}
+}
+
+// post 送出一次投遞，HTTP 2xx 視為成功
+func (d *WebhookDispatcher) post(delivery *models.WebhookDelivery) (int, error) {
+	body := delivery.Event.WebhookBody()
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequest(http.MethodPost, delivery.Endpoint.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", strconv.FormatInt(delivery.Id, 10))
+	req.Header.Set("X-Webhook-Event", string(delivery.Event.Type))
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", "sha256="+models.SignWebhookPayload(delivery.Endpoint.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// CreateWebhookEndpoint 註冊 webhook 端點並產生簽章金鑰
+func CreateWebhookEndpoint(userId int64, params models.WebhookEndpointParams) (*models.WebhookEndpoint, error) {
+	// 1. 驗證參數
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	// 2. 檢查端點數量
+	count, err := models.CountWebhookEndpointsByUser(userId)
+	if err != nil {
+		return nil, err
+	}
+	if count >= models.MaxWebhookEndpointsPerUser {
+		return nil, fmt.Errorf("at most %d webhook endpoints are allowed", models.MaxWebhookEndpointsPerUser)
+	}
+
+	// 3. 產生簽章金鑰並寫入
+	secret, err := models.NewWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	endpoint := &models.WebhookEndpoint{
+		User:   &models.User{Id: userId},
+		Secret: secret,
+	}
+	endpoint.Apply(params)
+	if err := models.CreateWebhookEndpoint(endpoint); err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+// UpdateWebhookEndpoint 修改端點的網址、訂閱事件與啟用狀態（簽章金鑰不變）
+func UpdateWebhookEndpoint(userId int64, id int64, params models.WebhookEndpointParams) (*models.WebhookEndpoint, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	endpoint, err := GetWebhookEndpoint(userId, id)
+	if err != nil {
+		return nil, err
+	}
+	endpoint.Apply(params)
+	if err := models.SaveWebhookEndpoint(endpoint); err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+// DeleteWebhookEndpoint 刪除端點與其投遞記錄
+func DeleteWebhookEndpoint(userId int64, id int64) error {
+	if _, err := GetWebhookEndpoint(userId, id); err != nil {
+		return err
+	}
+	return models.DeleteWebhookEndpoint(id)
+}
+
+// GetWebhookEndpoint 查詢使用者的端點
+func GetWebhookEndpoint(userId int64, id int64) (*models.WebhookEndpoint, error) {
+	endpoint, err := models.GetWebhookEndpointById(id)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.User.Id != userId {
+		return nil, errors.New("unauthorized: webhook endpoint does not belong to user")
+	}
+	return endpoint, nil
+}
+
+// GetWebhookDeliveries 查詢端點的投遞記錄
+func GetWebhookDeliveries(userId int64, endpointId int64, status models.WebhookDeliveryStatus, limit int, offset int) ([]*models.WebhookDelivery, error) {
+	if _, err := GetWebhookEndpoint(userId, endpointId); err != nil {
+		return nil, err
+	}
+	return models.GetWebhookDeliveries(endpointId, status, limit, offset)
+}
+
+// RedeliverWebhook 手動重送投遞：以同一個事件建立新的投遞，由投遞服務立即送出
+func RedeliverWebhook(userId int64, endpointId int64, deliveryId int64) (*models.WebhookDelivery, error) {
+	// 1. 檢查端點
+	endpoint, err := GetWebhookEndpoint(userId, endpointId)
+	if err != nil {
+		return nil, err
+	}
+	if !endpoint.Active {
+		return nil, errors.New("webhook endpoint is disabled")
+	}
+
+	// 2. 檢查投遞屬於該端點
+	delivery, err := models.GetWebhookDeliveryById(deliveryId)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.Endpoint.Id != endpoint.Id {
+		return nil, errors.New("webhook delivery not found")
+	}
+
+	// 3. 建立新的投遞
+	redelivery, err := models.RedeliverWebhook(delivery, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	redelivery.Event.Data = delivery.Event.Data
+	return redelivery, nil
+}
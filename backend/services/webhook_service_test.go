@@ -0,0 +1,30 @@
+package services
+
+import (
+	"backend/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestWebhookClientRejectsInternalAddress 測試投遞時拒絕連到內部位址（註冊後 DNS 改為指向內部位址時）
+func TestWebhookClientRejectsInternalAddress(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	d := NewWebhookDispatcher()
+	delivery := &models.WebhookDelivery{
+		Id:       1,
+		Endpoint: &models.WebhookEndpoint{Url: server.URL, Secret: "whsec_test"},
+		Event:    &models.WebhookEvent{Id: 1, Type: models.WebhookEventOrderFilled},
+	}
+	if _, err := d.post(delivery); err == nil {
+		t.Error("expected delivery to a loopback address to fail")
+	}
+	if called {
+		t.Error("expected the loopback server not to be called")
+	}
+}
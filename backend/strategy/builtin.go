@@ -0,0 +1,125 @@
+package strategy
+
+import (
+	"backend/models"
+	"errors"
+	"math"
+)
+
+// buyAndHold 第一根 K 線收盤時以市價買入，之後持有到結束（比較基準）
+// 參數：fraction 使用的 USDT 比例（預設 1）
+type buyAndHold struct {
+	Base
+	fraction float64
+	bought   bool
+}
+
+func newBuyAndHold(params map[string]float64) (Strategy, error) {
+	fraction := Param(params, "fraction", 1)
+	if err := validateFraction(fraction); err != nil {
+		return nil, err
+	}
+	return &buyAndHold{fraction: fraction}, nil
+}
+
+// OnCandle 第一根 K 線收盤時買入
+func (s *buyAndHold) OnCandle(broker Broker, candle Candle) {
+	if s.bought {
+		return
+	}
+	s.bought = true
+	broker.MarketOrder(models.OrderSideBuy, broker.Balance("USDT")*s.fraction)
+}
+
+// smaCross 均線交叉：短均線向上穿越長均線時做多，向下穿越時出場（槓桿模式時反手做空）
+// 參數：fast 短均線週期（預設 10）、slow 長均線週期（預設 30）、
+// leverage 槓桿倍數（預設 0 表示現貨買賣，1 ~ 10 時以槓桿倉位多空雙向交易）、fraction 每次使用的 USDT 比例（預設 0.95）
+type smaCross struct {
+	Base
+	fast     int
+	slow     int
+	leverage int
+	fraction float64
+	closes   []float64
+	previous int // 上一根 K 線的均線狀態：1 短均線在上，-1 短均線在下，0 尚未計算
+}
+
+func newSMACross(params map[string]float64) (Strategy, error) {
+	fast := Param(params, "fast", 10)
+	slow := Param(params, "slow", 30)
+	leverage := Param(params, "leverage", 0)
+	fraction := Param(params, "fraction", 0.95)
+
+	if fast < 1 || slow <= fast || fast != math.Trunc(fast) || slow != math.Trunc(slow) {
+		return nil, errors.New("fast and slow must be whole numbers with 1 <= fast < slow")
+	}
+	if leverage < 0 || leverage > 10 || leverage != math.Trunc(leverage) {
+		return nil, errors.New("leverage must be a whole number between 0 and 10")
+	}
+	if err := validateFraction(fraction); err != nil {
+		return nil, err
+	}
+	return &smaCross{fast: int(fast), slow: int(slow), leverage: int(leverage), fraction: fraction}, nil
+}
+
+// OnCandle 收盤時計算均線，交叉時調整部位
+func (s *smaCross) OnCandle(broker Broker, candle Candle) {
+	s.closes = append(s.closes, candle.Close)
+	if len(s.closes) > s.slow {
+		s.closes = s.closes[1:]
+	}
+	if len(s.closes) < s.slow {
+		return
+	}
+
+	state := -1
+	if average(s.closes[len(s.closes)-s.fast:]) > average(s.closes) {
+		state = 1
+	}
+	crossed := s.previous != 0 && state != s.previous
+	s.previous = state
+	if !crossed {
+		return
+	}
+
+	if s.leverage == 0 {
+		s.tradeSpot(broker, state)
+	} else {
+		s.tradeLeverage(broker, state)
+	}
+}
+
+// tradeSpot 現貨：黃金交叉買入，死亡交叉賣出全部持幣
+func (s *smaCross) tradeSpot(broker Broker, state int) {
+	base, _, _ := models.ParseSymbol(broker.Symbol())
+	if state > 0 {
+		broker.MarketOrder(models.OrderSideBuy, broker.Balance("USDT")*s.fraction)
+	} else if holding := broker.Balance(base); holding > 0 {
+		broker.MarketOrder(models.OrderSideSell, holding)
+	}
+}
+
+// tradeLeverage 槓桿：先平掉反方向持倉，再依交叉方向開倉
+func (s *smaCross) tradeLeverage(broker Broker, state int) {
+	side := models.PositionSideLong
+	if state < 0 {
+		side = models.PositionSideShort
+	}
+	if broker.Position(side.Opposite()) != nil {
+		broker.ClosePosition(side.Opposite(), 0)
+	}
+
+	quantity := broker.Balance("USDT") * s.fraction * float64(s.leverage) / broker.Price()
+	if quantity > 0 {
+		broker.OpenPosition(side, s.leverage, quantity, false)
+	}
+}
+
+// average 平均值
+func average(values []float64) float64 {
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
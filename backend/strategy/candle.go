@@ -0,0 +1,79 @@
+package strategy
+
+import (
+	"fmt"
+	"time"
+)
+
+// Candle 一根 K 線
+type Candle struct {
+	OpenTime time.Time `json:"openTime"`
+	Open     float64   `json:"open"`
+	High     float64   `json:"high"`
+	Low      float64   `json:"low"`
+	Close    float64   `json:"close"`
+	Volume   float64   `json:"volume"`
+}
+
+// intervals 支援的 K 線週期（與 Binance 相同）
+var intervals = map[string]time.Duration{
+	"1m":  time.Minute,
+	"3m":  3 * time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"2h":  2 * time.Hour,
+	"4h":  4 * time.Hour,
+	"6h":  6 * time.Hour,
+	"8h":  8 * time.Hour,
+	"12h": 12 * time.Hour,
+	"1d":  24 * time.Hour,
+}
+
+// ParseInterval 解析 K 線週期
+func ParseInterval(interval string) (time.Duration, error) {
+	duration, ok := intervals[interval]
+	if !ok {
+		return 0, fmt.Errorf("unsupported interval: %s", interval)
+	}
+	return duration, nil
+}
+
+// CandleBuilder 以即時價格組成 K 線（實盤使用，價格來源沒有成交量）
+type CandleBuilder struct {
+	interval time.Duration
+	current  *Candle
+}
+
+// NewCandleBuilder 建立指定週期的 K 線產生器
+func NewCandleBuilder(interval time.Duration) *CandleBuilder {
+	return &CandleBuilder{interval: interval}
+}
+
+// Update 加入一筆價格，進入下一個週期時返回剛收盤的 K 線（沒有價格的週期不產生 K 線）
+func (b *CandleBuilder) Update(price float64, now time.Time) *Candle {
+	openTime := now.UTC().Truncate(b.interval)
+
+	var closed *Candle
+	if b.current != nil && !b.current.OpenTime.Equal(openTime) {
+		if openTime.Before(b.current.OpenTime) {
+			return nil
+		}
+		closed = b.current
+		b.current = nil
+	}
+
+	if b.current == nil {
+		b.current = &Candle{OpenTime: openTime, Open: price, High: price, Low: price, Close: price}
+		return closed
+	}
+	if price > b.current.High {
+		b.current.High = price
+	}
+	if price < b.current.Low {
+		b.current.Low = price
+	}
+	b.current.Close = price
+	return closed
+}
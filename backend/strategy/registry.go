@@ -0,0 +1,73 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Factory 依參數建立策略，參數不正確時返回錯誤
+type Factory func(params map[string]float64) (Strategy, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+func init() {
+	Register("buy_and_hold", newBuyAndHold)
+	Register("sma_cross", newSMACross)
+}
+
+// Register 註冊策略（通常在套件的 init 中呼叫），名稱重複時 panic
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[name]; exists {
+		panic("strategy: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// New 依名稱與參數建立策略
+func New(name string, params map[string]float64) (Strategy, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy: %s", name)
+	}
+	return factory(params)
+}
+
+// Names 所有已註冊策略的名稱
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Param 讀取策略參數，未設定時使用預設值
+func Param(params map[string]float64, name string, defaultValue float64) float64 {
+	if value, ok := params[name]; ok {
+		return value
+	}
+	return defaultValue
+}
+
+// validateFraction 每次下單使用的資金比例需在 (0, 1] 之間
+func validateFraction(fraction float64) error {
+	if fraction <= 0 || fraction > 1 {
+		return errors.New("fraction must be greater than 0 and at most 1")
+	}
+	return nil
+}
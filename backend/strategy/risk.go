@@ -0,0 +1,133 @@
+package strategy
+
+import (
+	"backend/models"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RiskLimits 策略的風險限制，0 表示不限制
+type RiskLimits struct {
+	MaxOrderNotional    float64 `json:"maxOrderNotional,omitempty"`    // 單筆訂單的最大名目價值（USDT）
+	MaxPositionNotional float64 `json:"maxPositionNotional,omitempty"` // 交易對的最大曝險：現貨持幣市值 + 槓桿持倉名目價值（USDT）
+	MaxLeverage         int     `json:"maxLeverage,omitempty"`         // 最大槓桿倍數
+	MaxDailyLoss        float64 `json:"maxDailyLoss,omitempty"`        // 單日（UTC）權益的最大虧損（USDT），達到後不再增加曝險
+}
+
+// Validate 驗證風險限制
+func (l RiskLimits) Validate() error {
+	if l.MaxOrderNotional < 0 || l.MaxPositionNotional < 0 || l.MaxDailyLoss < 0 {
+		return errors.New("risk limits must not be negative")
+	}
+	if l.MaxLeverage < 0 || l.MaxLeverage > 100 {
+		return errors.New("max leverage must be between 0 and 100")
+	}
+	return nil
+}
+
+// RiskGuard 在下單前檢查風險限制的 Broker
+// 賣出、只減倉與平倉只檢查單筆訂單上限；買入與開倉另外檢查槓桿、曝險與單日虧損
+type RiskGuard struct {
+	Broker
+	limits         RiskLimits
+	day            time.Time
+	dayStartEquity float64
+}
+
+// NewRiskGuard 以風險限制包裝 Broker
+func NewRiskGuard(broker Broker, limits RiskLimits) *RiskGuard {
+	return &RiskGuard{Broker: broker, limits: limits}
+}
+
+// Limits 風險限制
+func (g *RiskGuard) Limits() RiskLimits {
+	return g.limits
+}
+
+// DailyLossExceeded 今日（UTC）的權益虧損是否已達上限，換日時以當下的權益作為新的起點
+func (g *RiskGuard) DailyLossExceeded() bool {
+	if g.limits.MaxDailyLoss == 0 {
+		return false
+	}
+
+	equity := g.Equity()
+	day := g.Time().UTC().Truncate(24 * time.Hour)
+	if !day.Equal(g.day) {
+		g.day = day
+		g.dayStartEquity = equity
+	}
+	return g.dayStartEquity-equity >= g.limits.MaxDailyLoss
+}
+
+// MarketOrder 現貨市價單（買入的 quantity 即為 USDT 名目價值）
+func (g *RiskGuard) MarketOrder(side models.OrderSide, quantity float64) (*models.Order, error) {
+	notional := quantity
+	if side == models.OrderSideSell {
+		notional = quantity * g.Price()
+	}
+	if err := g.check(notional, 1, side == models.OrderSideBuy); err != nil {
+		return nil, err
+	}
+	return g.Broker.MarketOrder(side, quantity)
+}
+
+// LimitOrder 現貨限價單
+func (g *RiskGuard) LimitOrder(side models.OrderSide, quantity float64, limitPrice float64) (*models.Order, error) {
+	if err := g.check(quantity*limitPrice, 1, side == models.OrderSideBuy); err != nil {
+		return nil, err
+	}
+	return g.Broker.LimitOrder(side, quantity, limitPrice)
+}
+
+// OpenPosition 槓桿市價單
+func (g *RiskGuard) OpenPosition(side models.PositionSide, leverage int, quantity float64, reduceOnly bool) error {
+	if err := g.check(quantity*g.Price(), leverage, !reduceOnly); err != nil {
+		return err
+	}
+	return g.Broker.OpenPosition(side, leverage, quantity, reduceOnly)
+}
+
+// LeverageLimitOrder 槓桿限價單
+func (g *RiskGuard) LeverageLimitOrder(side models.PositionSide, leverage int, quantity float64, limitPrice float64, reduceOnly bool) (*models.Order, error) {
+	if err := g.check(quantity*limitPrice, leverage, !reduceOnly); err != nil {
+		return nil, err
+	}
+	return g.Broker.LeverageLimitOrder(side, leverage, quantity, limitPrice, reduceOnly)
+}
+
+// check 檢查訂單是否超過風險限制，increasing 為訂單是否可能增加曝險
+func (g *RiskGuard) check(notional float64, leverage int, increasing bool) error {
+	if g.limits.MaxOrderNotional > 0 && notional > g.limits.MaxOrderNotional {
+		return fmt.Errorf("risk limit: order notional %.2f exceeds %.2f", notional, g.limits.MaxOrderNotional)
+	}
+	if !increasing {
+		return nil
+	}
+
+	if g.limits.MaxLeverage > 0 && leverage > g.limits.MaxLeverage {
+		return fmt.Errorf("risk limit: leverage %dx exceeds %dx", leverage, g.limits.MaxLeverage)
+	}
+	if g.DailyLossExceeded() {
+		return errors.New("risk limit: daily loss limit reached")
+	}
+	if g.limits.MaxPositionNotional > 0 {
+		if exposure := g.exposure() + notional; exposure > g.limits.MaxPositionNotional {
+			return fmt.Errorf("risk limit: position notional %.2f exceeds %.2f", exposure, g.limits.MaxPositionNotional)
+		}
+	}
+	return nil
+}
+
+// exposure 交易對目前的曝險：現貨持幣市值 + 多空持倉的名目價值
+func (g *RiskGuard) exposure() float64 {
+	price := g.Price()
+	base, _, _ := models.ParseSymbol(g.Symbol())
+	exposure := g.Balance(base) * price
+	for _, side := range []models.PositionSide{models.PositionSideLong, models.PositionSideShort} {
+		if position := g.Position(side); position != nil {
+			exposure += position.Quantity * price
+		}
+	}
+	return exposure
+}
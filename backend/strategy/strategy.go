@@ -0,0 +1,56 @@
+// Package strategy 交易策略的外掛介面
+//
+// 策略只透過 Broker 查詢帳戶與下單，因此同一個策略可以不經修改地在實盤（services.StrategyBotService，
+// 以使用者的帳戶下單）與回測（backtest.Run，以隔離的模擬帳戶下單）中執行。
+// 自訂策略以 Register 註冊後即可用名稱啟動或回測。
+package strategy
+
+import (
+	"backend/models"
+	"time"
+)
+
+// Broker 策略查詢帳戶與下單的介面，實盤為使用者的帳戶，回測為模擬帳戶
+// 下單方法的參數與限制和 REST API 相同
+type Broker interface {
+	Symbol() string                                             // 策略交易的交易對
+	Price() float64                                             // 目前價格
+	Time() time.Time                                            // 目前時間（回測為模擬時間）
+	Balance(asset string) float64                               // 錢包的可用餘額
+	Position(side models.PositionSide) *models.LeveragePosition // 交易對的持倉（沒有時為 nil）
+	OpenOrders() []*models.Order                                // 策略掛單中的訂單
+	Equity() float64                                            // 總權益：USDT + 現貨市值 + 交易對持倉的保證金與未實現盈虧
+
+	// MarketOrder 現貨市價單：買入時 quantity 為花費的 USDT，賣出時為 base 幣數量
+	MarketOrder(side models.OrderSide, quantity float64) (*models.Order, error)
+	// LimitOrder 現貨限價單：quantity 為 base 幣數量
+	LimitOrder(side models.OrderSide, quantity float64, limitPrice float64) (*models.Order, error)
+	// OpenPosition 槓桿市價單：依持倉模式開倉、加倉、減倉或反手（成交反映為 OnPositionUpdate）
+	OpenPosition(side models.PositionSide, leverage int, quantity float64, reduceOnly bool) error
+	// LeverageLimitOrder 槓桿限價單：掛單時凍結保證金
+	LeverageLimitOrder(side models.PositionSide, leverage int, quantity float64, limitPrice float64, reduceOnly bool) (*models.Order, error)
+	// ClosePosition 平倉：quantity 為 0 時全部平倉
+	ClosePosition(side models.PositionSide, quantity float64) error
+	// CancelOrder 取消策略的掛單
+	CancelOrder(orderId int64) error
+}
+
+// Strategy 交易策略，回呼依事件發生順序逐一呼叫，同一個策略不會被並行呼叫
+type Strategy interface {
+	// OnTick 價格更新
+	OnTick(broker Broker, price float64)
+	// OnCandle K 線收盤
+	OnCandle(broker Broker, candle Candle)
+	// OnFill 策略的訂單成交（現貨市價單、限價單與槓桿限價單）
+	OnFill(broker Broker, order *models.Order)
+	// OnPositionUpdate 交易對的持倉開倉、變更、平倉或爆倉
+	OnPositionUpdate(broker Broker, position *models.LeveragePosition)
+}
+
+// Base 不處理任何事件，策略嵌入後只需實作需要的回呼
+type Base struct{}
+
+func (Base) OnTick(broker Broker, price float64)                               {}
+func (Base) OnCandle(broker Broker, candle Candle)                             {}
+func (Base) OnFill(broker Broker, order *models.Order)                         {}
+func (Base) OnPositionUpdate(broker Broker, position *models.LeveragePosition) {}
@@ -0,0 +1,166 @@
+package strategy
+
+import (
+	"backend/models"
+	"testing"
+	"time"
+)
+
+// fakeBroker 固定價格與持倉的 Broker，記錄送出的訂單
+type fakeBroker struct {
+	now      time.Time
+	price    float64
+	equity   float64
+	balances map[string]float64
+	long     *models.LeveragePosition
+	orders   int
+}
+
+func (b *fakeBroker) Symbol() string                  { return "BTCUSDT" }
+func (b *fakeBroker) Price() float64                  { return b.price }
+func (b *fakeBroker) Time() time.Time                 { return b.now }
+func (b *fakeBroker) Balance(asset string) float64    { return b.balances[asset] }
+func (b *fakeBroker) OpenOrders() []*models.Order     { return nil }
+func (b *fakeBroker) Equity() float64                 { return b.equity }
+func (b *fakeBroker) CancelOrder(orderId int64) error { return nil }
+
+func (b *fakeBroker) Position(side models.PositionSide) *models.LeveragePosition {
+	if side == models.PositionSideLong {
+		return b.long
+	}
+	return nil
+}
+
+func (b *fakeBroker) MarketOrder(side models.OrderSide, quantity float64) (*models.Order, error) {
+	b.orders++
+	return &models.Order{}, nil
+}
+
+func (b *fakeBroker) LimitOrder(side models.OrderSide, quantity float64, limitPrice float64) (*models.Order, error) {
+	b.orders++
+	return &models.Order{}, nil
+}
+
+func (b *fakeBroker) OpenPosition(side models.PositionSide, leverage int, quantity float64, reduceOnly bool) error {
+	b.orders++
+	return nil
+}
+
+func (b *fakeBroker) LeverageLimitOrder(side models.PositionSide, leverage int, quantity float64, limitPrice float64, reduceOnly bool) (*models.Order, error) {
+	b.orders++
+	return &models.Order{}, nil
+}
+
+func (b *fakeBroker) ClosePosition(side models.PositionSide, quantity float64) error {
+	b.orders++
+	return nil
+}
+
+func TestRiskGuard(t *testing.T) {
+	broker := &fakeBroker{
+		now:      time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		price:    100,
+		equity:   10000,
+		balances: map[string]float64{"USDT": 5000, "BTC": 10},
+		long:     &models.LeveragePosition{Side: models.PositionSideLong, Quantity: 20},
+	}
+	guard := NewRiskGuard(broker, RiskLimits{MaxOrderNotional: 2000, MaxPositionNotional: 4000, MaxLeverage: 5})
+
+	// 曝險：現貨 10 × 100 + 多單 20 × 100 = 3000
+	tests := []struct {
+		name    string
+		place   func() error
+		wantErr bool
+	}{
+		{"buy within limits", func() error { _, err := guard.MarketOrder(models.OrderSideBuy, 1000); return err }, false},
+		{"buy over position notional", func() error { _, err := guard.MarketOrder(models.OrderSideBuy, 1500); return err }, true},
+		{"order over order notional", func() error { _, err := guard.LimitOrder(models.OrderSideSell, 25, 100); return err }, true},
+		{"sell does not add exposure", func() error { _, err := guard.LimitOrder(models.OrderSideSell, 15, 100); return err }, false},
+		{"leverage over limit", func() error { return guard.OpenPosition(models.PositionSideLong, 10, 1, false) }, true},
+		{"reduce-only ignores exposure", func() error { return guard.OpenPosition(models.PositionSideShort, 10, 15, true) }, false},
+		{"leverage limit within limits", func() error {
+			_, err := guard.LeverageLimitOrder(models.PositionSideShort, 5, 5, 100, false)
+			return err
+		}, false},
+	}
+
+	for _, tt := range tests {
+		if err := tt.place(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+	if broker.orders != 4 {
+		t.Errorf("broker received %d orders, want 4", broker.orders)
+	}
+}
+
+func TestRiskGuardDailyLoss(t *testing.T) {
+	broker := &fakeBroker{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), price: 100, equity: 10000}
+	guard := NewRiskGuard(broker, RiskLimits{MaxDailyLoss: 500})
+
+	if guard.DailyLossExceeded() {
+		t.Fatal("no loss yet")
+	}
+
+	broker.now = broker.now.Add(6 * time.Hour)
+	broker.equity = 9500
+	if !guard.DailyLossExceeded() {
+		t.Error("loss of 500 should reach the limit")
+	}
+	if _, err := guard.MarketOrder(models.OrderSideBuy, 100); err == nil {
+		t.Error("buy should be rejected after the daily loss limit")
+	}
+	if _, err := guard.MarketOrder(models.OrderSideSell, 1); err != nil {
+		t.Errorf("sell should still be allowed: %v", err)
+	}
+
+	// 換日後以當下的權益重新計算
+	broker.now = broker.now.Add(24 * time.Hour)
+	if guard.DailyLossExceeded() {
+		t.Error("a new day should reset the daily loss")
+	}
+}
+
+func TestCandleBuilder(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	builder := NewCandleBuilder(time.Minute)
+
+	for i, price := range []float64{100, 103, 98, 101} {
+		if closed := builder.Update(price, start.Add(time.Duration(i*10)*time.Second)); closed != nil {
+			t.Fatalf("unexpected candle closed at tick %d: %+v", i, closed)
+		}
+	}
+
+	closed := builder.Update(105, start.Add(3*time.Minute))
+	if closed == nil {
+		t.Fatal("expected the first minute to close")
+	}
+	if *closed != (Candle{OpenTime: start, Open: 100, High: 103, Low: 98, Close: 101}) {
+		t.Errorf("closed candle = %+v", closed)
+	}
+
+	if closed = builder.Update(104, start.Add(time.Minute)); closed != nil {
+		t.Errorf("a late tick should not close a candle: %+v", closed)
+	}
+}
+
+func TestNewValidatesParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]float64
+		wantErr bool
+	}{
+		{"sma_cross", nil, false},
+		{"sma_cross", map[string]float64{"fast": 30, "slow": 10}, true},
+		{"sma_cross", map[string]float64{"leverage": 20}, true},
+		{"buy_and_hold", map[string]float64{"fraction": 1.5}, true},
+		{"martingale", nil, true},
+	}
+
+	for _, tt := range tests {
+		_, err := New(tt.name, tt.params)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("New(%s, %v) error = %v, wantErr %v", tt.name, tt.params, err, tt.wantErr)
+		}
+	}
+}
@@ -17,7 +17,7 @@ func CORSFilter(ctx *context.Context) {
 		ctx.Output.Header("Access-Control-Allow-Origin", origin)
 		ctx.Output.Header("Access-Control-Allow-Credentials", "true")
 		ctx.Output.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-		ctx.Output.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
+		ctx.Output.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, Idempotency-Key")
 		ctx.Output.Header("Access-Control-Max-Age", "3600")
 
 		// 處理 OPTIONS 預檢請求